@@ -0,0 +1,47 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/bits-and-blooms/bloom/v3"
+)
+
+// ConcurrentFilter 并发安全的布隆过滤器，可作为多个请求 goroutine 共享的去重缓存
+type ConcurrentFilter struct {
+	mu     sync.RWMutex
+	filter *bloom.BloomFilter
+}
+
+// NewConcurrentFilter 包装已有过滤器，之后不应再直接访问 f
+func NewConcurrentFilter(f *bloom.BloomFilter) *ConcurrentFilter {
+	return &ConcurrentFilter{filter: f}
+}
+
+// Add 加入 key
+func (c *ConcurrentFilter) Add(key []byte) {
+	c.mu.Lock()
+	c.filter.Add(key)
+	c.mu.Unlock()
+}
+
+// Test 判断 key 是否可能存在
+func (c *ConcurrentFilter) Test(key []byte) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.filter.Test(key)
+}
+
+// TestAndAdd 原子地判断并加入 key，返回加入前是否已存在。
+// 多个 goroutine 同时对同一个 key 调用时，只有一个会得到 false。
+func (c *ConcurrentFilter) TestAndAdd(key []byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.filter.TestAndAdd(key)
+}
+
+// ApproxCount 估算已加入的元素个数
+func (c *ConcurrentFilter) ApproxCount() uint32 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.filter.ApproximatedSize()
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bits-and-blooms/bloom/v3"
+)
+
+func TestConcurrentFilterTestAndAdd(t *testing.T) {
+	const goroutines, n = 16, 1000
+	c := NewConcurrentFilter(bloom.NewWithEstimates(n, 0.001))
+	ks := keys("k", n)
+
+	// 每个 key 在所有 goroutine 中恰好有一次返回“不存在”
+	var firstSeen [n]atomic.Int32
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i, key := range ks {
+				if !c.TestAndAdd(key) {
+					firstSeen[i].Add(1)
+				}
+				c.Test(key)
+				c.Add(key)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i := range firstSeen {
+		// 布隆过滤器可能误判“已存在”，但不会有两个 goroutine 都看到“不存在”
+		if got := firstSeen[i].Load(); got > 1 {
+			t.Fatalf("key %d reported absent %d times", i, got)
+		}
+	}
+	if got := c.ApproxCount(); got < n*9/10 || got > n*11/10 {
+		t.Fatalf("ApproxCount = %d, want about %d", got, n)
+	}
+}
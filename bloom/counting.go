@@ -0,0 +1,80 @@
+package main
+
+import (
+	"github.com/bits-and-blooms/bloom/v3"
+)
+
+// counterMax 4 位计数器的上限
+const counterMax = 15
+
+// CountingFilter 计数布隆过滤器，每个位置用 4 位计数器代替 1 位，支持删除元素。
+// 计数器达到上限后不再增减（饱和），因此被过度加入的 key 删除后可能仍被判定为存在，
+// 但不会因为溢出回绕导致其他 key 被误删。
+type CountingFilter struct {
+	m        uint
+	k        uint
+	counters []byte // 每个字节存两个计数器
+}
+
+// NewCountingFilter 按预计元素个数 n 和误判率 fp 创建计数过滤器
+func NewCountingFilter(n uint, fp float64) *CountingFilter {
+	m, k := bloom.EstimateParameters(n, fp)
+	return &CountingFilter{m: m, k: k, counters: make([]byte, (m+1)/2)}
+}
+
+func (f *CountingFilter) get(i uint) byte {
+	if i%2 == 0 {
+		return f.counters[i/2] & 0x0f
+	}
+	return f.counters[i/2] >> 4
+}
+
+func (f *CountingFilter) set(i uint, v byte) {
+	if i%2 == 0 {
+		f.counters[i/2] = f.counters[i/2]&0xf0 | v
+	} else {
+		f.counters[i/2] = f.counters[i/2]&0x0f | v<<4
+	}
+}
+
+func (f *CountingFilter) locations(key []byte) []uint {
+	locs := bloom.Locations(key, f.k)
+	out := make([]uint, len(locs))
+	for i, l := range locs {
+		out[i] = uint(l % uint64(f.m))
+	}
+	return out
+}
+
+// Add 加入 key
+func (f *CountingFilter) Add(key []byte) {
+	for _, i := range f.locations(key) {
+		if c := f.get(i); c < counterMax {
+			f.set(i, c+1)
+		}
+	}
+}
+
+// Remove 删除 key，key 不存在时不做任何修改并返回 false。
+// 已饱和的计数器不会减少，因为无法知道真实的计数。
+func (f *CountingFilter) Remove(key []byte) bool {
+	if !f.Test(key) {
+		return false
+	}
+	for _, i := range f.locations(key) {
+		if c := f.get(i); c < counterMax {
+			f.set(i, c-1)
+		}
+	}
+	return true
+}
+
+// Test 判断 key 是否可能存在
+func (f *CountingFilter) Test(key []byte) bool {
+	for _, i := range f.locations(key) {
+		if f.get(i) == 0 {
+			return false
+		}
+	}
+	return true
+}
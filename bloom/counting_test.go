@@ -0,0 +1,71 @@
+package main
+
+import "testing"
+
+func TestCountingFilterAddRemove(t *testing.T) {
+	f := NewCountingFilter(1000, 0.01)
+	ks := keys("k", 1000)
+	for _, key := range ks {
+		f.Add(key)
+	}
+	for _, key := range ks {
+		if !f.Test(key) {
+			t.Fatalf("false negative for %s", key)
+		}
+	}
+
+	// 删除前一半，后一半仍然存在
+	for _, key := range ks[:500] {
+		if !f.Remove(key) {
+			t.Fatalf("Remove(%s) = false", key)
+		}
+	}
+	for _, key := range ks[500:] {
+		if !f.Test(key) {
+			t.Fatalf("removing other keys dropped %s", key)
+		}
+	}
+	removed := 0
+	for _, key := range ks[:500] {
+		if !f.Test(key) {
+			removed++
+		}
+	}
+	if removed < 490 {
+		t.Fatalf("only %d of 500 removed keys are absent", removed)
+	}
+
+	// 全部删除后计数器回到 0
+	for _, key := range ks[500:] {
+		f.Remove(key)
+	}
+	for i := uint(0); i < f.m; i++ {
+		if f.get(i) != 0 {
+			t.Fatalf("counter %d = %d after removing everything", i, f.get(i))
+		}
+	}
+	if f.Remove([]byte("never-added")) {
+		t.Fatal("Remove of absent key returned true")
+	}
+}
+
+func TestCountingFilterSaturation(t *testing.T) {
+	f := NewCountingFilter(100, 0.01)
+	key := []byte("hot")
+	for i := 0; i < counterMax+5; i++ {
+		f.Add(key)
+	}
+	for _, i := range f.locations(key) {
+		if f.get(i) != counterMax {
+			t.Fatalf("counter %d = %d, want saturated at %d", i, f.get(i), counterMax)
+		}
+	}
+
+	// 饱和后计数不再可信，删除同样次数也不会让 key 消失
+	for i := 0; i < counterMax+5; i++ {
+		f.Remove(key)
+	}
+	if !f.Test(key) {
+		t.Fatal("saturated key should remain present")
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+
+	"github.com/bits-and-blooms/bloom/v3"
+)
+
+// BuildFilter 按预计元素个数 n 和误判率 fp 创建过滤器，并加入 keys
+func BuildFilter(n uint, fp float64, keys [][]byte) *bloom.BloomFilter {
+	f := bloom.NewWithEstimates(n, fp)
+	for _, key := range keys {
+		f.Add(key)
+	}
+	return f
+}
+
+// SaveFilter 把过滤器序列化写入 path，预先构建好的过滤器可以随程序分发，启动时直接加载
+func SaveFilter(f *bloom.BloomFilter, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create filter file %s: %w", path, err)
+	}
+	w := bufio.NewWriter(file)
+	if _, err := f.WriteTo(w); err != nil {
+		file.Close()
+		return fmt.Errorf("write filter %s: %w", path, err)
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("write filter %s: %w", path, err)
+	}
+	return file.Close()
+}
+
+// LoadFilter 从 path 读取 SaveFilter 保存的过滤器
+func LoadFilter(path string) (*bloom.BloomFilter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open filter file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	f := &bloom.BloomFilter{}
+	if _, err := f.ReadFrom(bufio.NewReader(file)); err != nil {
+		return nil, fmt.Errorf("read filter %s: %w", path, err)
+	}
+	return f, nil
+}
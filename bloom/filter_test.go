@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func keys(prefix string, n int) [][]byte {
+	out := make([][]byte, n)
+	for i := range out {
+		out[i] = []byte(fmt.Sprintf("%s-%d", prefix, i))
+	}
+	return out
+}
+
+func TestSaveLoadFilterRoundTrip(t *testing.T) {
+	const n, fp = 10000, 0.01
+	f := BuildFilter(n, fp, keys("in", n))
+
+	path := filepath.Join(t.TempDir(), "filter.bin")
+	if err := SaveFilter(f, path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadFilter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Equal(f) {
+		t.Fatal("loaded filter differs from saved filter")
+	}
+
+	for _, key := range keys("in", n) {
+		if !loaded.Test(key) {
+			t.Fatalf("false negative for %s", key)
+		}
+	}
+	falsePositives := 0
+	for _, key := range keys("out", n) {
+		if loaded.Test(key) != f.Test(key) {
+			t.Fatalf("membership mismatch for %s", key)
+		}
+		if loaded.Test(key) {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / n; rate > 2*fp {
+		t.Fatalf("false positive rate %.4f exceeds %.4f", rate, 2*fp)
+	}
+}
+
+func TestLoadFilterMissingFile(t *testing.T) {
+	if _, err := LoadFilter(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Fatal("expected error for missing file")
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/bits-and-blooms/bloom/v3"
+)
+
+// Merge 合并两个分片的过滤器（位集按位或），返回新的过滤器，a 和 b 不会被修改。
+// 只有 m 和 k 都相同的过滤器才能合并。
+func Merge(a, b *bloom.BloomFilter) (*bloom.BloomFilter, error) {
+	if a.Cap() != b.Cap() || a.K() != b.K() {
+		return nil, fmt.Errorf("incompatible bloom filters: m=%d,k=%d vs m=%d,k=%d", a.Cap(), a.K(), b.Cap(), b.K())
+	}
+	merged := a.Copy()
+	if err := merged.Merge(b); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+// EstimateUnionFalsePositiveRate 估算合并后过滤器的误判率。
+// counts 为各分片加入的元素个数，按互不重叠计算，因此是上界。
+func EstimateUnionFalsePositiveRate(m, k uint, counts ...uint) float64 {
+	var n uint
+	for _, c := range counts {
+		n += c
+	}
+	return bloom.EstimateFalsePositiveRate(m, k, n)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/bits-and-blooms/bloom/v3"
+)
+
+func TestMerge(t *testing.T) {
+	const n = 2000
+	m, k := bloom.EstimateParameters(2*n, 0.01)
+	a, b := bloom.New(m, k), bloom.New(m, k)
+	for _, key := range keys("a", n) {
+		a.Add(key)
+	}
+	for _, key := range keys("b", n) {
+		b.Add(key)
+	}
+
+	merged, err := Merge(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range append(keys("a", n), keys("b", n)...) {
+		if !merged.Test(key) {
+			t.Fatalf("merged filter missing %s", key)
+		}
+	}
+	if a.Test([]byte("b-0")) && a.Test([]byte("b-1")) && a.Test([]byte("b-2")) {
+		t.Fatal("Merge modified its input")
+	}
+
+	if rate := EstimateUnionFalsePositiveRate(m, k, n, n); rate > 0.02 {
+		t.Fatalf("estimated union fp rate %.4f, want about 0.01", rate)
+	}
+}
+
+func TestMergeIncompatible(t *testing.T) {
+	if _, err := Merge(bloom.New(1000, 3), bloom.New(2000, 3)); err == nil {
+		t.Fatal("expected error for different m")
+	}
+	if _, err := Merge(bloom.New(1000, 3), bloom.New(1000, 4)); err == nil {
+		t.Fatal("expected error for different k")
+	}
+}
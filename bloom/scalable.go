@@ -0,0 +1,95 @@
+package main
+
+import (
+	"github.com/bits-and-blooms/bloom/v3"
+)
+
+const (
+	scaleGrowth     = 2   // 每一层的容量是上一层的 2 倍
+	scaleTightening = 0.5 // 每一层的误判率是上一层的一半
+)
+
+// scalableLayer 一层固定大小的过滤器
+type scalableLayer struct {
+	filter   *bloom.BloomFilter
+	capacity uint // 按设计误判率能容纳的元素个数
+	count    uint // 已加入的元素个数
+}
+
+// ScalableFilter 可扩容布隆过滤器（Scalable Bloom Filter）。
+// 固定大小的过滤器超过预计元素个数后误判率迅速上升，
+// 这里当前层装满时追加一层容量翻倍、误判率减半的新过滤器，新元素只写入最新一层，Test 查询所有层。
+// 第 i 层误判率为 fp*(1-r)*r^i（r = 0.5），各层之和不超过 fp，所以整体误判率始终不超过 fp。
+type ScalableFilter struct {
+	fp     float64 // 整体误判率上限
+	layers []*scalableLayer
+}
+
+// NewScalableFilter 按首层预计元素个数 n 和整体误判率 fp 创建过滤器
+func NewScalableFilter(n uint, fp float64) *ScalableFilter {
+	s := &ScalableFilter{fp: fp}
+	s.addLayer(n, fp*(1-scaleTightening))
+	return s
+}
+
+func (s *ScalableFilter) addLayer(capacity uint, fp float64) {
+	s.layers = append(s.layers, &scalableLayer{filter: bloom.NewWithEstimates(capacity, fp), capacity: capacity})
+}
+
+// Add 加入 key，当前层已满时先扩容
+func (s *ScalableFilter) Add(key []byte) {
+	last := s.layers[len(s.layers)-1]
+	if last.count >= last.capacity {
+		fp := s.fp * (1 - scaleTightening)
+		for range s.layers {
+			fp *= scaleTightening
+		}
+		s.addLayer(last.capacity*scaleGrowth, fp)
+		last = s.layers[len(s.layers)-1]
+	}
+	last.filter.Add(key)
+	last.count++
+}
+
+// Test 判断 key 是否可能存在，任意一层命中即返回 true
+func (s *ScalableFilter) Test(key []byte) bool {
+	for _, l := range s.layers {
+		if l.filter.Test(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// TestAndAdd 判断 key 是否可能存在，不存在时加入，返回加入前的判断结果。
+// 与 bloom.BloomFilter.TestAndAdd 不同，已存在的 key 不会重复加入，不占用新层容量
+func (s *ScalableFilter) TestAndAdd(key []byte) bool {
+	if s.Test(key) {
+		return true
+	}
+	s.Add(key)
+	return false
+}
+
+// Cap 所有层按设计误判率能容纳的元素总数
+func (s *ScalableFilter) Cap() uint {
+	var total uint
+	for _, l := range s.layers {
+		total += l.capacity
+	}
+	return total
+}
+
+// Count 已加入的元素个数
+func (s *ScalableFilter) Count() uint {
+	var total uint
+	for _, l := range s.layers {
+		total += l.count
+	}
+	return total
+}
+
+// Layers 当前层数
+func (s *ScalableFilter) Layers() int {
+	return len(s.layers)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestScalableFilterGrows(t *testing.T) {
+	const n, fp = 1000, 0.01
+	s := NewScalableFilter(n, fp)
+	for _, key := range keys("in", 5*n) {
+		s.Add(key)
+	}
+	for _, key := range keys("in", 5*n) {
+		if !s.Test(key) {
+			t.Fatalf("false negative for %s", key)
+		}
+	}
+
+	// 1000 + 2000 + 4000 >= 5000，扩容到 3 层
+	if s.Layers() != 3 || s.Cap() != 7*n || s.Count() != 5*n {
+		t.Fatalf("layers = %d, cap = %d, count = %d", s.Layers(), s.Cap(), s.Count())
+	}
+
+	var fps int
+	probes := keys("out", 100000)
+	for _, key := range probes {
+		if s.Test(key) {
+			fps++
+		}
+	}
+	// 整体误判率设计上不超过 fp，留一些随机波动的余量
+	if rate := float64(fps) / float64(len(probes)); rate > fp*1.5 {
+		t.Fatalf("false positive rate %.4f exceeds bound %.4f", rate, fp*1.5)
+	}
+}
+
+func TestScalableFilterTestAndAdd(t *testing.T) {
+	s := NewScalableFilter(10, 0.01)
+	if s.TestAndAdd([]byte("a")) {
+		t.Fatal("first TestAndAdd reported existing")
+	}
+	if !s.TestAndAdd([]byte("a")) {
+		t.Fatal("second TestAndAdd reported missing")
+	}
+	if s.Count() != 1 {
+		t.Fatalf("count = %d, want 1", s.Count())
+	}
+}
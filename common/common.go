@@ -7,9 +7,9 @@ import (
 	"time"
 )
 
-func LogOut() {
-	// 定义 EncoderConfig 配置
-	encoderConfig := zapcore.EncoderConfig{
+// newEncoderConfig 定义 EncoderConfig 配置
+func newEncoderConfig() zapcore.EncoderConfig {
+	return zapcore.EncoderConfig{
 		MessageKey:       "message",                     // 输出消息字段 "message":
 		LevelKey:         "level",                       // 日志级别 "level":
 		TimeKey:          "time",                        // 时间戳 "time":
@@ -25,23 +25,34 @@ func LogOut() {
 		EncodeName:       zapcore.FullNameEncoder,       // 记录器名称全名 默认
 		ConsoleSeparator: "\t",                          // 使用制表符分隔输出 默认\t
 	}
+}
+
+// NewCommonLogger 使用通用 JSON 编码配置创建 logger：
+// 输出调用者信息，Error 及以上级别附带堆栈，fields 作为每条日志的固定字段（可选）。
+func NewCommonLogger(w zapcore.WriteSyncer, level zapcore.Level, fields ...zap.Field) *zap.Logger {
+	return newLogger(w, level).With(fields...)
+}
 
+// newLogger 按通用配置创建 logger，level 可以是固定级别或 zap.AtomicLevel
+func newLogger(w zapcore.WriteSyncer, level zapcore.LevelEnabler) *zap.Logger {
 	// 创建 JSON 编码器
-	encoder := zapcore.NewJSONEncoder(encoderConfig)
+	encoder := zapcore.NewJSONEncoder(newEncoderConfig())
 
 	// 设置输出目标
-	core := zapcore.NewCore(encoder, zapcore.AddSync(os.Stdout), zapcore.DebugLevel)
+	core := zapcore.NewCore(encoder, w, level)
 	// zapcore.AddSync() 将日志写到指定的输出流
 	// zapcore.DebugLevel 设置日志级别, 只有日志级别>= DebugLevel 的日志才会输出; DebugLevel < InfoLevel < WarnLevel < ErrorLevel
 
 	// 创建 Logger 实例
 	// New(core zapcore.Core, options ...Option) *Logger
-	logger := zap.New(core, zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel))
 	// 要加上 AddCaller() 才能显示文件名和行号
 	// AddStacktrace() error时才能显示堆栈信息
+	return zap.New(core, zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel))
+}
 
+func LogOut() {
 	// 追加core配置固定输出字段
-	logger = logger.With(zap.String("extra_key", "extra_value"))
+	logger := NewCommonLogger(zapcore.AddSync(os.Stdout), zapcore.DebugLevel, zap.String("extra_key", "extra_value"))
 
 	// *****无糖输出******
 	// 输出field包含任意类型, 每个字段一个函数, 不包含infof, errorf等
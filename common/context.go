@@ -0,0 +1,30 @@
+package common
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// traceIDKey context 中 trace ID 的键，使用私有类型避免与其他包冲突
+type traceIDKey struct{}
+
+// ContextWithTraceID 返回携带 trace ID 的 context
+func ContextWithTraceID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, id)
+}
+
+// TraceIDFromContext 取出 ctx 中的 trace ID，不存在时返回空字符串
+func TraceIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(traceIDKey{}).(string)
+	return id
+}
+
+// LoggerFromContext 派生带 trace_id 字段的子 logger，ctx 中没有 trace ID 时原样返回 base
+func LoggerFromContext(ctx context.Context, base *zap.Logger) *zap.Logger {
+	id := TraceIDFromContext(ctx)
+	if id == "" {
+		return base
+	}
+	return base.With(zap.String("trace_id", id))
+}
@@ -0,0 +1,30 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestLoggerFromContext(t *testing.T) {
+	var buf bytes.Buffer
+	base := NewCommonLogger(zapcore.AddSync(&buf), zapcore.InfoLevel)
+
+	if got := LoggerFromContext(context.Background(), base); got != base {
+		t.Fatal("logger without trace ID should be returned unchanged")
+	}
+	LoggerFromContext(context.Background(), base).Info("no trace")
+	if strings.Contains(buf.String(), "trace_id") {
+		t.Fatalf("unexpected trace_id: %s", buf.String())
+	}
+
+	buf.Reset()
+	ctx := ContextWithTraceID(context.Background(), "abc123")
+	LoggerFromContext(ctx, base).Info("with trace")
+	if !strings.Contains(buf.String(), `"trace_id":"abc123"`) {
+		t.Fatalf("trace_id missing: %s", buf.String())
+	}
+}
@@ -0,0 +1,17 @@
+package common
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// RunWithGracefulFlush 阻塞到 ctx 结束，然后刷新 logger 并停止 ws（Stop 会写出剩余缓冲），
+// 刷新完成后才返回。用它代替固定时长的 sleep，保证退出时缓冲区里的日志不丢失。
+// 返回后不要再通过 logger 写日志，停止后的 BufferedWriteSyncer 不会再自动刷新。
+func RunWithGracefulFlush(ctx context.Context, logger *zap.Logger, ws *zapcore.BufferedWriteSyncer) error {
+	<-ctx.Done()
+	return errors.Join(logger.Sync(), ws.Stop())
+}
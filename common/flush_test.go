@@ -0,0 +1,57 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// lockedBuffer BufferedWriteSyncer 的后台刷新和测试读取可能并发
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestRunWithGracefulFlush(t *testing.T) {
+	var sink lockedBuffer
+	ws := &zapcore.BufferedWriteSyncer{WS: zapcore.AddSync(&sink), Size: 1 << 20, FlushInterval: time.Hour}
+	logger := NewCommonLogger(ws, zapcore.InfoLevel)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- RunWithGracefulFlush(ctx, logger, ws) }()
+
+	const n = 100
+	for i := 0; i < n; i++ {
+		logger.Info("line", zap.Int("count", i))
+	}
+	if got := strings.Count(sink.String(), "\n"); got != 0 {
+		t.Fatalf("sink has %d lines before cancel, want them buffered", got)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(sink.String(), "\n"); got != n {
+		t.Fatalf("sink has %d lines after flush, want %d", got, n)
+	}
+}
@@ -0,0 +1,105 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestNewLoggerLevelSwitch(t *testing.T) {
+	var buf bytes.Buffer
+	logger, level := NewLogger(LoggerConfig{Level: zapcore.InfoLevel, Output: zapcore.AddSync(&buf)})
+	mux := http.NewServeMux()
+	LevelHandler(mux, level)
+
+	logger.Debug("before")
+	if buf.Len() != 0 {
+		t.Fatalf("debug line emitted at info level: %s", buf.String())
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/loglevel", strings.NewReader(`{"level":"debug"}`))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT /loglevel = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	logger.Debug("after")
+	if !strings.Contains(buf.String(), `"message":"after"`) {
+		t.Fatalf("debug line not emitted after level change: %q", buf.String())
+	}
+}
+
+func TestNewCommonLoggerCallerAndStacktrace(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewCommonLogger(zapcore.AddSync(&buf), zapcore.DebugLevel)
+
+	logger.Error("boom")
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if caller, _ := entry["caller"].(string); !strings.Contains(caller, "logger_test.go") {
+		t.Errorf("caller = %q, want logger_test.go", caller)
+	}
+	if st, _ := entry["stacktrace"].(string); st == "" {
+		t.Error("stacktrace missing on error log")
+	}
+	if _, ok := entry["extra_key"]; ok {
+		t.Error("extra_key should be optional")
+	}
+
+	buf.Reset()
+	logger.With(zap.String("extra_key", "v")).Info("ok")
+	if strings.Contains(buf.String(), "stacktrace") || !strings.Contains(buf.String(), `"extra_key":"v"`) {
+		t.Errorf("unexpected info entry: %s", buf.String())
+	}
+}
+
+func TestNewSampledLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewSampledLogger(SampledLoggerConfig{
+		LoggerConfig: LoggerConfig{Level: zapcore.InfoLevel, Output: zapcore.AddSync(&buf)},
+		First:        10,
+		Thereafter:   100,
+	})
+
+	for i := 0; i < 1000; i++ {
+		logger.Info("storm")
+	}
+	// 同一秒内：前 10 条 + 之后每 100 条一条；跨越秒边界时最多翻倍
+	lines := strings.Count(buf.String(), "\n")
+	if lines == 0 || lines > 2*(10+10) {
+		t.Fatalf("wrote %d lines, want sampled output", lines)
+	}
+}
+
+func TestNewServiceLoggerFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newServiceLogger(zapcore.AddSync(&buf), "order")
+	logger.Info("one")
+	logger.With(zap.String("k", "v")).Warn("two")
+	logger.Named("sub").Error("three")
+
+	host, _ := os.Hostname()
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3", len(lines))
+	}
+	for _, line := range lines {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal(err)
+		}
+		if entry["service"] != "order" || entry["host"] != host || entry["pid"] != float64(os.Getpid()) {
+			t.Errorf("missing service fields: %s", line)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package common
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func countField(i int, fields []zap.Field) []zap.Field {
+	return append(fields, zap.Int("count", i), zap.String("url", "https://example.com"))
+}
+
+func TestLogLoop(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewCommonLogger(zapcore.AddSync(&buf), zapcore.InfoLevel)
+
+	LogLoop(logger, zapcore.InfoLevel, "hot", 3, countField)
+	if got := strings.Count(buf.String(), `"message":"hot"`); got != 3 {
+		t.Fatalf("wrote %d lines, want 3: %s", got, buf.String())
+	}
+	if !strings.Contains(buf.String(), `"count":2`) {
+		t.Errorf("fields not written: %s", buf.String())
+	}
+
+	buf.Reset()
+	allocs := testing.AllocsPerRun(100, func() {
+		LogLoop(logger, zapcore.DebugLevel, "hot", 10, countField)
+	})
+	if buf.Len() != 0 {
+		t.Fatalf("debug lines written at info level: %s", buf.String())
+	}
+	// 只有 fields 切片本身的一次分配
+	if allocs > 1 {
+		t.Errorf("disabled level allocated %v times per loop, want <= 1", allocs)
+	}
+}
+
+// BenchmarkLogLoop 对比 Debug 级别关闭时的开销：
+// naive 每次调用都为可变参数分配切片，check 只在 Check 返回非 nil 时才构造字段
+func BenchmarkLogLoop(b *testing.B) {
+	logger := NewCommonLogger(zapcore.AddSync(io.Discard), zapcore.InfoLevel)
+
+	b.Run("naive", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			logger.Debug("hot", zap.Int("count", i), zap.String("url", "https://example.com"))
+		}
+	})
+	b.Run("check", func(b *testing.B) {
+		b.ReportAllocs()
+		LogLoop(logger, zapcore.DebugLevel, "hot", b.N, countField)
+	})
+}
@@ -0,0 +1,33 @@
+package common
+
+import (
+	"os"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// NewTeeLogger 同时输出到控制台和滚动文件：
+// 控制台使用带颜色的 console 格式便于阅读，文件使用 JSON 格式便于采集，两者级别分别设置。
+func NewTeeLogger(consoleLevel, fileLevel zapcore.Level, file *lumberjack.Logger) *zap.Logger {
+	return newTeeLogger(zapcore.AddSync(os.Stdout), consoleLevel, zapcore.AddSync(file), fileLevel)
+}
+
+func newTeeLogger(console zapcore.WriteSyncer, consoleLevel zapcore.Level, file zapcore.WriteSyncer, fileLevel zapcore.Level) *zap.Logger {
+	consoleConfig := newEncoderConfig()
+	consoleConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder // 级别带颜色输出
+
+	core := zapcore.NewTee(
+		zapcore.NewCore(zapcore.NewConsoleEncoder(consoleConfig), console, consoleLevel),
+		zapcore.NewCore(zapcore.NewJSONEncoder(newEncoderConfig()), file, fileLevel),
+	)
+	return zap.New(core, zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel))
+}
+
+// NewWithErrorSink 在 mainCore 之外再把 Warn 及以上级别的日志以 JSON 格式写到 errWriter（如 errors.log），
+// 供告警单独采集。mainCore 的级别和输出不受影响。
+func NewWithErrorSink(mainCore zapcore.Core, errWriter zapcore.WriteSyncer) zapcore.Core {
+	errCore := zapcore.NewCore(zapcore.NewJSONEncoder(newEncoderConfig()), errWriter, zapcore.WarnLevel)
+	return zapcore.NewTee(mainCore, errCore)
+}
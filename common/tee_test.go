@@ -0,0 +1,67 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+func TestNewTeeLogger(t *testing.T) {
+	var console bytes.Buffer
+	filename := filepath.Join(t.TempDir(), "app.log")
+	file := &lumberjack.Logger{Filename: filename}
+	defer file.Close()
+
+	logger := newTeeLogger(zapcore.AddSync(&console), zapcore.DebugLevel, zapcore.AddSync(file), zapcore.InfoLevel)
+	logger.Debug("console only")
+	logger.Info("both")
+
+	// 控制台：console 格式 + ANSI 颜色
+	out := console.String()
+	if !strings.Contains(out, "\x1b[") || !strings.Contains(out, "console only") || !strings.Contains(out, "both") {
+		t.Fatalf("unexpected console output: %q", out)
+	}
+	if strings.HasPrefix(out, "{") {
+		t.Fatalf("console output should not be JSON: %q", out)
+	}
+
+	// 文件：JSON 格式，低于 Info 的日志不写入
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("file has %d lines, want 1: %s", len(lines), data)
+	}
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("file line is not JSON: %v", err)
+	}
+	if entry["message"] != "both" || entry["level"] != "INFO" {
+		t.Fatalf("unexpected file entry: %v", entry)
+	}
+}
+
+func TestNewWithErrorSink(t *testing.T) {
+	var main, errs bytes.Buffer
+	mainCore := zapcore.NewCore(zapcore.NewJSONEncoder(newEncoderConfig()), zapcore.AddSync(&main), zapcore.DebugLevel)
+	logger := zap.New(NewWithErrorSink(mainCore, zapcore.AddSync(&errs)))
+
+	logger.Info("routine")
+	logger.Error("broken")
+
+	if !strings.Contains(main.String(), "routine") || !strings.Contains(main.String(), "broken") {
+		t.Errorf("main sink = %q, want both lines", main.String())
+	}
+	if strings.Contains(errs.String(), "routine") || !strings.Contains(errs.String(), "broken") {
+		t.Errorf("error sink = %q, want only the error line", errs.String())
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type delayItem[T any] struct {
+	value   T
+	readyAt time.Time
+}
+
+// DelayQueue 延迟队列，按到期时间排序，元素到期后才能取出。
+// 可用于定时扫描过期的 TCC 事务等场景，并发安全。
+type DelayQueue[T any] struct {
+	mu     sync.Mutex
+	queue  *PriorityQueue[delayItem[T]]
+	wakeup chan struct{} // 有新元素入队时通知阻塞中的 Take
+	now    func() time.Time
+}
+
+// NewDelayQueue 创建延迟队列
+func NewDelayQueue[T any]() *DelayQueue[T] {
+	return &DelayQueue[T]{
+		queue: NewPriorityQueue(func(a, b delayItem[T]) int {
+			return a.readyAt.Compare(b.readyAt)
+		}),
+		wakeup: make(chan struct{}, 1),
+		now:    time.Now,
+	}
+}
+
+// Add 加入元素，readyAt 之后才能被取出
+func (q *DelayQueue[T]) Add(v T, readyAt time.Time) {
+	q.mu.Lock()
+	q.queue.Enqueue(delayItem[T]{value: v, readyAt: readyAt})
+	q.mu.Unlock()
+
+	select {
+	case q.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// Len 队列中的元素个数（包括未到期的）
+func (q *DelayQueue[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.queue.Len()
+}
+
+// poll 取出已到期的队首元素；未到期时返回距离到期的时间，队列为空时 wait 为负数
+func (q *DelayQueue[T]) poll() (v T, ok bool, wait time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	item, ok := q.queue.Peek()
+	if !ok {
+		return v, false, -1
+	}
+	if d := item.readyAt.Sub(q.now()); d > 0 {
+		return v, false, d
+	}
+	q.queue.Dequeue()
+	return item.value, true, 0
+}
+
+// Poll 非阻塞地取出已到期的元素，没有到期元素时 ok 为 false
+func (q *DelayQueue[T]) Poll() (v T, ok bool) {
+	v, ok, _ = q.poll()
+	return v, ok
+}
+
+// Take 阻塞直到有元素到期并取出，ctx 取消时返回 ctx.Err()
+func (q *DelayQueue[T]) Take(ctx context.Context) (T, error) {
+	for {
+		v, ok, wait := q.poll()
+		if ok {
+			return v, nil
+		}
+
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			timeout = timer.C
+		}
+		select {
+		case <-ctx.Done():
+		case <-q.wakeup: // 新元素可能比当前队首更早到期，重新计算
+		case <-timeout:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err := ctx.Err(); err != nil {
+			var zero T
+			return zero, err
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDelayQueuePollRespectsDelay(t *testing.T) {
+	now := time.Unix(1000, 0)
+	q := NewDelayQueue[string]()
+	q.now = func() time.Time { return now }
+
+	q.Add("c", now.Add(3*time.Second))
+	q.Add("a", now.Add(1*time.Second))
+	q.Add("b", now.Add(2*time.Second))
+
+	if _, ok := q.Poll(); ok {
+		t.Fatal("Poll returned an item before it was ready")
+	}
+
+	now = now.Add(2 * time.Second)
+	for _, want := range []string{"a", "b"} {
+		if got, ok := q.Poll(); !ok || got != want {
+			t.Fatalf("Poll = %q, %v, want %q", got, ok, want)
+		}
+	}
+	if _, ok := q.Poll(); ok {
+		t.Fatal("c should not be ready yet")
+	}
+
+	now = now.Add(time.Second)
+	if got, ok := q.Poll(); !ok || got != "c" {
+		t.Fatalf("Poll = %q, %v, want c", got, ok)
+	}
+	if q.Len() != 0 {
+		t.Fatalf("Len = %d", q.Len())
+	}
+}
+
+func TestDelayQueueTake(t *testing.T) {
+	q := NewDelayQueue[int]()
+	start := time.Now()
+	q.Add(2, start.Add(60*time.Millisecond))
+
+	// 阻塞期间加入更早到期的元素，Take 应当先返回它
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		q.Add(1, start.Add(30*time.Millisecond))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for _, want := range []int{1, 2} {
+		got, err := q.Take(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("Take = %d, want %d", got, want)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Fatalf("Take returned after %v, before the item was ready", elapsed)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := q.Take(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Take on empty queue = %v, want deadline exceeded", err)
+	}
+}
@@ -37,4 +37,13 @@ func main() {
 
 	// 获取队列长度
 	fmt.Println("Size:", queue.Size()) // 输出: Size: 2
+
+	fmt.Println("----------------")
+
+	// 泛型包装，出队直接得到 int，无需类型断言
+	typed := NewPriorityQueue(func(a, b int) int { return a - b })
+	typed.Enqueue(3)
+	typed.Enqueue(1)
+	n, _ := typed.Dequeue()
+	fmt.Println("Typed Dequeue:", n) // 输出: Typed Dequeue: 1
 }
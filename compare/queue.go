@@ -0,0 +1,48 @@
+package main
+
+import (
+	"github.com/emirpasic/gods/queues/priorityqueue"
+)
+
+// PriorityQueue 类型安全的优先级队列，包装 gods 的 priorityqueue，
+// cmp(a, b) < 0 表示 a 优先出队
+type PriorityQueue[T any] struct {
+	queue *priorityqueue.Queue
+}
+
+// NewPriorityQueue 使用比较函数 cmp 创建优先级队列
+func NewPriorityQueue[T any](cmp func(a, b T) int) *PriorityQueue[T] {
+	return &PriorityQueue[T]{
+		queue: priorityqueue.NewWith(func(a, b interface{}) int {
+			return cmp(a.(T), b.(T))
+		}),
+	}
+}
+
+// Enqueue 入队
+func (q *PriorityQueue[T]) Enqueue(v T) {
+	q.queue.Enqueue(v)
+}
+
+// Dequeue 取出优先级最高的元素，队列为空时 ok 为 false
+func (q *PriorityQueue[T]) Dequeue() (v T, ok bool) {
+	value, ok := q.queue.Dequeue()
+	if !ok {
+		return v, false
+	}
+	return value.(T), true
+}
+
+// Peek 查看优先级最高的元素但不出队，队列为空时 ok 为 false
+func (q *PriorityQueue[T]) Peek() (v T, ok bool) {
+	value, ok := q.queue.Peek()
+	if !ok {
+		return v, false
+	}
+	return value.(T), true
+}
+
+// Len 队列长度
+func (q *PriorityQueue[T]) Len() int {
+	return q.queue.Size()
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"cmp"
+	"testing"
+)
+
+func TestPriorityQueueInt(t *testing.T) {
+	q := NewPriorityQueue(cmp.Compare[int])
+	if _, ok := q.Dequeue(); ok {
+		t.Fatal("Dequeue on empty queue returned ok")
+	}
+	for _, v := range []int{3, 1, 2, 10, 9} {
+		q.Enqueue(v)
+	}
+	if v, _ := q.Peek(); v != 1 || q.Len() != 5 {
+		t.Fatalf("Peek = %d, Len = %d", v, q.Len())
+	}
+	for _, want := range []int{1, 2, 3, 9, 10} {
+		if got, ok := q.Dequeue(); !ok || got != want {
+			t.Fatalf("Dequeue = %d, %v, want %d", got, ok, want)
+		}
+	}
+	if q.Len() != 0 {
+		t.Fatalf("Len = %d after draining", q.Len())
+	}
+}
+
+func TestPriorityQueueStruct(t *testing.T) {
+	type task struct {
+		name     string
+		priority int
+	}
+	// 优先级数值大的先出队
+	q := NewPriorityQueue(func(a, b task) int { return cmp.Compare(b.priority, a.priority) })
+	q.Enqueue(task{"low", 1})
+	q.Enqueue(task{"high", 9})
+	q.Enqueue(task{"mid", 5})
+
+	for _, want := range []string{"high", "mid", "low"} {
+		if got, _ := q.Dequeue(); got.name != want {
+			t.Fatalf("Dequeue = %s, want %s", got.name, want)
+		}
+	}
+	if _, ok := q.Peek(); ok {
+		t.Fatal("Peek on empty queue returned ok")
+	}
+}
@@ -0,0 +1,58 @@
+package common
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// responseRecorder 记录响应状态码和写出的字节数用于访问日志
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *responseRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(p)
+	r.bytes += n
+	return n, err
+}
+
+// Flush 透传给底层 ResponseWriter，不影响流式响应
+func (r *responseRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// AccessLog 中间件：请求结束后记录 method、path、status、耗时和响应字节数，
+// context 中有请求 ID 时一并记录。挂在 Gzip 之前时字节数为压缩后实际写出的长度
+func AccessLog(logger *zap.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			fields := []zap.Field{
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.Int("status", rec.status),
+				zap.Duration("elapsed", time.Since(start)),
+				zap.Int("bytes", rec.bytes),
+			}
+			if id := RequestIDFromContext(r.Context()); id != "" {
+				fields = append(fields, zap.String("request_id", id))
+			}
+			logger.Info("request", fields...)
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestAccessLog(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	r := mux.NewRouter()
+	r.Use(RequestID(), AccessLog(zap.New(core)))
+	r.HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})
+	r.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/hello", nil)
+	req.Header.Set(RequestIDHeader, "abc")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/missing", nil))
+
+	entries := logs.All()
+	if len(entries) != 2 {
+		t.Fatalf("got %d log entries, want 2", len(entries))
+	}
+	first, second := entries[0].ContextMap(), entries[1].ContextMap()
+	if first["status"] != int64(200) || first["bytes"] != int64(5) || first["request_id"] != "abc" ||
+		first["method"] != "GET" || first["path"] != "/hello" {
+		t.Fatalf("unexpected first entry: %v", first)
+	}
+	if second["status"] != int64(404) || second["method"] != "POST" || second["path"] != "/missing" {
+		t.Fatalf("unexpected second entry: %v", second)
+	}
+	if _, ok := first["elapsed"]; !ok {
+		t.Fatalf("entry has no elapsed field: %v", first)
+	}
+}
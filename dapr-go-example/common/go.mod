@@ -0,0 +1,10 @@
+module dapr-common
+
+go 1.23.4
+
+require (
+	github.com/gorilla/mux v1.8.1
+	go.uber.org/zap v1.27.0
+)
+
+require go.uber.org/multierr v1.10.0 // indirect
@@ -0,0 +1,63 @@
+package common
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestGzipMiddleware(t *testing.T) {
+	large := strings.Repeat("subscriber event payload\n", 200)
+	r := mux.NewRouter()
+	r.Use(Gzip)
+	r.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, large) })
+	r.HandleFunc("/tiny", func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "hi") })
+	r.HandleFunc("/png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		io.WriteString(w, large)
+	})
+
+	tests := []struct {
+		path, accept string
+		wantGzip     bool
+		want         string
+	}{
+		{"/large", "gzip, deflate", true, large},
+		{"/large", "", false, large},
+		{"/large", "gzip;q=0", false, large},
+		{"/tiny", "gzip", false, "hi"},
+		{"/png", "gzip", false, large},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept-Encoding", tt.accept)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		body := io.Reader(rec.Body)
+		if gotGzip := rec.Header().Get("Content-Encoding") == "gzip"; gotGzip != tt.wantGzip {
+			t.Fatalf("%s (Accept-Encoding %q): gzip = %v, want %v", tt.path, tt.accept, gotGzip, tt.wantGzip)
+		}
+		if tt.wantGzip {
+			zr, err := gzip.NewReader(rec.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body = zr
+			if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+				t.Fatalf("%s: Content-Type = %q, want sniffed from plaintext", tt.path, ct)
+			}
+		}
+		got, err := io.ReadAll(body)
+		if err != nil || string(got) != tt.want {
+			t.Fatalf("%s (Accept-Encoding %q): body mismatch (%d bytes, err %v)", tt.path, tt.accept, len(got), err)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+// Package common 三个 dapr 示例服务共用的 HTTP 组件
+package common
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gorilla/mux"
+)
+
+// RegisterHealth 注册探针接口：
+//
+//	/healthz 存活探针，进程能处理请求即返回 200
+//	/readyz  就绪探针，ready 为 false（启动未完成）时返回 503
+func RegisterHealth(r *mux.Router, ready *atomic.Bool) {
+	r.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}).Methods(http.MethodGet)
+
+	r.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if !ready.Load() {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ready"))
+	}).Methods(http.MethodGet)
+}
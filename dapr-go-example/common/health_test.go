@@ -0,0 +1,34 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestRegisterHealth(t *testing.T) {
+	var ready atomic.Bool
+	r := mux.NewRouter()
+	RegisterHealth(r, &ready)
+
+	get := func(path string) int {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code
+	}
+
+	if code := get("/healthz"); code != http.StatusOK {
+		t.Fatalf("/healthz = %d before ready", code)
+	}
+	if code := get("/readyz"); code != http.StatusServiceUnavailable {
+		t.Fatalf("/readyz = %d before ready, want 503", code)
+	}
+
+	ready.Store(true)
+	if code := get("/readyz"); code != http.StatusOK {
+		t.Fatalf("/readyz = %d after ready, want 200", code)
+	}
+}
@@ -0,0 +1,35 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	r := mux.NewRouter()
+	r.Use(RequestID())
+	var seen string
+	r.HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestIDFromContext(r.Context())
+	})
+
+	// 没有请求头时生成新 ID
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/hello", nil))
+	generated := rec.Header().Get(RequestIDHeader)
+	if generated == "" || seen != generated {
+		t.Fatalf("generated id %q, handler saw %q", generated, seen)
+	}
+
+	// 已有请求头时沿用
+	req := httptest.NewRequest(http.MethodGet, "/hello", nil)
+	req.Header.Set(RequestIDHeader, "abc")
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if seen != "abc" || rec.Header().Get(RequestIDHeader) != "abc" {
+		t.Fatalf("incoming id not propagated: saw %q, echoed %q", seen, rec.Header().Get(RequestIDHeader))
+	}
+}
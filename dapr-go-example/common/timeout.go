@@ -0,0 +1,17 @@
+package common
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Timeout 中间件：请求处理超过 d 时返回 503，并取消请求的 context，
+// handler 中用 r.Context() 发起的下游调用随之中止。
+// 基于 http.TimeoutHandler，响应在 handler 返回前会先缓冲，不适合流式接口。
+func Timeout(d time.Duration) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.TimeoutHandler(next, d, "request timeout")
+	}
+}
@@ -3,20 +3,51 @@ package main
 import (
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"sync/atomic"
+	"time"
 
+	"dapr-common"
 	"github.com/gorilla/mux"
+	"go.uber.org/zap"
 )
 
+// requestTimeout 服务端处理单个请求的最长时间
+const requestTimeout = 5 * time.Second
+
 func main() {
+	logger, err := zap.NewProduction()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer logger.Sync()
+
 	r := mux.NewRouter()
+	// 读取或生成 X-Request-ID
+	r.Use(common.RequestID())
+	// 访问日志：method、path、status、耗时、字节数
+	r.Use(common.AccessLog(logger))
+	// 客户端支持时 gzip 压缩响应
+	r.Use(common.Gzip)
+	// 单个请求最长处理时间，超时返回 503 并取消请求 context
+	r.Use(common.Timeout(requestTimeout))
 
 	// 定义一个 HTTP 端点
 	r.HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintln(w, "Hello from Service A-01, port 8001!")
 	})
 
+	// 健康检查，监听成功后标记为就绪
+	var ready atomic.Bool
+	common.RegisterHealth(r, &ready)
+
 	// 启动 HTTP 服务器
+	ln, err := net.Listen("tcp", ":8001")
+	if err != nil {
+		log.Fatal(err)
+	}
+	ready.Store(true)
 	log.Println("Service A is running on :8001...")
-	log.Fatal(http.ListenAndServe(":8001", r))
+	log.Fatal(http.Serve(ln, r))
 }
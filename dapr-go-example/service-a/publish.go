@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Delivery 单个订阅者的投递结果
+type Delivery struct {
+	Subscriber string `json:"subscriber"`
+	Delivered  bool   `json:"delivered"`
+	Attempts   int    `json:"attempts"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Publisher 把 POST /publish 收到的 JSON 事件并发转发给所有订阅者。
+// 投递失败（连接错误或非 2xx）最多尝试 MaxAttempts 次，结果逐个返回给调用方。
+type Publisher struct {
+	Subscribers []string
+	MaxAttempts int
+	Backoff     time.Duration
+	Client      *http.Client
+	// Deadline 一次发布（含全部重试）的总时长上限，<=0 表示不限制。
+	// 须小于路由的超时时间，否则响应被超时中间件替换为 503，各订阅者的投递结果丢失
+	Deadline time.Duration
+	// MaxEventBytes 事件body的最大字节数，超过返回 413
+	MaxEventBytes int64
+}
+
+// NewPublisher 创建发布器，默认每个订阅者最多尝试 3 次，总时长不超过 4s（低于 requestTimeout）
+func NewPublisher(subscribers []string) *Publisher {
+	return &Publisher{
+		Subscribers:   subscribers,
+		MaxAttempts:   3,
+		Backoff:       100 * time.Millisecond,
+		Client:        &http.Client{Timeout: 3 * time.Second},
+		Deadline:      4 * time.Second,
+		MaxEventBytes: 1 << 20,
+	}
+}
+
+// ServeHTTP 全部投递成功返回 200，有订阅者失败返回 207，响应体为各订阅者的投递结果
+func (p *Publisher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if p.MaxEventBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, p.MaxEventBytes)
+	}
+	event, err := io.ReadAll(r.Body)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, fmt.Sprintf("event larger than %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("read event: %v", err), http.StatusBadRequest)
+		return
+	}
+	if !json.Valid(event) {
+		http.Error(w, "event must be valid JSON", http.StatusBadRequest)
+		return
+	}
+
+	results := p.Publish(r.Context(), event)
+	status := http.StatusOK
+	for _, d := range results {
+		if !d.Delivered {
+			status = http.StatusMultiStatus
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string][]Delivery{"results": results})
+}
+
+// Publish 并发投递 event，返回结果顺序与 Subscribers 一致；超过 Deadline 时未成功的投递以超时结束
+func (p *Publisher) Publish(ctx context.Context, event []byte) []Delivery {
+	if p.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Deadline)
+		defer cancel()
+	}
+	results := make([]Delivery, len(p.Subscribers))
+	var wg sync.WaitGroup
+	for i, sub := range p.Subscribers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = p.deliver(ctx, sub, event)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+func (p *Publisher) deliver(ctx context.Context, url string, event []byte) Delivery {
+	d := Delivery{Subscriber: url}
+	backoff := p.Backoff
+	for d.Attempts < p.MaxAttempts {
+		if d.Attempts > 0 {
+			select {
+			case <-ctx.Done():
+				d.Error = ctx.Err().Error()
+				return d
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+		d.Attempts++
+
+		err := p.post(ctx, url, event)
+		if err == nil {
+			d.Delivered = true
+			d.Error = ""
+			return d
+		}
+		d.Error = err.Error()
+	}
+	return d
+}
+
+func (p *Publisher) post(ctx context.Context, url string, event []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(event))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPublishFanOut(t *testing.T) {
+	received := make(chan string, 2)
+	subscriber := func() *httptest.Server {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			received <- string(body)
+		}))
+		t.Cleanup(srv.Close)
+		return srv
+	}
+	a, b := subscriber(), subscriber()
+
+	var failingCalls atomic.Int32
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failingCalls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	p := NewPublisher([]string{a.URL, failing.URL, b.URL})
+	p.Backoff = time.Millisecond
+
+	event := `{"type":"order.created","id":1}`
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/publish", strings.NewReader(event)))
+
+	if rec.Code != http.StatusMultiStatus {
+		t.Fatalf("status = %d, want 207", rec.Code)
+	}
+	for i := 0; i < 2; i++ {
+		if got := <-received; got != event {
+			t.Fatalf("subscriber received %q", got)
+		}
+	}
+
+	var resp struct{ Results []Delivery }
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Results) != 3 || !resp.Results[0].Delivered || !resp.Results[2].Delivered {
+		t.Fatalf("unexpected results: %+v", resp.Results)
+	}
+	if f := resp.Results[1]; f.Delivered || f.Attempts != 3 || f.Error == "" {
+		t.Fatalf("failing subscriber result = %+v", f)
+	}
+	if failingCalls.Load() != 3 {
+		t.Fatalf("failing subscriber called %d times, want 3", failingCalls.Load())
+	}
+}
+
+func TestPublishRejectsInvalidJSON(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewPublisher(nil).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/publish", strings.NewReader("not json")))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", rec.Code)
+	}
+}
+
+func TestPublishDeadlineBoundsRetries(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release // 订阅者在测试结束前一直不响应
+	}))
+	defer slow.Close()
+	defer close(release)
+
+	p := NewPublisher([]string{slow.URL})
+	p.Deadline = 100 * time.Millisecond
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/publish", strings.NewReader(`{}`)))
+
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("publish took %v, want it bounded by Deadline", d)
+	}
+	if rec.Code != http.StatusMultiStatus {
+		t.Fatalf("status = %d, want 207 with per-subscriber results", rec.Code)
+	}
+}
+
+func TestPublishRejectsOversizedEvent(t *testing.T) {
+	p := NewPublisher(nil)
+	p.MaxEventBytes = 8
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/publish", strings.NewReader(`{"id":"0123456789"}`)))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want 413", rec.Code)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrNoHealthyBackend 所有后端都处于不健康冷却期
+var ErrNoHealthyBackend = errors.New("no healthy backend")
+
+type backend struct {
+	url            string
+	unhealthyUntil time.Time
+}
+
+// Balancer 客户端轮询负载均衡，根据请求结果跟踪后端健康状态：
+// 请求失败的后端在 cooldown 时间内被跳过，成功后立即恢复。
+type Balancer struct {
+	mu       sync.Mutex
+	backends []*backend
+	next     int
+	cooldown time.Duration
+	now      func() time.Time
+}
+
+// NewBalancer 创建负载均衡器，urls 为各实例的 base URL
+func NewBalancer(urls []string, cooldown time.Duration) *Balancer {
+	b := &Balancer{cooldown: cooldown, now: time.Now}
+	for _, u := range urls {
+		b.backends = append(b.backends, &backend{url: u})
+	}
+	return b
+}
+
+// Next 按轮询顺序返回下一个健康的后端
+func (b *Balancer) Next() (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	for i := 0; i < len(b.backends); i++ {
+		be := b.backends[b.next]
+		b.next = (b.next + 1) % len(b.backends)
+		if !now.Before(be.unhealthyUntil) {
+			return be.url, nil
+		}
+	}
+	return "", ErrNoHealthyBackend
+}
+
+// Report 记录一次请求结果，失败的后端进入冷却期
+func (b *Balancer) Report(url string, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, be := range b.backends {
+		if be.url != url {
+			continue
+		}
+		if ok {
+			be.unhealthyUntil = time.Time{}
+		} else {
+			be.unhealthyUntil = b.now().Add(b.cooldown)
+		}
+	}
+}
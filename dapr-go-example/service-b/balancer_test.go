@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func namedServer(t *testing.T, name string, status *int) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status != nil && *status != http.StatusOK {
+			w.WriteHeader(*status)
+			return
+		}
+		w.Write([]byte(name))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func call(r http.Handler) (int, string) {
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/call-service-a", nil))
+	return rec.Code, rec.Body.String()
+}
+
+func TestBalancerAlternates(t *testing.T) {
+	a, b := namedServer(t, "A", nil), namedServer(t, "B", nil)
+	r := newRouter(newServiceClient(testConfig()), NewBalancer([]string{a.URL, b.URL}, time.Minute))
+
+	want := []string{"A", "B", "A", "B"}
+	for i, name := range want {
+		if code, body := call(r); code != http.StatusOK || body != "Response from Service A: "+name {
+			t.Fatalf("call %d: %d %q, want %s", i, code, body, name)
+		}
+	}
+}
+
+func TestBalancerSkipsFailingBackend(t *testing.T) {
+	status := http.StatusInternalServerError
+	a, b := namedServer(t, "A", &status), namedServer(t, "B", nil)
+	cfg := testConfig()
+	cfg.MaxRetries = 0
+	cfg.FailureThreshold = 100
+	balancer := NewBalancer([]string{a.URL, b.URL}, time.Minute)
+	now := time.Now()
+	balancer.now = func() time.Time { return now }
+	r := newRouter(newServiceClient(cfg), balancer)
+
+	if code, _ := call(r); code != http.StatusInternalServerError {
+		t.Fatalf("first call to failing A = %d, want 500", code)
+	}
+	// A 处于冷却期，后续请求全部落到 B
+	for i := 0; i < 3; i++ {
+		if code, body := call(r); code != http.StatusOK || body != "Response from Service A: B" {
+			t.Fatalf("call %d: %d %q, want B", i, code, body)
+		}
+	}
+
+	// 冷却结束且 A 恢复后重新参与轮询
+	status = http.StatusOK
+	now = now.Add(time.Minute)
+	seen := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		_, body := call(r)
+		seen[body] = true
+	}
+	if !seen["Response from Service A: A"] {
+		t.Fatalf("A not used after recovery: %v", seen)
+	}
+}
+
+func TestBalancerNoHealthyBackend(t *testing.T) {
+	b := NewBalancer([]string{"http://a", "http://b"}, time.Minute)
+	b.Report("http://a", false)
+	b.Report("http://b", false)
+	if _, err := b.Next(); err != ErrNoHealthyBackend {
+		t.Fatalf("Next = %v, want ErrNoHealthyBackend", err)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"dapr-common"
+)
+
+// ErrCircuitOpen 熔断器打开期间直接拒绝请求
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// ClientConfig 调用下游服务的超时、重试与熔断配置
+type ClientConfig struct {
+	Timeout          time.Duration // 单次请求超时
+	MaxRetries       int           // 5xx 或连接错误时的最大重试次数
+	Backoff          time.Duration // 首次重试等待时间，之后每次翻倍
+	FailureThreshold int           // 连续失败多少次后熔断
+	OpenTimeout      time.Duration // 熔断持续时间，之后放行一个探测请求
+}
+
+var defaultClientConfig = ClientConfig{
+	Timeout:          3 * time.Second,
+	MaxRetries:       2,
+	Backoff:          100 * time.Millisecond,
+	FailureThreshold: 5,
+	OpenTimeout:      10 * time.Second,
+}
+
+type breakerState int
+
+const (
+	stateClosed   breakerState = iota // 正常放行
+	stateOpen                         // 熔断，直接拒绝
+	stateHalfOpen                     // 放行一个探测请求
+)
+
+// circuitBreaker 连续失败达到阈值后打开，OpenTimeout 后半开探测，探测成功则关闭
+type circuitBreaker struct {
+	mu        sync.Mutex
+	state     breakerState
+	failures  int
+	openedAt  time.Time
+	threshold int
+	timeout   time.Duration
+	now       func() time.Time
+}
+
+func newCircuitBreaker(threshold int, timeout time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, timeout: timeout, now: time.Now}
+}
+
+// allow 判断是否放行本次请求
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case stateOpen:
+		if b.now().Sub(b.openedAt) < b.timeout {
+			return false
+		}
+		b.state = stateHalfOpen
+		return true
+	case stateHalfOpen:
+		return false // 探测请求尚未返回
+	default:
+		return true
+	}
+}
+
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = stateClosed
+	b.failures = 0
+}
+
+func (b *circuitBreaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.state == stateHalfOpen || b.failures >= b.threshold {
+		b.state = stateOpen
+		b.openedAt = b.now()
+	}
+}
+
+// serviceClient 带超时、重试和熔断的 HTTP 客户端，每个后端（scheme+host）一个熔断器，
+// 一个实例故障不会拒绝发往其他实例的请求
+type serviceClient struct {
+	cfg      ClientConfig
+	http     *http.Client
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+func newServiceClient(cfg ClientConfig) *serviceClient {
+	return &serviceClient{
+		cfg:      cfg,
+		http:     &http.Client{Timeout: cfg.Timeout},
+		breakers: make(map[string]*circuitBreaker),
+	}
+}
+
+// breaker 返回 rawURL 所属后端的熔断器，不存在时创建
+func (c *serviceClient) breaker(rawURL string) *circuitBreaker {
+	key := rawURL
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		key = u.Scheme + "://" + u.Host
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, ok := c.breakers[key]
+	if !ok {
+		b = newCircuitBreaker(c.cfg.FailureThreshold, c.cfg.OpenTimeout)
+		c.breakers[key] = b
+	}
+	return b
+}
+
+// Get 请求 url 并返回响应体。5xx 和连接错误按指数退避重试，重试耗尽计一次熔断失败；
+// 4xx 说明下游可用，不重试也不计入失败。
+func (c *serviceClient) Get(ctx context.Context, url string) ([]byte, error) {
+	breaker := c.breaker(url)
+	if !breaker.allow() {
+		return nil, ErrCircuitOpen
+	}
+
+	backoff := c.cfg.Backoff
+	var lastErr error
+	for attempt := 0; attempt <= c.cfg.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				breaker.failure()
+				return nil, ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		body, status, err := c.get(ctx, url)
+		switch {
+		case err != nil:
+			lastErr = err
+		case status >= 500:
+			lastErr = fmt.Errorf("GET %s: status %d", url, status)
+		case status >= 400:
+			breaker.success()
+			return nil, fmt.Errorf("GET %s: status %d", url, status)
+		default:
+			breaker.success()
+			return body, nil
+		}
+	}
+	breaker.failure()
+	return nil, fmt.Errorf("GET %s failed after %d attempts: %v", url, c.cfg.MaxRetries+1, lastErr)
+}
+
+func (c *serviceClient) get(ctx context.Context, url string) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	common.SetRequestID(req)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	return body, resp.StatusCode, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// failingServer 前 failures 次请求返回 500，之后返回 200
+func failingServer(t *testing.T, failures int32) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= failures {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("hello"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func testConfig() ClientConfig {
+	return ClientConfig{
+		Timeout:          time.Second,
+		MaxRetries:       2,
+		Backoff:          time.Millisecond,
+		FailureThreshold: 2,
+		OpenTimeout:      time.Minute,
+	}
+}
+
+func TestServiceClientRetries(t *testing.T) {
+	srv, calls := failingServer(t, 2)
+	c := newServiceClient(testConfig())
+
+	body, err := c.Get(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "hello" || calls.Load() != 3 {
+		t.Fatalf("body = %q after %d calls, want hello after 3", body, calls.Load())
+	}
+}
+
+func TestServiceClientBreaker(t *testing.T) {
+	srv, calls := failingServer(t, 6) // 两次 Get 各重试 3 次，全部失败
+	c := newServiceClient(testConfig())
+	now := time.Now()
+	c.breaker(srv.URL).now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Get(context.Background(), srv.URL); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("call %d: err = %v, want upstream failure", i, err)
+		}
+	}
+	// 熔断打开，请求不再到达下游
+	if _, err := c.Get(context.Background(), srv.URL); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("err = %v, want ErrCircuitOpen", err)
+	}
+	if calls.Load() != 6 {
+		t.Fatalf("upstream called %d times, want 6", calls.Load())
+	}
+
+	// 熔断超时后放行探测请求，下游已恢复，熔断关闭
+	now = now.Add(time.Minute)
+	if _, err := c.Get(context.Background(), srv.URL); err != nil {
+		t.Fatalf("probe failed: %v", err)
+	}
+	if st := c.breaker(srv.URL).state; st != stateClosed {
+		t.Fatalf("breaker state = %v, want closed", st)
+	}
+}
+
+func TestServiceClientBreakerPerBackend(t *testing.T) {
+	bad, _ := failingServer(t, 100)
+	good, calls := failingServer(t, 0)
+	cfg := testConfig()
+	cfg.MaxRetries = 0
+	cfg.FailureThreshold = 1
+	c := newServiceClient(cfg)
+
+	if _, err := c.Get(context.Background(), bad.URL+"/hello"); err == nil {
+		t.Fatal("want upstream failure")
+	}
+	if _, err := c.Get(context.Background(), bad.URL+"/hello"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("err = %v, want ErrCircuitOpen", err)
+	}
+	// 另一个实例的熔断器仍然关闭
+	if _, err := c.Get(context.Background(), good.URL+"/hello"); err != nil || calls.Load() != 1 {
+		t.Fatalf("healthy backend: err = %v after %d calls", err, calls.Load())
+	}
+}
+
+func TestCallServiceAReturns503WhenOpen(t *testing.T) {
+	srv, _ := failingServer(t, 100)
+	cfg := testConfig()
+	cfg.MaxRetries = 0
+	cfg.FailureThreshold = 1
+	r := newRouter(newServiceClient(cfg), NewBalancer([]string{srv.URL}, 0))
+
+	codes := make([]int, 2)
+	for i := range codes {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/call-service-a", nil))
+		codes[i] = rec.Code
+	}
+	if codes[0] != http.StatusInternalServerError || codes[1] != http.StatusServiceUnavailable {
+		t.Fatalf("status codes = %v, want [500 503]", codes)
+	}
+}
@@ -2,4 +2,12 @@ module service-b
 
 go 1.23.4
 
-require github.com/gorilla/mux v1.8.1
+require (
+	dapr-common v0.0.0
+	github.com/gorilla/mux v1.8.1
+	go.uber.org/zap v1.27.0
+)
+
+require go.uber.org/multierr v1.10.0 // indirect
+
+replace dapr-common => ../common
@@ -1,37 +1,88 @@
 package main
 
 import (
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
+	"sync/atomic"
+	"time"
 
+	"dapr-common"
 	"github.com/gorilla/mux"
+	"go.uber.org/zap"
 )
 
+// serviceAInstances Service A 的两个实例（service-a 和 service-a-1）
+var serviceAInstances = []string{"http://localhost:8000", "http://localhost:8001"}
+
+// unhealthyCooldown 请求失败的实例被跳过的时间
+const unhealthyCooldown = 10 * time.Second
+
+// requestTimeout 服务端处理单个请求的最长时间
+const requestTimeout = 5 * time.Second
+
 func main() {
+	logger, err := zap.NewProduction()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer logger.Sync()
+
+	r := newRouter(newServiceClient(defaultClientConfig), NewBalancer(serviceAInstances, unhealthyCooldown))
+	// 读取或生成 X-Request-ID，调用 Service A 时透传
+	r.Use(common.RequestID())
+	// 访问日志：method、path、status、耗时、字节数
+	r.Use(common.AccessLog(logger))
+	// 客户端支持时 gzip 压缩响应
+	r.Use(common.Gzip)
+	// 单个请求最长处理时间，超时返回 503 并取消请求 context
+	r.Use(common.Timeout(requestTimeout))
+
+	// 健康检查，监听成功后标记为就绪
+	var ready atomic.Bool
+	common.RegisterHealth(r, &ready)
+
+	// 启动 HTTP 服务器
+	ln, err := net.Listen("tcp", ":8081")
+	if err != nil {
+		log.Fatal(err)
+	}
+	ready.Store(true)
+	log.Println("Service B is running on :8081...")
+	log.Fatal(http.Serve(ln, r))
+}
+
+func newRouter(client *serviceClient, balancer *Balancer) *mux.Router {
 	r := mux.NewRouter()
 
 	// 定义一个 HTTP 端点，调用 Service A
 	r.HandleFunc("/call-service-a", func(w http.ResponseWriter, r *http.Request) {
-		// 使用 Dapr 的服务调用功能调用 Service A
-		resp, err := http.Get("http://localhost:3511/v1.0/invoke/service-a/method/hello")
+		// 轮询选择一个健康的 Service A 实例
+		target, err := balancer.Next()
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to call Service A: %v", err), http.StatusInternalServerError)
+			http.Error(w, "Service A unavailable", http.StatusServiceUnavailable)
 			return
 		}
-		defer resp.Body.Close()
 
-		body, err := ioutil.ReadAll(resp.Body)
+		// 下游调用使用请求的 context，服务端超时或客户端断开时立即中止
+		body, err := client.Get(r.Context(), target+"/hello")
+		if r.Context().Err() != nil {
+			return // 超时的响应已由 Timeout 中间件写出，也不算 Service A 故障
+		}
+		if errors.Is(err, ErrCircuitOpen) {
+			balancer.Report(target, false) // 该实例熔断中，后续请求先轮询其他实例
+			http.Error(w, "Service A unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		balancer.Report(target, err == nil)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to read response: %v", err), http.StatusInternalServerError)
+			http.Error(w, fmt.Sprintf("Failed to call Service A: %v", err), http.StatusInternalServerError)
 			return
 		}
 
 		fmt.Fprintf(w, "Response from Service A: %s", string(body))
 	})
-
-	// 启动 HTTP 服务器
-	log.Println("Service B is running on :8081...")
-	log.Fatal(http.ListenAndServe(":8081", r))
+	return r
 }
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"dapr-common"
+	"github.com/gorilla/mux"
+)
+
+func TestRequestIDPropagatedToServiceA(t *testing.T) {
+	// 模拟 Service A：同样挂载 RequestID 中间件，并在响应体中回显收到的 ID
+	a := mux.NewRouter()
+	a.Use(common.RequestID())
+	a.HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(common.RequestIDFromContext(r.Context())))
+	})
+	srvA := httptest.NewServer(a)
+	defer srvA.Close()
+
+	b := newRouter(newServiceClient(testConfig()), NewBalancer([]string{srvA.URL}, time.Minute))
+	b.Use(common.RequestID())
+
+	rec := httptest.NewRecorder()
+	b.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/call-service-a", nil))
+	id := rec.Header().Get(common.RequestIDHeader)
+	if id == "" {
+		t.Fatal("service-b did not generate a request id")
+	}
+	if want := "Response from Service A: " + id; rec.Body.String() != want {
+		t.Fatalf("body = %q, want %q", rec.Body.String(), want)
+	}
+}
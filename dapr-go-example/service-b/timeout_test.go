@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"dapr-common"
+)
+
+func TestTimeoutCancelsDownstreamCall(t *testing.T) {
+	// 慢的 Service A：直到请求被取消才返回
+	canceled := make(chan struct{})
+	srvA := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			close(canceled)
+		case <-time.After(5 * time.Second):
+			w.Write([]byte("too late"))
+		}
+	}))
+	defer srvA.Close()
+
+	balancer := NewBalancer([]string{srvA.URL}, time.Minute)
+	b := newRouter(newServiceClient(testConfig()), balancer)
+	b.Use(common.Timeout(50 * time.Millisecond))
+
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	b.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/call-service-a", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", rec.Code)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("request took %v, want it bounded by the timeout", elapsed)
+	}
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("downstream request was not canceled")
+	}
+	// 自身超时不应把 Service A 标记为不健康
+	if _, err := balancer.Next(); err != nil {
+		t.Fatalf("balancer marked service A unhealthy: %v", err)
+	}
+}
@@ -1,23 +1,17 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"os"
+	"os/signal"
+	"syscall"
+	"test/common"
 	"time"
 )
 
 func main() {
-	// 自定义Encoder配置
-	encoderConfig := zapcore.EncoderConfig{}
-
-	// 创建一个JSON格式的encoder
-	encoder := zapcore.NewJSONEncoder(encoderConfig)
-
-	// 设置日志级别
-	level := zapcore.InfoLevel
-
 	// 缓冲
 	bufferedWriteSyncer := &zapcore.BufferedWriteSyncer{
 		WS:            os.Stderr,
@@ -25,11 +19,8 @@ func main() {
 		FlushInterval: time.Second * 5,
 	}
 
-	// 创建一个输出目标（标准输出）
-	core := zapcore.NewCore(encoder, bufferedWriteSyncer, level)
-	//
-	//// 创建Logger
-	logger := zap.New(core)
+	// 使用 common 包的通用编码配置，日志级别 Info
+	logger := common.NewCommonLogger(bufferedWriteSyncer, zapcore.InfoLevel)
 
 	sugar := logger.Sugar()
 	//
@@ -45,10 +36,12 @@ func main() {
 		//	zap.Int("count", i))
 		//time.Sleep(time.Second)
 	}
-	time.Sleep(time.Second * 25)
-	fmt.Println("++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++")
-	time.Sleep(time.Second * 60)
 
-	// 确保日志输出被刷新
-	//defer logger.Sync()
+	// 最后不足 1024 B 的日志还留在缓冲区里，Ctrl+C 退出时刷出全部缓冲再返回
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	fmt.Println("press Ctrl+C to flush and exit")
+	if err := common.RunWithGracefulFlush(ctx, logger, bufferedWriteSyncer); err != nil {
+		fmt.Fprintln(os.Stderr, "flush logger failed:", err)
+	}
 }
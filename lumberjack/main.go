@@ -1,20 +1,45 @@
 package main
 
 import (
+	"errors"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"gopkg.in/natefinch/lumberjack.v2"
+	"log"
+	"test/common"
 	"time"
 )
 
 func main() {
+	logger, cleanup := BuildRotatingLogger()
+	// 退出前停止缓冲并刷盘，否则最后一个缓冲区的日志会丢失
+	defer func() {
+		if err := cleanup(); err != nil {
+			log.Println("flush logger failed:", err)
+		}
+	}()
+
+	// 示例日志输出：Check 判断级别后复用字段切片，避免每条日志分配
+	common.LogLoop(logger, zapcore.InfoLevel, "Logging with buffer and rotationttttyyyyyyyyyyyyyyyyyrotationttttyyyyyyyyyyyyyyyyyrotationttttyyyyyyyyyyyyyyyyyrotationttttyyyyyyy----------", 10000,
+		func(i int, fields []zap.Field) []zap.Field {
+			return append(fields, zap.Int("count", i))
+		})
+}
+
+// BuildRotatingLogger 创建写入 log.log 的带缓冲滚动 logger。
+// 返回的 cleanup 停止 BufferedWriteSyncer（会先刷出缓冲区）并关闭 lumberjack 文件，程序退出前必须调用。
+func BuildRotatingLogger() (*zap.Logger, func() error) {
+	return buildRotatingLogger("log.log")
+}
+
+func buildRotatingLogger(filename string) (*zap.Logger, func() error) {
 	// 创建一个 lumberjack.Logger，用于日志滚动
 	lumberjackLogger := &lumberjack.Logger{
-		Filename:   "log.log", // 日志文件名
-		MaxSize:    1,         // 单个日志文件最大大小（单位：MB）
-		MaxBackups: 3,         // 保留的旧日志文件个数
-		MaxAge:     7,         // 日志文件最多保存天数
-		Compress:   true,      // 是否压缩旧的日志文件
+		Filename:   filename, // 日志文件名
+		MaxSize:    1,        // 单个日志文件最大大小（单位：MB）
+		MaxBackups: 3,        // 保留的旧日志文件个数
+		MaxAge:     7,        // 日志文件最多保存天数
+		Compress:   true,     // 是否压缩旧的日志文件
 	}
 
 	// 创建Zap logger配置
@@ -35,14 +60,9 @@ func main() {
 		zapcore.InfoLevel,                     // 日志级别
 	)
 
-	logger := zap.New(core)
-
-	// 示例日志输出
-	for i := 0; i < 10000; i++ {
-		logger.Info("Logging with buffer and rotationttttyyyyyyyyyyyyyyyyyrotationttttyyyyyyyyyyyyyyyyyrotationttttyyyyyyyyyyyyyyyyyrotationttttyyyyyyy----------",
-			zap.Int("count", i))
-		//time.Sleep(time.Second)
+	cleanup := func() error {
+		// Stop 会刷出剩余缓冲并停止后台定时刷新
+		return errors.Join(bufferedWriteSyncer.Stop(), lumberjackLogger.Close())
 	}
-
-	//logger.Sync()
+	return zap.New(core), cleanup
 }
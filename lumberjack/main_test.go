@@ -0,0 +1,31 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestBuildRotatingLoggerCleanupFlushes(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "log.log")
+	logger, cleanup := buildRotatingLogger(filename)
+
+	const n = 10 // 远小于缓冲区刷新间隔内能写完的量，不调用 cleanup 时会留在缓冲区
+	for i := 0; i < n; i++ {
+		logger.Info("line", zap.Int("count", i))
+	}
+	if err := cleanup(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(string(data), "\n"); got != n {
+		t.Fatalf("file has %d lines, want %d", got, n)
+	}
+}
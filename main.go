@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"test/timeutil"
 	"time"
 )
 
@@ -21,8 +22,10 @@ func main() {
 	fmt.Println("方法1 - 分离秒和纳秒:", t1)
 
 	// 正确的用法2：从总纳秒数计算
+	// 注意 / 和 % 向零取整，1970 年之前的负数时间戳应使用 timeutil.FromUnixNano
 	t2 := time.Unix(nsec/1e9, nsec%1e9)
 	fmt.Println("方法2 - 从总纳秒数计算:", t2)
+	fmt.Println("方法2 - timeutil.FromUnixNano:", timeutil.FromUnixNano(nsec))
 
 	// 正确的用法3：最简单的方法
 	t3 := time.Now()
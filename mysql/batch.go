@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BatchRunner 把 Total 行按 BatchSize 分批交给插入函数，供大批量造数据的循环复用
+type BatchRunner struct {
+	Total     int
+	BatchSize int
+
+	// OnProgress 每批插入成功后调用，done 为累计插入的行数，elapsed 为从 Run 开始的耗时；
+	// 可用于打印 ETA 或上报指标，nil 时不回调
+	OnProgress func(done, total int, elapsed time.Duration)
+
+	// Checkpoint 断点文件路径，为空时不记录。每批插入成功后写入已完成的行数，
+	// Run 开始时从中恢复，崩溃后重新运行会跳过已插入的批次；要从头开始需先删除该文件
+	Checkpoint string
+}
+
+// Run 按顺序插入每一批，insert 收到本批的起始行号和行数（最后一批可能不足 BatchSize）。
+// insert 返回错误或 ctx 结束时停止，返回的错误带上出错批次的起始行号
+func (r *BatchRunner) Run(ctx context.Context, insert func(ctx context.Context, offset, n int) error) error {
+	if r.BatchSize <= 0 {
+		return fmt.Errorf("batch size must be positive, got %d", r.BatchSize)
+	}
+	resume, err := r.loadCheckpoint()
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	for offset := resume; offset < r.Total; offset += r.BatchSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n := min(r.BatchSize, r.Total-offset)
+		if err := insert(ctx, offset, n); err != nil {
+			return fmt.Errorf("batch at row %d: %w", offset, err)
+		}
+		if err := r.saveCheckpoint(offset + n); err != nil {
+			return err
+		}
+		if r.OnProgress != nil {
+			r.OnProgress(offset+n, r.Total, time.Since(start))
+		}
+	}
+	return nil
+}
+
+// loadCheckpoint 读取已完成的行数，文件不存在时从 0 开始
+func (r *BatchRunner) loadCheckpoint() (int, error) {
+	if r.Checkpoint == "" {
+		return 0, nil
+	}
+	data, err := os.ReadFile(r.Checkpoint)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("read checkpoint: %w", err)
+	}
+	done, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || done < 0 || done > r.Total {
+		return 0, fmt.Errorf("invalid checkpoint %s: %q", r.Checkpoint, data)
+	}
+	return done, nil
+}
+
+// saveCheckpoint 以"写临时文件、fsync、rename"的方式原子更新断点，
+// 崩溃时文件要么是旧值要么是新值，不会读到写了一半的内容
+func (r *BatchRunner) saveCheckpoint(done int) error {
+	if r.Checkpoint == "" {
+		return nil
+	}
+	tmp := r.Checkpoint + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("write checkpoint: %w", err)
+	}
+	_, err = f.WriteString(strconv.Itoa(done) + "\n")
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, r.Checkpoint)
+	}
+	if err != nil {
+		return fmt.Errorf("write checkpoint: %w", err)
+	}
+	// rename 本身也要落盘，否则掉电后目录里可能还是旧文件
+	if dir, err := os.Open(filepath.Dir(r.Checkpoint)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
+
+// PrintProgress 打印进度和按当前速度估算的剩余时间，可直接作为 OnProgress
+func PrintProgress(done, total int, elapsed time.Duration) {
+	var eta time.Duration
+	if done > 0 {
+		eta = time.Duration(float64(elapsed) / float64(done) * float64(total-done))
+	}
+	fmt.Printf("inserted %d/%d (%.1f%%), elapsed %v, eta %v\n",
+		done, total, float64(done)*100/float64(total), elapsed.Round(time.Second), eta.Round(time.Second))
+}
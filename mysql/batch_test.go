@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBatchRunnerProgress(t *testing.T) {
+	var done []int
+	var inserted int
+	r := &BatchRunner{Total: 10, BatchSize: 3, OnProgress: func(d, total int, elapsed time.Duration) {
+		if total != 10 || elapsed < 0 {
+			t.Fatalf("OnProgress(%d, %d, %v)", d, total, elapsed)
+		}
+		done = append(done, d)
+	}}
+	err := r.Run(context.Background(), func(_ context.Context, offset, n int) error {
+		if offset != inserted {
+			t.Fatalf("offset = %d, want %d", offset, inserted)
+		}
+		inserted += n
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []int{3, 6, 9, 10}
+	if len(done) != len(want) {
+		t.Fatalf("progress = %v, want %v", done, want)
+	}
+	for i := range want {
+		if done[i] != want[i] {
+			t.Fatalf("progress = %v, want %v", done, want)
+		}
+	}
+	if inserted != 10 {
+		t.Fatalf("inserted %d rows, want 10", inserted)
+	}
+
+	// 没有回调时正常执行；出错后停止，不再报告进度
+	boom := errors.New("boom")
+	r = &BatchRunner{Total: 10, BatchSize: 4}
+	calls := 0
+	err = r.Run(context.Background(), func(context.Context, int, int) error {
+		calls++
+		if calls == 2 {
+			return boom
+		}
+		return nil
+	})
+	if !errors.Is(err, boom) || calls != 2 {
+		t.Fatalf("err = %v after %d calls, want boom after 2", err, calls)
+	}
+}
+
+func TestBatchRunnerCheckpointResume(t *testing.T) {
+	checkpoint := filepath.Join(t.TempDir(), "ingest.checkpoint")
+	inserted := make(map[int]int) // 起始行号 -> 插入次数
+
+	// 第一次运行在第 3 批时"崩溃"
+	crash := errors.New("crash")
+	r := &BatchRunner{Total: 10, BatchSize: 3, Checkpoint: checkpoint}
+	err := r.Run(context.Background(), func(_ context.Context, offset, n int) error {
+		if offset == 6 {
+			return crash
+		}
+		inserted[offset]++
+		return nil
+	})
+	if !errors.Is(err, crash) {
+		t.Fatalf("err = %v, want crash", err)
+	}
+	if data, _ := os.ReadFile(checkpoint); string(data) != "6\n" {
+		t.Fatalf("checkpoint = %q, want 6", data)
+	}
+
+	// 重新运行从断点继续，已完成的批次不再插入
+	var progress []int
+	r = &BatchRunner{Total: 10, BatchSize: 3, Checkpoint: checkpoint, OnProgress: func(done, _ int, _ time.Duration) {
+		progress = append(progress, done)
+	}}
+	err = r.Run(context.Background(), func(_ context.Context, offset, n int) error {
+		inserted[offset]++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, offset := range []int{0, 3, 6, 9} {
+		if inserted[offset] != 1 {
+			t.Fatalf("batch at %d inserted %d times, want once (%v)", offset, inserted[offset], inserted)
+		}
+	}
+	if len(progress) != 2 || progress[0] != 9 || progress[1] != 10 {
+		t.Fatalf("progress after resume = %v, want [9 10]", progress)
+	}
+
+	// 全部完成后再次运行不插入任何数据
+	err = r.Run(context.Background(), func(context.Context, int, int) error {
+		t.Fatal("insert called after ingest completed")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// bulkLoadSeq 生成唯一的 Reader 名称，并发 BulkLoad 互不干扰
+var bulkLoadSeq atomic.Int64
+
+// loadDataEscaper LOAD DATA 默认 ESCAPED BY '\\' 下需要转义的字符
+var loadDataEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`, "\x00", `\0`)
+
+// BulkLoad 用 LOAD DATA LOCAL INFILE 批量导入，比多行 INSERT 快一个数量级。
+// 数据通过驱动的 RegisterReaderHandler 边生成边发送，不落临时文件；
+// 服务端需要开启 local_infile（SET GLOBAL local_infile = 1），否则直接返回错误。
+// 字段按制表符分隔，值里的反斜杠、制表符、换行会被转义。
+func BulkLoad(ctx context.Context, db *sql.DB, table string, columns []string, rows [][]string) (int64, error) {
+	if len(columns) == 0 {
+		return 0, fmt.Errorf("bulk load %s: no columns", table)
+	}
+	for i, row := range rows {
+		if len(row) != len(columns) {
+			return 0, fmt.Errorf("bulk load %s: row %d has %d fields, want %d", table, i, len(row), len(columns))
+		}
+	}
+
+	var localInfile bool
+	if err := db.QueryRowContext(ctx, "SELECT @@GLOBAL.local_infile").Scan(&localInfile); err != nil {
+		return 0, fmt.Errorf("bulk load %s: check local_infile: %v", table, err)
+	}
+	if !localInfile {
+		return 0, fmt.Errorf("bulk load %s: local_infile is disabled on the server, run SET GLOBAL local_infile = 1", table)
+	}
+
+	name := fmt.Sprintf("bulkload_%d", bulkLoadSeq.Add(1))
+	mysql.RegisterReaderHandler(name, func() io.Reader {
+		// 驱动真正需要数据时才开始写，读完后驱动会关闭 pr，写端随之退出
+		pr, pw := io.Pipe()
+		go func() { pw.CloseWithError(writeLoadData(pw, rows)) }()
+		return pr
+	})
+	defer mysql.DeregisterReaderHandler(name)
+
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = quoteIdent(c)
+	}
+	query := fmt.Sprintf(`LOAD DATA LOCAL INFILE 'Reader::%s' INTO TABLE %s `+
+		`CHARACTER SET utf8mb4 FIELDS TERMINATED BY '\t' ESCAPED BY '\\' LINES TERMINATED BY '\n' (%s)`,
+		name, quoteIdent(table), strings.Join(quoted, ", "))
+
+	res, err := db.ExecContext(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("bulk load %s: %v", table, err)
+	}
+	return res.RowsAffected()
+}
+
+// writeLoadData 按 LOAD DATA 默认格式写出：字段以 \t 分隔，行以 \n 结尾
+func writeLoadData(w io.Writer, rows [][]string) error {
+	var b strings.Builder
+	for _, row := range rows {
+		b.Reset()
+		for i, field := range row {
+			if i > 0 {
+				b.WriteByte('\t')
+			}
+			loadDataEscaper.WriteString(&b, field)
+		}
+		b.WriteByte('\n')
+		if _, err := io.WriteString(w, b.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func quoteIdent(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestWriteLoadDataEscaping(t *testing.T) {
+	var b strings.Builder
+	rows := [][]string{
+		{"1", "plain"},
+		{"2", "tab\there"},
+		{"3", "line\nbreak\\slash"},
+	}
+	if err := writeLoadData(&b, rows); err != nil {
+		t.Fatal(err)
+	}
+	want := "1\tplain\n" + "2\ttab\\there\n" + "3\tline\\nbreak\\\\slash\n"
+	if b.String() != want {
+		t.Fatalf("got %q, want %q", b.String(), want)
+	}
+}
+
+func TestBulkLoadRequiresLocalInfile(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mock.ExpectQuery("SELECT @@GLOBAL.local_infile").WillReturnRows(sqlmock.NewRows([]string{"v"}).AddRow(int64(0)))
+
+	_, err = BulkLoad(context.Background(), db, "order3s", []string{"id"}, [][]string{{"1"}})
+	if err == nil || !strings.Contains(err.Error(), "local_infile") {
+		t.Fatalf("err = %v, want local_infile disabled", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestBulkLoadMatchesInsert 需要真实 MySQL：MYSQL_TEST_DSN=root:123456@tcp(127.0.0.1:3306)/test
+func TestBulkLoadMatchesInsert(t *testing.T) {
+	dsn := os.Getenv("MYSQL_TEST_DSN")
+	if dsn == "" {
+		t.Skip("MYSQL_TEST_DSN not set")
+	}
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	columns := []string{"id", "note"}
+	var rows [][]string
+	for i := 1; i <= 300; i++ {
+		rows = append(rows, []string{fmt.Sprint(i), fmt.Sprintf("note %d\twith tab", i)})
+	}
+
+	for _, table := range []string{"bulkload_loaded", "bulkload_inserted"} {
+		if _, err := db.Exec("DROP TABLE IF EXISTS " + table); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec("CREATE TABLE " + table + " (id INT PRIMARY KEY, note VARCHAR(64))"); err != nil {
+			t.Fatal(err)
+		}
+		defer db.Exec("DROP TABLE " + table)
+	}
+
+	loaded, err := BulkLoad(ctx, db, "bulkload_loaded", columns, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 基准：和 order2.go 一样的多行 INSERT
+	query := "INSERT INTO bulkload_inserted (id, note) VALUES " + strings.TrimSuffix(strings.Repeat("(?, ?),", len(rows)), ",")
+	var args []interface{}
+	for _, r := range rows {
+		args = append(args, r[0], r[1])
+	}
+	res, err := db.Exec(query, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inserted, _ := res.RowsAffected()
+	if loaded != inserted || loaded != int64(len(rows)) {
+		t.Fatalf("loaded %d rows, inserted %d, want %d", loaded, inserted, len(rows))
+	}
+
+	var diff int
+	err = db.QueryRow(`SELECT COUNT(*) FROM bulkload_loaded l JOIN bulkload_inserted i ON l.id = i.id WHERE l.note <> i.note`).Scan(&diff)
+	if err != nil || diff != 0 {
+		t.Fatalf("%d rows differ between LOAD DATA and INSERT (err %v)", diff, err)
+	}
+}
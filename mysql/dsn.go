@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// newDSN tcp 连接配置。parseTime 让 DATETIME/TIMESTAMP 扫描为 time.Time；
+// loc 默认本机时区，会话时区不同的调用方需要自己设置 cfg.Loc
+func newDSN(user, password, addr, database string) *mysql.Config {
+	cfg := mysql.NewConfig()
+	cfg.User = user
+	cfg.Passwd = password
+	cfg.Net = "tcp"
+	cfg.Addr = addr
+	cfg.DBName = database
+	cfg.ParseTime = true
+	cfg.Loc = time.Local
+	return cfg
+}
+
+// localDSN 本地开发库 root:123456@127.0.0.1:3306
+func localDSN(database string) *mysql.Config {
+	return newDSN("root", "123456", "127.0.0.1:3306", database)
+}
+
+// ParseDSN 用驱动解析连接串并检查必填字段，重复的参数以最后一次出现为准，
+// 结果的 FormatDSN() 是去重后的规范连接串
+func ParseDSN(raw string) (*mysql.Config, error) {
+	cfg, err := mysql.ParseDSN(raw)
+	if err != nil {
+		return nil, fmt.Errorf("dsn %q: %v", raw, err)
+	}
+	if cfg.User == "" {
+		return nil, errors.New("dsn: empty user")
+	}
+	if cfg.Net == "tcp" {
+		_, port, err := net.SplitHostPort(cfg.Addr)
+		if err != nil {
+			return nil, fmt.Errorf("dsn %q: %v", raw, err)
+		}
+		if p, err := strconv.Atoi(port); err != nil || p <= 0 || p > 65535 {
+			return nil, fmt.Errorf("dsn %q: invalid port %q", raw, port)
+		}
+	}
+	return cfg, nil
+}
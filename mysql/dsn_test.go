@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestDSNDedupAndDefaults(t *testing.T) {
+	cfg, err := ParseDSN("root:123456@tcp(127.0.0.1:3306)/dbname?parseTime=true&parseTime=true&loc=Asia%2FShanghai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "root:123456@tcp(127.0.0.1:3306)/dbname?loc=Asia%2FShanghai&parseTime=true"
+	if got := cfg.FormatDSN(); got != want {
+		t.Fatalf("FormatDSN() = %q, want %q", got, want)
+	}
+
+	// 重复参数以最后一次为准
+	cfg, err = ParseDSN("u@tcp(db:3307)/x?timeout=1s&timeout=5s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Timeout.String() != "5s" {
+		t.Fatalf("timeout = %v, want 5s", cfg.Timeout)
+	}
+
+	// 默认开启 parseTime，loc 使用本机时区而不是写死的时区
+	cfg = localDSN("wcs_core")
+	if !cfg.ParseTime || cfg.Loc.String() != "Local" {
+		t.Fatalf("localDSN parseTime = %v loc = %v, want true Local", cfg.ParseTime, cfg.Loc)
+	}
+	if got, want := cfg.FormatDSN(), "root:123456@tcp(127.0.0.1:3306)/wcs_core?loc=Local&parseTime=true"; got != want {
+		t.Fatalf("localDSN = %q, want %q", got, want)
+	}
+}
+
+func TestDSNRoundTrip(t *testing.T) {
+	a := newDSN("root", "p@ss/word", "127.0.0.1:3306", "dbname")
+	a.Params = map[string]string{"autocommit": "true"} // 会话变量
+	b := newDSN("app", "", "[::1]:3307", "seckill")
+	b.ParseTime = false
+
+	for _, cfg := range []*mysql.Config{a, b} {
+		raw := cfg.FormatDSN()
+		got, err := ParseDSN(raw)
+		if err != nil {
+			t.Fatalf("ParseDSN(%q): %v", raw, err)
+		}
+		if got.User != cfg.User || got.Passwd != cfg.Passwd || got.Addr != cfg.Addr || got.DBName != cfg.DBName {
+			t.Fatalf("ParseDSN(%q) = %+v, want %+v", raw, got, cfg)
+		}
+		if got.FormatDSN() != raw {
+			t.Fatalf("round trip %q -> %q", raw, got.FormatDSN())
+		}
+	}
+}
+
+func TestParseDSNErrors(t *testing.T) {
+	for _, raw := range []string{
+		"root@tcp(127.0.0.1:3306)",            // 缺少 /database
+		"tcp(127.0.0.1:3306)/db",              // 缺少 user@
+		"root@tcp(127.0.0.1:abc)/db",          // 端口不是数字
+		"root@tcp(127.0.0.1:3306)/db?loc=%zz", // 参数转义错误
+	} {
+		if _, err := ParseDSN(raw); err == nil {
+			t.Errorf("ParseDSN(%q) succeeded, want error", raw)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrBackendUnavailable 后台探活失败期间直接返回，不再把请求交给可能已经失效的连接
+var ErrBackendUnavailable = errors.New("mysql backend unavailable")
+
+// HealthCheckedDB 定期 PingContext 探活的 *sql.DB 包装。
+// 探活失败后标记为不可用，查询立即返回 ErrBackendUnavailable；
+// 同时把空闲连接数降到 0，让连接池丢掉 MySQL 重启前留下的死连接，恢复后再还原。
+type HealthCheckedDB struct {
+	db           *sql.DB
+	interval     time.Duration
+	pingTimeout  time.Duration
+	maxIdleConns int // 恢复时还原的空闲连接数，和调用方 SetMaxIdleConns 的值保持一致
+
+	healthy atomic.Bool
+	stop    chan struct{}
+	wg      sync.WaitGroup
+	once    sync.Once
+}
+
+// NewHealthCheckedDB 立即探活一次并启动后台探活，maxIdleConns 为连接池原本的空闲连接数
+func NewHealthCheckedDB(db *sql.DB, interval time.Duration, maxIdleConns int) *HealthCheckedDB {
+	h := &HealthCheckedDB{
+		db:           db,
+		interval:     interval,
+		pingTimeout:  interval,
+		maxIdleConns: maxIdleConns,
+		stop:         make(chan struct{}),
+	}
+	h.healthy.Store(true)
+	h.check()
+
+	h.wg.Add(1)
+	go h.loop()
+	return h
+}
+
+func (h *HealthCheckedDB) loop() {
+	defer h.wg.Done()
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.stop:
+			return
+		case <-ticker.C:
+			h.check()
+		}
+	}
+}
+
+func (h *HealthCheckedDB) check() {
+	ctx, cancel := context.WithTimeout(context.Background(), h.pingTimeout)
+	err := h.db.PingContext(ctx)
+	cancel()
+
+	if err != nil {
+		if h.healthy.CompareAndSwap(true, false) {
+			log.Printf("mysql backend unavailable: %v", err)
+			h.db.SetMaxIdleConns(0) // 关闭所有空闲的死连接
+		}
+		return
+	}
+	if h.healthy.CompareAndSwap(false, true) {
+		log.Printf("mysql backend recovered")
+		h.db.SetMaxIdleConns(h.maxIdleConns)
+	}
+}
+
+// Healthy 最近一次探活是否成功
+func (h *HealthCheckedDB) Healthy() bool {
+	return h.healthy.Load()
+}
+
+func (h *HealthCheckedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if !h.Healthy() {
+		return nil, ErrBackendUnavailable
+	}
+	return h.db.QueryContext(ctx, query, args...)
+}
+
+func (h *HealthCheckedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if !h.Healthy() {
+		return nil, ErrBackendUnavailable
+	}
+	return h.db.ExecContext(ctx, query, args...)
+}
+
+// BeginTx 不可用时不开启事务，已开启的事务不受影响
+func (h *HealthCheckedDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	if !h.Healthy() {
+		return nil, ErrBackendUnavailable
+	}
+	return h.db.BeginTx(ctx, opts)
+}
+
+// Close 停止后台探活，不关闭底层 *sql.DB
+func (h *HealthCheckedDB) Close() {
+	h.once.Do(func() { close(h.stop) })
+	h.wg.Wait()
+}
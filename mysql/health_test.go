@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"log"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyBackend 可以随时"宕机"的驱动，宕机期间 Ping 和查询都返回 ErrBadConn
+type flakyBackend struct {
+	down    atomic.Bool
+	queries atomic.Int64
+}
+
+func (b *flakyBackend) Connect(context.Context) (driver.Conn, error) { return b.Open("") }
+func (b *flakyBackend) Driver() driver.Driver                        { return b }
+
+func (b *flakyBackend) Open(string) (driver.Conn, error) {
+	if b.down.Load() {
+		return nil, errors.New("dial tcp 127.0.0.1:3306: connection refused")
+	}
+	return &flakyConn{b}, nil
+}
+
+type flakyConn struct{ b *flakyBackend }
+
+func (c *flakyConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *flakyConn) Close() error                        { return nil }
+func (c *flakyConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (c *flakyConn) Ping(context.Context) error {
+	if c.b.down.Load() {
+		return driver.ErrBadConn
+	}
+	return nil
+}
+
+func (c *flakyConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	c.b.queries.Add(1)
+	if c.b.down.Load() {
+		return nil, driver.ErrBadConn
+	}
+	return emptyRows{}, nil
+}
+
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string         { return []string{"1"} }
+func (emptyRows) Close() error              { return nil }
+func (emptyRows) Next([]driver.Value) error { return io.EOF }
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !cond(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within 1s")
+		}
+	}
+}
+
+func TestHealthCheckedDBDownThenUp(t *testing.T) {
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(io.Discard)
+
+	backend := &flakyBackend{}
+	db := sql.OpenDB(backend)
+	defer db.Close()
+	h := NewHealthCheckedDB(db, 5*time.Millisecond, 2)
+	defer h.Close()
+
+	ctx := context.Background()
+	query := func() error {
+		rows, err := h.QueryContext(ctx, "SELECT 1")
+		if err == nil {
+			rows.Close()
+		}
+		return err
+	}
+	if err := query(); err != nil {
+		t.Fatalf("healthy query: %v", err)
+	}
+
+	backend.down.Store(true)
+	waitFor(t, func() bool { return !h.Healthy() })
+	before := backend.queries.Load()
+	if err := query(); !errors.Is(err, ErrBackendUnavailable) {
+		t.Fatalf("query during outage = %v, want ErrBackendUnavailable", err)
+	}
+	if backend.queries.Load() != before {
+		t.Fatal("query reached the backend during outage")
+	}
+	if idle := db.Stats().Idle; idle != 0 {
+		t.Fatalf("idle conns during outage = %d, want 0", idle)
+	}
+
+	backend.down.Store(false)
+	waitFor(t, h.Healthy)
+	if err := query(); err != nil {
+		t.Fatalf("query after recovery: %v", err)
+	}
+}
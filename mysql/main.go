@@ -16,8 +16,10 @@ import (
 
 func init1() {
 	// 连接到 MySQL 数据库
-	dsn := "root:123456@tcp(127.0.0.1:3306)/dbname?parseTime=true&parseTime=true&loc=Asia%2FShanghai"
-	db, err = sql.Open("mysql", dsn)
+	// 会话时区固定为 +08:00，loc 与之保持一致
+	cfg := localDSN("dbname")
+	cfg.Loc = time.FixedZone("UTC+8", 8*3600)
+	db, err = sql.Open("mysql", cfg.FormatDSN())
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -33,6 +35,7 @@ func init1() {
 func main1() {
 	Insert()
 	Raw()
+	RawTyped()
 }
 func Insert() {
 	// 获取当前时间
@@ -96,3 +99,29 @@ func Raw() {
 	}
 
 }
+
+// RawTyped 和 Raw 一样取原生结果，但按列类型解码，时间列直接是 time.Time
+func RawTyped() {
+	rows, err := db.Query("SELECT id, timestamp_column, datetime_column FROM your_table ORDER BY id DESC LIMIT 1")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	scanner, err := NewTypedScanner(rows, time.Local)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for rows.Next() {
+		row, err := scanner.Scan()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for key, value := range row {
+			fmt.Printf("TYPED: %s: %v (%T)\n", key, value, value)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Migration 一次命名的表结构变更
+type Migration struct {
+	Name string
+	SQL  string
+}
+
+// Migrator 按顺序执行迁移，已执行的迁移记录在 schema_migrations 表中，不会重复执行。
+// MySQL 的 DDL 会隐式提交，无法和迁移记录放在同一事务里，
+// 执行 DDL 后、写入记录前失败时下次会重新执行，所以迁移语句应当可重复执行（IF NOT EXISTS 等）。
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+func NewMigrator(db *sql.DB, migrations ...Migration) *Migrator {
+	return &Migrator{db: db, migrations: migrations}
+}
+
+// Migrate 执行所有未执行的迁移，返回本次执行的迁移名称
+func (m *Migrator) Migrate(ctx context.Context) ([]string, error) {
+	seen := make(map[string]bool, len(m.migrations))
+	for _, mg := range m.migrations {
+		if seen[mg.Name] {
+			return nil, fmt.Errorf("duplicate migration %q", mg.Name)
+		}
+		seen[mg.Name] = true
+	}
+
+	_, err := m.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+  name VARCHAR(255) NOT NULL PRIMARY KEY,
+  applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`)
+	if err != nil {
+		return nil, fmt.Errorf("create schema_migrations: %v", err)
+	}
+
+	done, err := m.appliedNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []string
+	for _, mg := range m.migrations {
+		if done[mg.Name] {
+			continue
+		}
+		if _, err := m.db.ExecContext(ctx, mg.SQL); err != nil {
+			return applied, fmt.Errorf("migration %s: %v", mg.Name, err)
+		}
+		if _, err := m.db.ExecContext(ctx, "INSERT INTO schema_migrations (name) VALUES (?)", mg.Name); err != nil {
+			return applied, fmt.Errorf("record migration %s: %v", mg.Name, err)
+		}
+		applied = append(applied, mg.Name)
+	}
+	return applied, nil
+}
+
+func (m *Migrator) appliedNames(ctx context.Context) (map[string]bool, error) {
+	rows, err := m.db.QueryContext(ctx, "SELECT name FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("query schema_migrations: %v", err)
+	}
+	defer rows.Close()
+
+	done := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		done[name] = true
+	}
+	return done, rows.Err()
+}
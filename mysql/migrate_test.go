@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestMigratorSecondRunIsNoop(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	m := NewMigrator(db,
+		Migration{Name: "0001_create_a", SQL: "CREATE TABLE IF NOT EXISTS a (id INT)"},
+		Migration{Name: "0002_create_b", SQL: "CREATE TABLE IF NOT EXISTS b (id INT)"},
+	)
+
+	// 第一次：两个迁移都执行并记录
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT name FROM schema_migrations").WillReturnRows(sqlmock.NewRows([]string{"name"}))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS a").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO schema_migrations").WithArgs("0001_create_a").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS b").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO schema_migrations").WithArgs("0002_create_b").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// 第二次：记录表里已有全部迁移，不再执行任何 DDL
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT name FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("0001_create_a").AddRow("0002_create_b"))
+
+	ctx := context.Background()
+	applied, err := m.Migrate(ctx)
+	if err != nil || len(applied) != 2 {
+		t.Fatalf("first run applied %v, err %v; want 2 migrations", applied, err)
+	}
+	applied, err = m.Migrate(ctx)
+	if err != nil || len(applied) != 0 {
+		t.Fatalf("second run applied %v, err %v; want none", applied, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigratorRejectsDuplicateNames(t *testing.T) {
+	m := NewMigrator(nil, Migration{Name: "0001"}, Migration{Name: "0001"})
+	if _, err := m.Migrate(context.Background()); err == nil {
+		t.Fatal("Migrate accepted duplicate migration names")
+	}
+}
+
+func TestWorkerQualityMigrationReusesDDL(t *testing.T) {
+	if len(migrations) == 0 || migrations[0].SQL != createWorkerQualitySessions {
+		t.Fatal("first migration should be the worker_quality_sessions DDL")
+	}
+}
@@ -1,12 +1,16 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"github.com/google/uuid"
 	"log"
+	"math"
 	"math/rand"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	_ "github.com/go-sql-driver/mysql"
 )
@@ -47,8 +51,13 @@ type Order struct {
 
 // GenerateRandomOrder generates a random order
 func GenerateRandomOrder() Order {
+	return GenerateRandomOrderWith(UUIDOrderNumber{})
+}
+
+// GenerateRandomOrderWith 生成随机订单，订单号由 numbers 生成
+func GenerateRandomOrderWith(numbers OrderNumberGenerator) Order {
 	return Order{
-		OrderNumber:     uuid.New().String(),
+		OrderNumber:     numbers.NextOrderNumber(),
 		CustomerID:      rand.Int63n(1000000), // 假设有 100 万客户
 		OrderDate:       time.Now(),
 		Status:          "PENDING",
@@ -67,30 +76,141 @@ func GenerateRandomOrder() Order {
 
 // InsertOrdersInBatch inserts orders in batch
 func InsertOrdersInBatch(db *sql.DB, orders []Order) error {
-	query := "INSERT INTO order2s (order_number, customer_id, order_date, status, total_amount, shipping_address, shipping_cost, payment_method, discount_code, tax_amount, items_count, delivery_date, notes) VALUES "
-	values := ""
+	_, err := InsertOrders(db, orders, BatchInsertOptions{})
+	return err
+}
 
-	// Create the values string with placeholders for batch insert
-	for _, order := range orders {
-		values += fmt.Sprintf("('%s', %d, '%s', '%s', %.2f, '%s', %.2f, '%s', '%s', %.2f, %d, '%s', '%s'),",
-			order.OrderNumber, order.CustomerID, order.OrderDate.Format("2006-01-02 15:04:05"), order.Status, order.TotalAmount, order.ShippingAddress,
-			order.ShippingCost, order.PaymentMethod, order.DiscountCode, order.TaxAmount, order.ItemsCount, order.DeliveryDate.Format("2006-01-02 15:04:05"), order.Notes)
+// BatchInsertOptions 批量插入选项
+type BatchInsertOptions struct {
+	// DryRun 只校验每一行并构建、预编译带参数绑定的语句，不执行插入。
+	// 预编译由服务器检查表名和列名，不会写入任何数据
+	DryRun bool
+}
 
-		//values += fmt.Sprintf("('%s', %d, '%s', '%s', %.2f),",
-		//	order.OrderNumber, order.CustomerID, order.OrderDate.Format("2006-01-02 15:04:05"), order.Status, order.TotalAmount)
+// BatchInsertResult 批量插入结果
+type BatchInsertResult struct {
+	Rows   int     // 插入的行数；DryRun 时为将会插入的行数（全部通过校验时等于批次大小）
+	Errors []error // 未通过校验的行，每个错误都包装 ErrInvalidOrder
+}
+
+// ErrInvalidOrder 订单字段不符合 order2s 表结构
+var ErrInvalidOrder = errors.New("invalid order")
+
+// order2s 列长度限制：VARCHAR 按字符计，TEXT 按字节计；decimal(10,2) 整数部分最多 8 位
+const (
+	maxOrderNumberLen   = 50
+	maxStatusLen        = 20
+	maxPaymentMethodLen = 20
+	maxDiscountCodeLen  = 50
+	maxTextBytes        = 65535
+	maxDecimal10_2      = 1e8
+)
+
+// Validate 检查订单能否写入 order2s，返回第一个不符合的字段
+func (o Order) Validate() error {
+	varchars := []struct {
+		column, value string
+		max           int
+		required      bool
+	}{
+		{"order_number", o.OrderNumber, maxOrderNumberLen, true},
+		{"status", o.Status, maxStatusLen, true},
+		{"payment_method", o.PaymentMethod, maxPaymentMethodLen, false},
+		{"discount_code", o.DiscountCode, maxDiscountCodeLen, false},
+	}
+	for _, c := range varchars {
+		if c.required && c.value == "" {
+			return fmt.Errorf("%w: %s is empty", ErrInvalidOrder, c.column)
+		}
+		if n := utf8.RuneCountInString(c.value); n > c.max {
+			return fmt.Errorf("%w: %s is %d characters, VARCHAR(%d)", ErrInvalidOrder, c.column, n, c.max)
+		}
+	}
+	texts := []struct{ column, value string }{
+		{"shipping_address", o.ShippingAddress},
+		{"notes", o.Notes},
+	}
+	for _, c := range texts {
+		if len(c.value) > maxTextBytes {
+			return fmt.Errorf("%w: %s is %d bytes, TEXT max %d", ErrInvalidOrder, c.column, len(c.value), maxTextBytes)
+		}
+	}
+	decimals := []struct {
+		column string
+		value  float64
+	}{
+		{"total_amount", o.TotalAmount},
+		{"shipping_cost", o.ShippingCost},
+		{"tax_amount", o.TaxAmount},
 	}
+	for _, c := range decimals {
+		if math.IsNaN(c.value) || math.Abs(c.value) >= maxDecimal10_2 {
+			return fmt.Errorf("%w: %s %v out of range for DECIMAL(10,2)", ErrInvalidOrder, c.column, c.value)
+		}
+	}
+	if o.OrderDate.IsZero() {
+		return fmt.Errorf("%w: order_date is zero", ErrInvalidOrder)
+	}
+	return nil
+}
 
-	// Remove the last comma and append to the query
-	query += values[:len(values)-1]
+const orderInsertPrefix = "INSERT INTO order2s (order_number, customer_id, order_date, status, total_amount, shipping_address, shipping_cost, payment_method, discount_code, tax_amount, items_count, delivery_date, notes) VALUES "
 
-	// Execute the batch insert query
-	_, err := db.Exec(query)
-	return err
+// buildOrderInsert 构建多行 INSERT 语句和绑定参数，每行 13 个占位符，
+// 单条语句的占位符不能超过 65535 个，即每批最多 5041 行
+func buildOrderInsert(orders []Order) (string, []interface{}) {
+	var b strings.Builder
+	b.WriteString(orderInsertPrefix)
+	args := make([]interface{}, 0, len(orders)*13)
+	for i, o := range orders {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString("(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+		args = append(args, o.OrderNumber, o.CustomerID, o.OrderDate, o.Status, o.TotalAmount, o.ShippingAddress,
+			o.ShippingCost, o.PaymentMethod, o.DiscountCode, o.TaxAmount, o.ItemsCount, o.DeliveryDate, o.Notes)
+	}
+	return b.String(), args
+}
+
+// InsertOrders 校验并批量插入订单。非 DryRun 时有任何一行未通过校验就不插入，返回第一个错误；
+// DryRun 时收集全部校验错误，只预编译语句而不执行
+func InsertOrders(db *sql.DB, orders []Order, opts BatchInsertOptions) (BatchInsertResult, error) {
+	var res BatchInsertResult
+	for i, o := range orders {
+		if err := o.Validate(); err != nil {
+			res.Errors = append(res.Errors, fmt.Errorf("row %d (%s): %w", i, o.OrderNumber, err))
+		}
+	}
+	if len(orders) == 0 {
+		return res, nil
+	}
+
+	if !opts.DryRun {
+		if len(res.Errors) > 0 {
+			return res, res.Errors[0]
+		}
+		query, args := buildOrderInsert(orders)
+		if _, err := db.Exec(query, args...); err != nil {
+			return res, err
+		}
+		res.Rows = len(orders)
+		return res, nil
+	}
+
+	query, _ := buildOrderInsert(orders)
+	stmt, err := db.Prepare(query)
+	if err != nil {
+		return res, fmt.Errorf("prepare: %w", err)
+	}
+	stmt.Close()
+	res.Rows = len(orders) - len(res.Errors)
+	return res, nil
 }
 
 func main5() {
 	// 连接 MySQL 数据库
-	dsn := "root:123456@tcp(127.0.0.1:3306)/dbname?parseTime=true&parseTime=true&loc=Asia%2FShanghai"
+	dsn := localDSN("dbname").FormatDSN()
 	db, err := sql.Open("mysql", dsn)
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
@@ -104,33 +224,43 @@ func main5() {
 	}
 
 	// 每次插入1000条数据，持续插入1千万条记录
-	batchSize := 5000
-	totalOrders := 10000000 * 2
-	var orders []Order
-
-	for i := 0; i < totalOrders; i++ {
-		orders = append(orders, GenerateRandomOrder())
-
-		// 批量插入
-		if len(orders) == batchSize {
-			err := InsertOrdersInBatch(db, orders)
-			if err != nil {
-				log.Fatal("Failed to insert batch:", err)
-			}
-
-			// 清空当前批次
-			orders = nil
-		}
-		fmt.Printf("Inserted batch %d\n", i+1)
+	// 崩溃后重新运行从断点继续，不会从头再插一遍
+	runner := &BatchRunner{BatchSize: 5000, Total: 10000000 * 2, OnProgress: PrintProgress, Checkpoint: "order2s.checkpoint"}
+	// 重试同一批次时按订单号去重，唯一索引兜底过滤器之外的重复
+	if err := EnsureOrderNumberUnique(context.Background(), db); err != nil {
+		log.Fatal("Failed to add unique index on order_number:", err)
 	}
+	inserter := NewDedupInserter(db, uint(runner.Total), 0.001)
 
-	// 如果还有剩余未插入的数据
-	if len(orders) > 0 {
-		err := InsertOrdersInBatch(db, orders)
-		if err != nil {
-			log.Fatal("Failed to insert remaining orders:", err)
+	err = runner.Run(context.Background(), func(ctx context.Context, _, n int) error {
+		orders := make([]Order, 0, n)
+		for i := 0; i < n; i++ {
+			orders = append(orders, GenerateRandomOrder())
 		}
+		// 失败时重试同一批订单，而不是重新生成：上次插入可能已生效，相同订单号才能被去重
+		var err error
+		for attempt := 1; attempt <= 3; attempt++ {
+			if err = inserter.Insert(orders); err == nil {
+				return nil
+			}
+			log.Printf("insert batch attempt %d: %v", attempt, err)
+			if attempt == 3 {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(attempt) * time.Second):
+			}
+		}
+		return err
+	})
+	if err != nil {
+		log.Fatal("Failed to insert orders:", err)
 	}
+	stats := inserter.Stats()
+	fmt.Printf("inserted=%d skipped=%d false_positives=%d estimated_fp=%.6f\n",
+		stats.Inserted, stats.Skipped, stats.FalsePositives, inserter.EstimatedFalsePositiveRate())
 
 	fmt.Println("Inserted all orders successfully!")
 }
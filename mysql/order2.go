@@ -1,11 +1,12 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"github.com/google/uuid"
 	"log"
 	"math/rand"
+	"strings"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -13,7 +14,7 @@ import (
 
 func main3() {
 	// 连接到 MySQL 数据库
-	dsn := "root:123456@tcp(127.0.0.1:3306)/dbname?parseTime=true&parseTime=true&loc=Asia%2FShanghai"
+	dsn := localDSN("dbname").FormatDSN()
 	db, err := sql.Open("mysql", dsn)
 	db.SetConnMaxLifetime(time.Hour * 4) // 允许连接存活的最大时间
 	db.SetMaxOpenConns(20)               // 最大打开连接数
@@ -31,16 +32,23 @@ func main3() {
 	}
 
 	// 准备批量插入
-	batchSize := 5000        // 每批次插入的数据量
-	totalRecords := 20000000 // 需要插入的总数据量
-	for i := 0; i < totalRecords/batchSize; i++ {
+	runner := &BatchRunner{
+		BatchSize:  5000,     // 每批次插入的数据量
+		Total:      20000000, // 需要插入的总数据量
+		OnProgress: PrintProgress,
+	}
+	// 除最后一批外每批的行数相同，SQL 也相同，复用同一条预编译语句
+	stmts := NewStmtCache(db, 2)
+	defer stmts.Close()
+	// 需要可读、可排序的订单号时换成 NewDatePrefixedSequence(nil)，并先调用 SeedFrom(ctx, db, "order2s")，
+	// 否则当天重启后序号从 1 开始，与已写入的订单号重复
+	var numbers OrderNumberGenerator = UUIDOrderNumber{}
+	err = runner.Run(context.Background(), func(ctx context.Context, _, n int) error {
 		//time.Sleep(100 * time.Millisecond)
-		// 构建批量插入的 SQL 语句
-		sqlStr := "INSERT INTO order3s (order_number, customer_id, order_date, status, total_amount, shipping_address, shipping_cost, payment_method, discount_code, tax_amount, items_count, delivery_date, notes) VALUES "
 		vals := []interface{}{}
 
-		for j := 0; j < batchSize; j++ {
-			orderNumber := uuid.New()
+		for j := 0; j < n; j++ {
+			orderNumber := numbers.NextOrderNumber()
 			customerID := rand.Int63n(1000000)
 			orderDate := time.Now().AddDate(0, 0, -rand.Intn(1000)).Format("2006-01-02 15:04:05")
 			status := "PENDING"
@@ -54,27 +62,26 @@ func main3() {
 			deliveryDate := time.Now().AddDate(0, 0, rand.Intn(30)).Format("2006-01-02 15:04:05")
 			notes := "Some notes about the order"
 
-			sqlStr += "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?),"
 			vals = append(vals, orderNumber, customerID, orderDate, status, totalAmount, shippingAddress, shippingCost, paymentMethod, discountCode, taxAmount, itemsCount, deliveryDate, notes)
 		}
 
-		// 去掉最后的逗号
-		sqlStr = sqlStr[0 : len(sqlStr)-1]
-
 		// 执行批量插入
-		stmt, err := db.Prepare(sqlStr)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		_, err = stmt.Exec(vals...)
+		stmt, err := stmts.Prepare(ctx, order3InsertSQL(n))
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
-		stmt.Close()
-
-		fmt.Printf("Inserted batch %d\n", i+1)
+		_, err = stmt.ExecContext(ctx, vals...)
+		return err
+	})
+	if err != nil {
+		log.Fatal(err)
 	}
 
 	fmt.Println("All records inserted successfully!")
 }
+
+// order3InsertSQL 构建插入 n 行的批量 INSERT 语句
+func order3InsertSQL(n int) string {
+	return "INSERT INTO order3s (order_number, customer_id, order_date, status, total_amount, shipping_address, shipping_cost, payment_method, discount_code, tax_amount, items_count, delivery_date, notes) VALUES " +
+		strings.TrimSuffix(strings.Repeat("(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?),", n), ",")
+}
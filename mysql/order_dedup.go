@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/bits-and-blooms/bloom/v3"
+	"github.com/go-sql-driver/mysql"
+)
+
+// DedupStats 去重插入统计
+type DedupStats struct {
+	Inserted       int // 实际插入的订单数
+	Skipped        int // 数据库中已存在、被跳过的订单数
+	FalsePositives int // 布隆过滤器判定可能重复、但数据库确认不存在的订单数
+}
+
+// DedupInserter 在 InsertOrdersInBatch 之前按 order_number 去重，防止重试时重复写入同一笔订单。
+// 本次运行中插入过的订单号记录在布隆过滤器里：
+// 过滤器判定不存在的一定是新订单，直接插入；判定可能存在的再到数据库按 order_number（唯一键）确认，
+// 因此误判只会多一次查询，不会漏插。过滤器只覆盖本进程插入过的订单，之前运行写入的数据依赖
+// order_number 上的唯一索引（见 EnsureOrderNumberUnique）：插入报 1062 时到库里确认后跳过已存在的订单。
+type DedupInserter struct {
+	db     *sql.DB
+	filter *bloom.BloomFilter
+	added  uint
+	stats  DedupStats
+}
+
+// orderMigrations order2s 的表结构迁移；已有重复订单号时加唯一索引会失败，需要先清理
+var orderMigrations = []Migration{
+	{Name: "0001_order2s_unique_order_number", SQL: "ALTER TABLE order2s ADD UNIQUE KEY uk_order_number (order_number)"},
+}
+
+// EnsureOrderNumberUnique 给 order2s.order_number 加唯一索引，DedupInserter 依赖它拦住过滤器之外的重复
+func EnsureOrderNumberUnique(ctx context.Context, db *sql.DB) error {
+	_, err := NewMigrator(db, orderMigrations...).Migrate(ctx)
+	return err
+}
+
+// mysqlErrDupEntry ER_DUP_ENTRY：唯一键冲突
+const mysqlErrDupEntry = 1062
+
+func isDuplicateEntry(err error) bool {
+	var myErr *mysql.MySQLError
+	return errors.As(err, &myErr) && myErr.Number == mysqlErrDupEntry
+}
+
+// NewDedupInserter 按预计订单数 n 和误判率 fp 创建过滤器
+func NewDedupInserter(db *sql.DB, n uint, fp float64) *DedupInserter {
+	return &DedupInserter{db: db, filter: bloom.NewWithEstimates(n, fp)}
+}
+
+// Insert 过滤掉已插入过的订单后批量插入剩余订单，同一批次内重复的订单号只保留第一条
+func (d *DedupInserter) Insert(orders []Order) error {
+	var fresh, maybe []Order
+	seen := make(map[string]bool, len(orders))
+	for _, o := range orders {
+		if seen[o.OrderNumber] {
+			d.stats.Skipped++
+			continue
+		}
+		seen[o.OrderNumber] = true
+		if d.filter.TestString(o.OrderNumber) {
+			maybe = append(maybe, o)
+		} else {
+			fresh = append(fresh, o)
+		}
+	}
+
+	if len(maybe) > 0 {
+		existing, err := d.existingOrderNumbers(maybe)
+		if err != nil {
+			return err
+		}
+		for _, o := range maybe {
+			if existing[o.OrderNumber] {
+				d.stats.Skipped++
+				continue
+			}
+			d.stats.FalsePositives++
+			fresh = append(fresh, o)
+		}
+	}
+
+	if len(fresh) == 0 {
+		return nil
+	}
+	err := InsertOrdersInBatch(d.db, fresh)
+	if isDuplicateEntry(err) {
+		// 过滤器之外已存在的订单（之前的运行写入，或上次插入已生效但没收到响应）。
+		// 多行 INSERT 是单条语句，冲突时整批都没有写入，去掉已存在的订单后重插一次
+		if fresh, err = d.skipExisting(fresh); err == nil && len(fresh) > 0 {
+			err = InsertOrdersInBatch(d.db, fresh)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	// 插入成功后才加入过滤器，失败重试时不会被误认为已插入
+	for _, o := range fresh {
+		d.filter.AddString(o.OrderNumber)
+		d.added++
+	}
+	d.stats.Inserted += len(fresh)
+	return nil
+}
+
+// skipExisting 去掉数据库中已存在的订单
+func (d *DedupInserter) skipExisting(orders []Order) ([]Order, error) {
+	existing, err := d.existingOrderNumbers(orders)
+	if err != nil {
+		return nil, err
+	}
+	remaining := orders[:0:0]
+	for _, o := range orders {
+		if existing[o.OrderNumber] {
+			d.stats.Skipped++
+			continue
+		}
+		remaining = append(remaining, o)
+	}
+	return remaining, nil
+}
+
+// existingOrderNumbers 查询 orders 中哪些订单号已经在表里
+func (d *DedupInserter) existingOrderNumbers(orders []Order) (map[string]bool, error) {
+	args := make([]interface{}, len(orders))
+	for i, o := range orders {
+		args[i] = o.OrderNumber
+	}
+	query := "SELECT order_number FROM order2s WHERE order_number IN (?" + strings.Repeat(", ?", len(orders)-1) + ")"
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("check existing orders: %w", err)
+	}
+	defer rows.Close()
+
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var number string
+		if err := rows.Scan(&number); err != nil {
+			return nil, fmt.Errorf("check existing orders: %w", err)
+		}
+		existing[number] = true
+	}
+	return existing, rows.Err()
+}
+
+func (d *DedupInserter) Stats() DedupStats {
+	return d.stats
+}
+
+// EstimatedFalsePositiveRate 按当前已加入的元素个数估算误判率：(1 - e^(-k*n/m))^k
+func (d *DedupInserter) EstimatedFalsePositiveRate() float64 {
+	m, k, n := float64(d.filter.Cap()), float64(d.filter.K()), float64(d.added)
+	return math.Pow(1-math.Exp(-k*n/m), k)
+}
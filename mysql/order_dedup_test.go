@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestDedupInserterReplay(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	batch := []Order{GenerateRandomOrder(), GenerateRandomOrder(), GenerateRandomOrder()}
+	d := NewDedupInserter(db, 1000, 0.01)
+
+	// 第一次：过滤器全部判定为新订单，直接插入，不查库
+	mock.ExpectExec("INSERT INTO order2s").WillReturnResult(sqlmock.NewResult(0, 3))
+	if err := d.Insert(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重放：全部命中过滤器，到库里确认已存在后跳过，不再插入
+	rows := sqlmock.NewRows([]string{"order_number"})
+	for _, o := range batch {
+		rows.AddRow(o.OrderNumber)
+	}
+	mock.ExpectQuery("SELECT order_number FROM order2s WHERE order_number IN").
+		WithArgs(batch[0].OrderNumber, batch[1].OrderNumber, batch[2].OrderNumber).
+		WillReturnRows(rows)
+	if err := d.Insert(append(batch, batch[0])); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := d.Stats(); got != (DedupStats{Inserted: 3, Skipped: 4}) {
+		t.Fatalf("stats = %+v", got)
+	}
+	if rate := d.EstimatedFalsePositiveRate(); rate <= 0 || rate > 0.01 {
+		t.Fatalf("estimated fp rate = %v", rate)
+	}
+}
+
+func TestDedupInserterFalsePositiveStillInserted(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	d := NewDedupInserter(db, 1000, 0.01)
+	o := GenerateRandomOrder()
+	// 模拟误判：过滤器里有这个订单号，但数据库里没有
+	d.filter.AddString(o.OrderNumber)
+
+	mock.ExpectQuery("SELECT order_number FROM order2s").WillReturnRows(sqlmock.NewRows([]string{"order_number"}))
+	mock.ExpectExec("INSERT INTO order2s").WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := d.Insert([]Order{o}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	if got := d.Stats(); got != (DedupStats{Inserted: 1, FalsePositives: 1}) {
+		t.Fatalf("stats = %+v", got)
+	}
+}
+
+func TestDedupInserterSkipsDuplicateEntry(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// 过滤器没见过，但上一次运行已经写入了 batch[0]
+	batch := []Order{GenerateRandomOrder(), GenerateRandomOrder()}
+	d := NewDedupInserter(db, 1000, 0.01)
+
+	mock.ExpectExec("INSERT INTO order2s").WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"})
+	mock.ExpectQuery("SELECT order_number FROM order2s WHERE order_number IN").
+		WithArgs(batch[0].OrderNumber, batch[1].OrderNumber).
+		WillReturnRows(sqlmock.NewRows([]string{"order_number"}).AddRow(batch[0].OrderNumber))
+	mock.ExpectExec("INSERT INTO order2s").WithArgs(anyArgs(13)...).WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := d.Insert(batch); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	if got := d.Stats(); got != (DedupStats{Inserted: 1, Skipped: 1}) {
+		t.Fatalf("stats = %+v", got)
+	}
+}
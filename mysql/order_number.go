@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OrderNumberGenerator 订单号生成器，实现必须并发安全
+type OrderNumberGenerator interface {
+	NextOrderNumber() string
+}
+
+// UUIDOrderNumber 默认的随机 UUID 订单号，全局唯一但不可读、不可排序
+type UUIDOrderNumber struct{}
+
+func (UUIDOrderNumber) NextOrderNumber() string { return uuid.New().String() }
+
+// maxDailySequence YYYYMMDD-NNNNNN 中序号部分的最大值
+const maxDailySequence = 999999
+
+// DatePrefixedSequence 生成 YYYYMMDD-NNNNNN 格式的订单号，按字符串排序即按生成顺序排序。
+// 日期和当天序号打包在一个 uint64 里用 CAS 更新，跨天时序号从 1 重新开始。
+// 时钟回拨时沿用已发出的日期继续递增；当天序号用尽时借用下一天的日期，
+// 两种情况都保证唯一且有序，代价是订单号上的日期可能早于或晚于实际日期。
+// 序号只在进程内递增：同一天内重启后会从 000001 重新开始，与已写入的订单号冲突，
+// 启动时要先调用 SeedFrom 从表中已有的最大订单号接着发号。
+// 多进程同时发号时 SeedFrom 也无法避免冲突，需要各自的前缀或改用数据库序列
+type DatePrefixedSequence struct {
+	now   func() time.Time
+	state atomic.Uint64 // 高 32 位为 Unix 纪元起的天数，低 32 位为当天已发出的最大序号
+}
+
+// NewDatePrefixedSequence now 为 nil 时使用 time.Now，日期按 now 返回时间的时区计算
+func NewDatePrefixedSequence(now func() time.Time) *DatePrefixedSequence {
+	if now == nil {
+		now = time.Now
+	}
+	return &DatePrefixedSequence{now: now}
+}
+
+func (s *DatePrefixedSequence) NextOrderNumber() string {
+	y, m, d := s.now().Date()
+	today := uint64(time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / 86400)
+	for {
+		old := s.state.Load()
+		day, seq := old>>32, old&0xffffffff
+		switch {
+		case today > day:
+			day, seq = today, 1
+		case seq >= maxDailySequence:
+			day, seq = day+1, 1
+		default:
+			seq++
+		}
+		if s.state.CompareAndSwap(old, day<<32|seq) {
+			return fmt.Sprintf("%s-%06d", time.Unix(int64(day)*86400, 0).UTC().Format("20060102"), seq)
+		}
+	}
+}
+
+// SeedFrom 从 table 的 order_number 列中取今天及之后（借用的日期）最大的订单号，
+// 之后的订单号从它的下一个开始；表中没有这种格式的订单号时不变
+func (s *DatePrefixedSequence) SeedFrom(ctx context.Context, db *sql.DB, table string) error {
+	prefix := s.now().Format("20060102") + "-"
+	var max sql.NullString
+	err := db.QueryRowContext(ctx,
+		"SELECT MAX(order_number) FROM "+table+" WHERE order_number >= ? AND order_number LIKE '________-______'",
+		prefix).Scan(&max)
+	if err != nil {
+		return fmt.Errorf("seed order number sequence: %w", err)
+	}
+	if !max.Valid {
+		return nil
+	}
+	date, num, ok := strings.Cut(max.String, "-")
+	day, err := time.Parse("20060102", date)
+	seq, serr := strconv.ParseUint(num, 10, 32)
+	if !ok || err != nil || serr != nil {
+		return fmt.Errorf("seed order number sequence: unexpected order number %q", max.String)
+	}
+	seeded := uint64(day.Unix()/86400)<<32 | seq
+	for {
+		old := s.state.Load()
+		if old >= seeded || s.state.CompareAndSwap(old, seeded) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestDatePrefixedSequenceAcrossDayBoundary(t *testing.T) {
+	var now atomic.Pointer[time.Time]
+	set := func(s string) {
+		tm, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		now.Store(&tm)
+	}
+	seq := NewDatePrefixedSequence(func() time.Time { return *now.Load() })
+
+	set("2024-12-31T23:59:59Z")
+	var got []string
+	for i := 0; i < 3; i++ {
+		got = append(got, seq.NextOrderNumber())
+	}
+	set("2025-01-01T00:00:00Z")
+	// 跨天后并发取号，序号从 1 重新开始且不重复
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				n := seq.NextOrderNumber()
+				mu.Lock()
+				got = append(got, n)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	// 时钟回拨仍沿用新的一天
+	set("2024-12-31T23:59:58Z")
+	got = append(got, seq.NextOrderNumber())
+
+	if got[0] != "20241231-000001" || got[2] != "20241231-000003" {
+		t.Fatalf("first day = %v", got[:3])
+	}
+	if last := got[len(got)-1]; last != "20250101-000801" {
+		t.Fatalf("after clock went back = %s, want 20250101-000801", last)
+	}
+	seen := make(map[string]bool, len(got))
+	for _, n := range got {
+		if seen[n] {
+			t.Fatalf("duplicate order number %s", n)
+		}
+		seen[n] = true
+	}
+	sorted := append([]string(nil), got...)
+	sort.Strings(sorted)
+	if sorted[0] != got[0] || sorted[3] != "20250101-000001" || sorted[len(sorted)-1] != got[len(got)-1] {
+		t.Fatalf("order numbers do not sort in generation order: %v ... %v", sorted[:4], sorted[len(sorted)-1])
+	}
+}
+
+func TestDatePrefixedSequenceBorrowsNextDayWhenExhausted(t *testing.T) {
+	day := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	seq := NewDatePrefixedSequence(func() time.Time { return day })
+	seq.state.Store(uint64(day.Unix()/86400)<<32 | maxDailySequence - 1)
+	if n := seq.NextOrderNumber(); n != "20250101-999999" {
+		t.Fatalf("got %s", n)
+	}
+	if n := seq.NextOrderNumber(); n != "20250102-000001" {
+		t.Fatalf("got %s, want next day's prefix", n)
+	}
+}
+
+func TestDatePrefixedSequenceSeedFrom(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// 当天重启：接着表中已有的最大订单号发号
+	day := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	seq := NewDatePrefixedSequence(func() time.Time { return day })
+	mock.ExpectQuery("SELECT MAX\\(order_number\\) FROM order2s WHERE order_number >= \\?").WithArgs("20250101-").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow("20250101-000042"))
+	if err := seq.SeedFrom(context.Background(), db, "order2s"); err != nil {
+		t.Fatal(err)
+	}
+	if n := seq.NextOrderNumber(); n != "20250101-000043" {
+		t.Fatalf("got %s, want 20250101-000043", n)
+	}
+
+	// 表中没有今天的订单号时不变
+	mock.ExpectQuery("SELECT MAX").WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(nil))
+	if err := seq.SeedFrom(context.Background(), db, "order2s"); err != nil {
+		t.Fatal(err)
+	}
+	if n := seq.NextOrderNumber(); n != "20250101-000044" {
+		t.Fatalf("got %s, want 20250101-000044", n)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGenerateRandomOrderWith(t *testing.T) {
+	seq := NewDatePrefixedSequence(func() time.Time { return time.Date(2025, 3, 9, 8, 0, 0, 0, time.Local) })
+	if o := GenerateRandomOrderWith(seq); o.OrderNumber != "20250309-000001" {
+		t.Fatalf("order number = %s", o.OrderNumber)
+	}
+	if o := GenerateRandomOrder(); len(o.OrderNumber) != 36 {
+		t.Fatalf("default order number %q is not a UUID", o.OrderNumber)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestInsertOrdersDryRun(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	orders := []Order{GenerateRandomOrder(), GenerateRandomOrder(), GenerateRandomOrder()}
+	orders[1].Notes = strings.Repeat("x", maxTextBytes+1)
+
+	// 只预编译，不执行：没有 ExpectExec，任何插入都会让测试失败
+	mock.ExpectPrepare("INSERT INTO order2s").WillBeClosed()
+	res, err := InsertOrders(db, orders, BatchInsertOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Rows != 2 {
+		t.Fatalf("rows = %d, want 2", res.Rows)
+	}
+	if len(res.Errors) != 1 || !errors.Is(res.Errors[0], ErrInvalidOrder) ||
+		!strings.Contains(res.Errors[0].Error(), "row 1") || !strings.Contains(res.Errors[0].Error(), "notes") {
+		t.Fatalf("errors = %v, want one notes error on row 1", res.Errors)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInsertOrdersRejectsInvalidBatch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	bad := GenerateRandomOrder()
+	bad.Notes = strings.Repeat("x", maxTextBytes+1)
+	if err := InsertOrdersInBatch(db, []Order{GenerateRandomOrder(), bad}); !errors.Is(err, ErrInvalidOrder) {
+		t.Fatalf("err = %v, want ErrInvalidOrder", err)
+	}
+
+	// 合法批次按参数绑定执行，每行 13 个参数
+	mock.ExpectExec("INSERT INTO order2s").WithArgs(anyArgs(26)...).WillReturnResult(sqlmock.NewResult(0, 2))
+	if err := InsertOrdersInBatch(db, []Order{GenerateRandomOrder(), GenerateRandomOrder()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func anyArgs(n int) []driver.Value {
+	args := make([]driver.Value, n)
+	for i := range args {
+		args[i] = sqlmock.AnyArg()
+	}
+	return args
+}
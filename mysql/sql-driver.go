@@ -10,7 +10,7 @@ import (
 )
 
 func main4() {
-	dsn := "root:123456@tcp(127.0.0.1:3306)/dbname"
+	dsn := localDSN("dbname").FormatDSN()
 	db, err := sql.Open("mysql", dsn)
 	if err != nil {
 		log.Fatal(err)
@@ -26,13 +26,17 @@ func main4() {
 	// 设置连接的最大生命周期
 	db.SetConnMaxLifetime(30 * time.Minute)
 
+	// 定期探活，MySQL 重启期间查询直接失败，不会拿到死连接
+	hdb := NewHealthCheckedDB(db, time.Second, 500)
+	defer hdb.Close()
+
 	// 示例查询
 	for i := 0; i < 200; i++ {
 		go func(i int) {
 			//ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 			//defer cancel()
 			ctx := context.Background()
-			rows, err := db.QueryContext(ctx, "SELECT SLEEP(3)")
+			rows, err := hdb.QueryContext(ctx, "SELECT SLEEP(3)")
 
 			if err != nil {
 				log.Printf("Query %d failed: %v", i, err)
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"sync"
+)
+
+// StmtCache 按 SQL 文本缓存预编译语句，同一条 SQL 只 Prepare 一次，并发安全。
+// 缓存条数超过 size 时关闭并淘汰最久未使用的语句，避免 SQL 形态很多时
+// 服务端的预编译语句数（max_prepared_stmt_count）无限增长。
+// 被淘汰语句上正在执行的调用会先完成，但之后再用它会返回 "sql: statement is closed"，
+// 所以取到的语句应立即使用，不要长期持有。
+type StmtCache struct {
+	db   *sql.DB
+	size int
+
+	mu     sync.Mutex
+	lru    *list.List // 元素为 *stmtEntry，表头最近使用
+	items  map[string]*list.Element
+	closed bool
+}
+
+type stmtEntry struct {
+	query string
+	stmt  *sql.Stmt
+}
+
+// NewStmtCache 创建最多缓存 size 条语句的缓存，size <= 0 时按 1 处理
+func NewStmtCache(db *sql.DB, size int) *StmtCache {
+	if size <= 0 {
+		size = 1
+	}
+	return &StmtCache{db: db, size: size, lru: list.New(), items: make(map[string]*list.Element)}
+}
+
+// Prepare 返回 query 对应的预编译语句，未缓存时 Prepare 并放入缓存。
+// 网络上的 Prepare 不持有锁，慢的 Prepare 不会挡住其他 SQL 的缓存命中；
+// 同一条 SQL 并发未命中时各自 Prepare，先放入缓存的胜出，其余关闭
+func (c *StmtCache) Prepare(ctx context.Context, query string) (*sql.Stmt, error) {
+	if stmt, ok, err := c.lookup(query); ok || err != nil {
+		return stmt, err
+	}
+
+	stmt, err := c.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		stmt.Close()
+		return nil, errStmtCacheClosed
+	}
+	if e, ok := c.items[query]; ok {
+		stmt.Close()
+		c.lru.MoveToFront(e)
+		return e.Value.(*stmtEntry).stmt, nil
+	}
+	c.items[query] = c.lru.PushFront(&stmtEntry{query: query, stmt: stmt})
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Remove(c.lru.Back()).(*stmtEntry)
+		delete(c.items, oldest.query)
+		// 关闭失败只影响服务端回收，不影响本次调用
+		if err := oldest.stmt.Close(); err != nil {
+			log.Printf("stmt cache: close evicted statement: %v", err)
+		}
+	}
+	return stmt, nil
+}
+
+var errStmtCacheClosed = errors.New("stmt cache is closed")
+
+// lookup 只查缓存，ok 为 false 表示未命中需要 Prepare
+func (c *StmtCache) lookup(query string) (stmt *sql.Stmt, ok bool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil, false, errStmtCacheClosed
+	}
+	if e, ok := c.items[query]; ok {
+		c.lru.MoveToFront(e)
+		return e.Value.(*stmtEntry).stmt, true, nil
+	}
+	return nil, false, nil
+}
+
+// Len 已缓存的语句数
+func (c *StmtCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// Close 关闭所有缓存的语句，之后 Prepare 返回错误
+func (c *StmtCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	var errs []error
+	for e := c.lru.Front(); e != nil; e = e.Next() {
+		errs = append(errs, e.Value.(*stmtEntry).stmt.Close())
+	}
+	c.lru.Init()
+	clear(c.items)
+	return errors.Join(errs...)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestStmtCacheReusesPreparedStatement(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	query := order3InsertSQL(2)
+	prep := mock.ExpectPrepare(query)
+	for i := 0; i < 3; i++ {
+		prep.ExpectExec().WillReturnResult(sqlmock.NewResult(0, 2))
+	}
+
+	ctx := context.Background()
+	cache := NewStmtCache(db, 2)
+	first, err := cache.Prepare(ctx, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		stmt, err := cache.Prepare(ctx, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stmt != first {
+			t.Fatalf("call %d returned a different statement", i+1)
+		}
+		if _, err := stmt.ExecContext(ctx, make([]interface{}, 26)...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cache.Len() != 1 {
+		t.Fatalf("Len = %d, want 1", cache.Len())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStmtCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	q1, q2, q3 := "SELECT 1", "SELECT 2", "SELECT 3"
+	mock.ExpectPrepare(q1)
+	mock.ExpectPrepare(q2).WillBeClosed()
+	mock.ExpectPrepare(q3)
+
+	ctx := context.Background()
+	cache := NewStmtCache(db, 2)
+	for _, q := range []string{q1, q2, q1, q3} { // 再次使用 q1 后，q2 成为最久未使用
+		if _, err := cache.Prepare(ctx, q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cache.Len() != 2 {
+		t.Fatalf("Len = %d, want 2", cache.Len())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStmtCacheSlowPrepareDoesNotBlockHits(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mock.ExpectPrepare("SELECT 1")
+	mock.ExpectPrepare("SELECT 2").WillDelayFor(300 * time.Millisecond)
+
+	ctx := context.Background()
+	cache := NewStmtCache(db, 2)
+	if _, err := cache.Prepare(ctx, "SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+	slow := make(chan error, 1)
+	go func() {
+		_, err := cache.Prepare(ctx, "SELECT 2")
+		slow <- err
+	}()
+	time.Sleep(50 * time.Millisecond) // 让慢 Prepare 先开始
+
+	start := time.Now()
+	if _, err := cache.Prepare(ctx, "SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Fatalf("cache hit took %v while another statement was being prepared", d)
+	}
+	if err := <-slow; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// StreamOption 调整 StreamQuery 的读取行为
+type StreamOption func(*streamConfig)
+
+type streamConfig struct {
+	maxRows    int  // 最多读取的行数，0 表示不限制
+	rawBytes   bool // 保留驱动返回的 []byte，不转成 string
+	checkEvery int  // 每读取多少行检查一次 ctx
+}
+
+// WithMaxRows 最多读取 n 行后停止，用于抽样导出
+func WithMaxRows(n int) StreamOption {
+	return func(c *streamConfig) { c.maxRows = n }
+}
+
+// WithRawBytes 保留 []byte 列值；默认转成 string，
+// 因为驱动复用的缓冲区在下一次 Next 后会失效，回调里保存 []byte 容易踩坑
+func WithRawBytes() StreamOption {
+	return func(c *streamConfig) { c.rawBytes = true }
+}
+
+// StreamQuery 逐行读取查询结果并回调 fn，不会把整个结果集读进内存。
+// MySQL 驱动本身就是边读网络边返回行的，内存占用只有当前行；
+// fn 返回错误时立即停止读取并返回该错误，未读完的行由 rows.Close 丢弃。
+// 注意流式读取期间连接一直被占用，fn 里不要再用同一个 db 做耗时操作。
+func StreamQuery(ctx context.Context, db *sql.DB, query string, args []interface{}, fn func(row map[string]interface{}) error, opts ...StreamOption) error {
+	cfg := streamConfig{checkEvery: 1000}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("stream query: %v", err)
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	values := make([]interface{}, len(cols))
+	ptrs := make([]interface{}, len(cols))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+
+	for n := 0; rows.Next(); n++ {
+		if cfg.maxRows > 0 && n >= cfg.maxRows {
+			break
+		}
+		if n%cfg.checkEvery == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return fmt.Errorf("stream scan row %d: %v", n, err)
+		}
+		row := make(map[string]interface{}, len(cols))
+		for i, col := range cols {
+			if b, ok := values[i].([]byte); ok && !cfg.rawBytes {
+				row[col] = string(b)
+			} else {
+				row[col] = values[i]
+			}
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// QuerySelect 逐行把查询结果扫描进调用方提供的 dest，每扫描一行回调一次 fn。
+// dest 在所有行之间复用，fn 里读取或拷贝当前行的值即可，不再为每行分配 map，适合热点路径；
+// dest 的个数必须与查询的列数一致，否则不读取任何行直接返回错误。
+// fn 返回错误时立即停止读取并返回该错误。
+func QuerySelect(ctx context.Context, db *sql.DB, query string, args []interface{}, fn func() error, dest ...interface{}) error {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("query select: %v", err)
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	if len(cols) != len(dest) {
+		return fmt.Errorf("query select: query returns %d columns %v but %d destinations given", len(cols), cols, len(dest))
+	}
+
+	for n := 0; rows.Next(); n++ {
+		if err := rows.Scan(dest...); err != nil {
+			return fmt.Errorf("query select scan row %d: %v", n, err)
+		}
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestStreamQuery(t *testing.T) {
+	stop := errors.New("stop")
+	tests := []struct {
+		name    string
+		opts    []StreamOption
+		stopAt  int // 回调在第几行返回错误，0 表示不返回
+		wantErr error
+		want    int
+	}{
+		{name: "all rows", want: 5},
+		{name: "early exit", stopAt: 2, wantErr: stop, want: 2},
+		{name: "max rows", opts: []StreamOption{WithMaxRows(3)}, want: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			rows := sqlmock.NewRows([]string{"id", "order_number"})
+			for i := 1; i <= 5; i++ {
+				rows.AddRow(int64(i), []byte("NO-"+string(rune('0'+i))))
+			}
+			mock.ExpectQuery("SELECT id, order_number FROM order2s WHERE id > ?").WithArgs(0).WillReturnRows(rows)
+
+			var seen []map[string]interface{}
+			err = StreamQuery(context.Background(), db, "SELECT id, order_number FROM order2s WHERE id > ?", []interface{}{0},
+				func(row map[string]interface{}) error {
+					seen = append(seen, row)
+					if len(seen) == tt.stopAt {
+						return stop
+					}
+					return nil
+				}, tt.opts...)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if len(seen) != tt.want {
+				t.Fatalf("callback invoked %d times, want %d", len(seen), tt.want)
+			}
+			if got := seen[0]["order_number"]; got != "NO-1" {
+				t.Fatalf("order_number = %#v, want string NO-1", got)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestQuerySelectBindsStructFields(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mock.ExpectQuery("SELECT id, order_number, amount FROM order2s").WithArgs(0).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "order_number", "amount"}).
+			AddRow(int64(1), []byte("NO-1"), 9.5).
+			AddRow(int64(2), []byte("NO-2"), 20.0))
+
+	type order struct {
+		ID     int64
+		Number string
+		Amount float64
+	}
+	var o order
+	var got []order
+	err = QuerySelect(context.Background(), db, "SELECT id, order_number, amount FROM order2s WHERE id > ?", []interface{}{0},
+		func() error {
+			got = append(got, o)
+			return nil
+		}, &o.ID, &o.Number, &o.Amount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []order{{1, "NO-1", 9.5}, {2, "NO-2", 20}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("rows = %+v, want %+v", got, want)
+	}
+
+	// 目标个数与列数不一致时不回调，错误里带上列名
+	mock.ExpectQuery("SELECT id, order_number, amount FROM order2s").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "order_number", "amount"}).AddRow(int64(1), []byte("NO-1"), 9.5))
+	called := false
+	err = QuerySelect(context.Background(), db, "SELECT id, order_number, amount FROM order2s", nil,
+		func() error { called = true; return nil }, &o.ID, &o.Number)
+	if err == nil || called || !strings.Contains(err.Error(), "3 columns") || !strings.Contains(err.Error(), "2 destinations") {
+		t.Fatalf("err = %v, called = %v; want column count mismatch", err, called)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mysqlTimeLayout DATETIME/TIMESTAMP 的文本格式，小数秒可选
+const mysqlTimeLayout = "2006-01-02 15:04:05.999999"
+
+// TypedScanner 按 rows.ColumnTypes() 把列值解码成 Go 类型：
+// DATETIME/TIMESTAMP/DATE → time.Time，整数 → int64（UNSIGNED 超过 MaxInt64 时为 uint64），
+// DECIMAL → string（保留精度），其他 → string，NULL → nil。
+// DSN 带 parseTime=true 时驱动已经返回 time.Time，直接透传；0000-00-00 零值日期与驱动一致解码为 time.Time{}。
+type TypedScanner struct {
+	rows  *sql.Rows
+	cols  []string
+	types []string
+	loc   *time.Location // 解析文本时间使用的时区，与 DSN 的 loc 保持一致
+	vals  []interface{}
+	ptrs  []interface{}
+}
+
+func NewTypedScanner(rows *sql.Rows, loc *time.Location) (*TypedScanner, error) {
+	cts, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+	s := &TypedScanner{rows: rows, loc: loc, vals: make([]interface{}, len(cts)), ptrs: make([]interface{}, len(cts))}
+	for i, ct := range cts {
+		s.cols = append(s.cols, ct.Name())
+		s.types = append(s.types, strings.ToUpper(ct.DatabaseTypeName()))
+		s.ptrs[i] = &s.vals[i]
+	}
+	return s, nil
+}
+
+// Scan 解码当前行，调用前需要先 rows.Next()
+func (s *TypedScanner) Scan() (map[string]interface{}, error) {
+	if err := s.rows.Scan(s.ptrs...); err != nil {
+		return nil, err
+	}
+	row := make(map[string]interface{}, len(s.cols))
+	for i, col := range s.cols {
+		v, err := s.decode(s.types[i], s.vals[i])
+		if err != nil {
+			return nil, fmt.Errorf("column %s (%s): %v", col, s.types[i], err)
+		}
+		row[col] = v
+	}
+	return row, nil
+}
+
+func (s *TypedScanner) decode(typ string, v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	switch {
+	case typ == "DATETIME" || typ == "TIMESTAMP" || typ == "DATE":
+		switch t := v.(type) {
+		case time.Time:
+			return t, nil
+		case []byte:
+			return parseMySQLTime(string(t), s.loc)
+		case string:
+			return parseMySQLTime(t, s.loc)
+		}
+	case strings.HasSuffix(typ, "INT"): // TINYINT ... BIGINT，包括 UNSIGNED
+		switch n := v.(type) {
+		case int64:
+			return n, nil
+		case uint64:
+			return unsignedValue(n), nil
+		case []byte:
+			return parseMySQLInt(string(n), typ)
+		case string:
+			return parseMySQLInt(n, typ)
+		}
+	}
+	// DECIMAL 和其他类型按文本返回
+	switch t := v.(type) {
+	case []byte:
+		return string(t), nil
+	case string:
+		return t, nil
+	}
+	return fmt.Sprint(v), nil
+}
+
+// parseMySQLInt 有符号列解析为 int64，UNSIGNED 列按 uint64 解析，放得下 int64 时仍返回 int64
+func parseMySQLInt(s, typ string) (interface{}, error) {
+	if !strings.Contains(typ, "UNSIGNED") {
+		return strconv.ParseInt(s, 10, 64)
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return unsignedValue(n), nil
+}
+
+func unsignedValue(n uint64) interface{} {
+	if n > math.MaxInt64 {
+		return n
+	}
+	return int64(n)
+}
+
+func parseMySQLTime(s string, loc *time.Location) (time.Time, error) {
+	// NO_ZERO_DATE 关闭时 MySQL 允许 0000-00-00，time.Time 无法表示，解码为零值
+	if strings.HasPrefix(s, "0000-00-00") {
+		return time.Time{}, nil
+	}
+	if len(s) == len("2006-01-02") {
+		return time.ParseInLocation("2006-01-02", s, loc)
+	}
+	return time.ParseInLocation(mysqlTimeLayout, s, loc)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestTypedScanner(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rows := mock.NewRowsWithColumnDefinition(
+		mock.NewColumn("id").OfType("BIGINT", int64(0)),
+		mock.NewColumn("created_at").OfType("DATETIME", []byte(nil)),
+		mock.NewColumn("paid_at").OfType("TIMESTAMP", []byte(nil)).Nullable(true),
+		mock.NewColumn("amount").OfType("DECIMAL", []byte(nil)),
+		mock.NewColumn("status").OfType("VARCHAR", []byte(nil)),
+	).
+		AddRow(int64(7), []byte("2024-05-01 12:30:45.123"), nil, []byte("12345678901234567.89"), []byte("PAID")).
+		AddRow([]byte("8"), time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC), []byte("2024-05-02 08:00:00"), []byte("0.10"), nil)
+	mock.ExpectQuery("SELECT").WillReturnRows(rows)
+
+	rs, err := db.Query("SELECT id, created_at, paid_at, amount, status FROM orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Close()
+	scanner, err := NewTypedScanner(rs, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []map[string]interface{}
+	for rs.Next() {
+		row, err := scanner.Scan()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, row)
+	}
+	if err := rs.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []map[string]interface{}{
+		{"id": int64(7), "created_at": time.Date(2024, 5, 1, 12, 30, 45, 123e6, time.UTC), "paid_at": nil, "amount": "12345678901234567.89", "status": "PAID"},
+		{"id": int64(8), "created_at": time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC), "paid_at": time.Date(2024, 5, 2, 8, 0, 0, 0, time.UTC), "amount": "0.10", "status": nil},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d rows, want %d", len(got), len(want))
+	}
+	for i := range want {
+		for col, w := range want[i] {
+			g := got[i][col]
+			if wt, ok := w.(time.Time); ok {
+				if gt, ok := g.(time.Time); !ok || !gt.Equal(wt) {
+					t.Fatalf("row %d %s = %#v, want %v", i, col, g, wt)
+				}
+				continue
+			}
+			if g != w {
+				t.Fatalf("row %d %s = %#v (%T), want %#v (%T)", i, col, g, g, w, w)
+			}
+		}
+	}
+}
+
+func TestTypedScannerUnsignedAndZeroDate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rows := mock.NewRowsWithColumnDefinition(
+		mock.NewColumn("id").OfType("UNSIGNED BIGINT", []byte(nil)),
+		mock.NewColumn("seq").OfType("UNSIGNED BIGINT", uint64(0)),
+		mock.NewColumn("shipped_at").OfType("DATETIME", []byte(nil)),
+		mock.NewColumn("birthday").OfType("DATE", []byte(nil)),
+	).
+		AddRow([]byte("18446744073709551615"), uint64(42), []byte("0000-00-00 00:00:00"), []byte("0000-00-00"))
+	mock.ExpectQuery("SELECT").WillReturnRows(rows)
+
+	rs, err := db.Query("SELECT id, seq, shipped_at, birthday FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Close()
+	scanner, err := NewTypedScanner(rs, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rs.Next() {
+		t.Fatal(rs.Err())
+	}
+	row, err := scanner.Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row["id"] != uint64(math.MaxUint64) || row["seq"] != int64(42) {
+		t.Fatalf("id = %#v seq = %#v, want MaxUint64 and int64(42)", row["id"], row["seq"])
+	}
+	for _, col := range []string{"shipped_at", "birthday"} {
+		if tm, ok := row[col].(time.Time); !ok || !tm.IsZero() {
+			t.Fatalf("%s = %#v, want zero time", col, row[col])
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
@@ -16,9 +17,10 @@ var (
 	err       error
 )
 
-func init() {
+// initDB 连接数据库，由 main 调用，避免包初始化（包括 go test）时就去连接数据库
+func initDB() {
 	// 连接到 MySQL 数据库
-	dsn := "root:123456@tcp(127.0.0.1:3306)/wcs_core?parseTime=true&parseTime=true&loc=Asia%2FShanghai"
+	dsn := localDSN("wcs_core").FormatDSN()
 	db, err = sql.Open("mysql", dsn)
 	db.SetConnMaxLifetime(time.Hour * 4) // 允许连接存活的最大时间
 	db.SetMaxOpenConns(20)               // 最大打开连接数
@@ -35,8 +37,8 @@ func init() {
 	}
 }
 
-func CreateTable() {
-	query := `CREATE TABLE IF NOT EXISTS ` + tableName + ` (
+// createWorkerQualitySessions 质检会话表 DDL，作为第一个迁移
+var createWorkerQualitySessions = `CREATE TABLE IF NOT EXISTS ` + tableName + ` (
   id bigint NOT NULL AUTO_INCREMENT,
   binding_session_id bigint NOT NULL DEFAULT '0' COMMENT '绑定会话记录id->worker_binding_relationship_log.id',
   tenant_id smallint NOT NULL DEFAULT '0' COMMENT '商户id',
@@ -68,26 +70,36 @@ func CreateTable() {
   KEY idx_binding_session_id (binding_session_id)
 ) ENGINE=InnoDB AUTO_INCREMENT=3369 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin COMMENT='推送的客服质检会话表';`
 
-	_, err = db.Exec(query)
+// migrations 表结构迁移，按顺序执行；已发布的迁移不要修改，新的变更追加在末尾
+var migrations = []Migration{
+	{Name: "0001_create_worker_quality_sessions", SQL: createWorkerQualitySessions},
+}
+
+func CreateTable() {
+	applied, err := NewMigrator(db, migrations...).Migrate(context.Background())
 	if err != nil {
 		log.Fatal(err)
 	}
+	fmt.Printf("Applied migrations: %v\n", applied)
 }
 
 func main() {
+	initDB()
 	//defer db.Close()
 	CreateTable()
 
 	// 批量插入 500 万条数据，分批处理
-	batchSize := 2000 // 每次插入 1000 条记录
-	totalRows := 5000000
-
-	for i := 0; i < totalRows/batchSize; i++ {
+	runner := &BatchRunner{
+		BatchSize:  2000, // 每次插入 1000 条记录
+		Total:      5000000,
+		OnProgress: PrintProgress,
+	}
+	err := runner.Run(context.Background(), func(ctx context.Context, _, n int) error {
 		query := "INSERT INTO " + tableName + " (binding_session_id, tenant_id, consult_id, worker_id, uid, user_role, user_level, check_type, first_send_time, last_reply_time, last_end_time, service_duration, client_send_message_count, worker_send_message_count, read_duration, score_worker_id, score_type, score_time, review_worker_id, review_score_type, review_time, created_at, group_max_score_time) VALUES "
 
-		params := make([]interface{}, 0, batchSize*23)
+		params := make([]interface{}, 0, n*23)
 
-		for j := 0; j < batchSize; j++ {
+		for j := 0; j < n; j++ {
 			query += "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?),"
 
 			params = append(params,
@@ -119,12 +131,10 @@ func main() {
 		query = query[:len(query)-1] // 移除最后的逗号
 
 		// 执行插入
-		_, err = db.Exec(query, params...)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		fmt.Printf("Batch %d inserted\n", i+1)
+		_, err := db.ExecContext(ctx, query, params...)
+		return err
+	})
+	if err != nil {
+		log.Fatal(err)
 	}
-
 }
@@ -14,28 +14,50 @@ package main
 }*/
 
 import (
+	"context"
+	"flag"
 	"log"
-	"net/http"
-	_ "net/http/pprof" // This registers the pprof handlers
-	"runtime"
+	"os"
+	"os/signal"
 )
 
-func init() {
-	runtime.SetMutexProfileFraction(1)
-	runtime.SetBlockProfileRate(1) // 启用阻塞分析
-}
-
 var datas []string
 
 func main() {
+	// 默认演示内存泄漏；-bounded 切换为固定容量的环形缓冲区，内存保持稳定
+	bounded := flag.Bool("bounded", false, "use a fixed-capacity ring buffer instead of the leaky slice")
+	capacity := flag.Int("capacity", 100000, "ring buffer capacity in bounded mode")
+	flag.Parse()
+
+	add := Add
+	if *bounded {
+		store, err := NewBoundedStore(*capacity)
+		if err != nil {
+			log.Fatal(err)
+		}
+		add = store.Add
+	}
 	go func() {
 		for {
-			log.Printf("len: %d", Add("go-programming-tour-book"))
+			log.Printf("len: %d", add("go-programming-tour-book"))
 			//time.Sleep(time.Millisecond * 1)
 		}
 	}()
 
-	_ = http.ListenAndServe("0.0.0.0:6060", nil)
+	// 演示程序需要观察锁竞争和阻塞，显式开启 mutex/block 分析
+	cfg := DefaultProfilerConfig()
+	cfg.MutexProfile = true
+	cfg.BlockProfile = true
+	addr, shutdown, err := StartProfiler(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("pprof listening on http://%s/debug/pprof/", addr)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	<-ctx.Done()
+	shutdown(context.Background())
 }
 
 func Add(str string) int {
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+)
+
+// ProfilerConfig pprof 服务配置
+type ProfilerConfig struct {
+	Addr string // 监听地址，如 "127.0.0.1:6060"，":0" 表示随机端口
+
+	// mutex/block 分析开销较大，默认关闭
+	MutexProfile  bool
+	MutexFraction int // 平均每 MutexFraction 次锁竞争采样一次，<=0 时按 1 处理
+	BlockProfile  bool
+	BlockRate     int // 阻塞时间每 BlockRate 纳秒采样一次，<=0 时按 1 处理
+}
+
+// DefaultProfilerConfig 仅本机访问，mutex/block 分析关闭
+func DefaultProfilerConfig() ProfilerConfig {
+	return ProfilerConfig{Addr: "127.0.0.1:6060"}
+}
+
+// StartProfiler 在独立的 ServeMux 上启动 pprof 服务，返回实际监听地址和关闭函数。
+// 关闭函数停止 HTTP 服务，并关闭本次开启的 mutex/block 分析。
+func StartProfiler(cfg ProfilerConfig) (net.Addr, func(context.Context) error, error) {
+	ln, err := net.Listen("tcp", cfg.Addr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if cfg.MutexProfile {
+		runtime.SetMutexProfileFraction(max(cfg.MutexFraction, 1))
+	}
+	if cfg.BlockProfile {
+		runtime.SetBlockProfileRate(max(cfg.BlockRate, 1)) // 启用阻塞分析
+	}
+
+	srv := &http.Server{Handler: newProfilerMux()}
+	go srv.Serve(ln)
+
+	shutdown := func(ctx context.Context) error {
+		if cfg.MutexProfile {
+			runtime.SetMutexProfileFraction(0)
+		}
+		if cfg.BlockProfile {
+			runtime.SetBlockProfileRate(0)
+		}
+		return srv.Shutdown(ctx)
+	}
+	return ln.Addr(), shutdown, nil
+}
+
+// newProfilerMux 注册与 net/http/pprof 默认相同的路由，但不污染 http.DefaultServeMux
+func newProfilerMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/trace", traceHandler)
+	return mux
+}
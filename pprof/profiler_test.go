@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestStartProfiler(t *testing.T) {
+	addr, shutdown, err := StartProfiler(ProfilerConfig{Addr: "127.0.0.1:0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.Get("http://" + addr.String() + "/debug/pprof/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /debug/pprof/ = %d", resp.StatusCode)
+	}
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := http.Get("http://" + addr.String() + "/debug/pprof/"); err == nil {
+		t.Fatal("profiler still serving after shutdown")
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import "fmt"
+
+// BoundedStore 固定容量的环形缓冲区，写满后覆盖最旧的数据，内存占用保持稳定
+type BoundedStore struct {
+	items []string
+	next  int // 下一个写入位置
+	full  bool
+}
+
+// NewBoundedStore 创建容量为 capacity 的环形缓冲区，capacity 必须大于 0
+func NewBoundedStore(capacity int) (*BoundedStore, error) {
+	if capacity <= 0 {
+		return nil, fmt.Errorf("bounded store capacity must be positive, got %d", capacity)
+	}
+	return &BoundedStore{items: make([]string, capacity)}, nil
+}
+
+// Add 写入一条数据并返回当前长度，不会超过容量
+func (s *BoundedStore) Add(str string) int {
+	s.items[s.next] = string([]byte(str))
+	s.next = (s.next + 1) % len(s.items)
+	if s.next == 0 {
+		s.full = true
+	}
+	return s.Len()
+}
+
+// Len 当前保存的数据条数
+func (s *BoundedStore) Len() int {
+	if s.full {
+		return len(s.items)
+	}
+	return s.next
+}
+
+// Items 按写入顺序（从旧到新）返回保存的数据
+func (s *BoundedStore) Items() []string {
+	if !s.full {
+		return append([]string(nil), s.items[:s.next]...)
+	}
+	return append(append([]string(nil), s.items[s.next:]...), s.items[:s.next]...)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestBoundedStore(t *testing.T) {
+	s, err := NewBoundedStore(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if n := s.Add(fmt.Sprint(i)); n > 3 || n != min(i+1, 3) {
+			t.Fatalf("Add #%d returned len %d", i, n)
+		}
+	}
+	// 只保留最新的 3 条，最旧的已被覆盖
+	if got, want := s.Items(), []string{"7", "8", "9"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Items = %v, want %v", got, want)
+	}
+}
+
+func TestBoundedStoreRejectsNonPositiveCapacity(t *testing.T) {
+	for _, c := range []int{0, -1} {
+		if _, err := NewBoundedStore(c); err == nil {
+			t.Fatalf("NewBoundedStore(%d) succeeded, want error", c)
+		}
+	}
+}
+
+func BenchmarkBoundedStoreAdd(b *testing.B) {
+	s, err := NewBoundedStore(1024)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < b.N; i++ {
+		s.Add("go-programming-tour-book")
+	}
+}
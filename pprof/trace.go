@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/trace"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxTraceSeconds 单次采集的最长时间
+const maxTraceSeconds = 60
+
+// traceMu 同一时刻只允许一个 trace 会话，runtime/trace 本身也不支持并发 Start
+var traceMu sync.Mutex
+
+// traceHandler 处理 /debug/trace?seconds=N：采集 N 秒（默认 1 秒）执行跟踪并直接写入响应，
+// 使用 go tool trace 查看。已有采集进行中时返回 409。
+func traceHandler(w http.ResponseWriter, r *http.Request) {
+	seconds := 1
+	if s := r.URL.Query().Get("seconds"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 || n > maxTraceSeconds {
+			http.Error(w, fmt.Sprintf("seconds must be between 1 and %d", maxTraceSeconds), http.StatusBadRequest)
+			return
+		}
+		seconds = n
+	}
+
+	if !traceMu.TryLock() {
+		http.Error(w, "another trace is in progress", http.StatusConflict)
+		return
+	}
+	defer traceMu.Unlock()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="trace.out"`)
+	if err := trace.Start(w); err != nil {
+		http.Error(w, fmt.Sprintf("start trace: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer trace.Stop()
+
+	// 客户端断开时提前结束采集
+	select {
+	case <-time.After(time.Duration(seconds) * time.Second):
+	case <-r.Context().Done():
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTraceHandler(t *testing.T) {
+	srv := httptest.NewServer(newProfilerMux())
+	defer srv.Close()
+
+	// 第一个请求采集期间，第二个请求应被拒绝
+	type result struct {
+		code int
+		size int
+	}
+	first := make(chan result, 1)
+	go func() {
+		rec := httptest.NewRecorder()
+		traceHandler(rec, httptest.NewRequest(http.MethodGet, "/debug/trace?seconds=1", nil))
+		first <- result{rec.Code, rec.Body.Len()}
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	resp, err := http.Get(srv.URL + "/debug/trace?seconds=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("concurrent trace status = %d, want 409", resp.StatusCode)
+	}
+
+	r := <-first
+	if r.code != http.StatusOK || r.size == 0 {
+		t.Fatalf("trace status = %d, %d bytes", r.code, r.size)
+	}
+}
+
+func TestTraceHandlerBadSeconds(t *testing.T) {
+	rec := httptest.NewRecorder()
+	traceHandler(rec, httptest.NewRequest(http.MethodGet, "/debug/trace?seconds=abc", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,46 @@
+package timeutil
+
+import "time"
+
+// Stopwatch 计时器。
+//
+// time.Now() 返回的 Time 同时带有墙上时间和单调时钟读数，两个都带单调读数的 Time 相减（Sub/Since）
+// 使用单调时钟，不受 NTP 校时、手动改时间的影响；而 UnixNano() 只取墙上时间，
+// 两次 UnixNano 相减在时钟回拨时会得到负数或偏大的结果。
+// 此外 Elapsed 保证不会比上一次返回的值小，即使时钟源本身不单调（如注入的时钟）。
+// Stopwatch 不是并发安全的。
+type Stopwatch struct {
+	now   func() time.Time
+	start time.Time
+	last  time.Duration
+}
+
+// NewStopwatch 创建并立即开始计时
+func NewStopwatch() *Stopwatch {
+	return newStopwatch(time.Now)
+}
+
+func newStopwatch(now func() time.Time) *Stopwatch {
+	s := &Stopwatch{now: now}
+	s.Start()
+	return s
+}
+
+// Start 从当前时刻开始计时
+func (s *Stopwatch) Start() {
+	s.start = s.now()
+	s.last = 0
+}
+
+// Reset 清零并重新开始计时，等同于 Start
+func (s *Stopwatch) Reset() {
+	s.Start()
+}
+
+// Elapsed 返回自 Start 以来经过的时间，多次调用结果单调不减
+func (s *Stopwatch) Elapsed() time.Duration {
+	if d := s.now().Sub(s.start); d > s.last {
+		s.last = d
+	}
+	return s.last
+}
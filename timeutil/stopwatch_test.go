@@ -0,0 +1,49 @@
+package timeutil
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStopwatchMonotonic(t *testing.T) {
+	// 注入的时钟不带单调读数，模拟墙上时间被回拨
+	base := time.Unix(1000, 0)
+	offsets := []time.Duration{0, 2 * time.Second, 5 * time.Second, 1 * time.Second, -3 * time.Second, 7 * time.Second}
+	i := 0
+	clock := func() time.Time {
+		t := base.Add(offsets[i])
+		i++
+		return t
+	}
+
+	s := newStopwatch(clock)
+	want := []time.Duration{2 * time.Second, 5 * time.Second, 5 * time.Second, 5 * time.Second, 7 * time.Second}
+	for n, w := range want {
+		if got := s.Elapsed(); got != w {
+			t.Fatalf("Elapsed #%d = %v, want %v", n, got, w)
+		}
+	}
+}
+
+func TestStopwatchReset(t *testing.T) {
+	now := time.Unix(0, 0)
+	s := newStopwatch(func() time.Time { return now })
+	now = now.Add(time.Minute)
+	if s.Elapsed() != time.Minute {
+		t.Fatalf("Elapsed = %v", s.Elapsed())
+	}
+
+	s.Reset()
+	now = now.Add(time.Second)
+	if got := s.Elapsed(); got != time.Second {
+		t.Fatalf("Elapsed after Reset = %v, want 1s", got)
+	}
+}
+
+func TestNewStopwatchRealClock(t *testing.T) {
+	s := NewStopwatch()
+	time.Sleep(time.Millisecond)
+	if s.Elapsed() < time.Millisecond {
+		t.Fatalf("Elapsed = %v, want >= 1ms", s.Elapsed())
+	}
+}
@@ -0,0 +1,38 @@
+// Package timeutil Unix 时间戳与 time.Time 之间的转换
+package timeutil
+
+import "time"
+
+// floorDiv 向下取整的除法，返回商和非负余数。
+// Go 的 / 和 % 向零取整，-1ns 会被拆成 (0, -1) 而不是 (-1, 999999999)。
+func floorDiv(x, y int64) (q, r int64) {
+	q, r = x/y, x%y
+	if r < 0 {
+		q--
+		r += y
+	}
+	return q, r
+}
+
+// FromUnixNano 把自 1970-01-01 UTC 起的纳秒数转换为 time.Time，支持 1970 年之前的负数
+func FromUnixNano(nsec int64) time.Time {
+	sec, ns := floorDiv(nsec, int64(time.Second))
+	return time.Unix(sec, ns)
+}
+
+// ToUnixNano 返回 t 自 1970-01-01 UTC 起的纳秒数，超出 int64 范围（约 1678~2262 年）时结果未定义
+func ToUnixNano(t time.Time) int64 {
+	return t.UnixNano()
+}
+
+// FromUnixMillis 把自 1970-01-01 UTC 起的毫秒数转换为 time.Time，支持负数
+func FromUnixMillis(msec int64) time.Time {
+	sec, ms := floorDiv(msec, 1000)
+	return time.Unix(sec, ms*int64(time.Millisecond))
+}
+
+// ToUnixMillis 返回 t 自 1970-01-01 UTC 起的毫秒数，不足 1 毫秒的部分向下取整
+func ToUnixMillis(t time.Time) int64 {
+	sec, ms := t.Unix(), int64(t.Nanosecond())/int64(time.Millisecond)
+	return sec*1000 + ms
+}
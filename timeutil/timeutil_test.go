@@ -0,0 +1,62 @@
+package timeutil
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUnixNano(t *testing.T) {
+	tests := []struct {
+		name string
+		nsec int64
+		want time.Time
+	}{
+		{"epoch", 0, time.Unix(0, 0)},
+		{"positive", 1_700_000_000_123_456_789, time.Date(2023, 11, 14, 22, 13, 20, 123_456_789, time.UTC)},
+		{"minus one ns", -1, time.Date(1969, 12, 31, 23, 59, 59, 999_999_999, time.UTC)},
+		{"negative", -1_500_000_000, time.Date(1969, 12, 31, 23, 59, 58, 500_000_000, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FromUnixNano(tt.nsec)
+			if !got.Equal(tt.want) {
+				t.Fatalf("FromUnixNano(%d) = %v, want %v", tt.nsec, got.UTC(), tt.want)
+			}
+			if got.Nanosecond() < 0 || got.Nanosecond() >= 1e9 {
+				t.Fatalf("nanosecond out of range: %d", got.Nanosecond())
+			}
+			if back := ToUnixNano(got); back != tt.nsec {
+				t.Fatalf("ToUnixNano = %d, want %d", back, tt.nsec)
+			}
+		})
+	}
+}
+
+func TestUnixMillis(t *testing.T) {
+	tests := []struct {
+		name string
+		msec int64
+		want time.Time
+	}{
+		{"epoch", 0, time.Unix(0, 0)},
+		{"positive", 1_700_000_000_123, time.Date(2023, 11, 14, 22, 13, 20, 123_000_000, time.UTC)},
+		{"minus one ms", -1, time.Date(1969, 12, 31, 23, 59, 59, 999_000_000, time.UTC)},
+		{"negative", -2_250, time.Date(1969, 12, 31, 23, 59, 57, 750_000_000, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FromUnixMillis(tt.msec)
+			if !got.Equal(tt.want) {
+				t.Fatalf("FromUnixMillis(%d) = %v, want %v", tt.msec, got.UTC(), tt.want)
+			}
+			if back := ToUnixMillis(got); back != tt.msec {
+				t.Fatalf("ToUnixMillis = %d, want %d", back, tt.msec)
+			}
+		})
+	}
+
+	// 不足 1 毫秒的部分向下取整，负数也一样
+	if got := ToUnixMillis(time.Unix(0, -1)); got != -1 {
+		t.Fatalf("ToUnixMillis(-1ns) = %d, want -1", got)
+	}
+}
@@ -2,7 +2,16 @@ module test/trans
 
 go 1.23.4
 
-require github.com/go-sql-driver/mysql v1.9.3
+require (
+	github.com/DATA-DOG/go-sqlmock v1.5.2
+	github.com/go-sql-driver/mysql v1.9.3
+	github.com/gobwas/ws v1.4.0
+)
+
+require (
+	github.com/gobwas/httphead v0.1.0 // indirect
+	github.com/gobwas/pool v0.2.1 // indirect
+)
 
 require (
 	filippo.io/edwards25519 v1.1.0 // indirect
@@ -0,0 +1,24 @@
+// Package lowstock 低库存通知的去抖，两个 TCC 实现的库存资源共用
+package lowstock
+
+import "sync"
+
+// Alert 库存低于阈值时的去抖通知：每个商品只通知一次，补货后调用 Reset 重新计数
+type Alert struct {
+	fired sync.Map // productID -> struct{}
+}
+
+// Notify 在扣减事务提交后调用，remaining 为扣减后的可用库存
+func (a *Alert) Notify(threshold int, hook func(productID int64, remaining int), productID int64, remaining int) {
+	if hook == nil || threshold <= 0 || remaining >= threshold {
+		return
+	}
+	if _, loaded := a.fired.LoadOrStore(productID, struct{}{}); !loaded {
+		hook(productID, remaining)
+	}
+}
+
+// Reset 补货后重新开始监控该商品
+func (a *Alert) Reset(productID int64) {
+	a.fired.Delete(productID)
+}
@@ -0,0 +1,20 @@
+package lowstock
+
+import "testing"
+
+func TestAlertFiresOncePerProduct(t *testing.T) {
+	var calls []int
+	hook := func(productID int64, remaining int) { calls = append(calls, remaining) }
+	var a Alert
+
+	a.Notify(3, hook, 1001, 3) // 不低于阈值
+	a.Notify(3, hook, 1001, 2)
+	a.Notify(3, hook, 1001, 1) // 去抖
+	a.Notify(0, hook, 1002, 0) // 阈值为 0 不通知
+	a.Reset(1001)
+	a.Notify(3, hook, 1001, 0)
+
+	if len(calls) != 2 || calls[0] != 2 || calls[1] != 0 {
+		t.Fatalf("calls = %v, want [2 0]", calls)
+	}
+}
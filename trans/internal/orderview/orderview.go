@@ -0,0 +1,102 @@
+// Package orderview 秒杀订单的只读查询，两个 TCC 实现共用
+package orderview
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotFound 事务ID没有对应的订单（Try 未执行或尚未提交）
+var ErrNotFound = errors.New("订单不存在")
+
+// DefaultPageSize ListByUser 未指定 limit 时的每页条数，MaxPageSize 为上限
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// OrderView 对用户展示的订单状态
+type OrderView struct {
+	TransactionID string    `json:"transactionId"`
+	UserID        int64     `json:"userId"`
+	ProductID     int64     `json:"productId"`
+	Quantity      int       `json:"quantity"`
+	UnitPrice     float64   `json:"unitPrice"`
+	TotalAmount   float64   `json:"totalAmount"`
+	Status        string    `json:"status"` // PENDING / CONFIRMED / CANCELLED
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// Source 订单所在的表。各实现的表名和单价列名不同，由调用方以常量给出，不能来自用户输入
+type Source struct {
+	DB          *sql.DB
+	Table       string // 如 seckill_orders
+	PriceColumn string // 如 price、unit_price
+}
+
+func (s Source) columns() string {
+	return `transaction_id, user_id, product_id, quantity, ` + s.PriceColumn + `, total_amount, status, created_at, updated_at`
+}
+
+func scanOrderView(scan func(dest ...interface{}) error) (*OrderView, error) {
+	var o OrderView
+	err := scan(&o.TransactionID, &o.UserID, &o.ProductID, &o.Quantity, &o.UnitPrice, &o.TotalAmount, &o.Status, &o.CreatedAt, &o.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// Get 按事务ID查询订单，没有订单时返回 ErrNotFound
+func (s Source) Get(ctx context.Context, transactionID string) (*OrderView, error) {
+	row := s.DB.QueryRowContext(ctx, `
+		SELECT `+s.columns()+` FROM `+s.Table+` 
+		WHERE transaction_id = ?
+	`, transactionID)
+	o, err := scanOrderView(row.Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, transactionID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询订单失败: %v", err)
+	}
+	return o, nil
+}
+
+// ListByUser 按创建时间倒序分页列出用户的订单，limit<=0 时取默认页大小，最多 MaxPageSize 条；
+// 没有订单时返回空切片而不是错误
+func (s Source) ListByUser(ctx context.Context, userID int64, limit, offset int) ([]OrderView, error) {
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	limit = min(limit, MaxPageSize)
+	offset = max(offset, 0)
+
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT `+s.columns()+` FROM `+s.Table+` 
+		WHERE user_id = ?
+		ORDER BY created_at DESC, id DESC
+		LIMIT ? OFFSET ?
+	`, userID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("查询用户订单失败: %v", err)
+	}
+	defer rows.Close()
+
+	orders := []OrderView{}
+	for rows.Next() {
+		o, err := scanOrderView(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("读取订单失败: %v", err)
+		}
+		orders = append(orders, *o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("读取订单失败: %v", err)
+	}
+	return orders, nil
+}
@@ -0,0 +1,80 @@
+package orderview
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var orderViewCols = []string{"transaction_id", "user_id", "product_id", "quantity", "price", "total_amount", "status", "created_at", "updated_at"}
+
+func TestGet(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	r := Source{DB: db, Table: "seckill_orders", PriceColumn: "price"}
+	now := time.Now()
+
+	for _, status := range []string{"CONFIRMED", "CANCELLED"} {
+		mock.ExpectQuery("FROM seckill_orders\\s+WHERE transaction_id = \\?").WithArgs("tx_" + status).
+			WillReturnRows(sqlmock.NewRows(orderViewCols).AddRow("tx_"+status, 10001, 1001, 2, 100.0, 200.0, status, now, now))
+		o, err := r.Get(context.Background(), "tx_"+status)
+		if err != nil {
+			t.Fatalf("GetOrder %s: %v", status, err)
+		}
+		if o.Status != status || o.TotalAmount != 200 || o.Quantity != 2 || o.UserID != 10001 {
+			t.Fatalf("GetOrder %s = %+v", status, o)
+		}
+	}
+
+	mock.ExpectQuery("FROM seckill_orders").WithArgs("tx_missing").WillReturnRows(sqlmock.NewRows(orderViewCols))
+	if _, err := r.Get(context.Background(), "tx_missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetOrder missing = %v, want ErrNotFound", err)
+	}
+
+	// 数据库错误不能当作订单不存在
+	mock.ExpectQuery("FROM seckill_orders").WithArgs("tx_err").WillReturnError(errors.New("connection reset"))
+	if _, err := r.Get(context.Background(), "tx_err"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetOrder on db error = %v, want non-not-found error", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListByUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	r := Source{DB: db, Table: "seckill_orders", PriceColumn: "price"}
+	now := time.Now()
+
+	mock.ExpectQuery("FROM seckill_orders\\s+WHERE user_id = \\?").WithArgs(int64(10001), MaxPageSize, 0).
+		WillReturnRows(sqlmock.NewRows(orderViewCols).
+			AddRow("tx_2", 10001, 1001, 1, 100.0, 100.0, "CANCELLED", now, now).
+			AddRow("tx_1", 10001, 1001, 1, 100.0, 100.0, "CONFIRMED", now.Add(-time.Minute), now))
+	orders, err := r.ListByUser(context.Background(), 10001, 1000, -5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 2 || orders[0].Status != "CANCELLED" || orders[1].Status != "CONFIRMED" {
+		t.Fatalf("orders = %+v", orders)
+	}
+
+	mock.ExpectQuery("FROM seckill_orders").WithArgs(int64(10002), DefaultPageSize, 0).
+		WillReturnRows(sqlmock.NewRows(orderViewCols))
+	orders, err = r.ListByUser(context.Background(), 10002, 0, 0)
+	if err != nil || orders == nil || len(orders) != 0 {
+		t.Fatalf("ListUserOrders empty = %v, %v; want empty slice", orders, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,57 @@
+// Package phaselog 分布式事务各阶段的结构化日志，TCC 与 XA 的管理器共用
+package phaselog
+
+import (
+	"io"
+	"log/slog"
+	"time"
+)
+
+// Event 一个资源（XA 中为分支）执行完一个阶段
+type Event struct {
+	TxID     string
+	Resource string // TCC 资源名或 XA 分支ID
+	Phase    string // TCC: try/confirm/cancel；XA: start/prepare/commit/commit_one_phase/rollback
+	Duration time.Duration
+	Err      error
+}
+
+// Outcome ok 或 error
+func (ev Event) Outcome() string {
+	if ev.Err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+// Logger 接收每个阶段的结构化事件
+type Logger interface {
+	LogPhase(ev Event)
+}
+
+// JSONLogger 每个阶段输出一行 JSON，便于日志系统解析、按 outcome/durationMs 聚合和告警
+type JSONLogger struct {
+	l           *slog.Logger
+	msg         string
+	resourceKey string
+}
+
+// NewJSONLogger msg 为每行的 msg 字段（如 "tcc phase"），resourceKey 为 Event.Resource 输出时的字段名
+func NewJSONLogger(w io.Writer, msg, resourceKey string) *JSONLogger {
+	return &JSONLogger{l: slog.New(slog.NewJSONHandler(w, nil)), msg: msg, resourceKey: resourceKey}
+}
+
+func (j *JSONLogger) LogPhase(ev Event) {
+	attrs := []any{
+		"txID", ev.TxID,
+		j.resourceKey, ev.Resource,
+		"phase", ev.Phase,
+		"outcome", ev.Outcome(),
+		"durationMs", float64(ev.Duration) / float64(time.Millisecond),
+	}
+	if ev.Err != nil {
+		j.l.Error(j.msg, append(attrs, "error", ev.Err.Error())...)
+		return
+	}
+	j.l.Info(j.msg, attrs...)
+}
@@ -0,0 +1,36 @@
+package phaselog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestJSONLoggerFields(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewJSONLogger(&buf, "xa phase", "branch")
+	l.LogPhase(Event{TxID: "gx", Resource: "db1", Phase: "prepare", Duration: 1500 * time.Microsecond})
+	l.LogPhase(Event{TxID: "gx", Resource: "db2", Phase: "commit", Err: errors.New("boom")})
+
+	var lines []map[string]any
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var m map[string]any
+		if err := dec.Decode(&m); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, m)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines", len(lines))
+	}
+	ok, failed := lines[0], lines[1]
+	if ok["msg"] != "xa phase" || ok["level"] != "INFO" || ok["branch"] != "db1" || ok["outcome"] != "ok" || ok["durationMs"] != 1.5 {
+		t.Fatalf("ok line = %v", ok)
+	}
+	if failed["level"] != "ERROR" || failed["outcome"] != "error" || failed["error"] != "boom" {
+		t.Fatalf("error line = %v", failed)
+	}
+}
@@ -0,0 +1,78 @@
+// Package tccutil TCC 各实现共用的语句级重试工具
+package tccutil
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// mysqlErrLockWaitTimeout ER_LOCK_WAIT_TIMEOUT：Lock wait timeout exceeded
+const mysqlErrLockWaitTimeout = 1205
+
+// 单条语句遇到锁等待超时时的重试参数
+var (
+	stmtMaxRetries   = 2
+	stmtRetryBackoff = 10 * time.Millisecond
+)
+
+// IsStatementRetryable 错误发生后能否在同一事务内重新执行这条语句。
+// 只有锁等待超时（1205）可以：InnoDB 默认只回滚当前语句，事务和已持有的锁都还在；
+// 死锁（1213）时 InnoDB 已经回滚了整个事务，再执行语句会落在一个新的隐式事务里，
+// 破坏原子性，只能交给调用方重做整个阶段
+func IsStatementRetryable(err error) bool {
+	var myErr *mysql.MySQLError
+	return errors.As(err, &myErr) && myErr.Number == mysqlErrLockWaitTimeout
+}
+
+// waitStmtRetry 等待下一次语句重试，ctx 结束时返回 false
+func waitStmtRetry(ctx context.Context, attempt int) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(jitter(stmtRetryBackoff << attempt)):
+		return true
+	}
+}
+
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// Retry 执行事务内的单条语句 op，锁等待超时时按退避重试，其他错误原样返回。
+// 用于预编译语句等 ExecRetry/QueryRowRetry 覆盖不到的执行方式
+func Retry(ctx context.Context, op func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := op()
+		if err == nil || !IsStatementRetryable(err) || attempt >= stmtMaxRetries || !waitStmtRetry(ctx, attempt) {
+			return err
+		}
+		log.Printf("[TCC] 语句锁等待超时，第%d次重试", attempt+1)
+	}
+}
+
+// ExecRetry 在事务内执行写语句，锁等待超时时按退避重试，其他错误原样返回
+func ExecRetry(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (sql.Result, error) {
+	var result sql.Result
+	err := Retry(ctx, func() (err error) {
+		result, err = tx.ExecContext(ctx, query, args...)
+		return err
+	})
+	return result, err
+}
+
+// QueryRowRetry 在事务内执行单行查询并扫描到 dest，锁等待超时时按退避重试。
+// sql.ErrNoRows 等其他错误原样返回，调用方的判断方式不变
+func QueryRowRetry(ctx context.Context, tx *sql.Tx, query string, args []interface{}, dest ...interface{}) error {
+	return Retry(ctx, func() error {
+		return tx.QueryRowContext(ctx, query, args...).Scan(dest...)
+	})
+}
@@ -0,0 +1,134 @@
+package tccutil
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"log"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// scriptedDB 每次执行语句依次返回 errs 中的错误，用完后一律成功
+type scriptedDB struct {
+	errs  []error
+	calls int
+}
+
+func (d *scriptedDB) Connect(context.Context) (driver.Conn, error) { return scriptedConn{d}, nil }
+func (d *scriptedDB) Driver() driver.Driver                        { return d }
+func (d *scriptedDB) Open(string) (driver.Conn, error)             { return scriptedConn{d}, nil }
+
+func (d *scriptedDB) next() error {
+	d.calls++
+	if len(d.errs) == 0 {
+		return nil
+	}
+	err := d.errs[0]
+	d.errs = d.errs[1:]
+	return err
+}
+
+type scriptedConn struct{ db *scriptedDB }
+
+func (c scriptedConn) Prepare(string) (driver.Stmt, error) { return scriptedStmt{c.db}, nil }
+func (c scriptedConn) Close() error                        { return nil }
+func (c scriptedConn) Begin() (driver.Tx, error)           { return c, nil }
+func (c scriptedConn) Commit() error                       { return nil }
+func (c scriptedConn) Rollback() error                     { return nil }
+
+type scriptedStmt struct{ db *scriptedDB }
+
+func (s scriptedStmt) Close() error  { return nil }
+func (s scriptedStmt) NumInput() int { return -1 }
+func (s scriptedStmt) Exec([]driver.Value) (driver.Result, error) {
+	if err := s.db.next(); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+func (s scriptedStmt) Query([]driver.Value) (driver.Rows, error) {
+	if err := s.db.next(); err != nil {
+		return nil, err
+	}
+	return &oneRow{}, nil
+}
+
+type oneRow struct{ done bool }
+
+func (r *oneRow) Columns() []string { return []string{"stock"} }
+func (r *oneRow) Close() error      { return nil }
+func (r *oneRow) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	dest[0], r.done = int64(7), true
+	return nil
+}
+
+var (
+	errLockWait = &mysql.MySQLError{Number: mysqlErrLockWaitTimeout, Message: "Lock wait timeout exceeded"}
+	errDeadlock = &mysql.MySQLError{Number: 1213, Message: "Deadlock found"}
+)
+
+func discardLog() func() {
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	return func() { log.SetOutput(out) }
+}
+
+func beginScripted(t *testing.T, errs ...error) (*sql.Tx, *scriptedDB) {
+	d := &scriptedDB{errs: errs}
+	db := sql.OpenDB(d)
+	t.Cleanup(func() { db.Close() })
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tx.Rollback() })
+	return tx, d
+}
+
+func TestExecRetryLockWaitThenSuccess(t *testing.T) {
+	defer discardLog()()
+	tx, d := beginScripted(t, errLockWait, errLockWait)
+	if _, err := ExecRetry(context.Background(), tx, "UPDATE seckill_inventory SET stock = stock - 1"); err != nil {
+		t.Fatal(err)
+	}
+	if d.calls != 3 {
+		t.Fatalf("calls = %d, want 3", d.calls)
+	}
+
+	tx, d = beginScripted(t, errLockWait)
+	var stock int
+	if err := QueryRowRetry(context.Background(), tx, "SELECT stock FROM seckill_inventory", nil, &stock); err != nil || stock != 7 {
+		t.Fatalf("stock = %d, err = %v", stock, err)
+	}
+	if d.calls != 2 {
+		t.Fatalf("calls = %d, want 2", d.calls)
+	}
+}
+
+func TestExecRetryPermanentFailure(t *testing.T) {
+	defer discardLog()()
+	for _, tt := range []struct {
+		name  string
+		errs  []error
+		calls int
+	}{
+		// 锁一直拿不到：重试用完后返回原始 1205
+		{"lock wait exhausted", []error{errLockWait, errLockWait, errLockWait, errLockWait}, stmtMaxRetries + 1},
+		// 死锁已回滚整个事务，不在语句级重试
+		{"deadlock", []error{errDeadlock}, 1},
+		{"other", []error{errors.New("syntax error")}, 1},
+	} {
+		tx, d := beginScripted(t, tt.errs...)
+		_, err := ExecRetry(context.Background(), tx, "UPDATE seckill_inventory SET stock = stock - 1")
+		if !errors.Is(err, tt.errs[0]) || d.calls != tt.calls {
+			t.Errorf("%s: err = %v, calls = %d; want %v after %d calls", tt.name, err, d.calls, tt.errs[0], tt.calls)
+		}
+	}
+}
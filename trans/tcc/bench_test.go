@@ -0,0 +1,185 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// benchConcurrency 并发扫描的档位
+var benchConcurrency = []int{1, 8, 32, 128}
+
+// benchUsers 压测轮询使用的用户，MySQL 上由 resetFreezeStock 准备
+var benchUsers = []int64{1001, 1002, 1003, 1004, 1005}
+
+// runSeckillSweep 把 b.N 笔秒杀分给 c 个 goroutine 执行，额外报告 tx/s 和失败比例。
+// 库存在每轮开始前按 b.N 重置，正常情况下 fail/op 应为 0
+func runSeckillSweep(b *testing.B, c int, seckill func(i int) error) {
+	var next, failed atomic.Int64
+	var wg sync.WaitGroup
+	b.ReportAllocs()
+	b.ResetTimer()
+	start := time.Now()
+	for g := 0; g < c; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := next.Add(1); i <= int64(b.N); i = next.Add(1) {
+				if err := seckill(int(i)); err != nil {
+					failed.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	b.StopTimer()
+	b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "tx/s")
+	b.ReportMetric(float64(failed.Load())/float64(b.N), "fail/op")
+}
+
+func benchContext(i int) *SeckillTCCContext {
+	return &SeckillTCCContext{
+		TransactionID: fmt.Sprintf("bench_%d", i),
+		UserID:        benchUsers[i%len(benchUsers)],
+		ProductID:     2001,
+		Quantity:      1,
+		Price:         99.99,
+		Timeout:       30 * time.Second,
+	}
+}
+
+// openBenchMySQL 连接 MYSQL_TEST_DSN 指定的测试库并建表，未设置时跳过
+func openBenchMySQL(b *testing.B) *sql.DB {
+	dsn := os.Getenv("MYSQL_TEST_DSN")
+	if dsn == "" {
+		b.Skip("MYSQL_TEST_DSN not set")
+	}
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { db.Close() })
+	if err := initSeckillDatabase(db); err != nil {
+		b.Fatal(err)
+	}
+	return db
+}
+
+// resetFreezeStock 把商品 2001 的可用库存重置为 n、冻结和已售清零，压测用户余额重置为
+// 足够买下全部库存，并清理上一轮压测留下的 bench_ 前缀事务数据
+func resetFreezeStock(b *testing.B, db *sql.DB, n int) {
+	stmts := []struct {
+		query string
+		args  []interface{}
+	}{
+		{`INSERT INTO seckill_inventory (product_id, stock) VALUES (2001, ?)
+			ON DUPLICATE KEY UPDATE stock = VALUES(stock), frozen_stock = 0, sold_stock = 0`, []interface{}{n}},
+		{`DELETE FROM seckill_inventory_freeze WHERE transaction_id LIKE 'bench\_%'`, nil},
+		{`DELETE FROM seckill_account_freeze WHERE transaction_id LIKE 'bench\_%'`, nil},
+		{`DELETE FROM seckill_orders WHERE transaction_id LIKE 'bench\_%'`, nil},
+		{`DELETE FROM tcc_phase_log WHERE tx_id LIKE 'bench\_%'`, nil},
+	}
+	for _, user := range benchUsers {
+		stmts = append(stmts, struct {
+			query string
+			args  []interface{}
+		}{`INSERT INTO seckill_account (user_id, balance) VALUES (?, ?)
+			ON DUPLICATE KEY UPDATE balance = VALUES(balance), frozen_balance = 0`, []interface{}{user, float64(n) * 99.99}})
+	}
+	for _, s := range stmts {
+		if _, err := db.Exec(s.query, s.args...); err != nil {
+			b.Fatalf("重置压测数据失败: %v", err)
+		}
+	}
+}
+
+// BenchmarkSeckillFreeze 冻结模式（Try 冻结、Confirm 转已售）的并发扫描。
+//
+// driver=fake 用 fakeSeckillDB 只跑库存资源，商品行锁真实串行化，加锁前模拟 50µs 查询开销，
+// 衡量单行热点下管理器和库存资源的开销；driver=mysql 在 MYSQL_TEST_DSN 指定的库上
+// 跑完整的库存/账户/订单三个资源。
+//
+//	go test -run '^$' -bench SeckillFreeze -benchtime 2000x -count 5 .
+//
+// 参考结果（单核 Xeon，driver=fake，-benchtime 2000x）：
+//
+//	c=1    约 1.2-1.3ms/op 4.7KB/op  122 allocs/op
+//	c=8    约 27-53µs/op   4.7KB/op  122-123 allocs/op
+//	c=32   约 30µs/op      4.8KB/op  122-123 allocs/op
+//	c=128  约 36µs/op      4.9KB/op  123 allocs/op
+//
+// 模拟开销在加锁之前，可以被并发摊薄，持锁区间仍然串行；
+// 该机器上 time.Sleep 的实际粒度约 1ms，跨机器只比较 allocs/op 和 fail/op
+func BenchmarkSeckillFreeze(b *testing.B) {
+	restore := discardLog()
+	defer restore()
+
+	for _, c := range benchConcurrency {
+		b.Run(fmt.Sprintf("driver=fake/c=%d", c), func(b *testing.B) {
+			benchmarkSeckillFreezeFake(b, c, 50*time.Microsecond)
+		})
+	}
+	for _, c := range benchConcurrency {
+		b.Run(fmt.Sprintf("driver=mysql/c=%d", c), func(b *testing.B) {
+			db := openBenchMySQL(b)
+			db.SetMaxOpenConns(c * 2)
+			db.SetMaxIdleConns(c * 2)
+			resetFreezeStock(b, db, b.N)
+
+			manager := NewSeckillTCCManager()
+			manager.AddResource(NewSeckillInventoryResource(db))
+			manager.AddResource(NewSeckillAccountResource(db))
+			manager.AddResource(NewSeckillOrderResource(db))
+			runSeckillSweep(b, c, func(i int) error {
+				ctx := benchContext(i)
+				ctx.TransactionID = fmt.Sprintf("bench_%d_%d", b.N, i)
+				return manager.ExecuteSeckillTCC(ctx)
+			})
+		})
+	}
+}
+
+// benchmarkSeckillFreezeFake 在 fakeSeckillDB 上执行秒杀，lockDelay 为每次加行锁的模拟开销
+func benchmarkSeckillFreezeFake(b *testing.B, c int, lockDelay time.Duration) {
+	db, store := newFakeSeckillDB(benchUsers...)
+	defer db.Close()
+	db.SetMaxOpenConns(c * 2)
+	db.SetMaxIdleConns(c * 2)
+	store.lockDelay = lockDelay
+	store.setStock(2001, int64(b.N))
+
+	manager := NewSeckillTCCManager()
+	manager.AddResource(NewSeckillInventoryResource(db))
+	runSeckillSweep(b, c, func(i int) error { return manager.ExecuteSeckillTCC(benchContext(i)) })
+	if left := store.stock(2001); left != 0 {
+		b.Fatalf("%d stock left after %d seckills", left, b.N)
+	}
+}
+
+// seckillFreezeMaxAllocs 单笔秒杀（fake 驱动、只含库存资源）允许的最大分配次数，
+// 在参考结果 122 allocs/op 上留了约 20% 余量
+const seckillFreezeMaxAllocs = 150
+
+func TestSeckillFreezeAllocsBaseline(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping benchmark-based check in -short mode")
+	}
+	restore := discardLog()
+	defer restore()
+
+	// 分配次数与延迟无关，去掉模拟延迟让检查尽快完成
+	res := testing.Benchmark(func(b *testing.B) { benchmarkSeckillFreezeFake(b, 8, 0) })
+	if res.N == 0 {
+		t.Fatal("benchmark did not run")
+	}
+	if allocs := res.AllocsPerOp(); allocs > seckillFreezeMaxAllocs {
+		t.Fatalf("ExecuteSeckillTCC allocs/op = %d, baseline allows %d", allocs, seckillFreezeMaxAllocs)
+	}
+	if fail := res.Extra["fail/op"]; fail != 0 {
+		t.Fatalf("fail/op = %v, want 0", fail)
+	}
+}
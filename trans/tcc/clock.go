@@ -0,0 +1,50 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock 时间来源。资源写入的 updated_at/expires_at 和过期扫描都从这里取时间，
+// 测试中换成 FakeClock 即可快进到过期之后，不必真的等待
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+// RealClock 系统时钟，Clock 字段为 nil 时使用
+var RealClock Clock = realClock{}
+
+// clockNow 返回 c 的当前时间，c 为 nil 时使用系统时钟
+func clockNow(c Clock) time.Time {
+	if c == nil {
+		return time.Now()
+	}
+	return c.Now()
+}
+
+// FakeClock 手动推进的时钟，可并发使用
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance 把时钟向前拨 d
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
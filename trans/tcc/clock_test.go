@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+type cancelRecorder struct{ cancelled []*SeckillTCCContext }
+
+func (r *cancelRecorder) Try(*SeckillTCCContext) error     { return nil }
+func (r *cancelRecorder) Confirm(*SeckillTCCContext) error { return nil }
+func (r *cancelRecorder) Cancel(ctx *SeckillTCCContext) error {
+	r.cancelled = append(r.cancelled, ctx)
+	return nil
+}
+
+func TestExpireStaleWithFakeClock(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(t0)
+	rec := &cancelRecorder{}
+	manager := NewSeckillTCCManager()
+	manager.Clock = clock
+	manager.AddResource(rec)
+
+	ctx := testContext()
+	ctx.CreatedAt = time.Time{}
+	if err := manager.ExecuteSeckillTCC(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !ctx.CreatedAt.Equal(t0) {
+		t.Fatalf("CreatedAt = %v, want %v", ctx.CreatedAt, t0)
+	}
+
+	db, mock := newMock(t)
+	cols := []string{"transaction_id", "product_id", "quantity", "user_id"}
+	// 未到期：扫描时间为 t0，没有过期记录
+	mock.ExpectQuery("FROM seckill_inventory_freeze").WithArgs(t0).
+		WillReturnRows(sqlmock.NewRows(cols))
+	ids, err := manager.ExpireStale(db)
+	if err != nil || len(ids) != 0 {
+		t.Fatalf("before expiry: ids=%v err=%v", ids, err)
+	}
+
+	// 快进到超时之后，同一条冻结记录被取消
+	clock.Advance(ctx.Timeout + time.Second)
+	mock.ExpectQuery("FROM seckill_inventory_freeze").WithArgs(t0.Add(ctx.Timeout + time.Second)).
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(ctx.TransactionID, ctx.ProductID, ctx.Quantity, ctx.UserID))
+	ids, err = manager.ExpireStale(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || ids[0] != ctx.TransactionID {
+		t.Fatalf("expired = %v, want [%s]", ids, ctx.TransactionID)
+	}
+	if len(rec.cancelled) != 1 || rec.cancelled[0].UserID != ctx.UserID || rec.cancelled[0].Quantity != ctx.Quantity {
+		t.Fatalf("cancelled = %+v", rec.cancelled)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
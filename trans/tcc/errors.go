@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// ErrorKind TCC 错误分类，决定管理器是否重试
+type ErrorKind int
+
+const (
+	KindFatal     ErrorKind = iota // 不可恢复的错误（数据异常、SQL 错误等），未分类的错误也按此处理
+	KindBusiness                   // 业务拒绝（库存不足、余额不足），重试没有意义
+	KindTransient                  // 暂时性故障（连接断开、超时），可以重试
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case KindBusiness:
+		return "Business"
+	case KindTransient:
+		return "Transient"
+	default:
+		return "Fatal"
+	}
+}
+
+// TCCError 带分类的 TCC 资源错误
+type TCCError struct {
+	Kind ErrorKind
+	Err  error
+}
+
+func (e *TCCError) Error() string { return e.Err.Error() }
+func (e *TCCError) Unwrap() error { return e.Err }
+
+// KindOf 返回错误链中第一个 TCCError 的分类，没有则视为 KindFatal
+func KindOf(err error) ErrorKind {
+	var tccErr *TCCError
+	if errors.As(err, &tccErr) {
+		return tccErr.Kind
+	}
+	return KindFatal
+}
+
+// IsTransient 错误是否可以重试
+func IsTransient(err error) bool {
+	return err != nil && KindOf(err) == KindTransient
+}
+
+func businessErrorf(format string, args ...interface{}) error {
+	return &TCCError{Kind: KindBusiness, Err: fmt.Errorf(format, args...)}
+}
+
+func fatalErrorf(format string, args ...interface{}) error {
+	return &TCCError{Kind: KindFatal, Err: fmt.Errorf(format, args...)}
+}
+
+// dbError 包装数据库错误，按底层错误判断是否为暂时性故障
+func dbError(msg string, err error) error {
+	return &TCCError{Kind: classifyDBError(err), Err: fmt.Errorf("%s: %w", msg, err)}
+}
+
+// MySQL 行锁冲突错误码
+const (
+	mysqlErrLockWaitTimeout = 1205 // Lock wait timeout exceeded
+	mysqlErrDeadlock        = 1213 // Deadlock found when trying to get lock
+)
+
+// isLockConflict 是否为锁等待超时或死锁，换个时间重试通常就能成功
+func isLockConflict(err error) bool {
+	var myErr *mysql.MySQLError
+	return errors.As(err, &myErr) &&
+		(myErr.Number == mysqlErrLockWaitTimeout || myErr.Number == mysqlErrDeadlock)
+}
+
+// classifyDBError 连接断开、网络错误、超时和锁冲突视为暂时性故障，其余数据库错误视为致命错误
+func classifyDBError(err error) ErrorKind {
+	if isLockConflict(err) {
+		return KindTransient
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, mysql.ErrInvalidConn) || errors.Is(err, context.DeadlineExceeded) {
+		return KindTransient
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return KindTransient
+	}
+	return KindFatal
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+)
+
+var errConnReset = &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+
+func TestInventoryTryStockOutIsBusiness(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT IGNORE INTO tcc_phase_log").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT stock FROM seckill_inventory").
+		WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(0))
+	mock.ExpectRollback()
+
+	err := NewSeckillInventoryResource(db).Try(testContext())
+	if KindOf(err) != KindBusiness {
+		t.Fatalf("kind = %v (%v), want Business", KindOf(err), err)
+	}
+	if IsTransient(err) {
+		t.Fatal("stock-out must not be retryable")
+	}
+}
+
+func TestInventoryTryConnectionErrorIsTransient(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT IGNORE INTO tcc_phase_log").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT stock FROM seckill_inventory").WillReturnError(errConnReset)
+	mock.ExpectRollback()
+
+	err := NewSeckillInventoryResource(db).Try(testContext())
+	if !IsTransient(err) {
+		t.Fatalf("kind = %v (%v), want Transient", KindOf(err), err)
+	}
+	if !errors.Is(err, syscall.ECONNRESET) {
+		t.Fatalf("err = %v, want to wrap ECONNRESET", err)
+	}
+}
+
+func TestAccountTryClassification(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT IGNORE INTO tcc_phase_log").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT balance FROM seckill_account").
+		WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(1.0))
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT IGNORE INTO tcc_phase_log").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT balance FROM seckill_account").
+		WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(1000.0))
+	mock.ExpectExec("UPDATE seckill_account").WillReturnError(errConnReset)
+	mock.ExpectRollback()
+
+	r := NewSeckillAccountResource(db)
+	if err := r.Try(testContext()); KindOf(err) != KindBusiness {
+		t.Fatalf("insufficient balance: kind = %v (%v), want Business", KindOf(err), err)
+	}
+	if err := r.Try(testContext()); KindOf(err) != KindTransient {
+		t.Fatalf("connection reset: kind = %v (%v), want Transient", KindOf(err), err)
+	}
+}
+
+func TestOrderResourceClassification(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM tcc_phase_log").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec("INSERT IGNORE INTO tcc_phase_log").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT user_id FROM seckill_account").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(10001))
+	mock.ExpectQuery("SELECT COALESCE").
+		WillReturnRows(sqlmock.NewRows([]string{"bought"}).AddRow(1))
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM tcc_phase_log").WillReturnError(errConnReset)
+	mock.ExpectRollback()
+
+	r := NewSeckillOrderResource(db)
+	r.PerUserLimit = 1
+	if err := r.Try(testContext()); KindOf(err) != KindBusiness || !errors.Is(err, ErrPurchaseLimitExceeded) {
+		t.Fatalf("purchase limit: kind = %v (%v), want Business", KindOf(err), err)
+	}
+	if err := r.Try(testContext()); KindOf(err) != KindTransient {
+		t.Fatalf("connection reset: kind = %v (%v), want Transient", KindOf(err), err)
+	}
+}
+
+// Try 的 COMMIT 已生效但响应丢失时管理器会重试，重放的 Try 只命中幂等记录，不再冻结或下单
+func TestTryReplayIsNoop(t *testing.T) {
+	db, mock := newMock(t)
+	replayed := func() {
+		mock.ExpectExec("INSERT IGNORE INTO tcc_phase_log").WithArgs("seckill_1", sqlmock.AnyArg(), "TRY", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+	}
+	mock.ExpectBegin()
+	replayed()
+	mock.ExpectBegin()
+	replayed()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM tcc_phase_log").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	replayed()
+
+	ctx := testContext()
+	for _, r := range []SeckillTCCResource{NewSeckillInventoryResource(db), NewSeckillAccountResource(db), NewSeckillOrderResource(db)} {
+		if err := r.Try(ctx); err != nil {
+			t.Fatalf("%T replay: %v", r, err)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAccountConfirmMissingFreezeIsFatal(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT IGNORE INTO tcc_phase_log").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT amount FROM seckill_account_freeze").WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	err := NewSeckillAccountResource(db).Confirm(testContext())
+	if KindOf(err) != KindFatal || errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("kind = %v (%v), want Fatal without wrapping ErrNoRows", KindOf(err), err)
+	}
+	var tccErr *TCCError
+	if !errors.As(err, &tccErr) {
+		t.Fatalf("err = %v, want a TCCError", err)
+	}
+}
+
+// flakyResource Try 先返回 failures 个 err，之后成功
+type flakyResource struct {
+	err              error
+	failures         int
+	tries, cancelled int
+}
+
+func (r *flakyResource) Try(*SeckillTCCContext) error {
+	r.tries++
+	if r.tries <= r.failures {
+		return r.err
+	}
+	return nil
+}
+func (r *flakyResource) Confirm(*SeckillTCCContext) error { return nil }
+func (r *flakyResource) Cancel(*SeckillTCCContext) error  { r.cancelled++; return nil }
+
+func TestManagerRetriesOnlyTransient(t *testing.T) {
+	defer discardLog()()
+
+	tests := []struct {
+		name      string
+		err       error
+		failures  int
+		wantTries int
+		wantErr   bool
+	}{
+		{"transient recovers", dbError("查询商品库存失败", errConnReset), 2, 3, false},
+		{"transient exhausted", dbError("查询商品库存失败", errConnReset), 10, 4, true},
+		{"business", businessErrorf("库存不足"), 1, 1, true},
+		{"fatal", errors.New("unclassified"), 1, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stm := NewSeckillTCCManager()
+			stm.RetryBackoff = 0
+			r := &flakyResource{err: tt.err, failures: tt.failures}
+			stm.AddResource(r)
+
+			err := stm.ExecuteSeckillTCC(testContext())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if r.tries != tt.wantTries {
+				t.Fatalf("tries = %d, want %d", r.tries, tt.wantTries)
+			}
+			if tt.wantErr && KindOf(err) != KindOf(tt.err) {
+				t.Fatalf("manager lost error kind: got %v, want %v", KindOf(err), KindOf(tt.err))
+			}
+			if tt.wantErr && r.cancelled == 0 {
+				t.Fatal("failed Try should be compensated")
+			}
+		})
+	}
+}
+
+// holdProductLock 在单独的事务中对商品行加锁，返回释放函数
+func holdProductLock(t *testing.T, db *sql.DB, productID int64) func() {
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := tx.Query("SELECT stock FROM seckill_inventory WHERE product_id = ? FOR UPDATE", productID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+	return func() { tx.Rollback() }
+}
+
+func TestInventoryTryLockWaitTimeoutIsTransient(t *testing.T) {
+	defer discardLog()()
+	db, store := newFakeSeckillDB()
+	defer db.Close()
+	store.setStock(2001, 5)
+	store.lockWaitTimeout = 20 * time.Millisecond
+	store.failRollback.Store(true) // 回滚失败也不能覆盖锁等待错误
+
+	release := holdProductLock(t, db, 2001)
+	defer release()
+
+	r := NewSeckillInventoryResource(db)
+	r.Gate = NewStockGate()
+	r.Gate.Seed(2001, 5)
+
+	err := r.Try(testContext())
+	var myErr *mysql.MySQLError
+	if !errors.As(err, &myErr) || myErr.Number != mysqlErrLockWaitTimeout {
+		t.Fatalf("err = %v, want MySQL 1205", err)
+	}
+	if !IsTransient(err) {
+		t.Fatalf("kind = %v, want Transient", KindOf(err))
+	}
+	if store.stock(2001) != 5 {
+		t.Fatalf("stock = %d after lock wait timeout, want 5", store.stock(2001))
+	}
+	if got, _ := r.Gate.Available(2001); got != 5 {
+		t.Fatalf("gate stock = %d, want 5", got)
+	}
+}
+
+// tryOnly 只执行库存 Try，Confirm/Cancel 为空操作
+type tryOnly struct{ *SeckillInventoryResource }
+
+func (tryOnly) Confirm(*SeckillTCCContext) error { return nil }
+func (tryOnly) Cancel(*SeckillTCCContext) error  { return nil }
+
+func TestManagerRetriesLockWaitUntilReleased(t *testing.T) {
+	defer discardLog()()
+	db, store := newFakeSeckillDB()
+	defer db.Close()
+	store.setStock(2001, 5)
+	store.lockWaitTimeout = 10 * time.Millisecond
+
+	// 持锁的事务 30ms 后结束，期间 Try 会遇到锁等待超时
+	release := holdProductLock(t, db, 2001)
+	time.AfterFunc(30*time.Millisecond, release)
+
+	stm := NewSeckillTCCManager()
+	stm.MaxRetries = 5
+	stm.RetryBackoff = 10 * time.Millisecond
+	stm.AddResource(tryOnly{NewSeckillInventoryResource(db)})
+
+	if err := stm.ExecuteSeckillTCC(testContext()); err != nil {
+		t.Fatalf("ExecuteSeckillTCC: %v", err)
+	}
+	if store.stock(2001) != 4 {
+		t.Fatalf("stock = %d, want 4", store.stock(2001))
+	}
+}
+
+func TestJitter(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		if d := jitter(10 * time.Millisecond); d < 5*time.Millisecond || d > 10*time.Millisecond {
+			t.Fatalf("jitter(10ms) = %v, want within [5ms, 10ms]", d)
+		}
+	}
+	if jitter(0) != 0 {
+		t.Fatal("jitter(0) != 0")
+	}
+}
+
+func TestClassifyLockConflicts(t *testing.T) {
+	for _, number := range []uint16{mysqlErrLockWaitTimeout, mysqlErrDeadlock} {
+		err := dbError("冻结库存失败", &mysql.MySQLError{Number: number})
+		if !IsTransient(err) {
+			t.Fatalf("MySQL %d: kind = %v, want Transient", number, KindOf(err))
+		}
+	}
+	if err := dbError("冻结库存失败", &mysql.MySQLError{Number: 1062}); KindOf(err) != KindFatal {
+		t.Fatalf("MySQL 1062: kind = %v, want Fatal", KindOf(err))
+	}
+}
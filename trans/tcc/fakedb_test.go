@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// fakeSeckillDB 测试用的内存数据库驱动，只支持限购和库存 Try/Confirm 路径用到的几条 SQL。
+// SELECT ... FOR UPDATE 用每行一把互斥锁模拟行锁（持有到事务结束），
+// 写操作暂存在连接上，提交时才生效。
+type fakeSeckillDB struct {
+	mu     sync.Mutex
+	locks  map[string]*sync.Mutex
+	stocks map[int64]int64
+	orders []fakeOrder
+	phases map[string]bool  // tcc_phase_log 中已提交的 "tx_id/resource/phase"
+	frozen map[string]int64 // seckill_inventory_freeze 中 FROZEN 状态的冻结数量，按 tx_id
+
+	failInventoryUpdate atomic.Bool   // 让库存扣减 UPDATE 返回错误
+	failRollback        atomic.Bool   // 让 Rollback 返回错误（锁仍会释放）
+	lockDelay           time.Duration // 模拟行锁查询的数据库开销
+	lockWaitTimeout     time.Duration // 大于 0 时行锁等待超时返回 MySQL 1205
+}
+
+type fakeOrder struct {
+	txID              string
+	userID, productID int64
+	quantity          int64
+	status            string
+}
+
+func newFakeSeckillDB(users ...int64) (*sql.DB, *fakeSeckillDB) {
+	f := &fakeSeckillDB{locks: make(map[string]*sync.Mutex), stocks: make(map[int64]int64), phases: make(map[string]bool), frozen: make(map[string]int64)}
+	for _, u := range users {
+		f.locks[rowKey("user", u)] = &sync.Mutex{}
+	}
+	return sql.OpenDB(f), f
+}
+
+func rowKey(table string, id int64) string {
+	return table + ":" + strconv.FormatInt(id, 10)
+}
+
+func (f *fakeSeckillDB) setStock(productID, stock int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stocks[productID] = stock
+	if _, ok := f.locks[rowKey("product", productID)]; !ok {
+		f.locks[rowKey("product", productID)] = &sync.Mutex{}
+	}
+}
+
+func (f *fakeSeckillDB) stock(productID int64) int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.stocks[productID]
+}
+
+func (f *fakeSeckillDB) committed() []fakeOrder {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]fakeOrder(nil), f.orders...)
+}
+
+func (f *fakeSeckillDB) Connect(context.Context) (driver.Conn, error) { return f.Open("") }
+func (f *fakeSeckillDB) Driver() driver.Driver                        { return f }
+func (f *fakeSeckillDB) Open(string) (driver.Conn, error)             { return &fakeConn{db: f}, nil }
+
+type fakeConn struct {
+	db      *fakeSeckillDB
+	locks   []*sync.Mutex
+	pending []func() // 提交时在 db.mu 保护下执行
+	orders  []fakeOrder
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) { return &fakeStmt{c, query}, nil }
+func (c *fakeConn) Close() error                              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)                 { return c, nil }
+
+func (c *fakeConn) Commit() error {
+	c.db.mu.Lock()
+	for _, apply := range c.pending {
+		apply()
+	}
+	c.db.orders = append(c.db.orders, c.orders...)
+	c.db.mu.Unlock()
+	c.end()
+	return nil
+}
+
+func (c *fakeConn) Rollback() error {
+	c.end()
+	if c.db.failRollback.Load() {
+		return errors.New("fake: rollback failed")
+	}
+	return nil
+}
+
+func (c *fakeConn) end() {
+	c.pending, c.orders = nil, nil
+	for _, l := range c.locks {
+		l.Unlock()
+	}
+	c.locks = nil
+}
+
+// lockRow 加行锁并持有到事务结束，行不存在时返回 false；
+// 设置了 lockWaitTimeout 时等待超时返回 MySQL 1205，与 InnoDB 一样只失败当前语句，已持有的锁不释放
+func (c *fakeConn) lockRow(key string) (bool, error) {
+	c.db.mu.Lock()
+	lock, ok := c.db.locks[key]
+	c.db.mu.Unlock()
+	if !ok {
+		return false, nil
+	}
+	time.Sleep(c.db.lockDelay)
+	if c.db.lockWaitTimeout <= 0 {
+		lock.Lock()
+	} else {
+		for deadline := time.Now().Add(c.db.lockWaitTimeout); !lock.TryLock(); time.Sleep(time.Millisecond) {
+			if time.Now().After(deadline) {
+				return false, &mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded; try restarting transaction"}
+			}
+		}
+	}
+	c.locks = append(c.locks, lock)
+	return true, nil
+}
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	switch {
+	case strings.Contains(s.query, "INSERT INTO seckill_orders"):
+		s.conn.orders = append(s.conn.orders, fakeOrder{
+			txID: args[0].(string), userID: args[1].(int64), productID: args[2].(int64), quantity: args[3].(int64), status: "PENDING",
+		})
+		return driver.RowsAffected(1), nil
+
+	case strings.Contains(s.query, "UPDATE seckill_inventory") && strings.Contains(s.query, "stock = stock -"):
+		if s.conn.db.failInventoryUpdate.Load() {
+			return nil, errors.New("fake: connection reset")
+		}
+		quantity, productID := args[0].(int64), args[3].(int64)
+		s.conn.pending = append(s.conn.pending, func() { s.conn.db.stocks[productID] -= quantity })
+		return driver.RowsAffected(1), nil
+
+	case strings.Contains(s.query, "INSERT INTO seckill_inventory_freeze"):
+		txID, quantity := args[0].(string), args[2].(int64)
+		s.conn.pending = append(s.conn.pending, func() { s.conn.db.frozen[txID] = quantity })
+		return driver.RowsAffected(1), nil
+
+	case strings.Contains(s.query, "UPDATE seckill_inventory_freeze"):
+		txID := args[1].(string)
+		s.conn.pending = append(s.conn.pending, func() { delete(s.conn.db.frozen, txID) })
+		return driver.RowsAffected(1), nil
+
+	case strings.Contains(s.query, "UPDATE seckill_inventory") && strings.Contains(s.query, "sold_stock = sold_stock +"):
+		return driver.RowsAffected(1), nil
+
+	case strings.Contains(s.query, "INSERT IGNORE INTO tcc_phase_log"):
+		key := args[0].(string) + "/" + args[1].(string) + "/" + args[2].(string)
+		s.conn.db.mu.Lock()
+		exists := s.conn.db.phases[key]
+		s.conn.db.mu.Unlock()
+		if exists {
+			return driver.RowsAffected(0), nil
+		}
+		s.conn.pending = append(s.conn.pending, func() { s.conn.db.phases[key] = true })
+		return driver.RowsAffected(1), nil
+
+	case strings.Contains(s.query, "UPDATE seckill_orders"):
+		txID := args[1].(string)
+		status := "CONFIRMED"
+		if strings.Contains(s.query, "'CANCELLED'") {
+			status = "CANCELLED"
+		}
+		s.conn.pending = append(s.conn.pending, func() {
+			for i := range s.conn.db.orders {
+				if s.conn.db.orders[i].txID == txID {
+					s.conn.db.orders[i].status = status
+				}
+			}
+		})
+		return driver.RowsAffected(1), nil
+	}
+	return nil, errors.New("fake: unsupported exec: " + s.query)
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	switch {
+	case strings.Contains(s.query, "FROM seckill_inventory_freeze"):
+		s.conn.db.mu.Lock()
+		quantity, ok := s.conn.db.frozen[args[0].(string)]
+		s.conn.db.mu.Unlock()
+		if !ok {
+			return &fakeRows{cols: []string{"quantity"}}, nil
+		}
+		return &fakeRows{cols: []string{"quantity"}, rows: [][]driver.Value{{quantity}}}, nil
+
+	case strings.Contains(s.query, "FROM seckill_account") && strings.Contains(s.query, "FOR UPDATE"):
+		userID := args[0].(int64)
+		ok, err := s.conn.lockRow(rowKey("user", userID))
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return &fakeRows{cols: []string{"user_id"}}, nil
+		}
+		return &fakeRows{cols: []string{"user_id"}, rows: [][]driver.Value{{userID}}}, nil
+
+	case strings.Contains(s.query, "FROM seckill_inventory") && strings.Contains(s.query, "FOR UPDATE"):
+		productID := args[0].(int64)
+		ok, err := s.conn.lockRow(rowKey("product", productID))
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return &fakeRows{cols: []string{"stock"}}, nil
+		}
+		return &fakeRows{cols: []string{"stock"}, rows: [][]driver.Value{{s.conn.db.stock(productID)}}}, nil
+
+	case strings.Contains(s.query, "SUM(quantity)") && strings.Contains(s.query, "FROM seckill_orders"):
+		userID, productID := args[0].(int64), args[1].(int64)
+		var sum int64
+		for _, o := range append(s.conn.db.committed(), s.conn.orders...) {
+			if o.userID == userID && o.productID == productID && o.status != "CANCELLED" {
+				sum += o.quantity
+			}
+		}
+		time.Sleep(time.Millisecond) // 放大统计与插入之间的竞争窗口
+		return &fakeRows{cols: []string{"sum"}, rows: [][]driver.Value{{sum}}}, nil
+
+	case strings.Contains(s.query, "FROM tcc_phase_log"):
+		key := args[0].(string) + "/" + args[1].(string) + "/CANCEL"
+		s.conn.db.mu.Lock()
+		var n int64
+		if s.conn.db.phases[key] {
+			n = 1
+		}
+		s.conn.db.mu.Unlock()
+		return &fakeRows{cols: []string{"count"}, rows: [][]driver.Value{{n}}}, nil
+	}
+	return nil, errors.New("fake: unsupported query: " + s.query)
+}
+
+type fakeRows struct {
+	cols []string
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.cols }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
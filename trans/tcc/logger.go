@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"test/trans/internal/phaselog"
+	"time"
+)
+
+// PhaseEvent 一个资源执行完 Try/Confirm/Cancel 中的一个阶段（含重试）
+type PhaseEvent = phaselog.Event
+
+// Logger 接收每个阶段的结构化事件，SeckillTCCManager.Logger 为 nil 时输出可读文本
+type Logger = phaselog.Logger
+
+type textLogger struct{}
+
+func (textLogger) LogPhase(ev PhaseEvent) {
+	if ev.Err != nil {
+		log.Printf("[Seckill TCC] 事务%s 资源%s %s失败，耗时%v: %v", ev.TxID, ev.Resource, ev.Phase, ev.Duration, ev.Err)
+		return
+	}
+	log.Printf("[Seckill TCC] 事务%s 资源%s %s成功，耗时%v", ev.TxID, ev.Resource, ev.Phase, ev.Duration)
+}
+
+// NewJSONLogger 每个阶段输出一行 JSON
+func NewJSONLogger(w io.Writer) *phaselog.JSONLogger {
+	return phaselog.NewJSONLogger(w, "tcc phase", "resource")
+}
+
+// resourceName 资源实现了 Name() 时用它，否则用类型名
+func resourceName(r SeckillTCCResource) string {
+	if n, ok := r.(interface{ Name() string }); ok {
+		return n.Name()
+	}
+	return strings.TrimPrefix(fmt.Sprintf("%T", r), "*main.")
+}
+
+func (stm *SeckillTCCManager) logPhase(ctx *SeckillTCCContext, resource SeckillTCCResource, phase string, start time.Time, err error) {
+	ev := PhaseEvent{
+		TxID:     ctx.TransactionID,
+		Resource: resourceName(resource),
+		Phase:    strings.ToLower(phase),
+		Duration: time.Since(start),
+		Err:      err,
+	}
+	var l Logger = textLogger{}
+	if stm.Logger != nil {
+		l = stm.Logger
+	}
+	l.LogPhase(ev)
+}
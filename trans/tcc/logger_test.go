@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestJSONLoggerCommitFields(t *testing.T) {
+	var buf bytes.Buffer
+	manager := NewSeckillTCCManager()
+	manager.Logger = NewJSONLogger(&buf)
+	manager.AddResource(&cancelRecorder{})
+	if err := manager.ExecuteSeckillTCC(testContext()); err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []map[string]any
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var l map[string]any
+		if err := dec.Decode(&l); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, l)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want try and confirm: %v", len(lines), lines)
+	}
+	for i, phase := range []string{"try", "confirm"} {
+		l := lines[i]
+		if l["txID"] != "seckill_1" || l["resource"] != "cancelRecorder" || l["phase"] != phase || l["outcome"] != "ok" {
+			t.Fatalf("line %d = %v", i, l)
+		}
+		if _, ok := l["durationMs"].(float64); !ok {
+			t.Fatalf("line %d has no durationMs: %v", i, l)
+		}
+	}
+}
@@ -0,0 +1,6 @@
+package main
+
+// ResetLowStockAlert 补货后重新开始监控该商品，库存再次低于阈值时会重新触发 OnLowStock
+func (sir *SeckillInventoryResource) ResetLowStockAlert(productID int64) {
+	sir.lowStock.Reset(productID)
+}
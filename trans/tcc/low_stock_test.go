@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestOnLowStockFiresOnceAfterCommit(t *testing.T) {
+	defer discardLog()()
+	db, store := newFakeSeckillDB(1001)
+	defer db.Close()
+	store.setStock(2001, 5)
+
+	var calls []int
+	inv := NewSeckillInventoryResource(db)
+	inv.LowStockThreshold = 3
+	inv.OnLowStock = func(productID int64, remaining int) {
+		if productID != 2001 {
+			t.Errorf("productID = %d, want 2001", productID)
+		}
+		calls = append(calls, remaining)
+	}
+
+	try := func(i int) error {
+		ctx := testContext()
+		ctx.TransactionID = fmt.Sprintf("seckill_%d", i)
+		return inv.Try(ctx)
+	}
+	// 5 -> 4 -> 3 都不低于阈值
+	for i := 0; i < 2; i++ {
+		if err := try(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 会把库存降到 2 的扣减失败回滚，不能触发
+	store.failInventoryUpdate.Store(true)
+	if err := try(2); err == nil {
+		t.Fatal("expected Try to fail")
+	}
+	store.failInventoryUpdate.Store(false)
+	if len(calls) != 0 || store.stock(2001) != 3 {
+		t.Fatalf("after rollback: calls = %v, stock = %d", calls, store.stock(2001))
+	}
+	// 3 -> 2 触发，2 -> 1 去抖
+	for i := 3; i < 5; i++ {
+		if err := try(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(calls) != 1 || calls[0] != 2 {
+		t.Fatalf("OnLowStock calls = %v, want exactly [2]", calls)
+	}
+
+	// 补货后重新计数
+	inv.ResetLowStockAlert(2001)
+	if err := try(5); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 2 || calls[1] != 0 {
+		t.Fatalf("OnLowStock calls after reset = %v, want [2 0]", calls)
+	}
+}
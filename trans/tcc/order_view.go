@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"test/trans/internal/orderview"
+)
+
+// ErrOrderNotFound 事务ID没有对应的订单（Try 未执行或尚未提交）
+var ErrOrderNotFound = orderview.ErrNotFound
+
+// OrderView 对用户展示的订单状态
+type OrderView = orderview.OrderView
+
+func (sor *SeckillOrderResource) orders() orderview.Source {
+	return orderview.Source{DB: sor.db, Table: "seckill_orders", PriceColumn: "price"}
+}
+
+// GetOrder 按事务ID查询订单，没有订单时返回 ErrOrderNotFound
+func (sor *SeckillOrderResource) GetOrder(ctx context.Context, transactionID string) (*OrderView, error) {
+	return sor.orders().Get(ctx, transactionID)
+}
+
+// ListUserOrders 按创建时间倒序分页列出用户的订单，见 orderview.Source.ListByUser
+func (sor *SeckillOrderResource) ListUserOrders(ctx context.Context, userID int64, limit, offset int) ([]OrderView, error) {
+	return sor.orders().ListByUser(ctx, userID, limit, offset)
+}
@@ -1,10 +1,17 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"math/rand"
+	"os"
 	"sync"
+	"test/trans/internal/lowstock"
+	"test/trans/internal/tccutil"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -28,56 +35,146 @@ type SeckillTCCResource interface {
 	Cancel(ctx *SeckillTCCContext) error
 }
 
+// TCC 资源名称，用于 tcc_phase_log 幂等记录
+const (
+	resourceInventory = "inventory"
+	resourceAccount   = "account"
+	resourceOrder     = "order"
+)
+
+// recordOnce 在 Try/Confirm/Cancel 的事务内写入 (tx_id, resource, phase) 幂等记录。
+// 唯一键冲突说明该阶段已执行过，返回 false，调用方应直接返回（重放视为成功）；
+// 记录与业务修改在同一事务中提交，业务失败回滚时记录也一并回滚，可以重试。
+// Try 的 COMMIT 可能已生效但响应丢失，管理器按暂时性错误重试时靠这条记录避免重复冻结。
+func recordOnce(tx *sql.Tx, txID, resource, phase string, now time.Time) (bool, error) {
+	result, err := tx.Exec(`
+		INSERT IGNORE INTO tcc_phase_log (tx_id, resource, phase, created_at)
+		VALUES (?, ?, ?, ?)
+	`, txID, resource, phase, now)
+	if err != nil {
+		return false, dbError(fmt.Sprintf("记录%s %s幂等日志失败", resource, phase), err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, dbError("检查幂等日志结果失败", err)
+	}
+	return rowsAffected > 0, nil
+}
+
 // SeckillInventoryResource 秒杀库存资源（重点优化）
 type SeckillInventoryResource struct {
 	db    *sql.DB
 	mutex sync.RWMutex // 读写锁保护
+
+	// Gate 可选的内存库存闸门，为 nil 时每个请求都访问数据库
+	Gate *StockGate
+
+	// Clock 写入 updated_at 等时间戳的时间来源，nil 时使用系统时钟
+	Clock Clock
+
+	// IsolationLevel Try/Confirm/Cancel 事务的隔离级别，零值沿用服务器默认（MySQL 为 REPEATABLE READ）。
+	// 扣减前先 FOR UPDATE 锁住库存行，锁定读总是读最新提交的数据，READ COMMITTED 下同样不会超卖，
+	// 且不加间隙锁，冻结记录的并发插入互不阻塞，热点商品下锁等待更少
+	IsolationLevel sql.IsolationLevel
+
+	// LowStockThreshold 冻结后可用库存低于该值时触发 OnLowStock，<=0 表示不检查
+	LowStockThreshold int
+	// OnLowStock 库存低于 LowStockThreshold 时在 Try 事务提交后回调，每个商品只触发一次，
+	// 补货后调用 ResetLowStockAlert 重新计数；回调在执行 Try 的 goroutine 中运行，耗时操作应自行异步
+	OnLowStock func(productID int64, remaining int)
+	lowStock   lowstock.Alert
 }
 
 func NewSeckillInventoryResource(db *sql.DB) *SeckillInventoryResource {
 	return &SeckillInventoryResource{db: db}
 }
 
+func (sir *SeckillInventoryResource) now() time.Time { return clockNow(sir.Clock) }
+
+func (sir *SeckillInventoryResource) Name() string { return resourceInventory }
+
 // Try 预扣库存 - 高并发优化版本
 func (sir *SeckillInventoryResource) Try(ctx *SeckillTCCContext) error {
-	tx, err := sir.db.Begin()
+	if sir.Gate == nil {
+		return sir.try(ctx)
+	}
+
+	// 内存闸门先拦截明显超卖的请求，不访问数据库
+	if !sir.Gate.Acquire(ctx.ProductID, ctx.Quantity) {
+		return businessErrorf("库存不足: 商品%d已售罄", ctx.ProductID)
+	}
+	if err := sir.try(ctx); err != nil {
+		// 数据库扣减失败，归还闸门额度，避免少卖
+		sir.Gate.Release(ctx.ProductID, ctx.Quantity)
+		return err
+	}
+	return nil
+}
+
+func (sir *SeckillInventoryResource) try(ctx *SeckillTCCContext) (err error) {
+	tx, err := sir.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sir.IsolationLevel})
 	if err != nil {
-		return fmt.Errorf("开始事务失败: %v", err)
+		return dbError("开始事务失败", err)
+	}
+	// 失败时显式回滚：锁等待超时（1205）时 MySQL 默认只回滚当前语句，
+	// 事务仍持有已加的锁，必须回滚才能释放；回滚失败只记录日志，不覆盖原始错误
+	defer func() {
+		if err == nil {
+			return
+		}
+		if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			log.Printf("[Seckill Try] 回滚事务失败: %v (原始错误: %v)", rbErr, err)
+		}
+	}()
+
+	// 幂等：上次 Try 已提交（例如 COMMIT 的响应丢失后重试）则直接返回，不重复冻结
+	first, err := recordOnce(tx, ctx.TransactionID, resourceInventory, "TRY", sir.now())
+	if err != nil {
+		return err
+	}
+	if !first {
+		log.Printf("[Seckill Try] 事务%s已执行过，跳过", ctx.TransactionID)
+		if err = tx.Rollback(); err != nil {
+			return dbError("回滚事务失败", err)
+		}
+		return nil
 	}
-	defer tx.Rollback()
 
 	// 1. 使用行锁查询当前库存（FOR UPDATE确保并发安全）
 	var currentStock int
-	err = tx.QueryRow(`
+	err = tccutil.QueryRowRetry(context.Background(), tx, `
 		SELECT stock FROM seckill_inventory 
 		WHERE product_id = ? FOR UPDATE
-	`, ctx.ProductID).Scan(&currentStock)
+	`, []interface{}{ctx.ProductID}, &currentStock)
+	if err == sql.ErrNoRows {
+		return businessErrorf("商品%d不存在", ctx.ProductID)
+	}
 	if err != nil {
-		return fmt.Errorf("查询商品库存失败: %v", err)
+		return dbError("查询商品库存失败", err)
 	}
 
 	// 2. 检查库存是否充足
 	if currentStock < ctx.Quantity {
-		return fmt.Errorf("库存不足: 剩余%d, 需要%d", currentStock, ctx.Quantity)
+		return businessErrorf("库存不足: 剩余%d, 需要%d", currentStock, ctx.Quantity)
 	}
 
 	// 3. 原子性扣减可用库存，增加冻结库存
-	result, err := tx.Exec(`
+	result, err := tccutil.ExecRetry(context.Background(), tx, `
 		UPDATE seckill_inventory 
 		SET stock = stock - ?, frozen_stock = frozen_stock + ?, updated_at = ? 
 		WHERE product_id = ? AND stock >= ?
-	`, ctx.Quantity, ctx.Quantity, time.Now(), ctx.ProductID, ctx.Quantity)
+	`, ctx.Quantity, ctx.Quantity, sir.now(), ctx.ProductID, ctx.Quantity)
 	if err != nil {
-		return fmt.Errorf("冻结库存失败: %v", err)
+		return dbError("冻结库存失败", err)
 	}
 
 	// 4. 检查是否真正更新了记录（防止并发导致的库存不足）
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("检查更新结果失败: %v", err)
+		return dbError("检查更新结果失败", err)
 	}
 	if rowsAffected == 0 {
-		return fmt.Errorf("库存不足或商品不存在")
+		return businessErrorf("库存不足或商品不存在")
 	}
 
 	// 5. 记录冻结详情（用于后续Confirm/Cancel）
@@ -87,25 +184,36 @@ func (sir *SeckillInventoryResource) Try(ctx *SeckillTCCContext) error {
 		VALUES (?, ?, ?, 'FROZEN', ?, ?)
 	`, ctx.TransactionID, ctx.ProductID, ctx.Quantity, ctx.CreatedAt, ctx.CreatedAt.Add(ctx.Timeout))
 	if err != nil {
-		return fmt.Errorf("记录冻结信息失败: %v", err)
+		return dbError("记录冻结信息失败", err)
 	}
 
 	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("提交事务失败: %v", err)
+		return dbError("提交事务失败", err)
 	}
 
+	// 行已 FOR UPDATE 锁住，扣减后的库存就是 currentStock - Quantity；只在提交后通知，回滚的冻结不会触发
+	sir.lowStock.Notify(sir.LowStockThreshold, sir.OnLowStock, ctx.ProductID, currentStock-ctx.Quantity)
+
 	log.Printf("[Seckill Try] 成功冻结商品%d库存%d个", ctx.ProductID, ctx.Quantity)
 	return nil
 }
 
 // Confirm 确认扣库存 - 将冻结库存转为已售
 func (sir *SeckillInventoryResource) Confirm(ctx *SeckillTCCContext) error {
-	tx, err := sir.db.Begin()
+	tx, err := sir.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sir.IsolationLevel})
 	if err != nil {
-		return fmt.Errorf("开始事务失败: %v", err)
+		return dbError("开始事务失败", err)
 	}
 	defer tx.Rollback()
 
+	// 幂等：该阶段已执行过则直接返回
+	if first, err := recordOnce(tx, ctx.TransactionID, resourceInventory, "CONFIRM", sir.now()); err != nil || !first {
+		if err == nil {
+			log.Printf("[Seckill Confirm] 事务%s已执行过，跳过", ctx.TransactionID)
+		}
+		return err
+	}
+
 	// 1. 检查冻结记录是否存在
 	var frozenQuantity int
 	err = tx.QueryRow(`
@@ -114,9 +222,9 @@ func (sir *SeckillInventoryResource) Confirm(ctx *SeckillTCCContext) error {
 	`, ctx.TransactionID, ctx.ProductID).Scan(&frozenQuantity)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return fmt.Errorf("未找到冻结记录")
+			return fatalErrorf("未找到冻结记录")
 		}
-		return fmt.Errorf("查询冻结记录失败: %v", err)
+		return dbError("查询冻结记录失败", err)
 	}
 
 	// 2. 减少冻结库存，增加已售库存
@@ -124,9 +232,9 @@ func (sir *SeckillInventoryResource) Confirm(ctx *SeckillTCCContext) error {
 		UPDATE seckill_inventory 
 		SET frozen_stock = frozen_stock - ?, sold_stock = sold_stock + ?, updated_at = ? 
 		WHERE product_id = ?
-	`, frozenQuantity, frozenQuantity, time.Now(), ctx.ProductID)
+	`, frozenQuantity, frozenQuantity, sir.now(), ctx.ProductID)
 	if err != nil {
-		return fmt.Errorf("确认库存扣减失败: %v", err)
+		return dbError("确认库存扣减失败", err)
 	}
 
 	// 3. 更新冻结记录状态
@@ -134,13 +242,13 @@ func (sir *SeckillInventoryResource) Confirm(ctx *SeckillTCCContext) error {
 		UPDATE seckill_inventory_freeze 
 		SET status = 'CONFIRMED', updated_at = ? 
 		WHERE transaction_id = ? AND product_id = ?
-	`, time.Now(), ctx.TransactionID, ctx.ProductID)
+	`, sir.now(), ctx.TransactionID, ctx.ProductID)
 	if err != nil {
-		return fmt.Errorf("更新冻结记录失败: %v", err)
+		return dbError("更新冻结记录失败", err)
 	}
 
 	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("提交事务失败: %v", err)
+		return dbError("提交事务失败", err)
 	}
 
 	log.Printf("[Seckill Confirm] 成功确认商品%d库存%d个", ctx.ProductID, frozenQuantity)
@@ -149,12 +257,20 @@ func (sir *SeckillInventoryResource) Confirm(ctx *SeckillTCCContext) error {
 
 // Cancel 取消扣库存 - 释放冻结库存
 func (sir *SeckillInventoryResource) Cancel(ctx *SeckillTCCContext) error {
-	tx, err := sir.db.Begin()
+	tx, err := sir.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sir.IsolationLevel})
 	if err != nil {
-		return fmt.Errorf("开始事务失败: %v", err)
+		return dbError("开始事务失败", err)
 	}
 	defer tx.Rollback()
 
+	// 幂等：该阶段已执行过则直接返回
+	if first, err := recordOnce(tx, ctx.TransactionID, resourceInventory, "CANCEL", sir.now()); err != nil || !first {
+		if err == nil {
+			log.Printf("[Seckill Cancel] 事务%s已执行过，跳过", ctx.TransactionID)
+		}
+		return err
+	}
+
 	// 1. 查询冻结记录
 	var frozenQuantity int
 	var status string
@@ -167,7 +283,7 @@ func (sir *SeckillInventoryResource) Cancel(ctx *SeckillTCCContext) error {
 			log.Printf("[Seckill Cancel] 未找到需要取消的记录")
 			return nil
 		}
-		return fmt.Errorf("查询冻结记录失败: %v", err)
+		return dbError("查询冻结记录失败", err)
 	}
 
 	// 2. 根据状态进行不同的处理
@@ -177,17 +293,17 @@ func (sir *SeckillInventoryResource) Cancel(ctx *SeckillTCCContext) error {
 			UPDATE seckill_inventory 
 			SET stock = stock + ?, frozen_stock = frozen_stock - ?, updated_at = ? 
 			WHERE product_id = ?
-		`, frozenQuantity, frozenQuantity, time.Now(), ctx.ProductID)
+		`, frozenQuantity, frozenQuantity, sir.now(), ctx.ProductID)
 	} else if status == "CONFIRMED" {
 		// 已确认状态：从已售库存中恢复到可用库存
 		_, err = tx.Exec(`
 			UPDATE seckill_inventory 
 			SET stock = stock + ?, sold_stock = sold_stock - ?, updated_at = ? 
 			WHERE product_id = ?
-		`, frozenQuantity, frozenQuantity, time.Now(), ctx.ProductID)
+		`, frozenQuantity, frozenQuantity, sir.now(), ctx.ProductID)
 	}
 	if err != nil {
-		return fmt.Errorf("释放库存失败: %v", err)
+		return dbError("释放库存失败", err)
 	}
 
 	// 3. 更新冻结记录状态
@@ -195,60 +311,152 @@ func (sir *SeckillInventoryResource) Cancel(ctx *SeckillTCCContext) error {
 		UPDATE seckill_inventory_freeze 
 		SET status = 'CANCELLED', updated_at = ? 
 		WHERE transaction_id = ? AND product_id = ?
-	`, time.Now(), ctx.TransactionID, ctx.ProductID)
+	`, sir.now(), ctx.TransactionID, ctx.ProductID)
 	if err != nil {
-		return fmt.Errorf("更新冻结记录失败: %v", err)
+		return dbError("更新冻结记录失败", err)
 	}
 
 	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("提交事务失败: %v", err)
+		return dbError("提交事务失败", err)
+	}
+
+	// 库存已回到可用库存，同步归还闸门额度
+	if sir.Gate != nil {
+		sir.Gate.Release(ctx.ProductID, frozenQuantity)
 	}
 
 	log.Printf("[Seckill Cancel] 成功取消商品%d库存%d个，状态:%s", ctx.ProductID, frozenQuantity, status)
 	return nil
 }
 
+// ErrInventoryDiscrepancy 库存表的冻结/已售数量与冻结记录表汇总不一致
+var ErrInventoryDiscrepancy = errors.New("库存对账不一致")
+
+// InventorySnapshot 商品库存快照，Available+Frozen+Sold 即商品初始总库存
+type InventorySnapshot struct {
+	ProductID int64
+	Available int // 可用库存
+	Frozen    int // 冻结中（Try 成功，尚未 Confirm/Cancel）
+	Sold      int // 已售
+}
+
+// Total 初始总库存
+func (s InventorySnapshot) Total() int {
+	return s.Available + s.Frozen + s.Sold
+}
+
+// InventorySnapshot 读取商品库存并与冻结记录对账：
+// frozen_stock 应等于 FROZEN 记录数量之和，sold_stock 应等于 CONFIRMED 记录数量之和。
+// 不一致时同时返回快照和 ErrInventoryDiscrepancy。
+func (sir *SeckillInventoryResource) InventorySnapshot(ctx context.Context, productID int64) (InventorySnapshot, error) {
+	snap := InventorySnapshot{ProductID: productID}
+
+	// 两次查询放在同一个只读事务中，读到同一个一致性快照
+	tx, err := sir.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return snap, fmt.Errorf("开始事务失败: %v", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+		SELECT stock, frozen_stock, sold_stock FROM seckill_inventory
+		WHERE product_id = ?
+	`, productID).Scan(&snap.Available, &snap.Frozen, &snap.Sold)
+	if err != nil {
+		return snap, fmt.Errorf("查询商品库存失败: %v", err)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT status, COALESCE(SUM(quantity), 0) FROM seckill_inventory_freeze
+		WHERE product_id = ? GROUP BY status
+	`, productID)
+	if err != nil {
+		return snap, fmt.Errorf("汇总冻结记录失败: %v", err)
+	}
+	defer rows.Close()
+
+	sums := make(map[string]int)
+	for rows.Next() {
+		var status string
+		var quantity int
+		if err := rows.Scan(&status, &quantity); err != nil {
+			return snap, fmt.Errorf("汇总冻结记录失败: %v", err)
+		}
+		sums[status] = quantity
+	}
+	if err := rows.Err(); err != nil {
+		return snap, fmt.Errorf("汇总冻结记录失败: %v", err)
+	}
+
+	if sums["FROZEN"] != snap.Frozen || sums["CONFIRMED"] != snap.Sold {
+		return snap, fmt.Errorf("%w: 商品%d frozen_stock=%d(记录%d), sold_stock=%d(记录%d)",
+			ErrInventoryDiscrepancy, productID, snap.Frozen, sums["FROZEN"], snap.Sold, sums["CONFIRMED"])
+	}
+	return snap, nil
+}
+
 // SeckillAccountResource 秒杀账户资源
 type SeckillAccountResource struct {
 	db *sql.DB
+
+	// IsolationLevel 事务隔离级别，取舍同 SeckillInventoryResource.IsolationLevel
+	IsolationLevel sql.IsolationLevel
+
+	// Clock 时间来源，nil 时使用系统时钟
+	Clock Clock
 }
 
+func (sar *SeckillAccountResource) now() time.Time { return clockNow(sar.Clock) }
+
+func (sar *SeckillAccountResource) Name() string { return resourceAccount }
+
 func NewSeckillAccountResource(db *sql.DB) *SeckillAccountResource {
 	return &SeckillAccountResource{db: db}
 }
 
 // Try 预扣余额
 func (sar *SeckillAccountResource) Try(ctx *SeckillTCCContext) error {
-	tx, err := sar.db.Begin()
+	tx, err := sar.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sar.IsolationLevel})
 	if err != nil {
-		return fmt.Errorf("开始事务失败: %v", err)
+		return dbError("开始事务失败", err)
 	}
 	defer tx.Rollback()
 
+	// 幂等：上次 Try 已提交则直接返回，不重复冻结余额
+	if first, err := recordOnce(tx, ctx.TransactionID, resourceAccount, "TRY", sar.now()); err != nil || !first {
+		if err == nil {
+			log.Printf("[Seckill Account Try] 事务%s已执行过，跳过", ctx.TransactionID)
+		}
+		return err
+	}
+
 	totalAmount := ctx.Price * float64(ctx.Quantity)
 
 	// 1. 检查余额是否充足（行锁）
 	var balance float64
-	err = tx.QueryRow(`
+	err = tccutil.QueryRowRetry(context.Background(), tx, `
 		SELECT balance FROM seckill_account 
 		WHERE user_id = ? FOR UPDATE
-	`, ctx.UserID).Scan(&balance)
+	`, []interface{}{ctx.UserID}, &balance)
+	if err == sql.ErrNoRows {
+		return businessErrorf("用户%d账户不存在", ctx.UserID)
+	}
 	if err != nil {
-		return fmt.Errorf("查询账户余额失败: %v", err)
+		return dbError("查询账户余额失败", err)
 	}
 
 	if balance < totalAmount {
-		return fmt.Errorf("余额不足: 余额%.2f, 需要%.2f", balance, totalAmount)
+		return businessErrorf("余额不足: 余额%.2f, 需要%.2f", balance, totalAmount)
 	}
 
 	// 2. 冻结金额
-	_, err = tx.Exec(`
+	_, err = tccutil.ExecRetry(context.Background(), tx, `
 		UPDATE seckill_account 
 		SET balance = balance - ?, frozen_balance = frozen_balance + ?, updated_at = ? 
 		WHERE user_id = ? AND balance >= ?
-	`, totalAmount, totalAmount, time.Now(), ctx.UserID, totalAmount)
+	`, totalAmount, totalAmount, sar.now(), ctx.UserID, totalAmount)
 	if err != nil {
-		return fmt.Errorf("冻结余额失败: %v", err)
+		return dbError("冻结余额失败", err)
 	}
 
 	// 3. 记录冻结详情
@@ -258,11 +466,11 @@ func (sar *SeckillAccountResource) Try(ctx *SeckillTCCContext) error {
 		VALUES (?, ?, ?, 'FROZEN', ?, ?)
 	`, ctx.TransactionID, ctx.UserID, totalAmount, ctx.CreatedAt, ctx.CreatedAt.Add(ctx.Timeout))
 	if err != nil {
-		return fmt.Errorf("记录冻结信息失败: %v", err)
+		return dbError("记录冻结信息失败", err)
 	}
 
 	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("提交事务失败: %v", err)
+		return dbError("提交事务失败", err)
 	}
 
 	log.Printf("[Seckill Account Try] 成功冻结用户%d余额%.2f", ctx.UserID, totalAmount)
@@ -271,12 +479,20 @@ func (sar *SeckillAccountResource) Try(ctx *SeckillTCCContext) error {
 
 // Confirm 确认扣款
 func (sar *SeckillAccountResource) Confirm(ctx *SeckillTCCContext) error {
-	tx, err := sar.db.Begin()
+	tx, err := sar.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sar.IsolationLevel})
 	if err != nil {
-		return fmt.Errorf("开始事务失败: %v", err)
+		return dbError("开始事务失败", err)
 	}
 	defer tx.Rollback()
 
+	// 幂等：该阶段已执行过则直接返回
+	if first, err := recordOnce(tx, ctx.TransactionID, resourceAccount, "CONFIRM", sar.now()); err != nil || !first {
+		if err == nil {
+			log.Printf("[Seckill Account Confirm] 事务%s已执行过，跳过", ctx.TransactionID)
+		}
+		return err
+	}
+
 	// 1. 查询冻结金额
 	var frozenAmount float64
 	err = tx.QueryRow(`
@@ -284,7 +500,10 @@ func (sar *SeckillAccountResource) Confirm(ctx *SeckillTCCContext) error {
 		WHERE transaction_id = ? AND user_id = ? AND status = 'FROZEN'
 	`, ctx.TransactionID, ctx.UserID).Scan(&frozenAmount)
 	if err != nil {
-		return fmt.Errorf("查询冻结记录失败: %v", err)
+		if err == sql.ErrNoRows {
+			return fatalErrorf("未找到冻结记录")
+		}
+		return dbError("查询冻结记录失败", err)
 	}
 
 	// 2. 确认扣款：减少冻结余额
@@ -292,9 +511,9 @@ func (sar *SeckillAccountResource) Confirm(ctx *SeckillTCCContext) error {
 		UPDATE seckill_account 
 		SET frozen_balance = frozen_balance - ?, updated_at = ? 
 		WHERE user_id = ?
-	`, frozenAmount, time.Now(), ctx.UserID)
+	`, frozenAmount, sar.now(), ctx.UserID)
 	if err != nil {
-		return fmt.Errorf("确认扣款失败: %v", err)
+		return dbError("确认扣款失败", err)
 	}
 
 	// 3. 更新冻结记录状态
@@ -302,13 +521,13 @@ func (sar *SeckillAccountResource) Confirm(ctx *SeckillTCCContext) error {
 		UPDATE seckill_account_freeze 
 		SET status = 'CONFIRMED', updated_at = ? 
 		WHERE transaction_id = ? AND user_id = ?
-	`, time.Now(), ctx.TransactionID, ctx.UserID)
+	`, sar.now(), ctx.TransactionID, ctx.UserID)
 	if err != nil {
-		return fmt.Errorf("更新冻结记录失败: %v", err)
+		return dbError("更新冻结记录失败", err)
 	}
 
 	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("提交事务失败: %v", err)
+		return dbError("提交事务失败", err)
 	}
 
 	log.Printf("[Seckill Account Confirm] 成功确认用户%d扣款%.2f", ctx.UserID, frozenAmount)
@@ -317,12 +536,20 @@ func (sar *SeckillAccountResource) Confirm(ctx *SeckillTCCContext) error {
 
 // Cancel 取消扣款
 func (sar *SeckillAccountResource) Cancel(ctx *SeckillTCCContext) error {
-	tx, err := sar.db.Begin()
+	tx, err := sar.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sar.IsolationLevel})
 	if err != nil {
-		return fmt.Errorf("开始事务失败: %v", err)
+		return dbError("开始事务失败", err)
 	}
 	defer tx.Rollback()
 
+	// 幂等：该阶段已执行过则直接返回
+	if first, err := recordOnce(tx, ctx.TransactionID, resourceAccount, "CANCEL", sar.now()); err != nil || !first {
+		if err == nil {
+			log.Printf("[Seckill Account Cancel] 事务%s已执行过，跳过", ctx.TransactionID)
+		}
+		return err
+	}
+
 	// 1. 查询冻结记录
 	var frozenAmount float64
 	var status string
@@ -335,7 +562,7 @@ func (sar *SeckillAccountResource) Cancel(ctx *SeckillTCCContext) error {
 			log.Printf("[Seckill Account Cancel] 未找到需要取消的记录")
 			return nil
 		}
-		return fmt.Errorf("查询冻结记录失败: %v", err)
+		return dbError("查询冻结记录失败", err)
 	}
 
 	// 2. 根据状态进行不同处理
@@ -345,17 +572,17 @@ func (sar *SeckillAccountResource) Cancel(ctx *SeckillTCCContext) error {
 			UPDATE seckill_account 
 			SET balance = balance + ?, frozen_balance = frozen_balance - ?, updated_at = ? 
 			WHERE user_id = ?
-		`, frozenAmount, frozenAmount, time.Now(), ctx.UserID)
+		`, frozenAmount, frozenAmount, sar.now(), ctx.UserID)
 	} else if status == "CONFIRMED" {
 		// 已确认状态：退款到可用余额
 		_, err = tx.Exec(`
 			UPDATE seckill_account 
 			SET balance = balance + ?, updated_at = ? 
 			WHERE user_id = ?
-		`, frozenAmount, time.Now(), ctx.UserID)
+		`, frozenAmount, sar.now(), ctx.UserID)
 	}
 	if err != nil {
-		return fmt.Errorf("释放余额失败: %v", err)
+		return dbError("释放余额失败", err)
 	}
 
 	// 3. 更新冻结记录状态
@@ -363,39 +590,117 @@ func (sar *SeckillAccountResource) Cancel(ctx *SeckillTCCContext) error {
 		UPDATE seckill_account_freeze 
 		SET status = 'CANCELLED', updated_at = ? 
 		WHERE transaction_id = ? AND user_id = ?
-	`, time.Now(), ctx.TransactionID, ctx.UserID)
+	`, sar.now(), ctx.TransactionID, ctx.UserID)
 	if err != nil {
-		return fmt.Errorf("更新冻结记录失败: %v", err)
+		return dbError("更新冻结记录失败", err)
 	}
 
 	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("提交事务失败: %v", err)
+		return dbError("提交事务失败", err)
 	}
 
 	log.Printf("[Seckill Account Cancel] 成功取消用户%d金额%.2f，状态:%s", ctx.UserID, frozenAmount, status)
 	return nil
 }
 
+// ErrPurchaseLimitExceeded 超出单用户限购数量
+var ErrPurchaseLimitExceeded = errors.New("超出限购数量")
+
 // SeckillOrderResource 秒杀订单资源
 type SeckillOrderResource struct {
 	db *sql.DB
+
+	// PerUserLimit 单个用户对同一商品的最大购买数量（未取消的订单合计），<=0 表示不限购
+	PerUserLimit int
+
+	// IsolationLevel 事务隔离级别，零值沿用服务器默认。Try 的防悬挂检查依赖 REPEATABLE READ 下
+	// FOR UPDATE 对不存在记录加的间隙锁挡住并发的 Cancel；改为 READ COMMITTED 时没有间隙锁，
+	// Try 与 Cancel 并发时仍可能留下孤儿订单
+	IsolationLevel sql.IsolationLevel
+
+	// Clock 时间来源，nil 时使用系统时钟
+	Clock Clock
 }
 
+func (sor *SeckillOrderResource) now() time.Time { return clockNow(sor.Clock) }
+
+func (sor *SeckillOrderResource) Name() string { return resourceOrder }
+
 func NewSeckillOrderResource(db *sql.DB) *SeckillOrderResource {
 	return &SeckillOrderResource{db: db}
 }
 
-// Try 创建预订单
+// Try 在事务内创建预订单，与库存、账户资源一样以资源级事务保证原子性。
+// 事务先检查本资源是否已执行过 Cancel（空回滚后 Try 才到达，即悬挂），是则拒绝，
+// 否则迟到的 Try 会插入一条再也不会被取消的 PENDING 订单；
+// 设置了限购时锁住用户账户行，同一用户的并发 Try 在此串行，统计与插入之间不会有其他订单插进来。
 func (sor *SeckillOrderResource) Try(ctx *SeckillTCCContext) error {
-	totalAmount := ctx.Price * float64(ctx.Quantity)
+	tx, err := sor.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sor.IsolationLevel})
+	if err != nil {
+		return dbError("开始事务失败", err)
+	}
+	defer tx.Rollback()
+
+	// 1. 防悬挂：FOR UPDATE 锁住 Cancel 记录所在的间隙，并发的 Cancel 要等本事务结束后才能写入
+	var cancelled int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM tcc_phase_log 
+		WHERE tx_id = ? AND resource = ? AND phase = 'CANCEL' FOR UPDATE
+	`, ctx.TransactionID, resourceOrder).Scan(&cancelled)
+	if err != nil {
+		return dbError("检查取消记录失败", err)
+	}
+	if cancelled > 0 {
+		return businessErrorf("事务%s的订单已取消，拒绝创建预订单", ctx.TransactionID)
+	}
 
-	_, err := sor.db.Exec(`
+	// 幂等：上次 Try 已提交则直接返回，不重复创建预订单
+	if first, err := recordOnce(tx, ctx.TransactionID, resourceOrder, "TRY", sor.now()); err != nil || !first {
+		if err == nil {
+			log.Printf("[Seckill Order Try] 事务%s已执行过，跳过", ctx.TransactionID)
+		}
+		return err
+	}
+
+	if sor.PerUserLimit > 0 {
+		// 2. 锁定用户（FOR UPDATE 串行化同一用户的下单）
+		var userID int64
+		err = tccutil.QueryRowRetry(context.Background(), tx, `
+			SELECT user_id FROM seckill_account 
+			WHERE user_id = ? FOR UPDATE
+		`, []interface{}{ctx.UserID}, &userID)
+		if err != nil {
+			return dbError("锁定用户失败", err)
+		}
+
+		// 3. 统计该用户对该商品未取消的购买数量
+		var bought int
+		err = tx.QueryRow(`
+			SELECT COALESCE(SUM(quantity), 0) FROM seckill_orders 
+			WHERE user_id = ? AND product_id = ? AND status != 'CANCELLED'
+		`, ctx.UserID, ctx.ProductID).Scan(&bought)
+		if err != nil {
+			return dbError("统计已购数量失败", err)
+		}
+		if bought+ctx.Quantity > sor.PerUserLimit {
+			return businessErrorf("%w: 用户%d已购%d, 本次%d, 限购%d",
+				ErrPurchaseLimitExceeded, ctx.UserID, bought, ctx.Quantity, sor.PerUserLimit)
+		}
+	}
+
+	// 4. 创建预订单
+	totalAmount := ctx.Price * float64(ctx.Quantity)
+	_, err = tx.Exec(`
 		INSERT INTO seckill_orders 
 		(transaction_id, user_id, product_id, quantity, price, total_amount, status, created_at) 
 		VALUES (?, ?, ?, ?, ?, ?, 'PENDING', ?)
 	`, ctx.TransactionID, ctx.UserID, ctx.ProductID, ctx.Quantity, ctx.Price, totalAmount, ctx.CreatedAt)
 	if err != nil {
-		return fmt.Errorf("创建预订单失败: %v", err)
+		return dbError("创建预订单失败", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return dbError("提交事务失败", err)
 	}
 
 	log.Printf("[Seckill Order Try] 成功创建预订单，用户%d商品%d", ctx.UserID, ctx.ProductID)
@@ -404,13 +709,31 @@ func (sor *SeckillOrderResource) Try(ctx *SeckillTCCContext) error {
 
 // Confirm 确认订单
 func (sor *SeckillOrderResource) Confirm(ctx *SeckillTCCContext) error {
-	_, err := sor.db.Exec(`
+	tx, err := sor.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sor.IsolationLevel})
+	if err != nil {
+		return dbError("开始事务失败", err)
+	}
+	defer tx.Rollback()
+
+	// 幂等：该阶段已执行过则直接返回
+	if first, err := recordOnce(tx, ctx.TransactionID, resourceOrder, "CONFIRM", sor.now()); err != nil || !first {
+		if err == nil {
+			log.Printf("[Seckill Order Confirm] 事务%s已执行过，跳过", ctx.TransactionID)
+		}
+		return err
+	}
+
+	_, err = tx.Exec(`
 		UPDATE seckill_orders 
 		SET status = 'CONFIRMED', updated_at = ? 
 		WHERE transaction_id = ? AND user_id = ?
-	`, time.Now(), ctx.TransactionID, ctx.UserID)
+	`, sor.now(), ctx.TransactionID, ctx.UserID)
 	if err != nil {
-		return fmt.Errorf("确认订单失败: %v", err)
+		return dbError("确认订单失败", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return dbError("提交事务失败", err)
 	}
 
 	log.Printf("[Seckill Order Confirm] 成功确认订单，用户%d", ctx.UserID)
@@ -419,13 +742,31 @@ func (sor *SeckillOrderResource) Confirm(ctx *SeckillTCCContext) error {
 
 // Cancel 取消订单
 func (sor *SeckillOrderResource) Cancel(ctx *SeckillTCCContext) error {
-	_, err := sor.db.Exec(`
+	tx, err := sor.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sor.IsolationLevel})
+	if err != nil {
+		return dbError("开始事务失败", err)
+	}
+	defer tx.Rollback()
+
+	// 幂等：该阶段已执行过则直接返回
+	if first, err := recordOnce(tx, ctx.TransactionID, resourceOrder, "CANCEL", sor.now()); err != nil || !first {
+		if err == nil {
+			log.Printf("[Seckill Order Cancel] 事务%s已执行过，跳过", ctx.TransactionID)
+		}
+		return err
+	}
+
+	_, err = tx.Exec(`
 		UPDATE seckill_orders 
 		SET status = 'CANCELLED', updated_at = ? 
 		WHERE transaction_id = ? AND user_id = ?
-	`, time.Now(), ctx.TransactionID, ctx.UserID)
+	`, sor.now(), ctx.TransactionID, ctx.UserID)
 	if err != nil {
-		return fmt.Errorf("取消订单失败: %v", err)
+		return dbError("取消订单失败", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return dbError("提交事务失败", err)
 	}
 
 	log.Printf("[Seckill Order Cancel] 成功取消订单，用户%d", ctx.UserID)
@@ -436,11 +777,23 @@ func (sor *SeckillOrderResource) Cancel(ctx *SeckillTCCContext) error {
 type SeckillTCCManager struct {
 	resources []SeckillTCCResource
 	mu        sync.RWMutex
+
+	// MaxRetries 单个资源操作遇到 Transient 错误时的最大重试次数，
+	// Business/Fatal 错误不重试，直接进入补偿
+	MaxRetries int
+	// RetryBackoff 首次重试前的等待时间，之后每次翻倍
+	RetryBackoff time.Duration
+	// Clock 事务创建时间和过期判断的时间来源，nil 时使用系统时钟
+	Clock Clock
+	// Logger 每个资源每个阶段的结构化日志，nil 时以文本输出到标准 log
+	Logger Logger
 }
 
 func NewSeckillTCCManager() *SeckillTCCManager {
 	return &SeckillTCCManager{
-		resources: make([]SeckillTCCResource, 0),
+		resources:    make([]SeckillTCCResource, 0),
+		MaxRetries:   3,
+		RetryBackoff: 20 * time.Millisecond,
 	}
 }
 
@@ -457,15 +810,18 @@ func (stm *SeckillTCCManager) ExecuteSeckillTCC(ctx *SeckillTCCContext) error {
 	defer stm.mu.RUnlock()
 
 	log.Printf("[Seckill TCC] 开始执行秒杀事务: %s", ctx.TransactionID)
+	if ctx.CreatedAt.IsZero() {
+		ctx.CreatedAt = clockNow(stm.Clock)
+	}
 
 	// Phase 1: Try阶段 - 预留所有资源
 	// var trySuccessCount int
 	for i, resource := range stm.resources {
-		if err := resource.Try(ctx); err != nil {
+		if err := stm.retry("Try", i, ctx, resource.Try); err != nil {
 			log.Printf("[Seckill TCC] Try阶段失败，资源%d: %v", i, err)
 			// Try失败，回滚已成功的Try操作
 			stm.cancelResources(ctx)
-			return fmt.Errorf("秒杀TCC Try阶段失败: %v", err)
+			return fmt.Errorf("秒杀TCC Try阶段失败: %w", err)
 		}
 		// trySuccessCount++
 	}
@@ -474,11 +830,11 @@ func (stm *SeckillTCCManager) ExecuteSeckillTCC(ctx *SeckillTCCContext) error {
 
 	// Phase 2: Confirm阶段 - 确认提交
 	for i, resource := range stm.resources {
-		if err := resource.Confirm(ctx); err != nil {
+		if err := stm.retry("Confirm", i, ctx, resource.Confirm); err != nil {
 			log.Printf("[Seckill TCC] Confirm阶段失败，资源%d: %v", i, err)
 			// Confirm失败，执行Cancel补偿
 			stm.cancelResources(ctx)
-			return fmt.Errorf("秒杀TCC Confirm阶段失败: %v", err)
+			return fmt.Errorf("秒杀TCC Confirm阶段失败: %w", err)
 		}
 	}
 
@@ -490,12 +846,75 @@ func (stm *SeckillTCCManager) ExecuteSeckillTCC(ctx *SeckillTCCContext) error {
 func (stm *SeckillTCCManager) cancelResources(ctx *SeckillTCCContext) {
 	log.Printf("[Seckill TCC] 开始执行Cancel补偿操作")
 	for i, resource := range stm.resources {
-		if err := resource.Cancel(ctx); err != nil {
+		if err := stm.retry("Cancel", i, ctx, resource.Cancel); err != nil {
 			log.Printf("[Seckill TCC] Cancel补偿失败，资源%d: %v", i, err)
 		}
 	}
 }
 
+// ExpireStale 取消已过期仍处于 FROZEN 的事务，返回被取消的事务ID。
+// 过期以 Clock 为准而不是数据库的 NOW()，与 Try 写入 expires_at 的时间来源一致
+func (stm *SeckillTCCManager) ExpireStale(db *sql.DB) ([]string, error) {
+	rows, err := db.Query(`
+		SELECT f.transaction_id, f.product_id, f.quantity, COALESCE(af.user_id, o.user_id, 0)
+		FROM seckill_inventory_freeze f
+		LEFT JOIN seckill_account_freeze af ON af.transaction_id = f.transaction_id
+		LEFT JOIN seckill_orders o ON o.transaction_id = f.transaction_id
+		WHERE f.status = 'FROZEN' AND f.expires_at < ?
+	`, clockNow(stm.Clock))
+	if err != nil {
+		return nil, fmt.Errorf("查询过期冻结记录失败: %v", err)
+	}
+	var expired []*SeckillTCCContext
+	for rows.Next() {
+		ctx := &SeckillTCCContext{}
+		if err := rows.Scan(&ctx.TransactionID, &ctx.ProductID, &ctx.Quantity, &ctx.UserID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("读取过期冻结记录失败: %v", err)
+		}
+		expired = append(expired, ctx)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("读取过期冻结记录失败: %v", err)
+	}
+
+	stm.mu.RLock()
+	defer stm.mu.RUnlock()
+	ids := make([]string, 0, len(expired))
+	for _, ctx := range expired {
+		log.Printf("[Seckill TCC] 事务%s已过期，执行Cancel", ctx.TransactionID)
+		stm.cancelResources(ctx)
+		ids = append(ids, ctx.TransactionID)
+	}
+	return ids, nil
+}
+
+// retry 执行资源的某个阶段，只有 Transient 错误才按退避重试。
+// COMMIT 失败也可能是暂时性错误而事务其实已提交，三个阶段都以 recordOnce 去重，重试不会重复执行
+func (stm *SeckillTCCManager) retry(phase string, i int, ctx *SeckillTCCContext, fn func(*SeckillTCCContext) error) error {
+	start := time.Now()
+	backoff := stm.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if !IsTransient(err) || attempt > stm.MaxRetries {
+			stm.logPhase(ctx, stm.resources[i], phase, start, err)
+			return err
+		}
+		log.Printf("[Seckill TCC] %s暂时失败，资源%d第%d次重试: %v", phase, i, attempt, err)
+		time.Sleep(jitter(backoff))
+		backoff *= 2
+	}
+}
+
+// jitter 返回 [d/2, d] 之间的随机等待时间，避免锁冲突的请求同时重试再次冲突
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
 // 初始化秒杀数据库表结构
 func initSeckillDatabase(db *sql.DB) error {
 	tables := []string{
@@ -520,7 +939,7 @@ func initSeckillDatabase(db *sql.DB) error {
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
 			expires_at TIMESTAMP NOT NULL COMMENT '过期时间',
-			INDEX idx_transaction_id (transaction_id),
+			UNIQUE KEY uk_transaction_product (transaction_id, product_id),
 			INDEX idx_expires_at (expires_at)
 		)`,
 		// 秒杀账户表
@@ -543,7 +962,7 @@ func initSeckillDatabase(db *sql.DB) error {
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
 			expires_at TIMESTAMP NOT NULL COMMENT '过期时间',
-			INDEX idx_transaction_id (transaction_id),
+			UNIQUE KEY uk_transaction_user (transaction_id, user_id),
 			INDEX idx_expires_at (expires_at)
 		)`,
 		// 秒杀订单表
@@ -558,10 +977,18 @@ func initSeckillDatabase(db *sql.DB) error {
 			status ENUM('PENDING', 'CONFIRMED', 'CANCELLED') NOT NULL,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
-			INDEX idx_transaction_id (transaction_id),
+			UNIQUE KEY uk_transaction_user (transaction_id, user_id),
 			INDEX idx_user_id (user_id),
 			INDEX idx_product_id (product_id)
 		)`,
+		// Try/Confirm/Cancel 幂等记录表，唯一键冲突即说明该阶段已执行过（见 recordOnce）
+		`CREATE TABLE IF NOT EXISTS tcc_phase_log (
+			tx_id VARCHAR(64) NOT NULL,
+			resource VARCHAR(32) NOT NULL,
+			phase VARCHAR(16) NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (tx_id, resource, phase)
+		)`,
 	}
 
 	for _, table := range tables {
@@ -575,6 +1002,9 @@ func initSeckillDatabase(db *sql.DB) error {
 
 // 示例：秒杀场景测试
 func main() {
+	logFormat := flag.String("log-format", "text", "阶段日志格式：text 或 json")
+	flag.Parse()
+
 	// 连接数据库
 	db, err := sql.Open("mysql", "root:password@tcp(localhost:3306)/seckill_db?charset=utf8mb4&parseTime=True&loc=Local")
 	if err != nil {
@@ -592,6 +1022,9 @@ func main() {
 
 	// 创建TCC管理器
 	tccManager := NewSeckillTCCManager()
+	if *logFormat == "json" {
+		tccManager.Logger = NewJSONLogger(os.Stdout)
+	}
 	tccManager.AddResource(NewSeckillInventoryResource(db))
 	tccManager.AddResource(NewSeckillAccountResource(db))
 	tccManager.AddResource(NewSeckillOrderResource(db))
@@ -603,7 +1036,7 @@ func main() {
 		ProductID:     2001,
 		Quantity:      1,
 		Price:         99.99,
-		CreatedAt:     time.Now(),
+		CreatedAt:     clockNow(tccManager.Clock),
 		Timeout:       30 * time.Second, // 30秒超时
 	}
 
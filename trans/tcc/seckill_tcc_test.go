@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func newMock(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, mock
+}
+
+func testContext() *SeckillTCCContext {
+	return &SeckillTCCContext{
+		TransactionID: "seckill_1",
+		UserID:        1001,
+		ProductID:     2001,
+		Quantity:      1,
+		Price:         99.99,
+		CreatedAt:     time.Now(),
+		Timeout:       30 * time.Second,
+	}
+}
+
+func TestOrderConfirmIdempotent(t *testing.T) {
+	db, mock := newMock(t)
+
+	// 第一次 Confirm：写入幂等记录并更新订单
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT IGNORE INTO tcc_phase_log").
+		WithArgs("seckill_1", resourceOrder, "CONFIRM", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE seckill_orders").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// 第二次 Confirm：幂等记录已存在，不再更新订单
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT IGNORE INTO tcc_phase_log").
+		WithArgs("seckill_1", resourceOrder, "CONFIRM", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	r := NewSeckillOrderResource(db)
+	for i := 0; i < 2; i++ {
+		if err := r.Confirm(testContext()); err != nil {
+			t.Fatalf("Confirm #%d: %v", i+1, err)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInventoryConfirmReplayIsNoop(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT IGNORE INTO tcc_phase_log").
+		WithArgs("seckill_1", resourceInventory, "CONFIRM", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	// 冻结记录已是 CONFIRMED，重放时不应再查询冻结记录或报“未找到冻结记录”
+	if err := NewSeckillInventoryResource(db).Confirm(testContext()); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func expectSnapshot(mock sqlmock.Sqlmock, stock, frozen, sold int, freezes *sqlmock.Rows) {
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT stock, frozen_stock, sold_stock FROM seckill_inventory").
+		WithArgs(int64(2001)).
+		WillReturnRows(sqlmock.NewRows([]string{"stock", "frozen_stock", "sold_stock"}).AddRow(stock, frozen, sold))
+	mock.ExpectQuery("SELECT status, COALESCE\\(SUM\\(quantity\\), 0\\) FROM seckill_inventory_freeze").
+		WithArgs(int64(2001)).
+		WillReturnRows(freezes)
+	mock.ExpectRollback()
+}
+
+func TestInventorySnapshot(t *testing.T) {
+	db, mock := newMock(t)
+	// 初始 100 件：3 件冻结中，7 件已售，2 件已取消（已回到可用库存）
+	expectSnapshot(mock, 90, 3, 7, sqlmock.NewRows([]string{"status", "quantity"}).
+		AddRow("FROZEN", 3).
+		AddRow("CONFIRMED", 7).
+		AddRow("CANCELLED", 2))
+
+	snap, err := NewSeckillInventoryResource(db).InventorySnapshot(context.Background(), 2001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap.Available != 90 || snap.Frozen != 3 || snap.Sold != 7 || snap.Total() != 100 {
+		t.Fatalf("snapshot = %+v, total %d", snap, snap.Total())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInventorySnapshotDiscrepancy(t *testing.T) {
+	db, mock := newMock(t)
+	// frozen_stock 为 3，但冻结记录只有 2 件
+	expectSnapshot(mock, 90, 3, 7, sqlmock.NewRows([]string{"status", "quantity"}).
+		AddRow("FROZEN", 2).
+		AddRow("CONFIRMED", 7))
+
+	_, err := NewSeckillInventoryResource(db).InventorySnapshot(context.Background(), 2001)
+	if !errors.Is(err, ErrInventoryDiscrepancy) {
+		t.Fatalf("err = %v, want ErrInventoryDiscrepancy", err)
+	}
+}
+
+func TestOrderTryPerUserLimitConcurrent(t *testing.T) {
+	const limit, attempts = 3, 20
+	db, store := newFakeSeckillDB(1001)
+	defer db.Close()
+	r := NewSeckillOrderResource(db)
+	r.PerUserLimit = limit
+
+	var wg sync.WaitGroup
+	var succeeded, limited atomic.Int32
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx := testContext()
+			ctx.TransactionID = fmt.Sprintf("seckill_%d", i)
+			switch err := r.Try(ctx); {
+			case err == nil:
+				succeeded.Add(1)
+			case errors.Is(err, ErrPurchaseLimitExceeded):
+				limited.Add(1)
+			default:
+				t.Errorf("Try: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if succeeded.Load() != limit || limited.Load() != attempts-limit {
+		t.Fatalf("succeeded=%d limited=%d, want %d/%d", succeeded.Load(), limited.Load(), limit, attempts-limit)
+	}
+	if n := len(store.committed()); n != limit {
+		t.Fatalf("%d orders committed, want %d", n, limit)
+	}
+}
+
+// failingResource Try 总是以业务错误失败，模拟排在订单之后的资源
+type failingResource struct{}
+
+func (failingResource) Try(*SeckillTCCContext) error     { return businessErrorf("余额不足") }
+func (failingResource) Confirm(*SeckillTCCContext) error { return nil }
+func (failingResource) Cancel(*SeckillTCCContext) error  { return nil }
+
+// lateOrderResource 模拟订单 Try 超时后由调用方重试：第一次 Try 没有到达数据库，
+// 补偿结束后迟到的 Try 才真正执行
+type lateOrderResource struct {
+	*SeckillOrderResource
+	late func() error
+}
+
+func (r *lateOrderResource) Try(ctx *SeckillTCCContext) error {
+	r.late = func() error { return r.SeckillOrderResource.Try(ctx) }
+	return nil
+}
+
+func TestOrderTryAfterCancelLeavesNoOrphan(t *testing.T) {
+	defer discardLog()()
+	db, store := newFakeSeckillDB(1001)
+	defer db.Close()
+
+	// 后面的资源失败，管理器对所有资源执行 Cancel；订单的 Cancel 是空回滚（还没有订单），只写下取消记录
+	order := &lateOrderResource{SeckillOrderResource: NewSeckillOrderResource(db)}
+	m := NewSeckillTCCManager()
+	m.AddResource(order)
+	m.AddResource(failingResource{})
+	if err := m.ExecuteSeckillTCC(testContext()); err == nil {
+		t.Fatal("ExecuteSeckillTCC succeeded, want Try failure")
+	}
+
+	// 迟到的 Try 在事务内看到取消记录，拒绝插入；
+	// 原先不带事务直接插入时，这里会留下一条永远 PENDING 的孤儿订单
+	if err := order.late(); KindOf(err) != KindBusiness {
+		t.Fatalf("late Try = %v, want business rejection", err)
+	}
+	for _, o := range store.committed() {
+		if o.status != "CANCELLED" {
+			t.Fatalf("orphan order left behind: %+v", o)
+		}
+	}
+}
+
+func TestOrderTryThenCancelInTransaction(t *testing.T) {
+	defer discardLog()()
+	db, store := newFakeSeckillDB(1001)
+	defer db.Close()
+
+	m := NewSeckillTCCManager()
+	m.AddResource(NewSeckillOrderResource(db))
+	m.AddResource(failingResource{})
+	if err := m.ExecuteSeckillTCC(testContext()); err == nil {
+		t.Fatal("ExecuteSeckillTCC succeeded, want Try failure")
+	}
+	orders := store.committed()
+	if len(orders) != 1 || orders[0].status != "CANCELLED" {
+		t.Fatalf("orders = %+v, want one CANCELLED order", orders)
+	}
+}
+
+// discardLog 关闭标准日志输出，返回恢复函数
+func discardLog() func() {
+	w := log.Writer()
+	log.SetOutput(io.Discard)
+	return func() { log.SetOutput(w) }
+}
+
+// recordOnce 依赖 tcc_phase_log 的主键去重，建库时必须一起创建
+func TestInitSeckillDatabaseCreatesPhaseLog(t *testing.T) {
+	db, mock := newMock(t)
+	for i := 0; i < 5; i++ {
+		mock.ExpectExec("CREATE TABLE IF NOT EXISTS").WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectExec(`(?s)CREATE TABLE IF NOT EXISTS tcc_phase_log .*PRIMARY KEY \(tx_id, resource, phase\)`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	if err := initSeckillDatabase(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStockGateAcquireRelease(t *testing.T) {
+	g := NewStockGate()
+	g.Seed(2001, 3)
+	if !g.Acquire(2001, 2) || g.Acquire(2001, 2) || !g.Acquire(2001, 1) {
+		t.Fatal("unexpected Acquire results with stock 3")
+	}
+	g.Release(2001, 1)
+	if stock, _ := g.Available(2001); stock != 1 {
+		t.Fatalf("Available = %d, want 1", stock)
+	}
+	if !g.Acquire(9999, 100) {
+		t.Fatal("unknown product should pass through to the database")
+	}
+}
+
+func TestStockGateNoUnderSellOnDBFailure(t *testing.T) {
+	const stock = 5
+	db, store := newFakeSeckillDB()
+	defer db.Close()
+	store.setStock(2001, stock)
+
+	r := NewSeckillInventoryResource(db)
+	r.Gate = NewStockGate()
+	r.Gate.Seed(2001, stock)
+
+	// 数据库扣减失败的请求必须归还闸门额度
+	store.failInventoryUpdate.Store(true)
+	for i := 0; i < 10; i++ {
+		if err := r.Try(testContext()); err == nil {
+			t.Fatal("Try succeeded while the database is failing")
+		}
+	}
+	if got, _ := r.Gate.Available(2001); got != stock {
+		t.Fatalf("gate stock = %d after failed deductions, want %d", got, stock)
+	}
+
+	// 数据库恢复后仍能卖完全部库存，之后的请求被闸门拦截
+	store.failInventoryUpdate.Store(false)
+	var sold atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx := testContext()
+			ctx.TransactionID = fmt.Sprintf("seckill_%d", i)
+			if r.Try(ctx) == nil {
+				sold.Add(1)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if sold.Load() != stock || store.stock(2001) != 0 {
+		t.Fatalf("sold %d, db stock %d, want %d sold and 0 left", sold.Load(), store.stock(2001), stock)
+	}
+}
+
+// benchmarkInventoryTry 库存远少于请求数的秒杀场景，每次行锁查询模拟 50µs 数据库开销
+func benchmarkInventoryTry(b *testing.B, gate bool) {
+	db, store := newFakeSeckillDB()
+	defer db.Close()
+	store.lockDelay = 50 * time.Microsecond
+	stock := int64(b.N/100 + 1)
+	store.setStock(2001, stock)
+
+	r := NewSeckillInventoryResource(db)
+	if gate {
+		r.Gate = NewStockGate()
+		r.Gate.Seed(2001, stock)
+	}
+
+	log := discardLog()
+	defer log()
+	var n atomic.Int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			ctx := testContext()
+			ctx.TransactionID = fmt.Sprintf("seckill_%d", n.Add(1))
+			r.Try(ctx)
+		}
+	})
+}
+
+func BenchmarkInventoryTryWithoutGate(b *testing.B) { benchmarkInventoryTry(b, false) }
+func BenchmarkInventoryTryWithGate(b *testing.B)    { benchmarkInventoryTry(b, true) }
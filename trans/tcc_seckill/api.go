@@ -0,0 +1,101 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// IdempotencyKeyHeader 客户端为同一次购买生成的唯一标识，重试时原样带上
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// seckillRequest POST /seckill 请求体
+type seckillRequest struct {
+	UserID    int64 `json:"userId"`
+	ProductID int64 `json:"productId"`
+	Quantity  int   `json:"quantity"`
+}
+
+// seckillResponse POST /seckill 响应体
+type seckillResponse struct {
+	TransactionID string `json:"transactionId,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// SeckillAPI 秒杀 HTTP 接口，供压测工具等进程外调用方使用
+type SeckillAPI struct {
+	manager *SeckillDirectTCCManager
+	price   float64
+
+	// Stream 秒杀结果推送，非 nil 时挂载 GET /seckill/stream；
+	// 需同时把 manager.OnResult 设为 Stream.Publish
+	Stream *ResultHub
+}
+
+func NewSeckillAPI(manager *SeckillDirectTCCManager, price float64) *SeckillAPI {
+	return &SeckillAPI{manager: manager, price: price}
+}
+
+// Register 挂载 POST /seckill，设置了 Stream 时同时挂载 GET /seckill/stream
+func (a *SeckillAPI) Register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /seckill", a.handleSeckill)
+	if a.Stream != nil {
+		mux.Handle("GET /seckill/stream", a.Stream)
+	}
+}
+
+// scopedIdempotencyKey 把请求头中的幂等键限定到用户，不同用户碰巧使用相同的键不会互相命中；
+// 取哈希后长度固定，放得进 tcc_transaction_log.idempotency_key。没有幂等键时返回空串
+func scopedIdempotencyKey(userID int64, key string) string {
+	if key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d:%s", userID, key)))
+	return hex.EncodeToString(sum[:])
+}
+
+// handleSeckill 成功返回 200；售罄、余额不足等业务拒绝以及相同幂等键的请求仍在执行时返回 409；其他错误返回 500。
+// 带幂等键的重复请求由 ExecuteSeckill 在 tcc_transaction_log 中登记去重，跨进程、重启后仍然有效
+func (a *SeckillAPI) handleSeckill(w http.ResponseWriter, r *http.Request) {
+	var req seckillRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeSeckillJSON(w, http.StatusBadRequest, seckillResponse{Error: "invalid request body"})
+		return
+	}
+	if req.UserID <= 0 || req.ProductID <= 0 || req.Quantity <= 0 {
+		writeSeckillJSON(w, http.StatusBadRequest, seckillResponse{Error: "userId, productId and quantity must be positive"})
+		return
+	}
+
+	ctx := &SeckillDirectTCCContext{
+		TransactionID:  fmt.Sprintf("seckill_%d_%d", time.Now().UnixNano(), req.UserID),
+		UserID:         req.UserID,
+		ProductID:      req.ProductID,
+		Quantity:       req.Quantity,
+		Price:          a.price,
+		IdempotencyKey: scopedIdempotencyKey(req.UserID, r.Header.Get(IdempotencyKeyHeader)),
+	}
+	err := a.manager.ExecuteSeckill(ctx)
+
+	switch {
+	case err == nil:
+		writeSeckillJSON(w, http.StatusOK, seckillResponse{TransactionID: ctx.TransactionID})
+	case errors.Is(err, ErrSoldOut), errors.Is(err, ErrInsufficientBalance), errors.Is(err, ErrTxCancelled),
+		errors.Is(err, ErrTxInProgress):
+		writeSeckillJSON(w, http.StatusConflict, seckillResponse{TransactionID: ctx.TransactionID, Error: err.Error()})
+	case errors.Is(err, ErrShuttingDown):
+		writeSeckillJSON(w, http.StatusServiceUnavailable, seckillResponse{TransactionID: ctx.TransactionID, Error: err.Error()})
+	default:
+		writeSeckillJSON(w, http.StatusInternalServerError, seckillResponse{TransactionID: ctx.TransactionID, Error: err.Error()})
+	}
+}
+
+func writeSeckillJSON(w http.ResponseWriter, code int, v seckillResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// countingResource 记录 Try 次数，tryErr 非空时 Try 失败
+type countingResource struct {
+	tries  atomic.Int32
+	tryErr error
+}
+
+func (r *countingResource) Try(*SeckillDirectTCCContext) error {
+	r.tries.Add(1)
+	return r.tryErr
+}
+func (r *countingResource) Confirm(*SeckillDirectTCCContext) error { return nil }
+func (r *countingResource) Cancel(*SeckillDirectTCCContext) error  { return nil }
+
+func postSeckill(t *testing.T, srv *httptest.Server, key, body string) (int, seckillResponse) {
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/seckill", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var out seckillResponse
+	json.NewDecoder(resp.Body).Decode(&out)
+	return resp.StatusCode, out
+}
+
+func newAPIServer(t *testing.T, resource DirectTCCResource) (*httptest.Server, sqlmock.Sqlmock) {
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(out) })
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	mock.MatchExpectationsInOrder(false)
+
+	manager := &SeckillDirectTCCManager{resources: []DirectTCCResource{resource}, db: db, stmts: newStmtCache(db)}
+	return serveAPI(t, manager), mock
+}
+
+func serveAPI(t *testing.T, manager *SeckillDirectTCCManager) *httptest.Server {
+	mux := http.NewServeMux()
+	NewSeckillAPI(manager, 100).Register(mux)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestSeckillAPIIdempotentRetry(t *testing.T) {
+	resource := &countingResource{}
+	srv := serveAPI(t, newIdempotencyManager(t, resource))
+
+	// 重试带相同幂等键，由 tcc_transaction_log 中的登记落到第一次的事务上
+	body := `{"userId":10001,"productId":1001,"quantity":1}`
+	code1, resp1 := postSeckill(t, srv, "order-abc", body)
+	code2, resp2 := postSeckill(t, srv, "order-abc", body)
+	if code1 != http.StatusOK || code2 != http.StatusOK {
+		t.Fatalf("status = %d, %d; want 200, 200", code1, code2)
+	}
+	if resp1.TransactionID == "" || resp1.TransactionID != resp2.TransactionID {
+		t.Fatalf("transaction ids = %q, %q; want the same", resp1.TransactionID, resp2.TransactionID)
+	}
+	if n := resource.tries.Load(); n != 1 {
+		t.Fatalf("Try ran %d times, want 1", n)
+	}
+
+	// 幂等键按用户隔离，其他用户用同一个键是另一笔购买
+	code3, resp3 := postSeckill(t, srv, "order-abc", `{"userId":10002,"productId":1001,"quantity":1}`)
+	if code3 != http.StatusOK || resp3.TransactionID == resp1.TransactionID {
+		t.Fatalf("other user: status = %d, transaction = %q", code3, resp3.TransactionID)
+	}
+	if n := resource.tries.Load(); n != 2 {
+		t.Fatalf("Try ran %d times, want 2", n)
+	}
+}
+
+func TestSeckillAPIStatusCodes(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		tryErr error
+		want   int
+	}{
+		{"sold out", ErrSoldOut, http.StatusConflict},
+		{"system error", io.ErrUnexpectedEOF, http.StatusInternalServerError},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, mock := newAPIServer(t, &countingResource{tryErr: tt.tryErr})
+			mock.ExpectPrepare("SELECT status FROM tcc_transaction_log").ExpectQuery().WillReturnRows(sqlmock.NewRows([]string{"status"}))
+			mock.ExpectPrepare("INSERT INTO tcc_transaction_log").ExpectExec().WillReturnResult(sqlmock.NewResult(0, 1))
+
+			code, resp := postSeckill(t, srv, "", `{"userId":10001,"productId":1001,"quantity":1}`)
+			if code != tt.want || resp.Error == "" {
+				t.Fatalf("status = %d, body = %+v; want %d with error", code, resp, tt.want)
+			}
+		})
+	}
+
+	srv, _ := newAPIServer(t, &countingResource{})
+	if code, _ := postSeckill(t, srv, "", `{"userId":0}`); code != http.StatusBadRequest {
+		t.Fatalf("invalid body status = %d, want 400", code)
+	}
+}
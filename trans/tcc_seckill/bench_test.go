@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// benchConcurrency 并发扫描的档位
+var benchConcurrency = []int{1, 8, 32, 128}
+
+// runSeckillSweep 把 b.N 笔秒杀分给 c 个 goroutine 执行，额外报告 tx/s 和失败比例。
+// 库存在每轮开始前按 b.N 重置，正常情况下 fail/op 应为 0
+func runSeckillSweep(b *testing.B, c int, seckill func(i int) error) {
+	var next, failed atomic.Int64
+	var wg sync.WaitGroup
+	b.ReportAllocs()
+	b.ResetTimer()
+	start := time.Now()
+	for g := 0; g < c; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := next.Add(1); i <= int64(b.N); i = next.Add(1) {
+				if err := seckill(int(i)); err != nil {
+					failed.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	b.StopTimer()
+	b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "tx/s")
+	b.ReportMetric(float64(failed.Load())/float64(b.N), "fail/op")
+}
+
+// openBenchMySQL 连接 MYSQL_TEST_DSN 指定的测试库并建表，未设置时跳过
+func openBenchMySQL(b *testing.B) *sql.DB {
+	dsn := os.Getenv("MYSQL_TEST_DSN")
+	if dsn == "" {
+		b.Skip("MYSQL_TEST_DSN not set")
+	}
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { db.Close() })
+	if err := initDirectSeckillDatabase(db); err != nil {
+		b.Fatal(err)
+	}
+	if err := initDirectSeckillTestData(db); err != nil {
+		b.Fatal(err)
+	}
+	return db
+}
+
+// resetDirectStock 把商品库存重置为 n、测试用户余额重置为足够买下全部库存，
+// 并清理上一轮压测留下的 bench_ 前缀事务数据
+func resetDirectStock(b *testing.B, db *sql.DB, productID int64, n int) {
+	stmts := []struct {
+		query string
+		args  []interface{}
+	}{
+		{`UPDATE seckill_inventory SET stock = ?, original_stock = ?, sold_count = 0 WHERE product_id = ?`, []interface{}{n, n, productID}},
+		{`UPDATE user_account SET balance = ? WHERE user_id BETWEEN 10001 AND 10005`, []interface{}{float64(n) * 8999}},
+		{`DELETE FROM seckill_order WHERE transaction_id LIKE 'bench\_%'`, nil},
+		{`DELETE FROM inventory_deduct_log WHERE transaction_id LIKE 'bench\_%'`, nil},
+		{`DELETE FROM account_deduct_log WHERE transaction_id LIKE 'bench\_%'`, nil},
+		{`DELETE FROM account_ledger WHERE tx_id LIKE 'bench\_%'`, nil},
+		{`DELETE FROM tcc_resource_status WHERE transaction_id LIKE 'bench\_%'`, nil},
+		{`DELETE FROM tcc_transaction_log WHERE transaction_id LIKE 'bench\_%'`, nil},
+	}
+	for _, s := range stmts {
+		if _, err := db.Exec(s.query, s.args...); err != nil {
+			b.Fatalf("重置压测数据失败: %v", err)
+		}
+	}
+}
+
+// BenchmarkSeckillDirect 直接扣减模式的并发扫描。
+//
+// driver=fake 用 countingDB（每次往返固定 50µs）只跑库存资源，没有行锁竞争，
+// 衡量的是管理器自身的开销和分配；driver=mysql 在 MYSQL_TEST_DSN 指定的库上跑完整的
+// 库存/账户/订单三个资源，所有请求抢同一个商品，衡量行锁竞争下的吞吐。
+//
+//	go test -run '^$' -bench SeckillDirect -benchtime 2000x -count 5 .
+//
+// 参考结果（单核 Xeon，driver=fake，-benchtime 2000x）：
+//
+//	c=1    约 12ms/op    5.5KB/op  135 allocs/op
+//	c=8    约 1.3ms/op   5.5KB/op  136 allocs/op
+//	c=32   约 30-45µs/op 5.6KB/op  136 allocs/op
+//	c=128  约 40-50µs/op 5.6KB/op  135-138 allocs/op
+//
+// 该机器上 time.Sleep 的实际粒度约 1ms，所以低并发的 ns/op 远大于 50µs×往返次数；
+// fake 的 ns/op 随并发下降说明事务之间没有被意外串行化，跨机器比较没有意义。
+// allocs/op 与并发和机器无关，TestSeckillDirectAllocsBaseline 据此防止回退
+func BenchmarkSeckillDirect(b *testing.B) {
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(out)
+
+	for _, c := range benchConcurrency {
+		b.Run(fmt.Sprintf("driver=fake/c=%d", c), func(b *testing.B) {
+			benchmarkSeckillDirectFake(b, c, 50*time.Microsecond)
+		})
+	}
+	for _, c := range benchConcurrency {
+		b.Run(fmt.Sprintf("driver=mysql/c=%d", c), func(b *testing.B) {
+			db := openBenchMySQL(b)
+			db.SetMaxOpenConns(c * 2)
+			db.SetMaxIdleConns(c * 2)
+			resetDirectStock(b, db, 1001, b.N)
+			manager := NewSeckillDirectTCCManager(db)
+			defer manager.Shutdown(context.Background())
+
+			runSeckillSweep(b, c, func(i int) error {
+				return manager.ExecuteSeckill(&SeckillDirectTCCContext{
+					TransactionID: fmt.Sprintf("bench_%d_%d", b.N, i),
+					UserID:        int64(10001 + i%5),
+					ProductID:     1001,
+					Quantity:      1,
+					Price:         8999.00,
+				})
+			})
+		})
+	}
+}
+
+// benchmarkSeckillDirectFake 在 countingDB 上执行秒杀，latency 为每次往返的模拟延迟
+func benchmarkSeckillDirectFake(b *testing.B, c int, latency time.Duration) {
+	counter := &countingDB{latency: latency}
+	db := sql.OpenDB(counter)
+	defer db.Close()
+	db.SetMaxOpenConns(c * 2)
+	db.SetMaxIdleConns(c * 2)
+	manager := NewSeckillDirectTCCManager(db)
+	manager.resources = manager.resources[:1] // 测试驱动只应答库存资源的查询
+
+	runSeckillSweep(b, c, func(i int) error {
+		return manager.ExecuteSeckill(&SeckillDirectTCCContext{
+			TransactionID: fmt.Sprintf("bench_%d", i),
+			UserID:        int64(10001 + i%5),
+			ProductID:     1001,
+			Quantity:      1,
+			Price:         8999.00,
+		})
+	})
+	manager.stmts.Close()
+}
+
+// seckillDirectMaxAllocs 单笔秒杀（fake 驱动、只含库存资源）允许的最大分配次数，
+// 在参考结果 136 allocs/op 上留了约 20% 余量
+const seckillDirectMaxAllocs = 165
+
+func TestSeckillDirectAllocsBaseline(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping benchmark-based check in -short mode")
+	}
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(out)
+
+	// 分配次数与延迟无关，去掉模拟延迟让检查在毫秒级完成
+	res := testing.Benchmark(func(b *testing.B) { benchmarkSeckillDirectFake(b, 8, 0) })
+	if res.N == 0 {
+		t.Fatal("benchmark did not run")
+	}
+	if allocs := res.AllocsPerOp(); allocs > seckillDirectMaxAllocs {
+		t.Fatalf("ExecuteSeckill allocs/op = %d, baseline allows %d", allocs, seckillDirectMaxAllocs)
+	}
+	if fail := res.Extra["fail/op"]; fail != 0 {
+		t.Fatalf("fail/op = %v, want 0", fail)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// ErrTxInProgress 相同幂等键的事务仍在执行，调用方稍后重试即可拿到结果
+var ErrTxInProgress = errors.New("相同幂等键的事务正在执行")
+
+// idempotencyClaimTTL 登记幂等键后超过这么久仍停在 TRYING/TRIED，视为登记者已崩溃，
+// 重复请求接手该事务继续执行（资源按事务ID幂等，重复执行是安全的）
+const idempotencyClaimTTL = time.Minute
+
+// mysqlErrDupEntry 唯一键冲突
+const mysqlErrDupEntry = 1062
+
+func isDuplicateKey(err error) bool {
+	var me *mysql.MySQLError
+	return errors.As(err, &me) && me.Number == mysqlErrDupEntry
+}
+
+// claimIdempotencyKey 以 TRYING 状态登记 (事务ID, 幂等键)。idempotency_key 上的唯一约束保证
+// 并发请求中只有一个登记成功；失败的一方查出胜者的事务ID写回 ctx，
+// 之后由 ExecuteSeckill 按事务ID返回已确认/已取消的结果，仍在执行时返回 ErrTxInProgress
+func (stm *SeckillDirectTCCManager) claimIdempotencyKey(ctx *SeckillDirectTCCContext) error {
+	_, err := stm.stmts.Exec(`
+		INSERT INTO tcc_transaction_log (transaction_id, idempotency_key, status, created_at, updated_at)
+		VALUES (?, ?, ?, NOW(), NOW())
+	`, ctx.TransactionID, ctx.IdempotencyKey, TCCStatusTrying)
+	if err == nil {
+		return nil
+	}
+	if !isDuplicateKey(err) {
+		return fmt.Errorf("登记幂等键失败: %v", err)
+	}
+
+	// 登记是否过期在数据库里按 NOW() 判断，与写入 updated_at 的是同一时钟，不受应用服务器时区和时钟偏差影响
+	var txID, status string
+	var fresh bool
+	err = stm.stmts.QueryRow(`
+		SELECT transaction_id, status, updated_at >= NOW() - INTERVAL ? MICROSECOND FROM tcc_transaction_log 
+		WHERE idempotency_key = ?
+	`, idempotencyClaimTTL.Microseconds(), ctx.IdempotencyKey).Scan(&txID, &status, &fresh)
+	if errors.Is(err, sql.ErrNoRows) {
+		// 冲突的是事务ID（同一事务ID重试），交给按事务ID的去重处理
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("查询幂等键失败: %v", err)
+	}
+
+	if txID != ctx.TransactionID {
+		log.Printf("[秒杀TCC] 幂等键%s已属于事务%s，忽略新事务ID%s", ctx.IdempotencyKey, txID, ctx.TransactionID)
+		ctx.TransactionID = txID
+	}
+	inProgress := status == string(TCCStatusTrying) || status == string(TCCStatusTried)
+	if inProgress && fresh {
+		return ErrTxInProgress
+	}
+	return nil
+}
+
+// isBusinessRejection 售罄、余额不足等确定性的业务拒绝，用同一个幂等键重试也会得到同样的结果
+func isBusinessRejection(err error) bool {
+	return errors.Is(err, ErrSoldOut) || errors.Is(err, ErrInsufficientBalance)
+}
+
+// logCancelled 记录事务已取消。带幂等键且失败原因不是业务拒绝时同时释放幂等键：
+// 连接中断、锁等待超时等暂时性错误之后，客户端用同一个键重试会登记为新事务重新执行，
+// 而不是一直拿到已取消的结果；原事务ID的资源已经补偿，不能复用，所以重试换用新的事务ID
+func (stm *SeckillDirectTCCManager) logCancelled(ctx *SeckillDirectTCCContext, cause error) error {
+	if ctx.IdempotencyKey == "" || isBusinessRejection(cause) {
+		return stm.logTCCTransaction(ctx.TransactionID, TCCStatusCancelled)
+	}
+	_, err := stm.stmts.Exec(`
+		UPDATE tcc_transaction_log SET status = ?, idempotency_key = NULL, updated_at = NOW()
+		WHERE transaction_id = ?
+	`, TCCStatusCancelled, ctx.TransactionID)
+	return err
+}
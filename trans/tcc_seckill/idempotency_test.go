@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// txLogDB 在内存中模拟 tcc_transaction_log（transaction_id 主键、idempotency_key 唯一），
+// 其他写操作一律成功、其他查询返回空结果
+type txLogDB struct {
+	mu     sync.Mutex
+	status map[string]string // transaction_id -> status
+	keys   map[string]string // idempotency_key -> transaction_id
+}
+
+func newTxLogDB() *txLogDB {
+	return &txLogDB{status: make(map[string]string), keys: make(map[string]string)}
+}
+
+func (d *txLogDB) Connect(context.Context) (driver.Conn, error) { return txLogConn{d}, nil }
+func (d *txLogDB) Driver() driver.Driver                        { return d }
+func (d *txLogDB) Open(string) (driver.Conn, error)             { return txLogConn{d}, nil }
+
+type txLogConn struct{ db *txLogDB }
+
+func (c txLogConn) Prepare(query string) (driver.Stmt, error) { return txLogStmt{c.db, query}, nil }
+func (c txLogConn) Close() error                              { return nil }
+func (c txLogConn) Begin() (driver.Tx, error)                 { return nopConn{}, nil }
+
+type txLogStmt struct {
+	db    *txLogDB
+	query string
+}
+
+func (s txLogStmt) Close() error  { return nil }
+func (s txLogStmt) NumInput() int { return -1 }
+
+func (s txLogStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	switch {
+	case strings.Contains(s.query, "idempotency_key, status"):
+		txID, key := args[0].(string), args[1].(string)
+		if _, dup := s.db.keys[key]; dup {
+			return nil, &mysql.MySQLError{Number: mysqlErrDupEntry, Message: "Duplicate entry for key 'idempotency_key'"}
+		}
+		if _, dup := s.db.status[txID]; dup {
+			return nil, &mysql.MySQLError{Number: mysqlErrDupEntry, Message: "Duplicate entry for key 'PRIMARY'"}
+		}
+		s.db.keys[key] = txID
+		s.db.status[txID] = args[2].(string)
+	case strings.Contains(s.query, "INSERT INTO tcc_transaction_log"):
+		s.db.status[args[0].(string)] = args[1].(string)
+	case strings.Contains(s.query, "idempotency_key = NULL"):
+		txID := args[1].(string)
+		s.db.status[txID] = args[0].(string)
+		for key, id := range s.db.keys {
+			if id == txID {
+				delete(s.db.keys, key)
+			}
+		}
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s txLogStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	switch {
+	case strings.Contains(s.query, "WHERE idempotency_key = ?"):
+		if txID, ok := s.db.keys[args[1].(string)]; ok {
+			return &sliceRows{cols: []string{"transaction_id", "status", "fresh"}, rows: [][]driver.Value{{txID, s.db.status[txID], int64(1)}}}, nil
+		}
+	case strings.Contains(s.query, "SELECT status FROM tcc_transaction_log"):
+		if status, ok := s.db.status[args[0].(string)]; ok {
+			return &sliceRows{cols: []string{"status"}, rows: [][]driver.Value{{status}}}, nil
+		}
+	}
+	return &sliceRows{}, nil
+}
+
+type sliceRows struct {
+	cols []string
+	rows [][]driver.Value
+}
+
+func (r *sliceRows) Columns() []string { return r.cols }
+func (r *sliceRows) Close() error      { return nil }
+
+func (r *sliceRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func newIdempotencyManager(t *testing.T, resource DirectTCCResource) *SeckillDirectTCCManager {
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(out) })
+
+	db := sql.OpenDB(newTxLogDB())
+	t.Cleanup(func() { db.Close() })
+	return &SeckillDirectTCCManager{resources: []DirectTCCResource{resource}, db: db, stmts: newStmtCache(db)}
+}
+
+func TestIdempotencyKeyConcurrent(t *testing.T) {
+	resource := &countingResource{}
+	manager := newIdempotencyManager(t, resource)
+
+	// 两个并发请求带相同幂等键、不同事务ID
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i, txID := range []string{"tx_a", "tx_b"} {
+		wg.Add(1)
+		go func(i int, txID string) {
+			defer wg.Done()
+			errs[i] = manager.ExecuteSeckill(&SeckillDirectTCCContext{TransactionID: txID, IdempotencyKey: "order-1", UserID: 1, ProductID: 1001, Quantity: 1})
+		}(i, txID)
+	}
+	wg.Wait()
+
+	if n := resource.tries.Load(); n != 1 {
+		t.Fatalf("Try ran %d times, want exactly one purchase", n)
+	}
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, ErrTxInProgress) {
+			t.Fatalf("ExecuteSeckill: %v", err)
+		}
+	}
+
+	// 之后的重试拿到第一次的结果，事务ID改为第一次登记的
+	retry := &SeckillDirectTCCContext{TransactionID: "tx_c", IdempotencyKey: "order-1", UserID: 1, ProductID: 1001, Quantity: 1}
+	if err := manager.ExecuteSeckill(retry); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if retry.TransactionID != "tx_a" && retry.TransactionID != "tx_b" {
+		t.Fatalf("retry transaction = %s, want the original", retry.TransactionID)
+	}
+	if n := resource.tries.Load(); n != 1 {
+		t.Fatalf("Try ran %d times after retry, want 1", n)
+	}
+}
+
+func TestIdempotencyKeyReturnsPriorFailure(t *testing.T) {
+	resource := &countingResource{tryErr: ErrSoldOut}
+	manager := newIdempotencyManager(t, resource)
+
+	for i, txID := range []string{"tx_a", "tx_b"} {
+		err := manager.ExecuteSeckill(&SeckillDirectTCCContext{TransactionID: txID, IdempotencyKey: "order-2", UserID: 1, ProductID: 1001, Quantity: 1})
+		if i == 0 && !errors.Is(err, ErrSoldOut) {
+			t.Fatalf("first call = %v, want ErrSoldOut", err)
+		}
+		if i == 1 && !errors.Is(err, ErrTxCancelled) {
+			t.Fatalf("retry = %v, want ErrTxCancelled", err)
+		}
+	}
+	if n := resource.tries.Load(); n != 1 {
+		t.Fatalf("Try ran %d times, want 1", n)
+	}
+}
+
+func TestIdempotencyKeyRetriesAfterTransientFailure(t *testing.T) {
+	resource := &countingResource{tryErr: io.ErrUnexpectedEOF}
+	manager := newIdempotencyManager(t, resource)
+
+	first := &SeckillDirectTCCContext{TransactionID: "tx_a", IdempotencyKey: "order-3", UserID: 1, ProductID: 1001, Quantity: 1}
+	if err := manager.ExecuteSeckill(first); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("first call = %v, want the transient error", err)
+	}
+
+	// 暂时性错误取消的事务释放了幂等键，同一个键重试作为新事务执行
+	resource.tryErr = nil
+	retry := &SeckillDirectTCCContext{TransactionID: "tx_b", IdempotencyKey: "order-3", UserID: 1, ProductID: 1001, Quantity: 1}
+	if err := manager.ExecuteSeckill(retry); err != nil {
+		t.Fatalf("retry = %v, want success", err)
+	}
+	if retry.TransactionID != "tx_b" {
+		t.Fatalf("retry transaction = %s, want a new transaction", retry.TransactionID)
+	}
+	if n := resource.tries.Load(); n != 2 {
+		t.Fatalf("Try ran %d times, want 2", n)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// LedgerEntry account_ledger 中的一条余额流水
+type LedgerEntry struct {
+	ID            int64     `json:"id"`
+	UserID        int64     `json:"userId"`
+	TransactionID string    `json:"transactionId"`
+	Delta         float64   `json:"delta"`        // 余额变动：Try 扣减为负，Cancel 返还为正，Confirm 为 0
+	BalanceAfter  float64   `json:"balanceAfter"` // 本次变动后的余额
+	Phase         string    `json:"phase"`        // try / confirm / cancel
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// appendLedger 在修改余额的同一事务内追加一条流水，余额变动与流水一起提交或回滚。
+// balance_after 在本事务内读取，UPDATE 已锁住该用户的账户行，读到的就是本次变动后的余额
+func (r *DirectAccountResource) appendLedger(tx *sql.Tx, userID int64, transactionID string, delta float64, phase string) error {
+	var balance float64
+	err := r.stmts.TxQueryRow(tx, `
+		SELECT balance FROM user_account 
+		WHERE user_id = ?
+	`, userID).Scan(&balance)
+	if err != nil {
+		return fmt.Errorf("查询变动后余额失败: %v", err)
+	}
+
+	_, err = r.stmts.TxExec(tx, `
+		INSERT INTO account_ledger 
+		(user_id, tx_id, delta, balance_after, phase, created_at)
+		VALUES (?, ?, ?, ?, ?, NOW())
+	`, userID, transactionID, delta, balance, phase)
+	if err != nil {
+		return fmt.Errorf("记录余额流水失败: %v", err)
+	}
+	return nil
+}
+
+// GetLedger 按写入顺序返回用户的全部余额流水，用于对账和纠纷核查
+func (r *DirectAccountResource) GetLedger(ctx context.Context, userID int64) ([]LedgerEntry, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, user_id, tx_id, delta, balance_after, phase, created_at FROM account_ledger 
+		WHERE user_id = ? 
+		ORDER BY id
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("查询余额流水失败: %v", err)
+	}
+	defer rows.Close()
+
+	var entries []LedgerEntry
+	for rows.Next() {
+		var e LedgerEntry
+		if err := rows.Scan(&e.ID, &e.UserID, &e.TransactionID, &e.Delta, &e.BalanceAfter, &e.Phase, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("读取余额流水失败: %v", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestAccountLedgerTryCancelNetsToZero(t *testing.T) {
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(out)
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	r := NewDirectAccountResource(db)
+	r.stmts.Close() // 不预编译，按顺序匹配直接执行的 SQL
+
+	const txID, userID = "tx_ledger", int64(10001)
+	// Try：扣 300，流水记 -300、余额 700
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM account_deduct_log").WithArgs(txID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE user_account").WithArgs(300.0, userID, 300.0).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO account_deduct_log").WithArgs(txID, userID, 300.0).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT balance FROM user_account").WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(700.0))
+	mock.ExpectExec("INSERT INTO account_ledger").WithArgs(userID, txID, -300.0, 700.0, "try").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	// Cancel：返还 300，流水记 +300、余额回到 1000
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT operation_type, amount FROM account_deduct_log").WithArgs(txID).
+		WillReturnRows(sqlmock.NewRows([]string{"operation_type", "amount"}).AddRow("TRY_DEDUCT", 300.0))
+	mock.ExpectExec("UPDATE user_account").WithArgs(300.0, userID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE account_deduct_log").WithArgs(txID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT balance FROM user_account").WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(1000.0))
+	mock.ExpectExec("INSERT INTO account_ledger").WithArgs(userID, txID, 300.0, 1000.0, "cancel").WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+	// 重复 Cancel 不改余额，也不追加流水
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT operation_type, amount FROM account_deduct_log").WithArgs(txID).
+		WillReturnRows(sqlmock.NewRows([]string{"operation_type", "amount"}).AddRow("CANCELLED", 300.0))
+	mock.ExpectRollback()
+
+	ctx := &SeckillDirectTCCContext{TransactionID: txID, UserID: userID, ProductID: 1001, Quantity: 2, Price: 150}
+	if err := r.Try(ctx); err != nil {
+		t.Fatalf("Try: %v", err)
+	}
+	if err := r.Cancel(ctx); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	if err := r.Cancel(ctx); err != nil {
+		t.Fatalf("second Cancel: %v", err)
+	}
+
+	now := time.Now()
+	mock.ExpectQuery("FROM account_ledger").WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "tx_id", "delta", "balance_after", "phase", "created_at"}).
+			AddRow(1, userID, txID, -300.0, 700.0, "try", now).
+			AddRow(2, userID, txID, 300.0, 1000.0, "cancel", now))
+	entries, err := r.GetLedger(context.Background(), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var net float64
+	for _, e := range entries {
+		net += e.Delta
+	}
+	if len(entries) != 2 || entries[0].Phase != "try" || entries[1].Phase != "cancel" || net != 0 {
+		t.Fatalf("ledger = %+v, want try then cancel netting to 0", entries)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
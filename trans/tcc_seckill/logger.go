@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"test/trans/internal/phaselog"
+	"time"
+)
+
+// PhaseEvent 一个资源执行完 Try/Confirm/Cancel 中的一个阶段
+type PhaseEvent = phaselog.Event
+
+// Logger 接收每个阶段的结构化事件，SeckillDirectTCCManager.Logger 为 nil 时输出可读文本
+type Logger = phaselog.Logger
+
+type textLogger struct{}
+
+func (textLogger) LogPhase(ev PhaseEvent) {
+	if ev.Err != nil {
+		log.Printf("[秒杀TCC] 事务%s 资源%s %s失败，耗时%v: %v", ev.TxID, ev.Resource, ev.Phase, ev.Duration, ev.Err)
+		return
+	}
+	log.Printf("[秒杀TCC] 事务%s 资源%s %s完成，耗时%v", ev.TxID, ev.Resource, ev.Phase, ev.Duration)
+}
+
+// NewJSONLogger 每个阶段输出一行 JSON，高并发压测时可以直接按 outcome/durationMs 聚合
+func NewJSONLogger(w io.Writer) *phaselog.JSONLogger {
+	return phaselog.NewJSONLogger(w, "tcc phase", "resource")
+}
+
+// resourceName 资源实现了 Name() 时用它，否则用类型名
+func resourceName(r DirectTCCResource) string {
+	if n, ok := r.(interface{ Name() string }); ok {
+		return n.Name()
+	}
+	return strings.TrimPrefix(fmt.Sprintf("%T", r), "*main.")
+}
+
+func (stm *SeckillDirectTCCManager) logPhase(ctx *SeckillDirectTCCContext, resource DirectTCCResource, phase string, start time.Time, err error) {
+	ev := PhaseEvent{
+		TxID:     ctx.TransactionID,
+		Resource: resourceName(resource),
+		Phase:    phase,
+		Duration: time.Since(start),
+		Err:      err,
+	}
+	var l Logger = textLogger{}
+	if stm.Logger != nil {
+		l = stm.Logger
+	}
+	l.LogPhase(ev)
+}
+
+// runPhase 执行一个资源的一个阶段并记录阶段日志
+func (stm *SeckillDirectTCCManager) runPhase(ctx *SeckillDirectTCCContext, resource DirectTCCResource, phase string, fn func(*SeckillDirectTCCContext) error) error {
+	start := time.Now()
+	err := fn(ctx)
+	stm.logPhase(ctx, resource, phase, start, err)
+	return err
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"log"
+	"testing"
+)
+
+func TestJSONLoggerCommitFields(t *testing.T) {
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(out)
+
+	var buf bytes.Buffer
+	db := sql.OpenDB(nopDB{})
+	manager := &SeckillDirectTCCManager{resources: []DirectTCCResource{&countingResource{}}, db: db, stmts: newStmtCache(db)}
+	manager.Logger = NewJSONLogger(&buf)
+	if err := manager.ExecuteSeckill(&SeckillDirectTCCContext{TransactionID: "tx_log", UserID: 10001, ProductID: 1001, Quantity: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []map[string]any
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var l map[string]any
+		if err := dec.Decode(&l); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, l)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want try and confirm: %v", len(lines), lines)
+	}
+	for i, phase := range []string{"try", "confirm"} {
+		l := lines[i]
+		if l["txID"] != "tx_log" || l["resource"] != "countingResource" || l["phase"] != phase || l["outcome"] != "ok" || l["level"] != "INFO" {
+			t.Fatalf("line %d = %v", i, l)
+		}
+		if _, ok := l["durationMs"].(float64); !ok {
+			t.Fatalf("line %d has no durationMs: %v", i, l)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestOnLowStockFiresOnceAfterCommit(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	type call struct {
+		productID int64
+		remaining int
+	}
+	var calls []call
+	r := NewDirectInventoryResource(db)
+	r.stmts.Close() // 不预编译，按顺序匹配直接执行的 SQL
+	r.LowStockThreshold = 3
+	r.OnLowStock = func(productID int64, remaining int) { calls = append(calls, call{productID, remaining}) }
+
+	// 库存从 5 开始，每笔扣 1；第二笔提交失败，扣减被回滚
+	steps := []struct {
+		remaining int
+		commitErr error
+	}{
+		{4, nil},
+		{3, nil},
+		{2, errors.New("commit lost")},
+		{2, nil},
+		{1, nil},
+	}
+	for i, s := range steps {
+		txID := fmt.Sprintf("tx_%d", i)
+		mock.ExpectQuery("SELECT COUNT").WithArgs(txID).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE seckill_inventory").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT stock FROM seckill_inventory").WithArgs(int64(1001)).
+			WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(s.remaining))
+		mock.ExpectExec("INSERT INTO inventory_deduct_log").WillReturnResult(sqlmock.NewResult(1, 1))
+		if s.commitErr != nil {
+			mock.ExpectCommit().WillReturnError(s.commitErr)
+		} else {
+			mock.ExpectCommit()
+		}
+
+		err := r.Try(&SeckillDirectTCCContext{TransactionID: txID, ProductID: 1001, Quantity: 1})
+		if (err != nil) != (s.commitErr != nil) {
+			t.Fatalf("step %d: Try error = %v", i, err)
+		}
+		if s.commitErr != nil && len(calls) != 0 {
+			t.Fatalf("OnLowStock fired for a rolled-back deduction: %v", calls)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 1 || calls[0] != (call{1001, 2}) {
+		t.Fatalf("OnLowStock calls = %v, want exactly [{1001 2}]", calls)
+	}
+
+	// 补货后重新计数
+	r.ResetLowStockAlert(1001)
+	r.lowStock.Notify(r.LowStockThreshold, r.OnLowStock, 1001, 0)
+	if len(calls) != 2 {
+		t.Fatalf("OnLowStock calls after reset = %d, want 2", len(calls))
+	}
+}
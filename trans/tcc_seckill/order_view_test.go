@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"test/trans/internal/orderview"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestOrderViewQueriesSeckillOrder(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	r := NewDirectOrderResource(db)
+	now := time.Now()
+
+	cols := []string{"transaction_id", "user_id", "product_id", "quantity", "unit_price", "total_amount", "status", "created_at", "updated_at"}
+	mock.ExpectQuery("SELECT transaction_id, user_id, product_id, quantity, unit_price, total_amount, status, created_at, updated_at FROM seckill_order\\s+WHERE transaction_id = \\?").
+		WithArgs("tx_1").WillReturnRows(sqlmock.NewRows(cols).AddRow("tx_1", 10001, 1001, 2, 100.0, 200.0, "CONFIRMED", now, now))
+	if o, err := r.GetOrder(context.Background(), "tx_1"); err != nil || o.UnitPrice != 100 || o.Status != "CONFIRMED" {
+		t.Fatalf("GetOrder = %+v, %v", o, err)
+	}
+	mock.ExpectQuery("FROM seckill_order\\s+WHERE user_id = \\?").WithArgs(int64(10001), orderview.DefaultPageSize, 0).
+		WillReturnRows(sqlmock.NewRows(cols))
+	if orders, err := r.ListUserOrders(context.Background(), 10001, 0, 0); err != nil || len(orders) != 0 {
+		t.Fatalf("ListUserOrders = %v, %v", orders, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
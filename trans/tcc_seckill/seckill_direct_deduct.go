@@ -1,11 +1,18 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
+	"os"
 	"sync"
+	"test/trans/internal/lowstock"
+	"test/trans/internal/tccutil"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -19,12 +26,17 @@ type SeckillDirectTCCContext struct {
 	Quantity      int
 	Price         float64
 	StartTime     time.Time
+
+	// IdempotencyKey 调用方为同一次购买生成的幂等键，可选。
+	// 相同幂等键的请求落到第一次登记的事务上，返回该事务的结果，不会产生第二笔购买
+	IdempotencyKey string
 }
 
 // TCC事务状态
 type TCCTransactionStatus string
 
 const (
+	TCCStatusTrying    TCCTransactionStatus = "TRYING" // 已登记幂等键，Try 尚未完成
 	TCCStatusTried     TCCTransactionStatus = "TRIED"
 	TCCStatusConfirmed TCCTransactionStatus = "CONFIRMED"
 	TCCStatusCancelled TCCTransactionStatus = "CANCELLED"
@@ -38,6 +50,16 @@ type TCCTransactionLog struct {
 	UpdatedAt     time.Time
 }
 
+// 业务拒绝：重试没有意义，调用方应直接告知用户
+var (
+	ErrSoldOut             = errors.New("库存不足或商品不可用")
+	ErrInsufficientBalance = errors.New("余额不足或账户不可用")
+	ErrTxCancelled         = errors.New("事务已取消")
+)
+
+// ErrShuttingDown 管理器已调用 Shutdown，不再接受新事务
+var ErrShuttingDown = errors.New("秒杀服务正在关闭")
+
 // TCC资源接口
 type DirectTCCResource interface {
 	Try(ctx *SeckillDirectTCCContext) error
@@ -47,12 +69,28 @@ type DirectTCCResource interface {
 
 // 库存资源 - Try阶段直接扣减
 type DirectInventoryResource struct {
-	db *sql.DB
-	mu sync.RWMutex
+	db     *sql.DB
+	stmts  *stmtCache
+	mu     sync.RWMutex
+	txOpts *sql.TxOptions // 与管理器共享，nil 时使用默认隔离级别
+
+	// LowStockThreshold 扣减后可用库存低于该值时触发 OnLowStock，<=0 表示不检查
+	LowStockThreshold int
+	// OnLowStock 库存低于 LowStockThreshold 时在扣减事务提交后回调，每个商品只触发一次，
+	// 补货后调用 ResetLowStockAlert 重新计数；回调在执行 Try 的 goroutine 中运行，耗时操作应自行异步
+	OnLowStock func(productID int64, remaining int)
+	lowStock   lowstock.Alert
 }
 
+func (r *DirectInventoryResource) Name() string { return "inventory" }
+
 func NewDirectInventoryResource(db *sql.DB) *DirectInventoryResource {
-	return &DirectInventoryResource{db: db}
+	return &DirectInventoryResource{db: db, stmts: newStmtCache(db)}
+}
+
+// Close 关闭资源的预编译语句缓存，不关闭 db
+func (r *DirectInventoryResource) Close() error {
+	return r.stmts.Close()
 }
 
 // Try阶段：直接扣减库存（幂等性保证）
@@ -62,7 +100,7 @@ func (r *DirectInventoryResource) Try(ctx *SeckillDirectTCCContext) error {
 
 	// 检查是否已经执行过Try操作（防重复执行）
 	var count int
-	err := r.db.QueryRow(`
+	err := r.stmts.QueryRow(`
 		SELECT COUNT(*) FROM inventory_deduct_log 
 		WHERE transaction_id = ? AND operation_type IN ('TRY_DEDUCT', 'CONFIRMED', 'CANCELLED')
 	`, ctx.TransactionID).Scan(&count)
@@ -77,20 +115,24 @@ func (r *DirectInventoryResource) Try(ctx *SeckillDirectTCCContext) error {
 	}
 
 	// 开启事务
-	tx, err := r.db.Begin()
+	tx, err := r.db.BeginTx(context.Background(), r.txOpts)
 	if err != nil {
 		return fmt.Errorf("开启事务失败: %v", err)
 	}
 	defer tx.Rollback()
 
-	// 使用行级锁直接扣减库存
-	result, err := tx.Exec(`
+	// 使用行级锁直接扣减库存，热点行锁等待超时时在事务内重试
+	var result sql.Result
+	err = tccutil.Retry(context.Background(), func() (err error) {
+		result, err = r.stmts.TxExec(tx, `
 		UPDATE seckill_inventory 
 		SET stock = stock - ?, 
 		    sold_count = sold_count + ?,
 		    updated_at = NOW()
 		WHERE product_id = ? AND stock >= ? AND status = 'ACTIVE'
 	`, ctx.Quantity, ctx.Quantity, ctx.ProductID, ctx.Quantity)
+		return err
+	})
 
 	if err != nil {
 		return fmt.Errorf("扣减库存失败: %v", err)
@@ -103,11 +145,23 @@ func (r *DirectInventoryResource) Try(ctx *SeckillDirectTCCContext) error {
 	}
 
 	if rowsAffected == 0 {
-		return errors.New("库存不足或商品不可用")
+		return ErrSoldOut
+	}
+
+	// 设置了低库存通知时读取扣减后的库存，行已被本事务的 UPDATE 锁住，读到的就是扣减后的值
+	remaining := -1
+	if r.OnLowStock != nil && r.LowStockThreshold > 0 {
+		err = r.stmts.TxQueryRow(tx, `
+			SELECT stock FROM seckill_inventory 
+			WHERE product_id = ?
+		`, ctx.ProductID).Scan(&remaining)
+		if err != nil {
+			return fmt.Errorf("查询剩余库存失败: %v", err)
+		}
 	}
 
 	// 记录扣减日志
-	_, err = tx.Exec(`
+	_, err = r.stmts.TxExec(tx, `
 		INSERT INTO inventory_deduct_log 
 		(transaction_id, product_id, quantity, operation_type, created_at)
 		VALUES (?, ?, ?, 'TRY_DEDUCT', NOW())
@@ -121,6 +175,11 @@ func (r *DirectInventoryResource) Try(ctx *SeckillDirectTCCContext) error {
 		return fmt.Errorf("提交事务失败: %v", err)
 	}
 
+	// 只在扣减已提交后通知，回滚的扣减不会触发
+	if remaining >= 0 {
+		r.lowStock.Notify(r.LowStockThreshold, r.OnLowStock, ctx.ProductID, remaining)
+	}
+
 	log.Printf("[库存资源] Try阶段成功 - 已扣减库存: %d", ctx.Quantity)
 	return nil
 }
@@ -129,7 +188,7 @@ func (r *DirectInventoryResource) Try(ctx *SeckillDirectTCCContext) error {
 func (r *DirectInventoryResource) Confirm(ctx *SeckillDirectTCCContext) error {
 	log.Printf("[库存资源] Confirm阶段开始 - 事务ID: %s", ctx.TransactionID)
 
-	tx, err := r.db.Begin()
+	tx, err := r.db.BeginTx(context.Background(), r.txOpts)
 	if err != nil {
 		return fmt.Errorf("开启事务失败: %v", err)
 	}
@@ -137,7 +196,7 @@ func (r *DirectInventoryResource) Confirm(ctx *SeckillDirectTCCContext) error {
 
 	// 检查当前状态，确保幂等性
 	var currentType string
-	err = tx.QueryRow(`
+	err = r.stmts.TxQueryRow(tx, `
 		SELECT operation_type FROM inventory_deduct_log 
 		WHERE transaction_id = ? 
 		ORDER BY updated_at DESC LIMIT 1
@@ -161,7 +220,7 @@ func (r *DirectInventoryResource) Confirm(ctx *SeckillDirectTCCContext) error {
 	}
 
 	// 更新扣减日志状态为已确认
-	_, err = tx.Exec(`
+	_, err = r.stmts.TxExec(tx, `
 		UPDATE inventory_deduct_log 
 		SET operation_type = 'CONFIRMED', updated_at = NOW()
 		WHERE transaction_id = ? AND operation_type = 'TRY_DEDUCT'
@@ -183,7 +242,7 @@ func (r *DirectInventoryResource) Confirm(ctx *SeckillDirectTCCContext) error {
 func (r *DirectInventoryResource) Cancel(ctx *SeckillDirectTCCContext) error {
 	log.Printf("[库存资源] Cancel阶段开始 - 事务ID: %s", ctx.TransactionID)
 
-	tx, err := r.db.Begin()
+	tx, err := r.db.BeginTx(context.Background(), r.txOpts)
 	if err != nil {
 		return fmt.Errorf("开启事务失败: %v", err)
 	}
@@ -192,7 +251,7 @@ func (r *DirectInventoryResource) Cancel(ctx *SeckillDirectTCCContext) error {
 	// 检查当前状态和扣减数量
 	var currentType string
 	var quantity int
-	err = tx.QueryRow(`
+	err = r.stmts.TxQueryRow(tx, `
 		SELECT operation_type, quantity FROM inventory_deduct_log 
 		WHERE transaction_id = ? 
 		ORDER BY updated_at DESC LIMIT 1
@@ -218,7 +277,7 @@ func (r *DirectInventoryResource) Cancel(ctx *SeckillDirectTCCContext) error {
 	}
 
 	// 返还库存
-	_, err = tx.Exec(`
+	_, err = r.stmts.TxExec(tx, `
 		UPDATE seckill_inventory 
 		SET stock = stock + ?, 
 		    sold_count = sold_count - ?,
@@ -231,7 +290,7 @@ func (r *DirectInventoryResource) Cancel(ctx *SeckillDirectTCCContext) error {
 	}
 
 	// 更新补偿日志
-	_, err = tx.Exec(`
+	_, err = r.stmts.TxExec(tx, `
 		UPDATE inventory_deduct_log 
 		SET operation_type = 'CANCELLED', updated_at = NOW()
 		WHERE transaction_id = ?
@@ -251,12 +310,21 @@ func (r *DirectInventoryResource) Cancel(ctx *SeckillDirectTCCContext) error {
 
 // 账户资源 - Try阶段直接扣减
 type DirectAccountResource struct {
-	db *sql.DB
-	mu sync.RWMutex
+	db     *sql.DB
+	stmts  *stmtCache
+	mu     sync.RWMutex
+	txOpts *sql.TxOptions // 与管理器共享，nil 时使用默认隔离级别
 }
 
+func (r *DirectAccountResource) Name() string { return "account" }
+
 func NewDirectAccountResource(db *sql.DB) *DirectAccountResource {
-	return &DirectAccountResource{db: db}
+	return &DirectAccountResource{db: db, stmts: newStmtCache(db)}
+}
+
+// Close 关闭资源的预编译语句缓存，不关闭 db
+func (r *DirectAccountResource) Close() error {
+	return r.stmts.Close()
 }
 
 // Try阶段：直接扣减余额（幂等性保证）
@@ -266,7 +334,7 @@ func (r *DirectAccountResource) Try(ctx *SeckillDirectTCCContext) error {
 
 	// 检查是否已经执行过Try操作
 	var count int
-	err := r.db.QueryRow(`
+	err := r.stmts.QueryRow(`
 		SELECT COUNT(*) FROM account_deduct_log 
 		WHERE transaction_id = ? AND operation_type IN ('TRY_DEDUCT', 'CONFIRMED', 'CANCELLED')
 	`, ctx.TransactionID).Scan(&count)
@@ -282,18 +350,22 @@ func (r *DirectAccountResource) Try(ctx *SeckillDirectTCCContext) error {
 
 	totalAmount := ctx.Price * float64(ctx.Quantity)
 
-	tx, err := r.db.Begin()
+	tx, err := r.db.BeginTx(context.Background(), r.txOpts)
 	if err != nil {
 		return fmt.Errorf("开启事务失败: %v", err)
 	}
 	defer tx.Rollback()
 
-	// 直接扣减用户余额
-	result, err := tx.Exec(`
+	// 直接扣减用户余额，行锁等待超时时在事务内重试
+	var result sql.Result
+	err = tccutil.Retry(context.Background(), func() (err error) {
+		result, err = r.stmts.TxExec(tx, `
 		UPDATE user_account 
 		SET balance = balance - ?, updated_at = NOW()
 		WHERE user_id = ? AND balance >= ? AND status = 'ACTIVE'
 	`, totalAmount, ctx.UserID, totalAmount)
+		return err
+	})
 
 	if err != nil {
 		return fmt.Errorf("扣减余额失败: %v", err)
@@ -305,11 +377,11 @@ func (r *DirectAccountResource) Try(ctx *SeckillDirectTCCContext) error {
 	}
 
 	if rowsAffected == 0 {
-		return errors.New("余额不足或账户不可用")
+		return ErrInsufficientBalance
 	}
 
 	// 记录扣减日志
-	_, err = tx.Exec(`
+	_, err = r.stmts.TxExec(tx, `
 		INSERT INTO account_deduct_log 
 		(transaction_id, user_id, amount, operation_type, created_at)
 		VALUES (?, ?, ?, 'TRY_DEDUCT', NOW())
@@ -319,6 +391,10 @@ func (r *DirectAccountResource) Try(ctx *SeckillDirectTCCContext) error {
 		return fmt.Errorf("记录扣减日志失败: %v", err)
 	}
 
+	if err = r.appendLedger(tx, ctx.UserID, ctx.TransactionID, -totalAmount, "try"); err != nil {
+		return err
+	}
+
 	if err = tx.Commit(); err != nil {
 		return fmt.Errorf("提交事务失败: %v", err)
 	}
@@ -331,7 +407,7 @@ func (r *DirectAccountResource) Try(ctx *SeckillDirectTCCContext) error {
 func (r *DirectAccountResource) Confirm(ctx *SeckillDirectTCCContext) error {
 	log.Printf("[账户资源] Confirm阶段开始 - 事务ID: %s", ctx.TransactionID)
 
-	tx, err := r.db.Begin()
+	tx, err := r.db.BeginTx(context.Background(), r.txOpts)
 	if err != nil {
 		return fmt.Errorf("开启事务失败: %v", err)
 	}
@@ -339,7 +415,7 @@ func (r *DirectAccountResource) Confirm(ctx *SeckillDirectTCCContext) error {
 
 	// 检查当前状态
 	var currentType string
-	err = tx.QueryRow(`
+	err = r.stmts.TxQueryRow(tx, `
 		SELECT operation_type FROM account_deduct_log 
 		WHERE transaction_id = ? 
 		ORDER BY updated_at DESC LIMIT 1
@@ -362,7 +438,7 @@ func (r *DirectAccountResource) Confirm(ctx *SeckillDirectTCCContext) error {
 		return fmt.Errorf("事务状态异常，当前状态: %s", currentType)
 	}
 
-	_, err = tx.Exec(`
+	_, err = r.stmts.TxExec(tx, `
 		UPDATE account_deduct_log 
 		SET operation_type = 'CONFIRMED', updated_at = NOW()
 		WHERE transaction_id = ? AND operation_type = 'TRY_DEDUCT'
@@ -372,6 +448,11 @@ func (r *DirectAccountResource) Confirm(ctx *SeckillDirectTCCContext) error {
 		return fmt.Errorf("确认扣减日志失败: %v", err)
 	}
 
+	// Confirm 不改变余额，仍记一条流水，便于核对每个阶段都执行过
+	if err = r.appendLedger(tx, ctx.UserID, ctx.TransactionID, 0, "confirm"); err != nil {
+		return err
+	}
+
 	if err = tx.Commit(); err != nil {
 		return fmt.Errorf("提交确认事务失败: %v", err)
 	}
@@ -384,7 +465,7 @@ func (r *DirectAccountResource) Confirm(ctx *SeckillDirectTCCContext) error {
 func (r *DirectAccountResource) Cancel(ctx *SeckillDirectTCCContext) error {
 	log.Printf("[账户资源] Cancel阶段开始 - 事务ID: %s", ctx.TransactionID)
 
-	tx, err := r.db.Begin()
+	tx, err := r.db.BeginTx(context.Background(), r.txOpts)
 	if err != nil {
 		return fmt.Errorf("开启事务失败: %v", err)
 	}
@@ -393,7 +474,7 @@ func (r *DirectAccountResource) Cancel(ctx *SeckillDirectTCCContext) error {
 	// 检查扣减记录
 	var currentType string
 	var amount float64
-	err = tx.QueryRow(`
+	err = r.stmts.TxQueryRow(tx, `
 		SELECT operation_type, amount FROM account_deduct_log 
 		WHERE transaction_id = ? 
 		ORDER BY updated_at DESC LIMIT 1
@@ -419,7 +500,7 @@ func (r *DirectAccountResource) Cancel(ctx *SeckillDirectTCCContext) error {
 	}
 
 	// 返还余额
-	_, err = tx.Exec(`
+	_, err = r.stmts.TxExec(tx, `
 		UPDATE user_account 
 		SET balance = balance + ?, updated_at = NOW()
 		WHERE user_id = ?
@@ -430,7 +511,7 @@ func (r *DirectAccountResource) Cancel(ctx *SeckillDirectTCCContext) error {
 	}
 
 	// 更新补偿日志
-	_, err = tx.Exec(`
+	_, err = r.stmts.TxExec(tx, `
 		UPDATE account_deduct_log 
 		SET operation_type = 'CANCELLED', updated_at = NOW()
 		WHERE transaction_id = ?
@@ -440,6 +521,10 @@ func (r *DirectAccountResource) Cancel(ctx *SeckillDirectTCCContext) error {
 		return fmt.Errorf("更新补偿日志失败: %v", err)
 	}
 
+	if err = r.appendLedger(tx, ctx.UserID, ctx.TransactionID, amount, "cancel"); err != nil {
+		return err
+	}
+
 	if err = tx.Commit(); err != nil {
 		return fmt.Errorf("提交补偿事务失败: %v", err)
 	}
@@ -450,11 +535,20 @@ func (r *DirectAccountResource) Cancel(ctx *SeckillDirectTCCContext) error {
 
 // 订单资源
 type DirectOrderResource struct {
-	db *sql.DB
+	db     *sql.DB
+	stmts  *stmtCache
+	txOpts *sql.TxOptions // 与管理器共享，nil 时使用默认隔离级别
 }
 
+func (r *DirectOrderResource) Name() string { return "order" }
+
 func NewDirectOrderResource(db *sql.DB) *DirectOrderResource {
-	return &DirectOrderResource{db: db}
+	return &DirectOrderResource{db: db, stmts: newStmtCache(db)}
+}
+
+// Close 关闭资源的预编译语句缓存，不关闭 db
+func (r *DirectOrderResource) Close() error {
+	return r.stmts.Close()
 }
 
 // Try阶段：创建订单（幂等性保证）
@@ -463,7 +557,7 @@ func (r *DirectOrderResource) Try(ctx *SeckillDirectTCCContext) error {
 
 	// 检查订单是否已存在
 	var count int
-	err := r.db.QueryRow(`
+	err := r.stmts.QueryRow(`
 		SELECT COUNT(*) FROM seckill_order 
 		WHERE transaction_id = ?
 	`, ctx.TransactionID).Scan(&count)
@@ -477,7 +571,7 @@ func (r *DirectOrderResource) Try(ctx *SeckillDirectTCCContext) error {
 		return nil
 	}
 
-	tx, err := r.db.Begin()
+	tx, err := r.db.BeginTx(context.Background(), r.txOpts)
 	if err != nil {
 		return fmt.Errorf("开启事务失败: %v", err)
 	}
@@ -485,7 +579,7 @@ func (r *DirectOrderResource) Try(ctx *SeckillDirectTCCContext) error {
 
 	totalAmount := ctx.Price * float64(ctx.Quantity)
 
-	_, err = tx.Exec(`
+	_, err = r.stmts.TxExec(tx, `
 		INSERT INTO seckill_order 
 		(transaction_id, user_id, product_id, quantity, unit_price, total_amount, status, created_at)
 		VALUES (?, ?, ?, ?, ?, ?, 'PENDING', NOW())
@@ -507,7 +601,7 @@ func (r *DirectOrderResource) Try(ctx *SeckillDirectTCCContext) error {
 func (r *DirectOrderResource) Confirm(ctx *SeckillDirectTCCContext) error {
 	log.Printf("[订单资源] Confirm阶段开始 - 事务ID: %s", ctx.TransactionID)
 
-	tx, err := r.db.Begin()
+	tx, err := r.db.BeginTx(context.Background(), r.txOpts)
 	if err != nil {
 		return fmt.Errorf("开启事务失败: %v", err)
 	}
@@ -515,7 +609,7 @@ func (r *DirectOrderResource) Confirm(ctx *SeckillDirectTCCContext) error {
 
 	// 检查当前订单状态
 	var currentStatus string
-	err = tx.QueryRow(`
+	err = r.stmts.TxQueryRow(tx, `
 		SELECT status FROM seckill_order 
 		WHERE transaction_id = ?
 	`, ctx.TransactionID).Scan(&currentStatus)
@@ -537,7 +631,7 @@ func (r *DirectOrderResource) Confirm(ctx *SeckillDirectTCCContext) error {
 		return fmt.Errorf("订单状态异常，当前状态: %s", currentStatus)
 	}
 
-	_, err = tx.Exec(`
+	_, err = r.stmts.TxExec(tx, `
 		UPDATE seckill_order 
 		SET status = 'CONFIRMED', updated_at = NOW()
 		WHERE transaction_id = ?
@@ -559,7 +653,7 @@ func (r *DirectOrderResource) Confirm(ctx *SeckillDirectTCCContext) error {
 func (r *DirectOrderResource) Cancel(ctx *SeckillDirectTCCContext) error {
 	log.Printf("[订单资源] Cancel阶段开始 - 事务ID: %s", ctx.TransactionID)
 
-	tx, err := r.db.Begin()
+	tx, err := r.db.BeginTx(context.Background(), r.txOpts)
 	if err != nil {
 		return fmt.Errorf("开启事务失败: %v", err)
 	}
@@ -567,7 +661,7 @@ func (r *DirectOrderResource) Cancel(ctx *SeckillDirectTCCContext) error {
 
 	// 检查当前订单状态
 	var currentStatus string
-	err = tx.QueryRow(`
+	err = r.stmts.TxQueryRow(tx, `
 		SELECT status FROM seckill_order 
 		WHERE transaction_id = ?
 	`, ctx.TransactionID).Scan(&currentStatus)
@@ -586,7 +680,7 @@ func (r *DirectOrderResource) Cancel(ctx *SeckillDirectTCCContext) error {
 		return nil
 	}
 
-	_, err = tx.Exec(`
+	_, err = r.stmts.TxExec(tx, `
 		UPDATE seckill_order 
 		SET status = 'CANCELLED', updated_at = NOW()
 		WHERE transaction_id = ?
@@ -608,23 +702,106 @@ func (r *DirectOrderResource) Cancel(ctx *SeckillDirectTCCContext) error {
 type SeckillDirectTCCManager struct {
 	resources []DirectTCCResource
 	db        *sql.DB
+	stmts     *stmtCache     // 管理器与资源共享的预编译语句缓存，Shutdown 时关闭
+	txOpts    *sql.TxOptions // 管理器与资源共享的事务选项，见 SetIsolationLevel
 	mu        sync.RWMutex
+
+	closing  bool           // 由 mu 保护，Shutdown 后为 true
+	inflight sync.WaitGroup // 进行中的 ExecuteSeckill
+
+	// Stats 秒杀事务成功/失败统计，由 ExecuteSeckill 更新
+	Stats Stats
+
+	// OnResult 每笔事务结束时回调成功/失败/售罄结果，为 nil 时不发布。
+	// 在 ExecuteSeckill 的 goroutine 中同步调用，不能阻塞，通常设为 ResultHub.Publish
+	OnResult func(SeckillEvent)
+
+	// Logger 每个资源每个阶段的结构化日志，nil 时以文本输出到标准 log
+	Logger Logger
 }
 
 func NewSeckillDirectTCCManager(db *sql.DB) *SeckillDirectTCCManager {
+	stmts := newStmtCache(db)
+	txOpts := &sql.TxOptions{}
 	return &SeckillDirectTCCManager{
 		resources: []DirectTCCResource{
-			NewDirectInventoryResource(db),
-			NewDirectAccountResource(db),
-			NewDirectOrderResource(db),
+			&DirectInventoryResource{db: db, stmts: stmts, txOpts: txOpts},
+			&DirectAccountResource{db: db, stmts: stmts, txOpts: txOpts},
+			&DirectOrderResource{db: db, stmts: stmts, txOpts: txOpts},
 		},
-		db: db,
+		db:     db,
+		stmts:  stmts,
+		txOpts: txOpts,
+	}
+}
+
+// SetIsolationLevel 设置内置资源开启事务时的隔离级别，须在执行事务前调用；
+// 默认 sql.LevelDefault 沿用服务器设置（MySQL 为 REPEATABLE READ）。
+// 库存和余额都用带条件的 UPDATE ... WHERE stock >= ? / balance >= ? 直接扣减，UPDATE 是当前读，
+// 总是基于最新提交的值判断并加行锁，两种级别下都不会超卖；
+// READ COMMITTED 不加间隙锁，扣减日志、订单等并发插入互不阻塞，热点商品下锁等待和死锁更少，
+// 但"先 COUNT 再插入"的防重检查可能被并发的同一事务ID同时通过，最终由
+// inventory_deduct_log / account_deduct_log 的 UNIQUE(transaction_id) 和 seckill_order 的 UNIQUE(transaction_id) 兜底
+func (stm *SeckillDirectTCCManager) SetIsolationLevel(level sql.IsolationLevel) {
+	stm.txOpts.Isolation = level
+}
+
+// begin 登记一笔进行中的事务，已关闭时返回 ErrShuttingDown。
+// 检查 closing 和 Add 在读锁内完成，Shutdown 拿到写锁后不会再有新的 Add，Wait 不会与 Add 竞争
+func (stm *SeckillDirectTCCManager) begin() error {
+	stm.mu.RLock()
+	defer stm.mu.RUnlock()
+	if stm.closing {
+		return ErrShuttingDown
 	}
+	stm.inflight.Add(1)
+	return nil
+}
+
+// Shutdown 停止接受新事务，等待进行中的事务结束（最多到 ctx 截止），
+// 再做一次恢复扫描处理残留的未完成事务，最后关闭管理器和各资源的预编译语句，不关闭 db。
+// ctx 先到期时既不做恢复扫描也不关闭预编译语句，仍在执行的事务可以正常走完 Confirm/Cancel，
+// 残留事务留给下次启动时的 RecoverTransactions；可以用新的 ctx 再次调用 Shutdown 继续等待
+func (stm *SeckillDirectTCCManager) Shutdown(ctx context.Context) error {
+	stm.mu.Lock()
+	stm.closing = true
+	stm.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		stm.inflight.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		log.Printf("[秒杀TCC] 等待进行中的事务超时: %v", ctx.Err())
+		return fmt.Errorf("等待进行中的事务超时: %w", ctx.Err())
+	}
+
+	var errs []error
+	log.Printf("[秒杀TCC] 进行中的事务已全部结束，执行最后一次恢复扫描")
+	if err := stm.RecoverTransactions(); err != nil {
+		errs = append(errs, fmt.Errorf("关闭前恢复扫描失败: %w", err))
+	}
+	if err := stm.stmts.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	// 单独创建后加入管理器的资源各有自己的语句缓存，与管理器共享的缓存重复关闭无影响
+	for _, r := range stm.resources {
+		if c, ok := r.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
 }
 
 // 记录TCC事务日志
 func (stm *SeckillDirectTCCManager) logTCCTransaction(transactionID string, status TCCTransactionStatus) error {
-	_, err := stm.db.Exec(`
+	_, err := stm.stmts.Exec(`
 		INSERT INTO tcc_transaction_log (transaction_id, status, created_at, updated_at)
 		VALUES (?, ?, NOW(), NOW())
 		ON DUPLICATE KEY UPDATE status = ?, updated_at = NOW()
@@ -633,13 +810,32 @@ func (stm *SeckillDirectTCCManager) logTCCTransaction(transactionID string, stat
 }
 
 // 执行秒杀事务（带防重复执行）
-func (stm *SeckillDirectTCCManager) ExecuteSeckill(ctx *SeckillDirectTCCContext) error {
+func (stm *SeckillDirectTCCManager) ExecuteSeckill(ctx *SeckillDirectTCCContext) (err error) {
+	if err := stm.begin(); err != nil {
+		return err
+	}
+	defer stm.inflight.Done()
+	defer func() { stm.Stats.record(err) }()
+	defer func() {
+		// 与进行中事务重复的请求没有自己的结果，不发布
+		if stm.OnResult != nil && !errors.Is(err, ErrTxInProgress) {
+			stm.OnResult(newSeckillEvent(ctx, err))
+		}
+	}()
+
 	log.Printf("[秒杀TCC] 开始执行秒杀事务: %s", ctx.TransactionID)
 	ctx.StartTime = time.Now()
 
+	// 带幂等键时先登记，重复的请求改用第一次登记的事务ID，再按事务ID去重
+	if ctx.IdempotencyKey != "" {
+		if err = stm.claimIdempotencyKey(ctx); err != nil {
+			return err
+		}
+	}
+
 	// 检查事务是否已经完成（防重复执行）
 	var status string
-	err := stm.db.QueryRow(`
+	err = stm.stmts.QueryRow(`
 		SELECT status FROM tcc_transaction_log 
 		WHERE transaction_id = ?
 	`, ctx.TransactionID).Scan(&status)
@@ -651,16 +847,16 @@ func (stm *SeckillDirectTCCManager) ExecuteSeckill(ctx *SeckillDirectTCCContext)
 		}
 		if status == string(TCCStatusCancelled) {
 			log.Printf("[秒杀TCC] 事务已取消，跳过重复执行: %s", ctx.TransactionID)
-			return errors.New("事务已取消")
+			return ErrTxCancelled
 		}
 	}
 
 	// Try阶段：直接扣减资源
 	if err := stm.tryResources(ctx); err != nil {
 		log.Printf("[秒杀TCC] Try阶段失败: %v", err)
-		stm.logTCCTransaction(ctx.TransactionID, TCCStatusCancelled)
+		stm.logCancelled(ctx, err)
 		stm.cancelResources(ctx)
-		return fmt.Errorf("秒杀失败: %v", err)
+		return fmt.Errorf("秒杀失败: %w", err)
 	}
 
 	// 记录Try成功状态
@@ -671,7 +867,7 @@ func (stm *SeckillDirectTCCManager) ExecuteSeckill(ctx *SeckillDirectTCCContext)
 	// Confirm阶段：确认所有操作
 	if err := stm.confirmResources(ctx); err != nil {
 		log.Printf("[秒杀TCC] Confirm阶段失败: %v", err)
-		stm.logTCCTransaction(ctx.TransactionID, TCCStatusCancelled)
+		stm.logCancelled(ctx, err)
 		stm.cancelResources(ctx)
 		return fmt.Errorf("确认失败: %v", err)
 	}
@@ -690,11 +886,11 @@ func (stm *SeckillDirectTCCManager) ExecuteSeckill(ctx *SeckillDirectTCCContext)
 func (stm *SeckillDirectTCCManager) tryResources(ctx *SeckillDirectTCCContext) error {
 	log.Printf("[秒杀TCC] 开始Try阶段")
 	for i, resource := range stm.resources {
-		if err := resource.Try(ctx); err != nil {
+		if err := stm.runPhase(ctx, resource, "try", resource.Try); err != nil {
 			log.Printf("[秒杀TCC] Try失败，资源%d: %v", i, err)
 			// 补偿已成功的资源
 			for j := i - 1; j >= 0; j-- {
-				if cancelErr := stm.resources[j].Cancel(ctx); cancelErr != nil {
+				if cancelErr := stm.runPhase(ctx, stm.resources[j], "cancel", stm.resources[j].Cancel); cancelErr != nil {
 					log.Printf("[秒杀TCC] 补偿失败，资源%d: %v", j, cancelErr)
 				} else {
 					stm.markResourceCancelCompleted(ctx.TransactionID, j)
@@ -712,7 +908,7 @@ func (stm *SeckillDirectTCCManager) tryResources(ctx *SeckillDirectTCCContext) e
 func (stm *SeckillDirectTCCManager) confirmResources(ctx *SeckillDirectTCCContext) error {
 	log.Printf("[秒杀TCC] 开始Confirm阶段")
 	for i, resource := range stm.resources {
-		if err := resource.Confirm(ctx); err != nil {
+		if err := stm.runPhase(ctx, resource, "confirm", resource.Confirm); err != nil {
 			log.Printf("[秒杀TCC] Confirm失败，资源%d: %v", i, err)
 			return err
 		}
@@ -726,7 +922,7 @@ func (stm *SeckillDirectTCCManager) confirmResources(ctx *SeckillDirectTCCContex
 func (stm *SeckillDirectTCCManager) cancelResources(ctx *SeckillDirectTCCContext) {
 	log.Printf("[秒杀TCC] 开始Cancel补偿操作")
 	for i, resource := range stm.resources {
-		if err := resource.Cancel(ctx); err != nil {
+		if err := stm.runPhase(ctx, resource, "cancel", resource.Cancel); err != nil {
 			log.Printf("[秒杀TCC] Cancel补偿失败，资源%d: %v", i, err)
 		} else {
 			// 标记Cancel成功
@@ -809,7 +1005,7 @@ func (stm *SeckillDirectTCCManager) recoverSingleTransaction(transactionID strin
 // 从Try阶段恢复
 func (stm *SeckillDirectTCCManager) recoverFromTryPhase(ctx *SeckillDirectTCCContext) error {
 	log.Printf("[恢复机制] 从Try阶段恢复: %s", ctx.TransactionID)
-	
+
 	// 检查Try阶段每个资源的执行状态
 	for i, resource := range stm.resources {
 		if !stm.isResourceTryCompleted(ctx.TransactionID, i) {
@@ -834,7 +1030,7 @@ func (stm *SeckillDirectTCCManager) recoverFromTryPhase(ctx *SeckillDirectTCCCon
 // 从Confirm阶段恢复
 func (stm *SeckillDirectTCCManager) recoverFromConfirmPhase(ctx *SeckillDirectTCCContext) error {
 	log.Printf("[恢复机制] 从Confirm阶段恢复: %s", ctx.TransactionID)
-	
+
 	// 检查Confirm阶段每个资源的执行状态
 	for i, resource := range stm.resources {
 		if !stm.isResourceConfirmCompleted(ctx.TransactionID, i) {
@@ -854,7 +1050,7 @@ func (stm *SeckillDirectTCCManager) recoverFromConfirmPhase(ctx *SeckillDirectTC
 // 从Cancel阶段恢复
 func (stm *SeckillDirectTCCManager) recoverFromCancelPhase(ctx *SeckillDirectTCCContext) error {
 	log.Printf("[恢复机制] 从Cancel阶段恢复: %s", ctx.TransactionID)
-	
+
 	// 检查Cancel阶段每个资源的执行状态
 	for i, resource := range stm.resources {
 		if !stm.isResourceCancelCompleted(ctx.TransactionID, i) {
@@ -901,8 +1097,8 @@ func (stm *SeckillDirectTCCManager) isResourceCancelCompleted(transactionID stri
 func (stm *SeckillDirectTCCManager) markResourceTryCompleted(transactionID string, resourceIndex int) {
 	resourceTypes := []string{"inventory", "account", "order"}
 	resourceType := resourceTypes[resourceIndex]
-	
-	stm.db.Exec(`
+
+	stm.stmts.Exec(`
 		INSERT INTO tcc_resource_status 
 		(transaction_id, resource_type, resource_index, phase, status, created_at, updated_at)
 		VALUES (?, ?, ?, 'try', 'completed', NOW(), NOW())
@@ -914,8 +1110,8 @@ func (stm *SeckillDirectTCCManager) markResourceTryCompleted(transactionID strin
 func (stm *SeckillDirectTCCManager) markResourceConfirmCompleted(transactionID string, resourceIndex int) {
 	resourceTypes := []string{"inventory", "account", "order"}
 	resourceType := resourceTypes[resourceIndex]
-	
-	stm.db.Exec(`
+
+	stm.stmts.Exec(`
 		INSERT INTO tcc_resource_status 
 		(transaction_id, resource_type, resource_index, phase, status, created_at, updated_at)
 		VALUES (?, ?, ?, 'confirm', 'completed', NOW(), NOW())
@@ -927,8 +1123,8 @@ func (stm *SeckillDirectTCCManager) markResourceConfirmCompleted(transactionID s
 func (stm *SeckillDirectTCCManager) markResourceCancelCompleted(transactionID string, resourceIndex int) {
 	resourceTypes := []string{"inventory", "account", "order"}
 	resourceType := resourceTypes[resourceIndex]
-	
-	stm.db.Exec(`
+
+	stm.stmts.Exec(`
 		INSERT INTO tcc_resource_status 
 		(transaction_id, resource_type, resource_index, phase, status, created_at, updated_at)
 		VALUES (?, ?, ?, 'cancel', 'completed', NOW(), NOW())
@@ -969,7 +1165,8 @@ func initDirectSeckillDatabase(db *sql.DB) error {
 		// TCC事务日志表
 		`CREATE TABLE IF NOT EXISTS tcc_transaction_log (
 			transaction_id VARCHAR(64) PRIMARY KEY,
-			status ENUM('TRIED', 'CONFIRMED', 'CANCELLED') NOT NULL,
+			idempotency_key VARCHAR(64) DEFAULT NULL UNIQUE COMMENT '调用方幂等键，并发重复请求靠唯一约束判定先后',
+			status ENUM('TRYING', 'TRIED', 'CONFIRMED', 'CANCELLED') NOT NULL,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
 			INDEX idx_status (status),
@@ -1013,7 +1210,7 @@ func initDirectSeckillDatabase(db *sql.DB) error {
 			operation_type ENUM('TRY_DEDUCT', 'CONFIRMED', 'CANCELLED') NOT NULL,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
-			INDEX idx_transaction_id (transaction_id),
+			UNIQUE KEY uk_transaction_id (transaction_id),
 			INDEX idx_product_id (product_id)
 		)`,
 		// 用户账户表
@@ -1037,9 +1234,21 @@ func initDirectSeckillDatabase(db *sql.DB) error {
 			operation_type ENUM('TRY_DEDUCT', 'CONFIRMED', 'CANCELLED') NOT NULL,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
-			INDEX idx_transaction_id (transaction_id),
+			UNIQUE KEY uk_transaction_id (transaction_id),
 			INDEX idx_user_id (user_id)
 		)`,
+		// 余额流水表，只追加不修改，每次 Try/Confirm/Cancel 与余额变动在同一事务内写入
+		`CREATE TABLE IF NOT EXISTS account_ledger (
+			id BIGINT PRIMARY KEY AUTO_INCREMENT,
+			user_id BIGINT NOT NULL,
+			tx_id VARCHAR(64) NOT NULL,
+			delta DECIMAL(15,2) NOT NULL COMMENT '余额变动，扣减为负',
+			balance_after DECIMAL(15,2) NOT NULL COMMENT '变动后余额',
+			phase VARCHAR(16) NOT NULL COMMENT 'try/confirm/cancel',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			INDEX idx_user_id (user_id),
+			INDEX idx_tx_id (tx_id)
+		)`,
 		// 秒杀订单表
 		`CREATE TABLE IF NOT EXISTS seckill_order (
 			id BIGINT PRIMARY KEY AUTO_INCREMENT,
@@ -1103,53 +1312,81 @@ func initDirectSeckillTestData(db *sql.DB) error {
 	return nil
 }
 
-// 高并发测试函数
-func runConcurrentSeckillTest(manager *SeckillDirectTCCManager, concurrency int) {
-	log.Printf("开始高并发秒杀测试，并发数: %d", concurrency)
+// TestConfig 高并发秒杀测试参数
+type TestConfig struct {
+	Concurrency int   // 并发 goroutine 数
+	ProductID   int64 // 秒杀商品
+	Iterations  int   // 每个 goroutine 连续发起的秒杀次数
+	UserPool    int   // 轮询使用的测试用户数，从 10001 开始编号
+}
 
-	var wg sync.WaitGroup
-	successCount := int64(0)
-	failCount := int64(0)
+var defaultTestConfig = TestConfig{
+	Concurrency: 50,
+	ProductID:   1001, // iPhone 15 Pro
+	Iterations:  1,
+	UserPool:    5,
+}
 
-	startTime := time.Now()
+// 高并发测试函数，返回本轮测试的统计快照
+func runConcurrentSeckillTest(manager *SeckillDirectTCCManager, cfg TestConfig) StatsSnapshot {
+	log.Printf("开始高并发秒杀测试，并发数: %d，每个并发 %d 次，商品: %d，用户数: %d",
+		cfg.Concurrency, cfg.Iterations, cfg.ProductID, cfg.UserPool)
+
+	var wg sync.WaitGroup
+	manager.Stats.Reset()
 
-	for i := 0; i < concurrency; i++ {
+	for i := 0; i < cfg.Concurrency; i++ {
 		wg.Add(1)
 		go func(index int) {
 			defer wg.Done()
 
-			ctx := &SeckillDirectTCCContext{
-				TransactionID: fmt.Sprintf("seckill_%d_%d", time.Now().UnixNano(), index),
-				UserID:        int64(10001 + index%5), // 轮询使用5个测试用户
-				ProductID:     1001,                   // iPhone 15 Pro
-				Quantity:      1,
-				Price:         8999.00,
-			}
+			for iter := 0; iter < cfg.Iterations; iter++ {
+				ctx := &SeckillDirectTCCContext{
+					TransactionID: fmt.Sprintf("seckill_%d_%d_%d", time.Now().UnixNano(), index, iter),
+					UserID:        int64(10001 + (index*cfg.Iterations+iter)%cfg.UserPool), // 轮询使用测试用户
+					ProductID:     cfg.ProductID,
+					Quantity:      1,
+					Price:         8999.00,
+				}
 
-			if err := manager.ExecuteSeckill(ctx); err != nil {
-				log.Printf("秒杀失败[%d]: %v", index, err)
-				failCount++
-			} else {
-				log.Printf("秒杀成功[%d]: %s", index, ctx.TransactionID)
-				successCount++
+				if err := manager.ExecuteSeckill(ctx); err != nil {
+					log.Printf("秒杀失败[%d]: %v", index, err)
+				} else {
+					log.Printf("秒杀成功[%d]: %s", index, ctx.TransactionID)
+				}
 			}
 		}(i)
 	}
 
 	wg.Wait()
-	duration := time.Since(startTime)
+	stats := manager.Stats.Snapshot()
 
 	log.Printf("高并发秒杀测试完成:")
-	log.Printf("- 总并发数: %d", concurrency)
-	log.Printf("- 成功数: %d", successCount)
-	log.Printf("- 失败数: %d", failCount)
-	log.Printf("- 成功率: %.2f%%", float64(successCount)/float64(concurrency)*100)
-	log.Printf("- 总耗时: %v", duration)
-	log.Printf("- 平均TPS: %.2f", float64(concurrency)/duration.Seconds())
+	log.Printf("- 总并发数: %d", cfg.Concurrency)
+	log.Printf("- 总请求数: %d", stats.Total)
+	log.Printf("- 成功数: %d", stats.Success)
+	log.Printf("- 失败数: %d", stats.Failed)
+	log.Printf("- 成功率: %.2f%%", stats.SuccessRate()*100)
+	log.Printf("- 总耗时: %v", stats.Elapsed)
+	log.Printf("- 平均TPS: %.2f", stats.TPS)
+	return stats
 }
 
 // 主函数
 func main() {
+	// 压测参数，默认值与原先写死的一致
+	cfg := defaultTestConfig
+	flag.IntVar(&cfg.Concurrency, "concurrency", cfg.Concurrency, "number of concurrent seckill goroutines")
+	flag.Int64Var(&cfg.ProductID, "product", cfg.ProductID, "product id to seckill")
+	flag.IntVar(&cfg.Iterations, "iterations", cfg.Iterations, "seckill attempts per goroutine")
+	flag.IntVar(&cfg.UserPool, "users", cfg.UserPool, "number of test users (ids from 10001)")
+	listen := flag.String("listen", "", "serve POST /seckill on this address (e.g. :8090) instead of running the built-in load test")
+	logFormat := flag.String("log-format", "text", "per-phase log format: text or json")
+	flag.Parse()
+	if cfg.Concurrency <= 0 || cfg.Iterations <= 0 || cfg.UserPool <= 0 {
+		log.Fatal("concurrency、iterations、users 必须大于 0")
+	}
+
 	// 连接数据库
 	db, err := sql.Open("mysql", "root:password@tcp(localhost:3306)/seckill_db?charset=utf8mb4&parseTime=True&loc=Local")
 	if err != nil {
@@ -1172,8 +1409,12 @@ func main() {
 		log.Fatal("初始化测试数据失败:", err)
 	}
 
-	// 创建TCC管理器
+	// 创建TCC管理器，退出前关闭预编译语句（需在 db.Close 之前）
 	manager := NewSeckillDirectTCCManager(db)
+	if *logFormat == "json" {
+		manager.Logger = NewJSONLogger(os.Stdout)
+	}
+	defer manager.Shutdown(context.Background())
 
 	// 系统启动时执行恢复机制
 	log.Println("\n=== 系统启动恢复机制 ===")
@@ -1186,7 +1427,7 @@ func main() {
 	singleCtx := &SeckillDirectTCCContext{
 		TransactionID: fmt.Sprintf("single_test_%d", time.Now().UnixNano()),
 		UserID:        10001,
-		ProductID:     1001,
+		ProductID:     cfg.ProductID,
 		Quantity:      1,
 		Price:         8999.00,
 	}
@@ -1197,9 +1438,20 @@ func main() {
 		log.Printf("单个秒杀测试成功: %s", singleCtx.TransactionID)
 	}
 
+	// 指定 -listen 时改为提供 HTTP 接口，由外部压测工具驱动
+	if *listen != "" {
+		mux := http.NewServeMux()
+		api := NewSeckillAPI(manager, 8999.00)
+		api.Stream = NewResultHub()
+		manager.OnResult = api.Stream.Publish
+		api.Register(mux)
+		log.Printf("秒杀接口监听 %s: POST /seckill, GET /seckill/stream", *listen)
+		log.Fatal(http.ListenAndServe(*listen, mux))
+	}
+
 	// 高并发秒杀测试
 	log.Println("\n=== 高并发秒杀测试 ===")
-	runConcurrentSeckillTest(manager, 50) // 50个并发
+	runConcurrentSeckillTest(manager, cfg)
 
 	log.Println("\n秒杀TCC测试完成")
 }
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// nopDB 接受所有写操作、查询均返回空结果的测试驱动，
+// 只用来满足管理器记录事务日志和资源状态的 SQL
+type nopDB struct{}
+
+func (nopDB) Connect(context.Context) (driver.Conn, error) { return nopConn{}, nil }
+func (d nopDB) Driver() driver.Driver                      { return d }
+func (nopDB) Open(string) (driver.Conn, error)             { return nopConn{}, nil }
+
+type nopConn struct{}
+
+func (nopConn) Prepare(string) (driver.Stmt, error) { return nopStmt{}, nil }
+func (nopConn) Close() error                        { return nil }
+func (nopConn) Begin() (driver.Tx, error)           { return nopConn{}, nil }
+func (nopConn) Commit() error                       { return nil }
+func (nopConn) Rollback() error                     { return nil }
+
+type nopStmt struct{}
+
+func (nopStmt) Close() error                               { return nil }
+func (nopStmt) NumInput() int                              { return -1 }
+func (nopStmt) Exec([]driver.Value) (driver.Result, error) { return driver.RowsAffected(1), nil }
+func (nopStmt) Query([]driver.Value) (driver.Rows, error)  { return nopRows{}, nil }
+
+type nopRows struct{}
+
+func (nopRows) Columns() []string         { return []string{"status"} }
+func (nopRows) Close() error              { return nil }
+func (nopRows) Next([]driver.Value) error { return io.EOF }
+
+// stubResource 偶数用户 Try 失败
+type stubResource struct{}
+
+func (stubResource) Try(ctx *SeckillDirectTCCContext) error {
+	if ctx.UserID%2 == 0 {
+		return errors.New("库存不足")
+	}
+	return nil
+}
+func (stubResource) Confirm(*SeckillDirectTCCContext) error { return nil }
+func (stubResource) Cancel(*SeckillDirectTCCContext) error  { return nil }
+
+func TestStatsConcurrentSeckill(t *testing.T) {
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(out)
+
+	db := sql.OpenDB(nopDB{})
+	manager := &SeckillDirectTCCManager{
+		resources: []DirectTCCResource{stubResource{}, stubResource{}},
+		db:        db,
+		stmts:     newStmtCache(db),
+	}
+	manager.Stats.Reset()
+
+	const n = 100
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			manager.ExecuteSeckill(&SeckillDirectTCCContext{
+				TransactionID: fmt.Sprintf("tx_%d", i),
+				UserID:        int64(i),
+				ProductID:     1001,
+				Quantity:      1,
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	s := manager.Stats.Snapshot()
+	if s.Total != n || s.Success+s.Failed != s.Total {
+		t.Fatalf("snapshot = %+v, want total %d = success + failed", s, n)
+	}
+	if s.Success != n/2 || s.Failed != n/2 {
+		t.Fatalf("success/failed = %d/%d, want %d/%d", s.Success, s.Failed, n/2, n/2)
+	}
+	if s.TPS <= 0 {
+		t.Fatalf("TPS = %v, want > 0", s.TPS)
+	}
+	if rate := s.SuccessRate(); rate != 0.5 {
+		t.Fatalf("SuccessRate = %v, want 0.5", rate)
+	}
+
+	manager.Stats.Reset()
+	if s := manager.Stats.Snapshot(); s.Total != 0 || s.Success != 0 || s.Failed != 0 {
+		t.Fatalf("after Reset snapshot = %+v, want zero counters", s)
+	}
+}
+
+func TestStatsSkipsInProgressReplays(t *testing.T) {
+	var s Stats
+	s.record(ErrTxInProgress)
+	s.record(fmt.Errorf("replay: %w", ErrTxInProgress))
+	s.record(nil)
+	s.record(ErrSoldOut)
+	if snap := s.Snapshot(); snap.Total != 2 || snap.Success != 1 || snap.Failed != 1 {
+		t.Fatalf("snapshot = %+v, want total 2, success 1, failed 1", snap)
+	}
+}
+
+func TestRunConcurrentSeckillTestConfig(t *testing.T) {
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(out)
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mock.MatchExpectationsInOrder(false)
+	// 连接被占用时 database/sql 会在新连接上重新 Prepare，限制为一个连接才能保证每条 SQL 只 Prepare 一次
+	db.SetMaxOpenConns(1)
+
+	cfg := TestConfig{Concurrency: 3, ProductID: 2002, Iterations: 2, UserPool: 1}
+	n := cfg.Concurrency * cfg.Iterations
+
+	// 每条 SQL 只预编译一次；每笔事务：查重 1 次，Try/Confirm 资源状态各 1 次，事务日志 TRIED/CONFIRMED 2 次
+	status := mock.ExpectPrepare("SELECT status FROM tcc_transaction_log")
+	try := mock.ExpectPrepare("phase, status.*'try'")
+	confirm := mock.ExpectPrepare("phase, status.*'confirm'")
+	txLog := mock.ExpectPrepare("INSERT INTO tcc_transaction_log")
+	for i := 0; i < n; i++ {
+		status.ExpectQuery().WillReturnRows(sqlmock.NewRows([]string{"status"}))
+		try.ExpectExec().WillReturnResult(sqlmock.NewResult(0, 1))
+		confirm.ExpectExec().WillReturnResult(sqlmock.NewResult(0, 1))
+		txLog.ExpectExec().WithArgs(sqlmock.AnyArg(), TCCStatusTried, TCCStatusTried).WillReturnResult(sqlmock.NewResult(0, 1))
+		txLog.ExpectExec().WithArgs(sqlmock.AnyArg(), TCCStatusConfirmed, TCCStatusConfirmed).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+
+	// UserPool 为 1 时只用奇数用户 10001，stubResource 全部成功
+	manager := &SeckillDirectTCCManager{
+		resources: []DirectTCCResource{stubResource{}},
+		db:        db,
+		stmts:     newStmtCache(db),
+	}
+	s := runConcurrentSeckillTest(manager, cfg)
+	if s.Total != int64(n) || s.Success != int64(n) {
+		t.Fatalf("snapshot = %+v, want %d successful seckills", s, n)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// slowResource Try 阶段阻塞到 release 关闭，用来模拟进行中的事务
+type slowResource struct {
+	entered, release chan struct{}
+}
+
+func (r *slowResource) Try(*SeckillDirectTCCContext) error {
+	close(r.entered)
+	<-r.release
+	return nil
+}
+func (r *slowResource) Confirm(*SeckillDirectTCCContext) error { return nil }
+func (r *slowResource) Cancel(*SeckillDirectTCCContext) error  { return nil }
+
+func TestShutdownDrainsInflight(t *testing.T) {
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(out)
+
+	db := sql.OpenDB(nopDB{})
+	defer db.Close()
+	slow := &slowResource{entered: make(chan struct{}), release: make(chan struct{})}
+	manager := &SeckillDirectTCCManager{resources: []DirectTCCResource{slow}, db: db, stmts: newStmtCache(db)}
+
+	txDone := make(chan error, 1)
+	go func() {
+		txDone <- manager.ExecuteSeckill(&SeckillDirectTCCContext{TransactionID: "tx_slow", ProductID: 1001, Quantity: 1})
+	}()
+	<-slow.entered
+
+	shutdownDone := make(chan error, 1)
+	go func() { shutdownDone <- manager.Shutdown(context.Background()) }()
+
+	// Shutdown 开始后新事务被拒绝，且不会在进行中的事务结束前返回
+	for closing := false; !closing; runtime.Gosched() {
+		manager.mu.RLock()
+		closing = manager.closing
+		manager.mu.RUnlock()
+	}
+	err := manager.ExecuteSeckill(&SeckillDirectTCCContext{TransactionID: "tx_new", ProductID: 1001, Quantity: 1})
+	if !errors.Is(err, ErrShuttingDown) {
+		t.Fatalf("ExecuteSeckill during Shutdown = %v, want ErrShuttingDown", err)
+	}
+	select {
+	case err := <-shutdownDone:
+		t.Fatalf("Shutdown returned before in-flight transaction finished: %v", err)
+	default:
+	}
+
+	close(slow.release)
+	if err := <-shutdownDone; err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	select {
+	case err := <-txDone:
+		if err != nil {
+			t.Fatalf("in-flight transaction: %v", err)
+		}
+	default:
+		t.Fatal("Shutdown returned before in-flight transaction finished")
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(out)
+
+	db := sql.OpenDB(nopDB{})
+	defer db.Close()
+	slow := &slowResource{entered: make(chan struct{}), release: make(chan struct{})}
+	defer close(slow.release)
+	manager := &SeckillDirectTCCManager{resources: []DirectTCCResource{slow}, db: db, stmts: newStmtCache(db)}
+
+	go manager.ExecuteSeckill(&SeckillDirectTCCContext{TransactionID: "tx_stuck", ProductID: 1001, Quantity: 1})
+	<-slow.entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := manager.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown = %v, want DeadlineExceeded", err)
+	}
+	// 仍在执行的事务还要用预编译语句完成 Confirm/Cancel，超时返回时不能关闭
+	manager.stmts.mu.Lock()
+	closed := manager.stmts.closed
+	manager.stmts.mu.Unlock()
+	if closed {
+		t.Fatal("Shutdown closed the statement cache while a transaction was still in flight")
+	}
+}
+
+func TestShutdownClosesResourceStmtCaches(t *testing.T) {
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(out)
+
+	db := sql.OpenDB(nopDB{})
+	defer db.Close()
+	inv := NewDirectInventoryResource(db)
+	manager := &SeckillDirectTCCManager{resources: []DirectTCCResource{inv}, db: db, stmts: newStmtCache(db)}
+	manager.Shutdown(context.Background())
+
+	for name, c := range map[string]*stmtCache{"manager": manager.stmts, "inventory": inv.stmts} {
+		c.mu.Lock()
+		closed := c.closed
+		c.mu.Unlock()
+		if !closed {
+			t.Errorf("%s statement cache not closed by Shutdown", name)
+		}
+	}
+}
+
+// isolationDB 在 nopDB 基础上记录每次 BeginTx 收到的隔离级别
+type isolationDB struct {
+	mu     sync.Mutex
+	levels []sql.IsolationLevel
+}
+
+func (d *isolationDB) Connect(context.Context) (driver.Conn, error) { return isolationConn{db: d}, nil }
+func (d *isolationDB) Driver() driver.Driver                        { return d }
+func (d *isolationDB) Open(string) (driver.Conn, error)             { return isolationConn{db: d}, nil }
+
+type isolationConn struct {
+	db *isolationDB
+	nopConn
+}
+
+func (c isolationConn) BeginTx(_ context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.levels = append(c.db.levels, sql.IsolationLevel(opts.Isolation))
+	return nopConn{}, nil
+}
+
+func TestSetIsolationLevel(t *testing.T) {
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(out)
+
+	rec := &isolationDB{}
+	db := sql.OpenDB(rec)
+	defer db.Close()
+	manager := NewSeckillDirectTCCManager(db)
+	manager.SetIsolationLevel(sql.LevelReadCommitted)
+
+	// nopDB 的查询没有结果，Try 会失败并进入 Cancel，Try/Cancel 开启的事务都应使用设置的级别
+	manager.ExecuteSeckill(&SeckillDirectTCCContext{TransactionID: "tx_iso", UserID: 1, ProductID: 1001, Quantity: 1, Price: 100})
+	if len(rec.levels) == 0 {
+		t.Fatal("no transaction started")
+	}
+	for i, level := range rec.levels {
+		if level != sql.LevelReadCommitted {
+			t.Fatalf("BeginTx #%d isolation = %v, want READ COMMITTED", i+1, level)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// Stats 秒杀事务统计，全部字段都是原子操作，可被大量 goroutine 并发更新而不互相等待
+type Stats struct {
+	total   atomic.Int64
+	success atomic.Int64
+	failed  atomic.Int64
+
+	since atomic.Pointer[time.Time] // 统计起始时间，第一笔事务或 Reset 时设置
+}
+
+// StatsSnapshot 某一时刻的统计快照
+type StatsSnapshot struct {
+	Total   int64
+	Success int64
+	Failed  int64
+	Elapsed time.Duration // 自统计开始经过的时间
+	TPS     float64       // 每秒完成的事务数（成功+失败）
+}
+
+// SuccessRate 成功率（0~1），没有事务时为 0
+func (s StatsSnapshot) SuccessRate() float64 {
+	if s.Total == 0 {
+		return 0
+	}
+	return float64(s.Success) / float64(s.Total)
+}
+
+// record 记录一笔事务的结果。ErrTxInProgress 是对执行中事务的重放，
+// 该事务结束时会自己计数，这里不计入
+func (s *Stats) record(err error) {
+	if errors.Is(err, ErrTxInProgress) {
+		return
+	}
+	if s.since.Load() == nil {
+		now := time.Now()
+		s.since.CompareAndSwap(nil, &now)
+	}
+
+	s.total.Add(1)
+	if err != nil {
+		s.failed.Add(1)
+	} else {
+		s.success.Add(1)
+	}
+}
+
+// Reset 清零计数并从现在开始重新统计，与并发的 record 之间不保证原子性
+func (s *Stats) Reset() {
+	now := time.Now()
+	s.since.Store(&now)
+	s.total.Store(0)
+	s.success.Store(0)
+	s.failed.Store(0)
+}
+
+// Snapshot 返回当前统计快照
+func (s *Stats) Snapshot() StatsSnapshot {
+	snap := StatsSnapshot{
+		Total:   s.total.Load(),
+		Success: s.success.Load(),
+		Failed:  s.failed.Load(),
+	}
+	if since := s.since.Load(); since != nil {
+		snap.Elapsed = time.Since(*since)
+	}
+	if snap.Elapsed > 0 {
+		snap.TPS = float64(snap.Total) / snap.Elapsed.Seconds()
+	}
+	return snap
+}
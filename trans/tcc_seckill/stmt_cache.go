@@ -0,0 +1,145 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"sync"
+)
+
+// stmtCache 按 SQL 文本缓存预编译语句，每条 SQL 只 Prepare 一次。
+// 不使用预编译时 go-sql-driver/mysql 对带参数的 SQL 每次都要
+// prepare/execute/close 三次往返，缓存后只剩一次 execute；
+// 事务内通过 tx.Stmt 复用，同一连接上不会重复 Prepare。
+// 关闭后所有调用退化为不预编译的直接执行。
+type stmtCache struct {
+	db      *sql.DB
+	mu      sync.Mutex
+	stmts   map[string]*sql.Stmt
+	warming map[string]bool
+	closed  bool
+	wg      sync.WaitGroup // 后台预编译
+}
+
+func newStmtCache(db *sql.DB) *stmtCache {
+	return &stmtCache{db: db, stmts: make(map[string]*sql.Stmt), warming: make(map[string]bool)}
+}
+
+// stmt 返回 query 对应的预编译语句，缓存已关闭或 Prepare 失败时返回 nil。
+// Prepare 在锁外执行，未命中时不会让其他 SQL 的调用者一起等待网络往返；
+// 并发未命中同一条 SQL 时先存入的胜出，其余的关闭自己的语句改用胜出者的
+func (c *stmtCache) stmt(query string) *sql.Stmt {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	if s, ok := c.stmts[query]; ok {
+		c.mu.Unlock()
+		return s
+	}
+	c.mu.Unlock()
+
+	s, err := c.db.Prepare(query)
+	if err != nil {
+		// Prepare 失败时交给直接执行路径返回同样的错误
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		s.Close()
+		return nil
+	}
+	if existing, ok := c.stmts[query]; ok {
+		s.Close()
+		return existing
+	}
+	c.stmts[query] = s
+	return s
+}
+
+// cached 只查缓存，未命中时在后台预编译。
+// 事务内不能同步调用 db.Prepare：它需要另取一个连接，
+// 连接池被事务占满时会与持有连接的事务互相等待。
+func (c *stmtCache) cached(query string) *sql.Stmt {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	if s, ok := c.stmts[query]; ok {
+		return s
+	}
+	if !c.warming[query] {
+		c.warming[query] = true
+		c.wg.Add(1)
+		go c.warm(query)
+	}
+	return nil
+}
+
+func (c *stmtCache) warm(query string) {
+	defer c.wg.Done()
+	s, err := c.db.Prepare(query)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.warming, query)
+	if err != nil {
+		return
+	}
+	if c.closed {
+		s.Close()
+		return
+	}
+	c.stmts[query] = s
+}
+
+func (c *stmtCache) Exec(query string, args ...interface{}) (sql.Result, error) {
+	if s := c.stmt(query); s != nil {
+		return s.Exec(args...)
+	}
+	return c.db.Exec(query, args...)
+}
+
+func (c *stmtCache) QueryRow(query string, args ...interface{}) *sql.Row {
+	if s := c.stmt(query); s != nil {
+		return s.QueryRow(args...)
+	}
+	return c.db.QueryRow(query, args...)
+}
+
+func (c *stmtCache) TxExec(tx *sql.Tx, query string, args ...interface{}) (sql.Result, error) {
+	if s := c.cached(query); s != nil {
+		return tx.Stmt(s).Exec(args...)
+	}
+	return tx.Exec(query, args...)
+}
+
+func (c *stmtCache) TxQueryRow(tx *sql.Tx, query string, args ...interface{}) *sql.Row {
+	if s := c.cached(query); s != nil {
+		return tx.Stmt(s).QueryRow(args...)
+	}
+	return tx.QueryRow(query, args...)
+}
+
+// Len 已缓存的语句数
+func (c *stmtCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.stmts)
+}
+
+// Close 关闭所有缓存的语句，之后完成的后台预编译会被立即关闭
+func (c *stmtCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	var errs []error
+	for query, s := range c.stmts {
+		errs = append(errs, s.Close())
+		delete(c.stmts, query)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingDB 统计 Prepare/Close 次数的测试驱动，只应答库存资源 Try/Confirm 用到的查询
+type countingDB struct {
+	prepares, closes atomic.Int64
+	latency          time.Duration // 每次 Prepare/Exec/Query 模拟的网络往返
+}
+
+func (d *countingDB) Connect(context.Context) (driver.Conn, error) { return countingConn{d}, nil }
+func (d *countingDB) Driver() driver.Driver                        { return d }
+func (d *countingDB) Open(string) (driver.Conn, error)             { return countingConn{d}, nil }
+
+type countingConn struct{ db *countingDB }
+
+func (c countingConn) Prepare(query string) (driver.Stmt, error) {
+	c.db.prepares.Add(1)
+	time.Sleep(c.db.latency)
+	return countingStmt{c.db, query}, nil
+}
+func (c countingConn) Close() error              { return nil }
+func (c countingConn) Begin() (driver.Tx, error) { return c, nil }
+func (c countingConn) Commit() error             { return nil }
+func (c countingConn) Rollback() error           { return nil }
+
+type countingStmt struct {
+	db    *countingDB
+	query string
+}
+
+func (s countingStmt) Close() error  { s.db.closes.Add(1); return nil }
+func (s countingStmt) NumInput() int { return -1 }
+func (s countingStmt) Exec([]driver.Value) (driver.Result, error) {
+	time.Sleep(s.db.latency)
+	return driver.RowsAffected(1), nil
+}
+
+func (s countingStmt) Query([]driver.Value) (driver.Rows, error) {
+	time.Sleep(s.db.latency)
+	switch {
+	case strings.Contains(s.query, "SELECT COUNT(*)"):
+		return &valueRows{v: int64(0)}, nil
+	case strings.Contains(s.query, "SELECT operation_type"):
+		return &valueRows{v: "TRY_DEDUCT"}, nil
+	}
+	return &valueRows{}, nil
+}
+
+// valueRows 返回单行单列 v，v 为 nil 时没有数据
+type valueRows struct{ v driver.Value }
+
+func (r *valueRows) Columns() []string { return []string{"v"} }
+func (r *valueRows) Close() error      { return nil }
+
+func (r *valueRows) Next(dest []driver.Value) error {
+	if r.v == nil {
+		return io.EOF
+	}
+	dest[0], r.v = r.v, nil
+	return nil
+}
+
+func TestStmtCacheReusesPreparedStatements(t *testing.T) {
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(out)
+
+	counter := &countingDB{}
+	db := sql.OpenDB(counter)
+	defer db.Close()
+	db.SetMaxOpenConns(1) // 单连接，每条 SQL 只会在这一个连接上 Prepare
+
+	stmts := newStmtCache(db)
+	manager := &SeckillDirectTCCManager{
+		resources: []DirectTCCResource{&DirectInventoryResource{db: db, stmts: stmts}},
+		db:        db,
+		stmts:     stmts,
+	}
+
+	run := func(prefix string, n int) {
+		for i := 0; i < n; i++ {
+			ctx := &SeckillDirectTCCContext{TransactionID: fmt.Sprintf("%s_%d", prefix, i), ProductID: 1001, Quantity: 1}
+			if err := manager.ExecuteSeckill(ctx); err != nil {
+				t.Fatalf("ExecuteSeckill %s #%d: %v", prefix, i, err)
+			}
+		}
+	}
+
+	// 预热：第一笔事务内的语句直接执行并在后台预编译
+	run("warm", 1)
+	stmts.wg.Wait()
+	cached := stmts.Len()
+	if cached == 0 {
+		t.Fatal("no statements cached")
+	}
+
+	const n = 20
+	before := counter.prepares.Load()
+	run("tx", n)
+	if got := counter.prepares.Load() - before; got != 0 {
+		t.Fatalf("%d new prepares during %d warmed-up transactions, want 0", got, n)
+	}
+	if stmts.Len() != cached {
+		t.Fatalf("cache grew from %d to %d statements", cached, stmts.Len())
+	}
+
+	closesBefore := counter.closes.Load()
+
+	if err := stmts.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if got := counter.closes.Load() - closesBefore; got != int64(cached) {
+		t.Fatalf("closed %d statements on Close, want %d", got, cached)
+	}
+	if stmts.Len() != 0 {
+		t.Fatalf("%d statements still cached after Close", stmts.Len())
+	}
+
+	// 关闭后退化为直接执行，仍然可用
+	ctx := &SeckillDirectTCCContext{TransactionID: "tx_after_close", ProductID: 1001, Quantity: 1}
+	if err := manager.ExecuteSeckill(ctx); err != nil {
+		t.Fatalf("ExecuteSeckill after Close: %v", err)
+	}
+}
+
+func TestStmtCachePreparesOutsideLock(t *testing.T) {
+	counter := &countingDB{latency: 100 * time.Millisecond}
+	db := sql.OpenDB(counter)
+	defer db.Close()
+	db.SetMaxIdleConns(16) // 多余的空闲连接被关闭时会连带关闭其上的语句，干扰 closes 计数
+	stmts := newStmtCache(db)
+	defer stmts.Close()
+
+	hot := stmts.stmt("SELECT hot")
+	// 8 个调用者同时未命中同一条 SQL，各自 Prepare，只保留先存入的一个
+	const n = 8
+	got := make([]*sql.Stmt, n)
+	var wg sync.WaitGroup
+	for i := range got {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			got[i] = stmts.stmt("SELECT cold")
+		}(i)
+	}
+	time.Sleep(10 * time.Millisecond)
+	start := time.Now()
+	if s := stmts.stmt("SELECT hot"); s != hot {
+		t.Fatal("cached statement replaced")
+	}
+	if d := time.Since(start); d > counter.latency/2 {
+		t.Fatalf("cache hit waited %v behind another query's Prepare", d)
+	}
+	wg.Wait()
+
+	for i, s := range got {
+		if s == nil || s != got[0] {
+			t.Fatalf("caller %d got a different statement", i)
+		}
+	}
+	if stmts.Len() != 2 {
+		t.Fatalf("cached %d statements, want 2", stmts.Len())
+	}
+	if prepares, closes := counter.prepares.Load(), counter.closes.Load(); closes != prepares-2 {
+		t.Fatalf("prepares = %d, closes = %d; losing statements must be closed", prepares, closes)
+	}
+}
+
+// benchmarkExecuteSeckill 以 50 并发执行秒杀事务并报告每笔事务的 Prepare 次数。
+// 测试驱动每次往返固定 50µs，ns/op 只能说明趋势；对 MySQL 而言
+// 每次 Prepare 还意味着一次额外的 close 往返，prepares/op 才是关键指标。
+// 参考结果（单核，50 并发）：不缓存 10 prepares/op、46µs/op，
+// 缓存后约 0.24 prepares/op（每个连接每条语句只 Prepare 一次）、38µs/op。
+func benchmarkExecuteSeckill(b *testing.B, cached bool) {
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(out)
+
+	counter := &countingDB{latency: 50 * time.Microsecond}
+	db := sql.OpenDB(counter)
+	defer db.Close()
+	db.SetMaxOpenConns(100)
+	db.SetMaxIdleConns(100)
+
+	manager := NewSeckillDirectTCCManager(db)
+	manager.resources = manager.resources[:1] // 测试驱动只应答库存资源的查询
+	if !cached {
+		manager.stmts.Close()
+	}
+
+	var seq atomic.Int64
+	b.SetParallelism(50)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			ctx := &SeckillDirectTCCContext{TransactionID: fmt.Sprintf("tx_%d", seq.Add(1)), ProductID: 1001, Quantity: 1}
+			if err := manager.ExecuteSeckill(ctx); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+	b.StopTimer()
+	b.ReportMetric(float64(counter.prepares.Load())/float64(b.N), "prepares/op")
+	manager.Shutdown(context.Background())
+}
+
+func BenchmarkExecuteSeckillUncached(b *testing.B) { benchmarkExecuteSeckill(b, false) }
+func BenchmarkExecuteSeckillCached(b *testing.B)   { benchmarkExecuteSeckill(b, true) }
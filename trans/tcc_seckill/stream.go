@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+// SeckillOutcome 一笔秒杀事务的结果
+type SeckillOutcome string
+
+const (
+	OutcomeSuccess SeckillOutcome = "success"
+	OutcomeSoldOut SeckillOutcome = "sold_out"
+	OutcomeFail    SeckillOutcome = "fail"
+)
+
+// SeckillEvent ExecuteSeckill 结束时发布的结果事件，/seckill/stream 以 JSON 文本帧推送
+type SeckillEvent struct {
+	TransactionID string         `json:"transactionId"`
+	UserID        int64          `json:"userId"`
+	ProductID     int64          `json:"productId"`
+	Quantity      int            `json:"quantity"`
+	Outcome       SeckillOutcome `json:"outcome"`
+	Error         string         `json:"error,omitempty"`
+	Time          time.Time      `json:"time"`
+}
+
+func newSeckillEvent(ctx *SeckillDirectTCCContext, err error) SeckillEvent {
+	ev := SeckillEvent{
+		TransactionID: ctx.TransactionID,
+		UserID:        ctx.UserID,
+		ProductID:     ctx.ProductID,
+		Quantity:      ctx.Quantity,
+		Outcome:       OutcomeSuccess,
+		Time:          time.Now(),
+	}
+	if err != nil {
+		ev.Outcome = OutcomeFail
+		if errors.Is(err, ErrSoldOut) {
+			ev.Outcome = OutcomeSoldOut
+		}
+		ev.Error = err.Error()
+	}
+	return ev
+}
+
+// streamQueueSize 每个订阅者发送队列的默认长度
+const streamQueueSize = 64
+
+// streamCloseTimeout 关闭订阅连接时写 close 帧的最长时间
+const streamCloseTimeout = time.Second
+
+// ResultHub 把秒杀结果广播给 WebSocket 订阅者，与 websocket/server 的 Hub 做法相同：
+// Publish 只把消息放入每个订阅者自己的发送队列，由订阅者的写协程发出，
+// 队列满的慢订阅者以 1011 断开，不会拖慢秒杀事务本身。
+// 那个 Hub 在 test 模块的 main 包里，trans 模块无法引用，因此这里单独实现
+type ResultHub struct {
+	// QueueSize 每个订阅者的发送队列长度，0 表示 streamQueueSize；需在接受订阅前设置
+	QueueSize int
+
+	mu   sync.RWMutex
+	subs map[*subscriber]struct{}
+}
+
+type subscriber struct {
+	conn    net.Conn
+	wmu     sync.Mutex // 读协程的 pong/close 回复与写协程的推送并发写同一连接
+	sendq   chan []byte
+	evicted bool // 由 ResultHub.mu 保护
+}
+
+func NewResultHub() *ResultHub {
+	return &ResultHub{subs: make(map[*subscriber]struct{})}
+}
+
+// Publish 非阻塞地向所有订阅者推送事件，可直接赋给 SeckillDirectTCCManager.OnResult
+func (h *ResultHub) Publish(ev SeckillEvent) {
+	msg, err := json.Marshal(ev)
+	if err != nil {
+		log.Printf("[秒杀推送] 编码事件失败: %v", err)
+		return
+	}
+	var slow []*subscriber
+	h.mu.RLock()
+	for sub := range h.subs {
+		select {
+		case sub.sendq <- msg:
+		default:
+			slow = append(slow, sub)
+		}
+	}
+	h.mu.RUnlock()
+	for _, sub := range slow {
+		h.evict(sub)
+	}
+}
+
+// Subscribers 当前订阅者数量
+func (h *ResultHub) Subscribers() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subs)
+}
+
+func (h *ResultHub) register(conn net.Conn) *subscriber {
+	size := h.QueueSize
+	if size <= 0 {
+		size = streamQueueSize
+	}
+	sub := &subscriber{conn: conn, sendq: make(chan []byte, size)}
+	h.mu.Lock()
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+	return sub
+}
+
+// unregister 移除订阅者并关闭其发送队列，重复调用无副作用
+func (h *ResultHub) unregister(sub *subscriber) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[sub]; !ok {
+		return false
+	}
+	delete(h.subs, sub)
+	close(sub.sendq)
+	return true
+}
+
+// evict 移除慢订阅者；写协程发现 evicted 后以 1011 关闭连接
+func (h *ResultHub) evict(sub *subscriber) {
+	h.mu.Lock()
+	sub.evicted = true
+	h.mu.Unlock()
+	if h.unregister(sub) {
+		log.Printf("[秒杀推送] 订阅者 %s 发送队列已满，断开连接", sub.conn.RemoteAddr())
+	}
+}
+
+// ServeHTTP 处理 GET /seckill/stream：升级为 WebSocket 后持续推送秒杀结果。
+// 订阅者只接收不发送，读协程只用来处理 ping/close 控制帧和发现断开
+func (h *ResultHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, _, _, err := ws.UpgradeHTTP(r, w)
+	if err != nil {
+		log.Printf("[秒杀推送] 升级 WebSocket 失败: %v", err)
+		return
+	}
+	sub := h.register(conn)
+
+	// 对端断开或发送 close 后移除订阅，写协程随队列关闭退出
+	go func() {
+		sub.readLoop()
+		h.unregister(sub)
+	}()
+
+	defer conn.Close()
+	for msg := range sub.sendq {
+		if err := sub.write(ws.OpText, msg); err != nil {
+			h.unregister(sub)
+			// 排空队列，unregister 关闭队列后循环结束
+			for range sub.sendq {
+			}
+			return
+		}
+	}
+	h.mu.RLock()
+	evicted := sub.evicted
+	h.mu.RUnlock()
+	if evicted {
+		conn.SetWriteDeadline(time.Now().Add(streamCloseTimeout))
+		sub.write(ws.OpClose, ws.NewCloseFrameBody(ws.StatusInternalServerError, "slow consumer"))
+	}
+}
+
+// write 在写锁内发出一帧
+func (sub *subscriber) write(op ws.OpCode, p []byte) error {
+	sub.wmu.Lock()
+	defer sub.wmu.Unlock()
+	return wsutil.WriteServerMessage(sub.conn, op, p)
+}
+
+// readLoop 丢弃客户端数据帧，直到连接出错或收到 close。
+// 控制帧的回复要和推送共用写锁，所以不用 wsutil.ReadClientData 自带的处理器
+func (sub *subscriber) readLoop() error {
+	reply := wsutil.ControlFrameHandler(sub.conn, ws.StateServerSide)
+	control := func(hdr ws.Header, r io.Reader) error {
+		sub.wmu.Lock()
+		defer sub.wmu.Unlock()
+		return reply(hdr, r)
+	}
+	rd := &wsutil.Reader{
+		Source:         sub.conn,
+		State:          ws.StateServerSide,
+		OnIntermediate: control,
+	}
+	for {
+		hdr, err := rd.NextFrame()
+		if err != nil {
+			return err
+		}
+		if hdr.OpCode.IsControl() {
+			if err := control(hdr, rd); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := io.Copy(io.Discard, rd); err != nil {
+			return err
+		}
+	}
+}
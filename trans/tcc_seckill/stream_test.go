@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); !cond(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within 2s")
+		}
+	}
+}
+
+func TestSeckillStreamPushesOutcome(t *testing.T) {
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(out)
+
+	db := sql.OpenDB(nopDB{})
+	manager := &SeckillDirectTCCManager{resources: []DirectTCCResource{&countingResource{tryErr: ErrSoldOut}}, db: db, stmts: newStmtCache(db)}
+	api := NewSeckillAPI(manager, 100)
+	api.Stream = NewResultHub()
+	manager.OnResult = api.Stream.Publish
+	mux := http.NewServeMux()
+	api.Register(mux)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	conn, _, _, err := ws.Dial(context.Background(), "ws"+strings.TrimPrefix(srv.URL, "http")+"/seckill/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	waitFor(t, func() bool { return api.Stream.Subscribers() == 1 })
+
+	ctx := &SeckillDirectTCCContext{TransactionID: "tx_stream", UserID: 10001, ProductID: 1001, Quantity: 1, Price: 100}
+	if err := manager.ExecuteSeckill(ctx); err == nil {
+		t.Fatal("ExecuteSeckill succeeded, want sold out")
+	}
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	msg, err := wsutil.ReadServerText(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ev SeckillEvent
+	if err := json.Unmarshal(msg, &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.TransactionID != ctx.TransactionID || ev.UserID != ctx.UserID || ev.Outcome != OutcomeSoldOut {
+		t.Fatalf("event = %+v, want sold_out for %s", ev, ctx.TransactionID)
+	}
+
+	// 客户端关闭后订阅被移除，之后的发布不受影响
+	wsutil.WriteClientMessage(conn, ws.OpClose, ws.NewCloseFrameBody(ws.StatusNormalClosure, ""))
+	waitFor(t, func() bool { return api.Stream.Subscribers() == 0 })
+	api.Stream.Publish(SeckillEvent{TransactionID: "after_close"})
+}
+
+func TestSeckillStreamPongsDoNotInterleaveWithEvents(t *testing.T) {
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(out)
+
+	hub := NewResultHub()
+	hub.QueueSize = 1024
+	srv := httptest.NewServer(hub)
+	defer srv.Close()
+
+	conn, _, _, err := ws.Dial(context.Background(), "ws"+strings.TrimPrefix(srv.URL, "http"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	waitFor(t, func() bool { return hub.Subscribers() == 1 })
+
+	// 推送与 pong 回复同时写连接，帧不能交错
+	const n = 200
+	go func() {
+		for i := 0; i < n; i++ {
+			hub.Publish(SeckillEvent{TransactionID: "tx_interleave", Outcome: OutcomeSuccess})
+		}
+	}()
+	go func() {
+		for i := 0; i < n; i++ {
+			wsutil.WriteClientMessage(conn, ws.OpPing, []byte("ping-payload"))
+		}
+	}()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var events, pongs int
+	for events < n || pongs < n {
+		hdr, err := ws.ReadHeader(conn)
+		if err != nil {
+			t.Fatalf("after %d events, %d pongs: %v", events, pongs, err)
+		}
+		payload := make([]byte, hdr.Length)
+		if _, err := io.ReadFull(conn, payload); err != nil {
+			t.Fatal(err)
+		}
+		switch hdr.OpCode {
+		case ws.OpText:
+			var ev SeckillEvent
+			if err := json.Unmarshal(payload, &ev); err != nil || ev.TransactionID != "tx_interleave" {
+				t.Fatalf("corrupt event %q: %v", payload, err)
+			}
+			events++
+		case ws.OpPong:
+			if string(payload) != "ping-payload" {
+				t.Fatalf("corrupt pong %q", payload)
+			}
+			pongs++
+		default:
+			t.Fatalf("unexpected frame %v", hdr.OpCode)
+		}
+	}
+}
@@ -26,8 +26,10 @@ CREATE TABLE tcc_branch (
   max_retry TINYINT UNSIGNED DEFAULT 5 COMMENT '分支最大重试次数', # 分支级限制，允许不同资源自定义（如库存重试 3 次，订单 10 次）
   error_message VARCHAR(255) DEFAULT NULL COMMENT '最后失败原因', # 记录分支失败细节（如 "inventory insufficient"）
   create_time DATETIME(3) DEFAULT CURRENT_TIMESTAMP(3),
+  update_time DATETIME(3) DEFAULT CURRENT_TIMESTAMP(3) ON UPDATE CURRENT_TIMESTAMP(3),
   FOREIGN KEY(tx_id) REFERENCES tcc_transaction(tx_id),
-  KEY idx_tx_resource (tx_id, resource_type)
+  KEY idx_tx_resource (tx_id, resource_type),
+  KEY idx_status_update_time (status, update_time) -- FindStaleBranches 按状态和最后更新时间查找超时分支
 ) ENGINE=InnoDB;
 
 -- tcc_error_log 设计成能记录 全局级 和 分支级，区分方式是：
@@ -47,6 +49,18 @@ CREATE TABLE tcc_error_log (
 );
 
 
+-- tcc_event 只追加的事件日志，按 id 顺序即可重建每个事务完整的 Try/Confirm/Cancel 过程
+CREATE TABLE tcc_event (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  tx_id VARCHAR(64) NOT NULL,
+  phase ENUM('TRY','CONFIRM','CANCEL') NOT NULL,
+  resource VARCHAR(32) NOT NULL COMMENT '资源名',
+  result VARCHAR(255) NOT NULL COMMENT 'OK 或失败原因',
+  create_time DATETIME(3) DEFAULT CURRENT_TIMESTAMP(3),
+  KEY idx_tx_id (tx_id, id)
+) ENGINE=InnoDB;
+
+
 -- 业务表
 CREATE TABLE seckill_inventory (
   item_id BIGINT PRIMARY KEY COMMENT '商品ID',
@@ -3,14 +3,37 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"sort"
+	"sync"
+	"test/trans/internal/tccutil"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/google/uuid"
 )
 
+// ErrVersionConflict 乐观锁更新没有命中任何行：读取版本号之后该行已被并发修改。
+// 协调器回滚本次事务后在新事务中重新读取版本号重试，见 Coordinator.MaxVersionRetries
+var ErrVersionConflict = errors.New("version conflict")
+
+// checkVersion 检查带 version 条件的写操作是否生效，影响 0 行时返回 ErrVersionConflict
+func checkVersion(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
 type ResourceManager interface {
 	Try(ctx context.Context, tx *sql.Tx, args map[string]interface{}) error
 	Confirm(ctx context.Context, tx *sql.Tx, args map[string]interface{}) error
@@ -24,45 +47,33 @@ func (rm *InventoryRM) Try(ctx context.Context, tx *sql.Tx, args map[string]inte
 	quantity := args["quantity"].(int)
 	// 幂等: 检查version
 	var version int
-	err := tx.QueryRow("SELECT version FROM seckill_inventory WHERE item_id = ? FOR UPDATE", itemID).Scan(&version)
-	if err != nil {
-		return err
-	}
-	_, err = tx.Exec("UPDATE seckill_inventory SET frozen = frozen + ?, available = available - ?, version = version + 1 WHERE item_id = ? AND version = ?", quantity, quantity, itemID, version)
+	err := tccutil.QueryRowRetry(ctx, tx, "SELECT version FROM seckill_inventory WHERE item_id = ?", []interface{}{itemID}, &version)
 	if err != nil {
 		return err
 	}
-	return nil
+	return checkVersion(tccutil.ExecRetry(ctx, tx, "UPDATE seckill_inventory SET frozen = frozen + ?, available = available - ?, version = version + 1 WHERE item_id = ? AND version = ?", quantity, quantity, itemID, version))
 }
 
 func (rm *InventoryRM) Confirm(ctx context.Context, tx *sql.Tx, args map[string]interface{}) error {
 	itemID := args["item_id"].(int)
 	quantity := args["quantity"].(int)
 	var version int
-	err := tx.QueryRow("SELECT version FROM seckill_inventory WHERE item_id = ? FOR UPDATE", itemID).Scan(&version)
-	if err != nil {
-		return err
-	}
-	_, err = tx.Exec("UPDATE seckill_inventory SET frozen = frozen - ?, total = total - ?, version = version + 1 WHERE item_id = ? AND version = ?", quantity, quantity, itemID, version)
+	err := tx.QueryRow("SELECT version FROM seckill_inventory WHERE item_id = ?", itemID).Scan(&version)
 	if err != nil {
 		return err
 	}
-	return nil
+	return checkVersion(tx.Exec("UPDATE seckill_inventory SET frozen = frozen - ?, total = total - ?, version = version + 1 WHERE item_id = ? AND version = ?", quantity, quantity, itemID, version))
 }
 
 func (rm *InventoryRM) Cancel(ctx context.Context, tx *sql.Tx, args map[string]interface{}) error {
 	itemID := args["item_id"].(int)
 	quantity := args["quantity"].(int)
 	var version int
-	err := tx.QueryRow("SELECT version FROM seckill_inventory WHERE item_id = ? FOR UPDATE", itemID).Scan(&version)
+	err := tx.QueryRow("SELECT version FROM seckill_inventory WHERE item_id = ?", itemID).Scan(&version)
 	if err != nil {
 		return err
 	}
-	_, err = tx.Exec("UPDATE seckill_inventory SET frozen = frozen - ?, available = available + ?, version = version + 1 WHERE item_id = ? AND version = ?", quantity, quantity, itemID, version)
-	if err != nil {
-		return err
-	}
-	return nil
+	return checkVersion(tx.Exec("UPDATE seckill_inventory SET frozen = frozen - ?, available = available + ?, version = version + 1 WHERE item_id = ? AND version = ?", quantity, quantity, itemID, version))
 }
 
 // 类似地实现AccountRM和OrderRM（省略，逻辑类似，添加version幂等）
@@ -74,45 +85,33 @@ func (rm *AccountRM) Try(ctx context.Context, tx *sql.Tx, args map[string]interf
 	amount := args["amount"].(int)
 	// 幂等: 检查version
 	var version int
-	err := tx.QueryRow("SELECT version FROM account WHERE account_id = ? FOR UPDATE", accountID).Scan(&version)
-	if err != nil {
-		return err
-	}
-	_, err = tx.Exec("UPDATE account SET balance = balance - ?, version = version + 1 WHERE account_id = ? AND version = ?", amount, accountID, version)
+	err := tccutil.QueryRowRetry(ctx, tx, "SELECT version FROM account WHERE account_id = ?", []interface{}{accountID}, &version)
 	if err != nil {
 		return err
 	}
-	return nil
+	return checkVersion(tccutil.ExecRetry(ctx, tx, "UPDATE account SET balance = balance - ?, version = version + 1 WHERE account_id = ? AND version = ?", amount, accountID, version))
 }
 
 func (rm *AccountRM) Confirm(ctx context.Context, tx *sql.Tx, args map[string]interface{}) error {
 	accountID := args["account_id"].(int)
 	amount := args["amount"].(int)
 	var version int
-	err := tx.QueryRow("SELECT version FROM account WHERE account_id = ? FOR UPDATE", accountID).Scan(&version)
+	err := tx.QueryRow("SELECT version FROM account WHERE account_id = ?", accountID).Scan(&version)
 	if err != nil {
 		return err
 	}
-	_, err = tx.Exec("UPDATE account SET balance = balance + ?, version = version + 1 WHERE account_id = ? AND version = ?", amount, accountID, version)
-	if err != nil {
-		return err
-	}
-	return nil
+	return checkVersion(tx.Exec("UPDATE account SET balance = balance + ?, version = version + 1 WHERE account_id = ? AND version = ?", amount, accountID, version))
 }
 
 func (rm *AccountRM) Cancel(ctx context.Context, tx *sql.Tx, args map[string]interface{}) error {
 	accountID := args["account_id"].(int)
 	amount := args["amount"].(int)
 	var version int
-	err := tx.QueryRow("SELECT version FROM account WHERE account_id = ? FOR UPDATE", accountID).Scan(&version)
-	if err != nil {
-		return err
-	}
-	_, err = tx.Exec("UPDATE account SET balance = balance + ?, version = version + 1 WHERE account_id = ? AND version = ?", amount, accountID, version)
+	err := tx.QueryRow("SELECT version FROM account WHERE account_id = ?", accountID).Scan(&version)
 	if err != nil {
 		return err
 	}
-	return nil
+	return checkVersion(tx.Exec("UPDATE account SET balance = balance + ?, version = version + 1 WHERE account_id = ? AND version = ?", amount, accountID, version))
 }
 
 type OrderRM struct{}
@@ -125,48 +124,151 @@ func (rm *OrderRM) Try(ctx context.Context, tx *sql.Tx, args map[string]interfac
 	price := args["price"].(int)
 	// 幂等: 检查version
 	var version int
-	err := tx.QueryRow("SELECT version FROM account WHERE account_id = ? FOR UPDATE", accountID).Scan(&version)
-	if err != nil {
-		return err
-	}
-	_, err = tx.Exec("INSERT INTO seckill_order(account_id, item_id, quantity, price, version) VALUES(?, ?, ?, ?, ?)", accountID, itemID, quantity, price, version)
+	err := tccutil.QueryRowRetry(ctx, tx, "SELECT version FROM account WHERE account_id = ?", []interface{}{accountID}, &version)
 	if err != nil {
 		return err
 	}
-	return nil
+	_, err = tccutil.ExecRetry(ctx, tx, "INSERT INTO seckill_order(account_id, item_id, quantity, price, version) VALUES(?, ?, ?, ?, ?)", accountID, itemID, quantity, price, version)
+	return err
 }
 
 func (rm *OrderRM) Confirm(ctx context.Context, tx *sql.Tx, args map[string]interface{}) error {
 	orderID := args["order_id"].(int)
 	var version int
-	err := tx.QueryRow("SELECT version FROM seckill_order WHERE order_id = ? FOR UPDATE", orderID).Scan(&version)
+	err := tx.QueryRow("SELECT version FROM seckill_order WHERE order_id = ?", orderID).Scan(&version)
 	if err != nil {
 		return err
 	}
-	_, err = tx.Exec("UPDATE seckill_order SET status = 'CONFIRMED', version = version + 1 WHERE order_id = ? AND version = ?", orderID, version)
-	if err != nil {
-		return err
-	}
-	return nil
+	return checkVersion(tx.Exec("UPDATE seckill_order SET status = 'CONFIRMED', version = version + 1 WHERE order_id = ? AND version = ?", orderID, version))
 }
 
 func (rm *OrderRM) Cancel(ctx context.Context, tx *sql.Tx, args map[string]interface{}) error {
 	orderID := args["order_id"].(int)
 	var version int
-	err := tx.QueryRow("SELECT version FROM seckill_order WHERE order_id = ? FOR UPDATE", orderID).Scan(&version)
+	err := tx.QueryRow("SELECT version FROM seckill_order WHERE order_id = ?", orderID).Scan(&version)
 	if err != nil {
 		return err
 	}
-	_, err = tx.Exec("UPDATE seckill_order SET status = 'CANCELLED', version = version + 1 WHERE order_id = ? AND version = ?", orderID, version)
-	if err != nil {
-		return err
-	}
-	return nil
+	return checkVersion(tx.Exec("UPDATE seckill_order SET status = 'CANCELLED', version = version + 1 WHERE order_id = ? AND version = ?", orderID, version))
 }
 
 type Coordinator struct {
 	db        *sql.DB
 	resources map[string]ResourceManager
+
+	// EventsInTx 为 true 时事件与业务修改在同一事务中写入，二者原子提交，但失败阶段的事件随回滚丢失；
+	// 默认 false：事件通过独立连接自动提交，不占用业务事务、写入失败也不影响提交，只记录日志
+	EventsInTx bool
+
+	// DecisionTimeout StartTransaction 开始后这么久仍未 Confirm/Cancel，由定时器主动 Cancel；
+	// 默认 0 不启用，需要自动取消的调用方显式设置
+	DecisionTimeout time.Duration
+	// ScanInterval Compensate 扫描未完成事务的间隔
+	ScanInterval time.Duration
+	// StaleAfter 创建超过这么久仍未完成的事务才由 Compensate 处理
+	StaleAfter time.Duration
+
+	// MaxVersionRetries 资源返回 ErrVersionConflict 时回滚并在新事务中重试的次数，<=0 时为 defaultMaxVersionRetries
+	MaxVersionRetries int
+
+	// IsolationLevel 协调器开启的事务使用的隔离级别，零值 sql.LevelDefault 沿用服务器默认（MySQL 为 REPEATABLE READ）。
+	// 资源用普通 SELECT 读出 version 后再带 version 条件 UPDATE，UPDATE 是当前读，总是与最新提交的版本比较，
+	// 所以两种级别下都能发现冲突；REPEATABLE READ 下同一事务内再读仍是旧快照，冲突只能换新事务重试。区别在锁的范围：REPEATABLE READ 对不存在的行和范围加间隙锁，
+	// 同一间隙上的并发 INSERT（事务日志、订单）会互相阻塞，热点商品下死锁更多；
+	// READ COMMITTED 只锁命中的记录，锁冲突少，代价是事务内的普通 SELECT 每次都可能看到别人新提交的数据，
+	// 依赖"先查不存在再插入"的逻辑必须改用唯一键兜底
+	IsolationLevel sql.IsolationLevel
+
+	mu       sync.Mutex
+	deadline map[string]*time.Timer // 等待决议的事务
+}
+
+func (c *Coordinator) txOptions() *sql.TxOptions {
+	return &sql.TxOptions{Isolation: c.IsolationLevel}
+}
+
+// defaultMaxVersionRetries Coordinator 未设置 MaxVersionRetries 时的重试次数
+const defaultMaxVersionRetries = 3
+
+// withVersionRetry 执行一个阶段，fn 每次开启新事务，遇到版本冲突时（fn 已回滚）重新执行；
+// 新事务重新读取版本号，即基于最新版本重新应用修改
+func (c *Coordinator) withVersionRetry(txID, phase string, fn func() error) error {
+	retries := c.MaxVersionRetries
+	if retries <= 0 {
+		retries = defaultMaxVersionRetries
+	}
+	err := fn()
+	for i := 0; i < retries && errors.Is(err, ErrVersionConflict); i++ {
+		log.Printf("tx %s %s version conflict, retry %d", txID, phase, i+1)
+		err = fn()
+	}
+	return err
+}
+
+// 事件结果
+const (
+	EventOK     = "OK"
+	EventFailed = "FAILED"
+)
+
+// Event tcc_event 中的一条事件
+type Event struct {
+	ID         int64
+	TxID       string
+	Phase      string // TRY / CONFIRM / CANCEL
+	Resource   string
+	Result     string // EventOK，失败时为 "FAILED: 原因"
+	CreateTime time.Time
+}
+
+// emitEvent 追加一条事件，err 为 nil 表示该资源的阶段执行成功
+func (c *Coordinator) emitEvent(ctx context.Context, tx *sql.Tx, txID, phase, resource string, err error) {
+	result := EventOK
+	if err != nil {
+		result = fmt.Sprintf("%s: %v", EventFailed, err)
+		// result 是 utf8mb4 VARCHAR(255)，按字符截断，按字节截断可能切开中文
+		if r := []rune(result); len(r) > 255 {
+			result = string(r[:255])
+		}
+	}
+	const query = "INSERT INTO tcc_event(tx_id, phase, resource, result) VALUES(?, ?, ?, ?)"
+	if c.EventsInTx {
+		_, err = tx.ExecContext(ctx, query, txID, phase, resource, result)
+	} else {
+		_, err = c.db.ExecContext(ctx, query, txID, phase, resource, result)
+	}
+	if err != nil {
+		log.Printf("emit event %s %s %s failed: %v", txID, phase, resource, err)
+	}
+}
+
+// ReplayEvents 按写入顺序返回事务的全部事件
+func (c *Coordinator) ReplayEvents(ctx context.Context, txID string) ([]Event, error) {
+	rows, err := c.db.QueryContext(ctx, "SELECT id, tx_id, phase, resource, result, create_time FROM tcc_event WHERE tx_id = ? ORDER BY id", txID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var e Event
+		if err := rows.Scan(&e.ID, &e.TxID, &e.Phase, &e.Resource, &e.Result, &e.CreateTime); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// resourceIDs 按名称排序的资源列表，保证各阶段执行顺序和事件顺序稳定
+func (c *Coordinator) resourceIDs() []string {
+	ids := make([]string, 0, len(c.resources))
+	for id := range c.resources {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
 }
 
 func NewCoordinator(db *sql.DB) *Coordinator {
@@ -177,11 +279,54 @@ func NewCoordinator(db *sql.DB) *Coordinator {
 			"account":   &AccountRM{},
 			"order":     &OrderRM{},
 		},
+		ScanInterval: time.Minute,
+		StaleAfter:   5 * time.Minute,
+	}
+}
+
+// watch 登记事务的决议期限，到期仍未 Confirm/Cancel 时直接 Cancel，
+// 不必等 Compensate 的下一轮扫描。进程崩溃后定时器丢失，仍由 Compensate 兜底
+func (c *Coordinator) watch(txID string, args map[string]interface{}) {
+	if c.DecisionTimeout <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.deadline == nil {
+		c.deadline = make(map[string]*time.Timer)
 	}
+	c.deadline[txID] = time.AfterFunc(c.DecisionTimeout, func() {
+		c.unwatch(txID)
+		log.Printf("tx %s not decided within %v, cancelling", txID, c.DecisionTimeout)
+		if err := c.Cancel(context.Background(), txID, args); err != nil {
+			log.Printf("timeout cancel tx %s failed: %v", txID, err)
+		}
+	})
+}
+
+// unwatch 事务已有决议，停止定时器
+func (c *Coordinator) unwatch(txID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t, ok := c.deadline[txID]; ok {
+		t.Stop()
+		delete(c.deadline, txID)
+	}
+}
+
+func (c *Coordinator) StartTransaction(ctx context.Context, txID string, args map[string]interface{}) (err error) {
+	c.watch(txID, args)
+	defer func() {
+		if err != nil {
+			c.unwatch(txID) // 本地事务已回滚，没有需要 Cancel 的预留
+		}
+	}()
+
+	return c.withVersionRetry(txID, "TRY", func() error { return c.try(ctx, txID, args) })
 }
 
-func (c *Coordinator) StartTransaction(ctx context.Context, txID string, args map[string]interface{}) error {
-	tx, err := c.db.BeginTx(ctx, nil)
+func (c *Coordinator) try(ctx context.Context, txID string, args map[string]interface{}) error {
+	tx, err := c.db.BeginTx(ctx, c.txOptions())
 	if err != nil {
 		return err
 	}
@@ -191,8 +336,10 @@ func (c *Coordinator) StartTransaction(ctx context.Context, txID string, args ma
 		tx.Rollback()
 		return err
 	}
-	for resourceID, rm := range c.resources {
-		if err := rm.Try(ctx, tx, args); err != nil {
+	for _, resourceID := range c.resourceIDs() {
+		err := c.resources[resourceID].Try(ctx, tx, args)
+		c.emitEvent(ctx, tx, txID, "TRY", resourceID, err)
+		if err != nil {
 			tx.Rollback()
 			return err
 		}
@@ -212,19 +359,30 @@ func (c *Coordinator) StartTransaction(ctx context.Context, txID string, args ma
 }
 
 func (c *Coordinator) Confirm(ctx context.Context, txID string, args map[string]interface{}) error {
-	tx, err := c.db.BeginTx(ctx, nil)
+	c.unwatch(txID)
+	return c.withVersionRetry(txID, "CONFIRM", func() error { return c.confirm(ctx, txID, args) })
+}
+
+func (c *Coordinator) confirm(ctx context.Context, txID string, args map[string]interface{}) error {
+	tx, err := c.db.BeginTx(ctx, c.txOptions())
 	if err != nil {
 		return err
 	}
 	// 幂等: 检查并更新到CONFIRMING
 	res, err := tx.Exec("UPDATE tcc_transaction SET status = 'CONFIRMING' WHERE tx_id = ? AND status = 'TRIED'", txID)
-	rows, _ := res.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	rows, err := res.RowsAffected()
 	if err != nil || rows == 0 {
 		tx.Rollback()
 		return fmt.Errorf("invalid state for confirm")
 	}
-	for _, rm := range c.resources {
-		if err := rm.Confirm(ctx, tx, args); err != nil {
+	for _, resourceID := range c.resourceIDs() {
+		err := c.resources[resourceID].Confirm(ctx, tx, args)
+		c.emitEvent(ctx, tx, txID, "CONFIRM", resourceID, err)
+		if err != nil {
 			tx.Rollback()
 			return err
 		}
@@ -244,19 +402,30 @@ func (c *Coordinator) Confirm(ctx context.Context, txID string, args map[string]
 
 func (c *Coordinator) Cancel(ctx context.Context, txID string, args map[string]interface{}) error {
 	// 类似Confirm，实现CANCELLING检查和更新（省略）
-	tx, err := c.db.BeginTx(ctx, nil)
+	c.unwatch(txID)
+	return c.withVersionRetry(txID, "CANCEL", func() error { return c.cancel(ctx, txID, args) })
+}
+
+func (c *Coordinator) cancel(ctx context.Context, txID string, args map[string]interface{}) error {
+	tx, err := c.db.BeginTx(ctx, c.txOptions())
+	if err != nil {
+		return err
+	}
+	// 幂等: 检查并更新到CANCELLING，未决议的 TRYING 事务（超时或补偿）也可以取消
+	res, err := tx.Exec("UPDATE tcc_transaction SET status = 'CANCELLING' WHERE tx_id = ? AND status IN ('TRYING', 'TRIED')", txID)
 	if err != nil {
+		tx.Rollback()
 		return err
 	}
-	// 幂等: 检查并更新到CANCELLING
-	res, err := tx.Exec("UPDATE tcc_transaction SET status = 'CANCELLING' WHERE tx_id = ? AND status = 'TRIED'", txID)
 	rows, err := res.RowsAffected()
 	if err != nil || rows == 0 {
 		tx.Rollback()
 		return fmt.Errorf("invalid state for cancel")
 	}
-	for _, rm := range c.resources {
-		if err := rm.Cancel(ctx, tx, args); err != nil {
+	for _, resourceID := range c.resourceIDs() {
+		err := c.resources[resourceID].Cancel(ctx, tx, args)
+		c.emitEvent(ctx, tx, txID, "CANCEL", resourceID, err)
+		if err != nil {
 			tx.Rollback()
 			return err
 		}
@@ -277,8 +446,8 @@ func (c *Coordinator) Cancel(ctx context.Context, txID string, args map[string]i
 // 重启补偿: 定时扫描未完成事务
 func (c *Coordinator) Compensate() {
 	for {
-		time.Sleep(1 * time.Minute)
-		rows, err := c.db.Query("SELECT tx_id, status FROM tcc_transaction WHERE status IN ('TRYING', 'TRIED', 'CONFIRMING', 'CANCELLING') AND create_time < NOW() - INTERVAL 5 MINUTE")
+		time.Sleep(c.ScanInterval)
+		rows, err := c.db.Query("SELECT tx_id, status FROM tcc_transaction WHERE status IN ('TRYING', 'TRIED', 'CONFIRMING', 'CANCELLING') AND create_time < NOW() - INTERVAL ? MICROSECOND", c.StaleAfter.Microseconds())
 		if err != nil {
 			log.Println("compensate error:", err)
 			continue
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+// isolationDB 记录 BeginTx 收到的隔离级别后拒绝开启事务，只用于观察协调器传下来的选项
+type isolationDB struct{ levels []sql.IsolationLevel }
+
+func (d *isolationDB) Connect(context.Context) (driver.Conn, error) { return isolationConn{d}, nil }
+func (d *isolationDB) Driver() driver.Driver                        { return d }
+func (d *isolationDB) Open(string) (driver.Conn, error)             { return isolationConn{d}, nil }
+
+type isolationConn struct{ db *isolationDB }
+
+func (c isolationConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c isolationConn) Close() error                        { return nil }
+func (c isolationConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (c isolationConn) BeginTx(_ context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.db.levels = append(c.db.levels, sql.IsolationLevel(opts.Isolation))
+	return nil, errors.New("begin refused")
+}
+
+func TestCoordinatorIsolationLevel(t *testing.T) {
+	rec := &isolationDB{}
+	db := sql.OpenDB(rec)
+	defer db.Close()
+
+	c := NewCoordinator(db)
+	c.DecisionTimeout = 0
+	c.IsolationLevel = sql.LevelReadCommitted
+	ctx := context.Background()
+	c.StartTransaction(ctx, "tx1", map[string]interface{}{})
+	c.Confirm(ctx, "tx1", map[string]interface{}{})
+	c.Cancel(ctx, "tx1", map[string]interface{}{})
+
+	if len(rec.levels) != 3 {
+		t.Fatalf("BeginTx called %d times, want 3", len(rec.levels))
+	}
+	for i, level := range rec.levels {
+		if level != sql.LevelReadCommitted {
+			t.Fatalf("BeginTx #%d isolation = %v, want READ COMMITTED", i+1, level)
+		}
+	}
+}
+
+// isolationProbe 在协调器开启的事务里读取隔离级别，然后让 Try 失败回滚，不留下数据
+type isolationProbe struct{ got string }
+
+var errProbeDone = errors.New("probe done")
+
+func (p *isolationProbe) Try(ctx context.Context, tx *sql.Tx, _ map[string]interface{}) error {
+	// MySQL 8 之前为 @@tx_isolation
+	err := tx.QueryRowContext(ctx, "SELECT @@transaction_isolation").Scan(&p.got)
+	if err != nil && strings.Contains(err.Error(), "Unknown system variable") {
+		err = tx.QueryRowContext(ctx, "SELECT @@tx_isolation").Scan(&p.got)
+	}
+	if err != nil {
+		return err
+	}
+	return errProbeDone
+}
+func (p *isolationProbe) Confirm(context.Context, *sql.Tx, map[string]interface{}) error { return nil }
+func (p *isolationProbe) Cancel(context.Context, *sql.Tx, map[string]interface{}) error  { return nil }
+
+// TestCoordinatorIsolationLevelMySQL 在真实 MySQL 上确认 StartTransaction 开启的事务确实使用了 IsolationLevel
+func TestCoordinatorIsolationLevelMySQL(t *testing.T) {
+	dsn := os.Getenv("MYSQL_TEST_DSN")
+	if dsn == "" {
+		t.Skip("MYSQL_TEST_DSN not set")
+	}
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// StartTransaction 先写 tcc_transaction，完整表结构见 mysql.sql；探测结束后事务回滚
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS tcc_transaction (
+		tx_id VARCHAR(64) PRIMARY KEY,
+		status VARCHAR(16) NOT NULL,
+		create_time DATETIME(3) DEFAULT CURRENT_TIMESTAMP(3)
+	)`); err != nil {
+		t.Fatal(err)
+	}
+
+	for level, want := range map[sql.IsolationLevel]string{
+		sql.LevelReadCommitted:  "READ-COMMITTED",
+		sql.LevelRepeatableRead: "REPEATABLE-READ",
+	} {
+		probe := &isolationProbe{}
+		c := &Coordinator{db: db, resources: map[string]ResourceManager{"probe": probe}, IsolationLevel: level}
+		err := c.StartTransaction(context.Background(), "isolation-probe-"+want, map[string]interface{}{})
+		if !errors.Is(err, errProbeDone) {
+			t.Fatalf("StartTransaction = %v, want the probe to run", err)
+		}
+		if probe.got != want {
+			t.Fatalf("isolation = %s, want %s", probe.got, want)
+		}
+	}
+}
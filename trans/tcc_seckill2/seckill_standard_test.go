@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// stubRM 不访问数据库的资源，tryErr 非空时 Try 失败
+type stubRM struct{ tryErr error }
+
+func (rm stubRM) Try(context.Context, *sql.Tx, map[string]interface{}) error     { return rm.tryErr }
+func (rm stubRM) Confirm(context.Context, *sql.Tx, map[string]interface{}) error { return nil }
+func (rm stubRM) Cancel(context.Context, *sql.Tx, map[string]interface{}) error  { return nil }
+
+func newTestCoordinator(t *testing.T, resources map[string]ResourceManager) (*Coordinator, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return &Coordinator{db: db, resources: resources}, mock
+}
+
+func expectEvent(mock sqlmock.Sqlmock, phase, resource, result string) {
+	mock.ExpectExec("INSERT INTO tcc_event").
+		WithArgs("tx1", phase, resource, result).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
+func TestEventSequenceCommitted(t *testing.T) {
+	c, mock := newTestCoordinator(t, map[string]ResourceManager{"inventory": stubRM{}, "account": stubRM{}})
+
+	// Try：资源按名称顺序执行，每个资源 Try 后立即写事件
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO tcc_transaction").WillReturnResult(sqlmock.NewResult(0, 1))
+	expectEvent(mock, "TRY", "account", EventOK)
+	mock.ExpectExec("INSERT INTO tcc_branch").WillReturnResult(sqlmock.NewResult(0, 1))
+	expectEvent(mock, "TRY", "inventory", EventOK)
+	mock.ExpectExec("INSERT INTO tcc_branch").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE tcc_transaction SET status = 'TRIED'").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// Confirm
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE tcc_transaction SET status = 'CONFIRMING'").WillReturnResult(sqlmock.NewResult(0, 1))
+	expectEvent(mock, "CONFIRM", "account", EventOK)
+	expectEvent(mock, "CONFIRM", "inventory", EventOK)
+	mock.ExpectExec("UPDATE tcc_branch SET status = 'CONFIRMED'").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("UPDATE tcc_transaction SET status = 'CONFIRMED'").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	ctx := context.Background()
+	if err := c.StartTransaction(ctx, "tx1", nil); err != nil {
+		t.Fatalf("StartTransaction: %v", err)
+	}
+	if err := c.Confirm(ctx, "tx1", nil); err != nil {
+		t.Fatalf("Confirm: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFailedTryEventSurvivesRollback(t *testing.T) {
+	c, mock := newTestCoordinator(t, map[string]ResourceManager{"inventory": stubRM{tryErr: errors.New("sold out")}})
+
+	// 默认事件不在业务事务内，业务回滚后失败事件仍然保留
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO tcc_transaction").WillReturnResult(sqlmock.NewResult(0, 1))
+	expectEvent(mock, "TRY", "inventory", "FAILED: sold out")
+	mock.ExpectRollback()
+
+	if err := c.StartTransaction(context.Background(), "tx1", nil); err == nil {
+		t.Fatal("StartTransaction succeeded, want Try failure")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplayEvents(t *testing.T) {
+	c, mock := newTestCoordinator(t, nil)
+	now := time.Now()
+	mock.ExpectQuery("SELECT id, tx_id, phase, resource, result, create_time FROM tcc_event").
+		WithArgs("tx1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tx_id", "phase", "resource", "result", "create_time"}).
+			AddRow(1, "tx1", "TRY", "account", EventOK, now).
+			AddRow(2, "tx1", "CONFIRM", "account", EventOK, now))
+
+	events, err := c.ReplayEvents(context.Background(), "tx1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Phase != "TRY" || events[1].Phase != "CONFIRM" || events[1].ID != 2 {
+		t.Fatalf("events = %+v", events)
+	}
+}
+
+func expectStart(mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO tcc_transaction").WillReturnResult(sqlmock.NewResult(0, 1))
+	expectEvent(mock, "TRY", "inventory", EventOK)
+	mock.ExpectExec("INSERT INTO tcc_branch").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE tcc_transaction SET status = 'TRIED'").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func TestDecisionTimeoutCancels(t *testing.T) {
+	c, mock := newTestCoordinator(t, map[string]ResourceManager{"inventory": stubRM{}})
+	c.DecisionTimeout = 50 * time.Millisecond
+
+	expectStart(mock)
+	// 没有 Confirm，期限到后由定时器取消
+	mock.ExpectBegin()
+	mock.ExpectExec(`SET status = 'CANCELLING' WHERE tx_id = \? AND status IN \('TRYING', 'TRIED'\)`).
+		WithArgs("tx1").WillReturnResult(sqlmock.NewResult(0, 1))
+	expectEvent(mock, "CANCEL", "inventory", EventOK)
+	mock.ExpectExec("UPDATE tcc_branch SET status = 'CANCELLED'").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE tcc_transaction SET status = 'CANCELLED'").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	start := time.Now()
+	if err := c.StartTransaction(context.Background(), "tx1", nil); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for mock.ExpectationsWereMet() != nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("transaction not cancelled: %v", mock.ExpectationsWereMet())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if elapsed := time.Since(start); elapsed < c.DecisionTimeout {
+		t.Fatalf("cancelled after %v, before the %v timeout", elapsed, c.DecisionTimeout)
+	}
+}
+
+func TestConfirmStopsDecisionTimer(t *testing.T) {
+	c, mock := newTestCoordinator(t, map[string]ResourceManager{"inventory": stubRM{}})
+	c.DecisionTimeout = 50 * time.Millisecond
+
+	expectStart(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE tcc_transaction SET status = 'CONFIRMING'").WillReturnResult(sqlmock.NewResult(0, 1))
+	expectEvent(mock, "CONFIRM", "inventory", EventOK)
+	mock.ExpectExec("UPDATE tcc_branch SET status = 'CONFIRMED'").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE tcc_transaction SET status = 'CONFIRMED'").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	ctx := context.Background()
+	if err := c.StartTransaction(ctx, "tx1", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Confirm(ctx, "tx1", nil); err != nil {
+		t.Fatal(err)
+	}
+	c.mu.Lock()
+	pending := len(c.deadline)
+	c.mu.Unlock()
+	if pending != 0 {
+		t.Fatalf("%d decision timers still registered after Confirm", pending)
+	}
+	// 定时器若仍触发会发起意外的 Begin，使期望校验失败
+	time.Sleep(2 * c.DecisionTimeout)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewCoordinatorDecisionTimeoutOptIn(t *testing.T) {
+	if c := NewCoordinator(nil); c.DecisionTimeout != 0 {
+		t.Fatalf("DecisionTimeout = %v, want 0 (disabled) by default", c.DecisionTimeout)
+	}
+}
+
+func TestCancelReturnsStatusUpdateError(t *testing.T) {
+	c, mock := newTestCoordinator(t, map[string]ResourceManager{"inventory": stubRM{}})
+	mock.ExpectBegin()
+	mock.ExpectExec("SET status = 'CANCELLING'").WillReturnError(errors.New("connection reset"))
+	mock.ExpectRollback()
+
+	if err := c.Cancel(context.Background(), "tx1", nil); err == nil || err.Error() != "connection reset" {
+		t.Fatalf("Cancel error = %v, want connection reset", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// utf8Arg 要求参数是合法 UTF-8 且不超过 n 个字符
+type utf8Arg struct{ n int }
+
+func (a utf8Arg) Match(v driver.Value) bool {
+	s, ok := v.(string)
+	return ok && utf8.ValidString(s) && utf8.RuneCountInString(s) == a.n
+}
+
+func TestEventResultTruncatedByRunes(t *testing.T) {
+	c, mock := newTestCoordinator(t, nil)
+	mock.ExpectExec("INSERT INTO tcc_event").
+		WithArgs("tx1", "TRY", "inventory", utf8Arg{255}).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	c.emitEvent(context.Background(), nil, "tx1", "TRY", "inventory", errors.New(strings.Repeat("库存不足", 100)))
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 版本冲突时回滚，在新事务中重新读取版本号后重试成功
+func TestVersionConflictRetriedInNewTransaction(t *testing.T) {
+	c, mock := newTestCoordinator(t, map[string]ResourceManager{"inventory": &InventoryRM{}})
+	for version, affected := range []int64{0, 1} {
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO tcc_transaction").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT version FROM seckill_inventory WHERE item_id = \\?").
+			WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(version + 1))
+		mock.ExpectExec("UPDATE seckill_inventory").WithArgs(1, 1, 1, version+1).
+			WillReturnResult(sqlmock.NewResult(0, affected))
+		if affected == 0 {
+			expectEvent(mock, "TRY", "inventory", EventFailed+": "+ErrVersionConflict.Error())
+			mock.ExpectRollback()
+		}
+	}
+	expectEvent(mock, "TRY", "inventory", EventOK)
+	mock.ExpectExec("INSERT INTO tcc_branch").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE tcc_transaction SET status = 'TRIED'").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := c.StartTransaction(context.Background(), "tx1", map[string]interface{}{"item_id": 1, "quantity": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
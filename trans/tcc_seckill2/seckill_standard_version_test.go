@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// versionDB 只实现 seckill_inventory 乐观锁读写的内存驱动，其余写操作一律成功，UPDATE 立即生效。
+// 前两次读版本号会互相等待，保证两个事务读到同一个版本，制造冲突
+type versionDB struct {
+	mu        sync.Mutex
+	available int64
+	version   int64
+	reads     int
+	bothRead  chan struct{}
+	conflicts int
+}
+
+func (f *versionDB) Connect(context.Context) (driver.Conn, error) { return f.Open("") }
+func (f *versionDB) Driver() driver.Driver                        { return f }
+func (f *versionDB) Open(string) (driver.Conn, error)             { return versionConn{f}, nil }
+
+type versionConn struct{ db *versionDB }
+
+func (c versionConn) Prepare(query string) (driver.Stmt, error) { return versionStmt{c.db, query}, nil }
+func (c versionConn) Close() error                              { return nil }
+func (c versionConn) Begin() (driver.Tx, error)                 { return c, nil }
+func (c versionConn) Commit() error                             { return nil }
+func (c versionConn) Rollback() error                           { return nil }
+
+type versionStmt struct {
+	db    *versionDB
+	query string
+}
+
+func (s versionStmt) Close() error  { return nil }
+func (s versionStmt) NumInput() int { return -1 }
+
+func (s versionStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if !strings.HasPrefix(s.query, "UPDATE seckill_inventory") {
+		return driver.RowsAffected(1), nil
+	}
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if args[3].(int64) != s.db.version {
+		s.db.conflicts++
+		return driver.RowsAffected(0), nil
+	}
+	s.db.available -= args[1].(int64)
+	s.db.version++
+	return driver.RowsAffected(1), nil
+}
+
+func (s versionStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if !strings.HasPrefix(s.query, "SELECT version FROM seckill_inventory") {
+		return nil, fmt.Errorf("unsupported query: %s", s.query)
+	}
+	s.db.mu.Lock()
+	s.db.reads++
+	first := s.db.reads <= 2
+	if s.db.reads == 2 {
+		close(s.db.bothRead)
+	}
+	v := s.db.version
+	s.db.mu.Unlock()
+	if first {
+		<-s.db.bothRead
+	}
+	return &versionRows{v: v}, nil
+}
+
+type versionRows struct {
+	v    int64
+	done bool
+}
+
+func (r *versionRows) Columns() []string { return []string{"version"} }
+func (r *versionRows) Close() error      { return nil }
+func (r *versionRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	dest[0], r.done = r.v, true
+	return nil
+}
+
+func TestVersionConflictRetried(t *testing.T) {
+	f := &versionDB{available: 10, version: 1, bothRead: make(chan struct{})}
+	db := sql.OpenDB(f)
+	defer db.Close()
+	c := &Coordinator{db: db, resources: map[string]ResourceManager{"inventory": &InventoryRM{}}}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			args := map[string]interface{}{"item_id": 1, "quantity": 1}
+			errs[i] = c.StartTransaction(context.Background(), fmt.Sprintf("tx%d", i), args)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("tx%d: %v", i, err)
+		}
+	}
+	// 两个事务读到同一版本，后写的一方冲突后回滚，在新事务中重读版本号重试，两次扣减都生效
+	if f.conflicts != 1 || f.available != 8 || f.version != 3 {
+		t.Fatalf("conflicts = %d, available = %d, version = %d; want 1, 8, 3", f.conflicts, f.available, f.version)
+	}
+}
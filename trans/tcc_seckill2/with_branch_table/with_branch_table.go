@@ -5,9 +5,10 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"strconv"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
-	"github.com/google/uuid"
 )
 
 // 带分支表的版本 - 传统TCC设计
@@ -142,7 +143,7 @@ func (rm *InventoryRMWithBranch) Cancel(ctx context.Context, tx *sql.Tx, branchI
 		WHERE branch_id = ? AND tx_id = ?`, 
 		branchID, txID).Scan(&status)
 	
-	if err != nil || status == "CANCELLED" {
+	if err != nil || status == "ROLLBACKED" {
 		return nil // 幂等
 	}
 
@@ -179,7 +180,7 @@ func (rm *InventoryRMWithBranch) Cancel(ctx context.Context, tx *sql.Tx, branchI
 	// 4. 更新分支状态
 	_, err = tx.Exec(`
 		UPDATE tcc_branch 
-		SET status = 'CANCELLED', update_time = NOW() 
+		SET status = 'ROLLBACKED', update_time = NOW() 
 		WHERE branch_id = ? AND tx_id = ?`, 
 		branchID, txID)
 
@@ -190,12 +191,15 @@ func (rm *InventoryRMWithBranch) Cancel(ctx context.Context, tx *sql.Tx, branchI
 type CoordinatorWithBranch struct {
 	db        *sql.DB
 	resources map[string]ResourceManagerWithBranch
+
+	// IsolationLevel 事务隔离级别，取舍见 Coordinator.IsolationLevel
+	IsolationLevel sql.IsolationLevel
 }
 
 func (c *CoordinatorWithBranch) StartTransaction(ctx context.Context, txID string, args map[string]interface{}) error {
 	args["tx_id"] = txID
 
-	tx, err := c.db.BeginTx(ctx, nil)
+	tx, err := c.db.BeginTx(ctx, &sql.TxOptions{Isolation: c.IsolationLevel})
 	if err != nil {
 		return err
 	}
@@ -237,7 +241,7 @@ func (c *CoordinatorWithBranch) StartTransaction(ctx context.Context, txID strin
 func (c *CoordinatorWithBranch) Confirm(ctx context.Context, txID string, args map[string]interface{}) error {
 	args["tx_id"] = txID
 
-	tx, err := c.db.BeginTx(ctx, nil)
+	tx, err := c.db.BeginTx(ctx, &sql.TxOptions{Isolation: c.IsolationLevel})
 	if err != nil {
 		return err
 	}
@@ -290,6 +294,150 @@ func (c *CoordinatorWithBranch) Confirm(ctx context.Context, txID string, args m
 	return tx.Commit()
 }
 
+// Cancel 取消全局事务：对所有仍为 PREPARED 的分支执行 Cancel
+func (c *CoordinatorWithBranch) Cancel(ctx context.Context, txID string, args map[string]interface{}) error {
+	args["tx_id"] = txID
+
+	tx, err := c.db.BeginTx(ctx, &sql.TxOptions{Isolation: c.IsolationLevel})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// 1. 更新全局事务状态，已经在提交或已结束的事务不能取消
+	res, err := tx.Exec(`
+		UPDATE tcc_transaction
+		SET status = 'CANCELLING', update_time = NOW()
+		WHERE tx_id = ? AND status IN ('TRYING', 'TRIED', 'CANCELLING')`, txID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return fmt.Errorf("invalid state for cancel: %s", txID)
+	}
+
+	// 2. 先读出全部待取消的分支再逐个 Cancel，同一连接上结果集未关闭时不能执行其他语句
+	rows, err := tx.Query(`
+		SELECT branch_id, resource_type
+		FROM tcc_branch
+		WHERE tx_id = ? AND status = 'PREPARED'`, txID)
+	if err != nil {
+		return err
+	}
+	type branch struct {
+		id           int64
+		resourceType string
+	}
+	var branches []branch
+	for rows.Next() {
+		var b branch
+		if err := rows.Scan(&b.id, &b.resourceType); err != nil {
+			rows.Close()
+			return err
+		}
+		branches = append(branches, b)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, b := range branches {
+		if rm, exists := c.resources[b.resourceType]; exists {
+			if err := rm.Cancel(ctx, tx, b.id, args); err != nil {
+				return fmt.Errorf("cancel branch %d (%s): %v", b.id, b.resourceType, err)
+			}
+		}
+	}
+
+	// 3. 更新全局事务状态
+	_, err = tx.Exec(`
+		UPDATE tcc_transaction
+		SET status = 'CANCELLED', update_time = NOW()
+		WHERE tx_id = ?`, txID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// StaleBranch 超时未推进的分支
+type StaleBranch struct {
+	BranchID     int64
+	TxID         string
+	ResourceType string
+	ResourceID   string
+	Status       string
+	CreateTime   time.Time
+	UpdateTime   time.Time
+}
+
+// FindStaleBranches 查找最后更新时间早于 olderThan 之前、仍停留在 PREPARED 的分支。
+// 正常情况下 Try 之后很快会 Confirm 或 Cancel，长时间 PREPARED 说明协调者在两阶段之间崩溃或丢失了决议。
+// 截止时间用数据库的 NOW(3) 计算，与 update_time 同一时钟，不受应用服务器时钟偏差影响
+func (c *CoordinatorWithBranch) FindStaleBranches(ctx context.Context, olderThan time.Duration) ([]StaleBranch, error) {
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT branch_id, tx_id, resource_type, resource_id, status, create_time, update_time
+		FROM tcc_branch
+		WHERE status = 'PREPARED' AND update_time < NOW(3) - INTERVAL ? MICROSECOND
+		ORDER BY update_time`, olderThan.Microseconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var branches []StaleBranch
+	for rows.Next() {
+		var b StaleBranch
+		if err := rows.Scan(&b.BranchID, &b.TxID, &b.ResourceType, &b.ResourceID, &b.Status, &b.CreateTime, &b.UpdateTime); err != nil {
+			return nil, err
+		}
+		branches = append(branches, b)
+	}
+	return branches, rows.Err()
+}
+
+// RecoverStaleBranches 把含超时分支的全局事务全部 Cancel，返回成功取消的事务ID。
+// Cancel 需要的业务参数从分支记录中恢复（inventory 分支的 resource_id 即 item_id），
+// 补偿数量以冻结表为准。单个事务取消失败只记录日志，不影响其他事务
+func (c *CoordinatorWithBranch) RecoverStaleBranches(ctx context.Context, olderThan time.Duration) ([]string, error) {
+	branches, err := c.FindStaleBranches(ctx, olderThan)
+	if err != nil {
+		return nil, err
+	}
+
+	var order []string
+	argsByTx := make(map[string]map[string]interface{})
+	for _, b := range branches {
+		args, ok := argsByTx[b.TxID]
+		if !ok {
+			args = make(map[string]interface{})
+			argsByTx[b.TxID] = args
+			order = append(order, b.TxID)
+		}
+		if b.ResourceType == "inventory" {
+			itemID, err := strconv.ParseInt(b.ResourceID, 10, 64)
+			if err != nil {
+				log.Printf("branch %d has invalid item id %q: %v", b.BranchID, b.ResourceID, err)
+				continue
+			}
+			args["item_id"] = itemID
+		}
+	}
+
+	var cancelled []string
+	for _, txID := range order {
+		log.Printf("recovering stale tx %s: cancel", txID)
+		if err := c.Cancel(ctx, txID, argsByTx[txID]); err != nil {
+			log.Printf("recover stale tx %s failed: %v", txID, err)
+			continue
+		}
+		cancelled = append(cancelled, txID)
+	}
+	return cancelled, nil
+}
+
 // 演示：分支表的查询和监控功能
 func (c *CoordinatorWithBranch) QueryTransactionStatus(ctx context.Context, txID string) error {
 	// 查询全局事务状态
@@ -305,7 +453,7 @@ func (c *CoordinatorWithBranch) QueryTransactionStatus(ctx context.Context, txID
 
 	// 查询分支详情
 	rows, err := c.db.Query(`
-		SELECT branch_id, resource_type, resource_id, status, create_time 
+		SELECT branch_id, resource_type, resource_id, status, create_time, update_time 
 		FROM tcc_branch 
 		WHERE tx_id = ? 
 		ORDER BY branch_id`, txID)
@@ -317,13 +465,13 @@ func (c *CoordinatorWithBranch) QueryTransactionStatus(ctx context.Context, txID
 	fmt.Println("分支事务详情:")
 	for rows.Next() {
 		var branchID int64
-		var resourceType, resourceID, status, createTime string
-		err = rows.Scan(&branchID, &resourceType, &resourceID, &status, &createTime)
+		var resourceType, resourceID, status, createTime, updateTime string
+		err = rows.Scan(&branchID, &resourceType, &resourceID, &status, &createTime, &updateTime)
 		if err != nil {
 			return err
 		}
-		fmt.Printf("  分支 %d: %s[%s] = %s (创建时间: %s)\n", 
-			branchID, resourceType, resourceID, status, createTime)
+		fmt.Printf("  分支 %d: %s[%s] = %s (创建时间: %s, 更新时间: %s)\n", 
+			branchID, resourceType, resourceID, status, createTime, updateTime)
 	}
 
 	return nil
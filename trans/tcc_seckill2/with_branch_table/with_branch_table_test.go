@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestRecoverStaleBranches(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	c := &CoordinatorWithBranch{db: db, resources: map[string]ResourceManagerWithBranch{"inventory": &InventoryRMWithBranch{}}}
+
+	// 一个 10 分钟前 Try 完成后再无进展的分支
+	old := time.Now().Add(-10 * time.Minute)
+	mock.ExpectQuery("FROM tcc_branch\\s+WHERE status = 'PREPARED' AND update_time < NOW\\(3\\) - INTERVAL \\? MICROSECOND").
+		WithArgs((5 * time.Minute).Microseconds()).
+		WillReturnRows(sqlmock.NewRows([]string{"branch_id", "tx_id", "resource_type", "resource_id", "status", "create_time", "update_time"}).
+			AddRow(1, "tx1", "inventory", "1001", "PREPARED", old, old))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SET status = 'CANCELLING'").WithArgs("tx1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT branch_id, resource_type").WithArgs("tx1").
+		WillReturnRows(sqlmock.NewRows([]string{"branch_id", "resource_type"}).AddRow(1, "inventory"))
+	// InventoryRMWithBranch.Cancel：按冻结表数量归还库存
+	mock.ExpectQuery("SELECT status FROM tcc_branch").WithArgs(1, "tx1").
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("PREPARED"))
+	mock.ExpectQuery("SELECT freeze_quantity FROM inventory_freeze").WithArgs("tx1", int64(1001)).
+		WillReturnRows(sqlmock.NewRows([]string{"freeze_quantity"}).AddRow(2))
+	mock.ExpectExec("UPDATE seckill_inventory").WithArgs(2, int64(1001)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE inventory_freeze").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("SET status = 'ROLLBACKED', update_time = NOW\\(\\)\\s+WHERE branch_id").WithArgs(1, "tx1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE tcc_transaction\\s+SET status = 'CANCELLED'").WithArgs("tx1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	cancelled, err := c.RecoverStaleBranches(context.Background(), 5*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cancelled) != 1 || cancelled[0] != "tx1" {
+		t.Fatalf("cancelled = %v, want [tx1]", cancelled)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
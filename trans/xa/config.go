@@ -0,0 +1,141 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// XAConfig 分支配置文件（JSON），新增分片只需要在 branches 中加一项
+//
+//	{
+//	  "branches": [
+//	    {"id": "db1", "name": "Database1", "dsn": "root:123456@tcp(localhost:3306)/test_db?parseTime=true",
+//	     "maxOpenConns": 10, "maxIdleConns": 5, "connMaxLifetime": "5m"}
+//	  ]
+//	}
+type XAConfig struct {
+	Branches []BranchConfig `json:"branches"`
+}
+
+// BranchConfig 一个分支数据库
+type BranchConfig struct {
+	ID              string   `json:"id"`
+	Name            string   `json:"name"`
+	Driver          string   `json:"driver,omitempty"` // 默认 mysql
+	DSN             string   `json:"dsn"`
+	MaxOpenConns    int      `json:"maxOpenConns,omitempty"`
+	MaxIdleConns    int      `json:"maxIdleConns,omitempty"`
+	ConnMaxLifetime Duration `json:"connMaxLifetime,omitempty"`
+}
+
+// Duration 以 "30s"、"5m" 形式出现在配置文件中的时长
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"5m\": %v", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// LoadXAConfig 读取并校验分支配置文件
+func LoadXAConfig(path string) (*XAConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg XAConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %v", path, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// Validate 至少一个分支，分支 ID 非空且不重复，DSN 非空
+func (c *XAConfig) Validate() error {
+	if len(c.Branches) == 0 {
+		return errors.New("no branches configured")
+	}
+	seen := make(map[string]bool, len(c.Branches))
+	for i, b := range c.Branches {
+		if b.ID == "" {
+			return fmt.Errorf("branch %d: id is required", i)
+		}
+		if seen[b.ID] {
+			return fmt.Errorf("branch %s: duplicate id", b.ID)
+		}
+		seen[b.ID] = true
+		if b.DSN == "" {
+			return fmt.Errorf("branch %s: dsn is required", b.ID)
+		}
+	}
+	return nil
+}
+
+// NewXAManagerFromConfig 按配置打开每个分支的数据库并注册为分支，
+// 打开的连接池归管理器所有，用完后调用 Close 关闭
+func NewXAManagerFromConfig(globalXID string, cfg *XAConfig) (*XAManager, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	xm := NewXAManager(globalXID)
+	for _, b := range cfg.Branches {
+		driver := b.Driver
+		if driver == "" {
+			driver = "mysql"
+		}
+		db, err := sql.Open(driver, b.DSN)
+		if err != nil {
+			xm.Close()
+			return nil, fmt.Errorf("branch %s: %v", b.ID, err)
+		}
+		if b.MaxOpenConns > 0 {
+			db.SetMaxOpenConns(b.MaxOpenConns)
+		}
+		if b.MaxIdleConns > 0 {
+			db.SetMaxIdleConns(b.MaxIdleConns)
+		}
+		if b.ConnMaxLifetime > 0 {
+			db.SetConnMaxLifetime(time.Duration(b.ConnMaxLifetime))
+		}
+		name := b.Name
+		if name == "" {
+			name = b.ID
+		}
+		xm.AddBranch(b.ID, name, db)
+		xm.owned = append(xm.owned, db)
+	}
+	return xm, nil
+}
+
+// Close 关闭 NewXAManagerFromConfig 打开的数据库，AddBranch 传入的由调用方自己关闭
+func (xm *XAManager) Close() error {
+	xm.mu.Lock()
+	owned := xm.owned
+	xm.owned = nil
+	xm.mu.Unlock()
+	var errs []error
+	for _, db := range owned {
+		if err := db.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "xa.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewXAManagerFromConfig(t *testing.T) {
+	// sqlmock 以 DSN 注册连接，配置里用 driver=sqlmock 打开；DSN 全局唯一，每次运行换一个
+	dsn1 := fmt.Sprintf("xa_config_db1_%d", time.Now().UnixNano())
+	dsn2 := fmt.Sprintf("xa_config_db2_%d", time.Now().UnixNano())
+	_, mock1, err := sqlmock.NewWithDSN(dsn1, sqlmock.QueryMatcherOption(matcher))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, mock2, err := sqlmock.NewWithDSN(dsn2, sqlmock.QueryMatcherOption(matcher))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := writeConfig(t, fmt.Sprintf(`{"branches": [
+		{"id": "db1", "name": "Database1", "driver": "sqlmock", "dsn": %q, "maxOpenConns": 4, "connMaxLifetime": "5m"},
+		{"id": "db2", "name": "Database2", "driver": "sqlmock", "dsn": %q}
+	]}`, dsn1, dsn2))
+
+	cfg, err := LoadXAConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := time.Duration(cfg.Branches[0].ConnMaxLifetime); got != 5*time.Minute {
+		t.Fatalf("connMaxLifetime = %v, want 5m", got)
+	}
+	xm, err := NewXAManagerFromConfig("gx", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ids := xm.branchIDs(); strings.Join(ids, ",") != "db1,db2" {
+		t.Fatalf("branches = %v, want db1,db2", ids)
+	}
+	if name := xm.branches["db2"].Name; name != "Database2" {
+		t.Fatalf("db2 name = %q", name)
+	}
+	if stats := xm.branches["db1"].DB.Stats(); stats.MaxOpenConnections != 4 {
+		t.Fatalf("db1 MaxOpenConnections = %d, want 4", stats.MaxOpenConnections)
+	}
+
+	// 每个分支连到各自的 DSN
+	mock1.ExpectExec("XA START 'gx,db1'").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock2.ExpectExec("XA START 'gx,db2'").WillReturnResult(sqlmock.NewResult(0, 0))
+	for _, id := range []string{"db1", "db2"} {
+		if err := xm.StartXA(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	xm.RollbackAll() // 释放固定连接，回滚语句没有期望，错误忽略
+	for _, mock := range []sqlmock.Sqlmock{mock1, mock2} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := xm.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadXAConfigValidation(t *testing.T) {
+	for _, tt := range []struct {
+		name, content, want string
+	}{
+		{"empty", `{"branches": []}`, "no branches"},
+		{"duplicate", `{"branches": [{"id": "db1", "dsn": "a"}, {"id": "db1", "dsn": "b"}]}`, "duplicate id"},
+		{"no dsn", `{"branches": [{"id": "db1"}]}`, "dsn is required"},
+		{"bad duration", `{"branches": [{"id": "db1", "dsn": "a", "connMaxLifetime": "soon"}]}`, "invalid duration"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadXAConfig(writeConfig(t, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// globalXIDPrefix NewGlobalXID 生成的全局事务ID前缀，后跟开始时间
+	globalXIDPrefix = "xa_tx_"
+	globalXIDLayout = "20060102150405"
+)
+
+// NewGlobalXID 按开始时间生成全局事务ID，/debug/xa 据此计算悬挂事务的时长
+func NewGlobalXID(start time.Time) string {
+	return globalXIDPrefix + start.Format(globalXIDLayout)
+}
+
+// RecoveredXID XA RECOVER 返回的一行。data 列是 gtrid 与 bqual 直接拼接的结果，
+// 必须按 gtrid_length/bqual_length 切分，整列当作 XID 会把 bqual 也算进去
+type RecoveredXID struct {
+	FormatID int
+	Gtrid    string
+	Bqual    string
+}
+
+func parseRecoveredXID(formatID, gtridLength, bqualLength int, data []byte) (RecoveredXID, error) {
+	if gtridLength < 0 || bqualLength < 0 || gtridLength+bqualLength != len(data) {
+		return RecoveredXID{}, fmt.Errorf("XA RECOVER: gtrid_length=%d bqual_length=%d but data has %d bytes",
+			gtridLength, bqualLength, len(data))
+	}
+	return RecoveredXID{
+		FormatID: formatID,
+		Gtrid:    string(data[:gtridLength]),
+		Bqual:    string(data[gtridLength:]),
+	}, nil
+}
+
+// SQL 返回可用于 XA COMMIT/XA ROLLBACK 的 XID 字面量
+func (x RecoveredXID) SQL() string {
+	if x.Bqual == "" && x.FormatID == 1 {
+		return fmt.Sprintf("'%s'", x.Gtrid)
+	}
+	return fmt.Sprintf("'%s','%s',%d", x.Gtrid, x.Bqual, x.FormatID)
+}
+
+// InDoubtXA 某个分支数据库上已 PREPARE 但未提交也未回滚的 XA 事务
+type InDoubtXA struct {
+	Branch     string     `json:"branch"`
+	BranchName string     `json:"branchName"`
+	XID        string     `json:"xid"`
+	Bqual      string     `json:"bqual,omitempty"`
+	FormatID   int        `json:"formatId"`
+	GlobalXID  string     `json:"globalXid,omitempty"` // XID 为本管理器的 "<全局ID>,<分支ID>" 格式时解析得到
+	StartedAt  *time.Time `json:"startedAt,omitempty"` // 全局ID 由 NewGlobalXID 生成时才有
+	AgeSeconds int64      `json:"ageSeconds,omitempty"`
+}
+
+// recoverBranch 在一个分支数据库上执行 XA RECOVER
+func (xm *XAManager) recoverBranch(ctx context.Context, branch *Branch) ([]RecoveredXID, error) {
+	rows, err := branch.DB.QueryContext(ctx, "XA RECOVER")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var xids []RecoveredXID
+	for rows.Next() {
+		var formatID, gtridLength, bqualLength int
+		var data []byte
+		if err := rows.Scan(&formatID, &gtridLength, &bqualLength, &data); err != nil {
+			return nil, err
+		}
+		xid, err := parseRecoveredXID(formatID, gtridLength, bqualLength, data)
+		if err != nil {
+			return nil, err
+		}
+		xids = append(xids, xid)
+	}
+	return xids, rows.Err()
+}
+
+// InDoubt 在所有分支上执行 XA RECOVER，返回悬挂的 XA 事务；
+// 单个分支查询失败不影响其他分支，失败原因按分支ID返回
+func (xm *XAManager) InDoubt(ctx context.Context) ([]InDoubtXA, map[string]error) {
+	now := xm.now()
+	txs := []InDoubtXA{}
+	errs := make(map[string]error)
+	for _, branchID := range xm.branchIDs() {
+		xm.mu.RLock()
+		branch := xm.branches[branchID]
+		xm.mu.RUnlock()
+
+		xids, err := xm.recoverBranch(ctx, branch)
+		if err != nil {
+			errs[branchID] = err
+			continue
+		}
+		for _, xid := range xids {
+			tx := InDoubtXA{
+				Branch:     branchID,
+				BranchName: branch.Name,
+				XID:        xid.Gtrid,
+				Bqual:      xid.Bqual,
+				FormatID:   xid.FormatID,
+			}
+			if global, _, ok := strings.Cut(xid.Gtrid, ","); ok {
+				tx.GlobalXID = global
+				if stamp, ok := strings.CutPrefix(global, globalXIDPrefix); ok {
+					if start, err := time.ParseInLocation(globalXIDLayout, stamp, time.Local); err == nil {
+						tx.StartedAt = &start
+						tx.AgeSeconds = int64(now.Sub(start) / time.Second)
+					}
+				}
+			}
+			txs = append(txs, tx)
+		}
+	}
+	return txs, errs
+}
+
+// debugXAResponse GET /debug/xa 响应体
+type debugXAResponse struct {
+	Transactions []InDoubtXA       `json:"transactions"`
+	Errors       map[string]string `json:"errors,omitempty"`
+}
+
+// DebugHandler 返回 GET /debug/xa 的处理器，列出各分支上悬挂的 XA 事务，
+// 运维不用登录 MySQL 执行 XA RECOVER 就能看到卡住的分支；所有分支都查询失败时返回 502
+func (xm *XAManager) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		txs, errs := xm.InDoubt(r.Context())
+		resp := debugXAResponse{Transactions: txs}
+		if len(errs) > 0 {
+			resp.Errors = make(map[string]string, len(errs))
+			for id, err := range errs {
+				resp.Errors[id] = err.Error()
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if len(errs) > 0 && len(errs) == len(xm.branchIDs()) {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+		json.NewEncoder(w).Encode(resp)
+	})
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestDebugXAListsPreparedBranch(t *testing.T) {
+	globalXID := NewGlobalXID(time.Now().Add(-2 * time.Minute))
+	xid := globalXID + ",db1"
+	recoverCols := []string{"formatID", "gtrid_length", "bqual_length", "data"}
+
+	db1, mock1 := newMock(t)
+	mock1.ExpectExec("XA START '" + xid + "'").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock1.ExpectExec("XA END '" + xid + "'").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock1.ExpectExec("XA PREPARE '" + xid + "'").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock1.ExpectQuery("XA RECOVER").WillReturnRows(sqlmock.NewRows(recoverCols).AddRow(1, len(xid), 0, []byte(xid)))
+
+	// 其他程序留下的 XID 带 bqual，data 列是 gtrid 与 bqual 拼接的结果
+	db2, mock2 := newMock(t)
+	mock2.ExpectQuery("XA RECOVER").WillReturnRows(sqlmock.NewRows(recoverCols).AddRow(7, 3, 2, []byte("fooba")))
+
+	xm := NewXAManager(globalXID)
+	xm.AddBranch("db1", "Database1", db1)
+	xm.AddBranch("db2", "Database2", db2)
+	if err := xm.StartXA("db1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := xm.EndAndPrepare("db1"); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	xm.DebugHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/xa", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	var resp debugXAResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Transactions) != 2 || len(resp.Errors) != 0 {
+		t.Fatalf("response = %+v, want two transactions", resp)
+	}
+
+	got := resp.Transactions[0]
+	if got.Branch != "db1" || got.BranchName != "Database1" || got.XID != xid || got.GlobalXID != globalXID {
+		t.Fatalf("prepared branch = %+v", got)
+	}
+	if got.AgeSeconds < 119 || got.StartedAt == nil {
+		t.Fatalf("age = %ds, startedAt = %v; want about 2m", got.AgeSeconds, got.StartedAt)
+	}
+	if other := resp.Transactions[1]; other.Branch != "db2" || other.XID != "foo" || other.Bqual != "ba" || other.FormatID != 7 || other.StartedAt != nil {
+		t.Fatalf("foreign xid = %+v, want gtrid foo bqual ba", other)
+	}
+	for _, mock := range []sqlmock.Sqlmock{mock1, mock2} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRecoveredXIDSQL(t *testing.T) {
+	if _, err := parseRecoveredXID(1, 4, 0, []byte("abc")); err == nil {
+		t.Fatal("length mismatch not reported")
+	}
+	for _, tt := range []struct {
+		xid  RecoveredXID
+		want string
+	}{
+		{RecoveredXID{FormatID: 1, Gtrid: "gx,db1"}, "'gx,db1'"},
+		{RecoveredXID{FormatID: 7, Gtrid: "foo", Bqual: "ba"}, "'foo','ba',7"},
+	} {
+		if got := tt.xid.SQL(); got != tt.want {
+			t.Errorf("SQL() = %s, want %s", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// Decision 全局事务的最终决定
+type Decision string
+
+const (
+	DecisionCommit   Decision = "commit"
+	DecisionRollback Decision = "rollback"
+)
+
+// DecisionLog 持久化全局事务的提交/回滚决定。同一全局事务只有第一次 Record 生效，
+// 返回值是最终生效的决定：提交前写入 commit，回滚前写入 rollback，
+// 两边都以返回值为准，XA RECOVER 找不到 XID 时才能据此判断分支是提交了还是被回滚了
+type DecisionLog interface {
+	Record(ctx context.Context, globalXID string, d Decision) (Decision, error)
+	// Lookup 查询已记录的决定，没有记录时 ok 为 false
+	Lookup(ctx context.Context, globalXID string) (d Decision, ok bool, err error)
+}
+
+var (
+	// ErrOutcomeUnknown XA RECOVER 找不到 XID，且决定日志中没有提交记录，无法判断分支是否已提交
+	ErrOutcomeUnknown = errors.New("XA branch outcome unknown")
+	// ErrCommitDecided 全局事务已决定提交，不能再回滚，剩余分支需要由 RecoverXA 提交
+	ErrCommitDecided = errors.New("XA transaction already decided to commit")
+	// ErrRollbackDecided 全局事务已被其他进程决定回滚，不能再提交
+	ErrRollbackDecided = errors.New("XA transaction already decided to roll back")
+)
+
+// decisionLogDDL 决定日志表，放在任一分支库上即可
+const decisionLogDDL = `CREATE TABLE IF NOT EXISTS xa_decision_log (
+	global_xid VARCHAR(64) NOT NULL PRIMARY KEY,
+	decision ENUM('commit','rollback') NOT NULL,
+	created_at DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3)
+)`
+
+// SQLDecisionLog 把决定写入 xa_decision_log 表
+type SQLDecisionLog struct {
+	DB *sql.DB
+}
+
+// NewSQLDecisionLog 建表并返回决定日志
+func NewSQLDecisionLog(ctx context.Context, db *sql.DB) (*SQLDecisionLog, error) {
+	if _, err := db.ExecContext(ctx, decisionLogDDL); err != nil {
+		return nil, fmt.Errorf("create xa_decision_log: %v", err)
+	}
+	return &SQLDecisionLog{DB: db}, nil
+}
+
+// Record 以主键保证只写入第一次决定，再读回生效的决定
+func (l *SQLDecisionLog) Record(ctx context.Context, globalXID string, d Decision) (Decision, error) {
+	if _, err := l.DB.ExecContext(ctx,
+		"INSERT IGNORE INTO xa_decision_log (global_xid, decision) VALUES (?, ?)", globalXID, string(d)); err != nil {
+		return "", fmt.Errorf("record %s decision for %s: %v", d, globalXID, err)
+	}
+	got, ok, err := l.Lookup(ctx, globalXID)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("record %s decision for %s: row missing after insert", d, globalXID)
+	}
+	return got, nil
+}
+
+func (l *SQLDecisionLog) Lookup(ctx context.Context, globalXID string) (Decision, bool, error) {
+	var d string
+	err := l.DB.QueryRowContext(ctx,
+		"SELECT decision FROM xa_decision_log WHERE global_xid = ?", globalXID).Scan(&d)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("lookup decision for %s: %v", globalXID, err)
+	}
+	return Decision(d), true, nil
+}
+
+// decide 记录本事务的决定并返回生效的决定；未配置 Decisions 时直接返回 d
+func (xm *XAManager) decide(globalXID string, d Decision) (Decision, error) {
+	if xm.Decisions == nil {
+		return d, nil
+	}
+	return xm.Decisions.Record(context.Background(), globalXID, d)
+}
+
+// resolve 结束另一个协调者留下的已 PREPARE 分支：先尝试记录回滚决定，
+// 该全局事务已决定提交时改为 XA COMMIT，返回实际执行的决定
+func (xm *XAManager) resolve(ctx context.Context, branch *Branch, xid RecoveredXID, globalXID string) (Decision, error) {
+	d, err := xm.decide(globalXID, DecisionRollback)
+	if err != nil {
+		return "", err
+	}
+	stmt := "XA ROLLBACK "
+	if d == DecisionCommit {
+		log.Printf("XA %s decided to commit, committing instead of rolling back", globalXID)
+		stmt = "XA COMMIT "
+	}
+	if _, err := branch.DB.ExecContext(ctx, stmt+xid.SQL()); err != nil {
+		return "", err
+	}
+	return d, nil
+}
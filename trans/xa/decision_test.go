@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// memDecisions 内存中的决定日志
+type memDecisions struct {
+	mu sync.Mutex
+	m  map[string]Decision
+}
+
+func (l *memDecisions) Record(_ context.Context, globalXID string, d Decision) (Decision, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.m == nil {
+		l.m = make(map[string]Decision)
+	}
+	if got, ok := l.m[globalXID]; ok {
+		return got, nil
+	}
+	l.m[globalXID] = d
+	return d, nil
+}
+
+func (l *memDecisions) Lookup(_ context.Context, globalXID string) (Decision, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	d, ok := l.m[globalXID]
+	return d, ok, nil
+}
+
+// commitLostAfterApply db1 的 XA COMMIT 已生效但连接在响应前断开：XID 不在 XA RECOVER 中
+func commitLostAfterApply(t *testing.T, decisions DecisionLog) error {
+	t.Helper()
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 10, 0, 0, 0, time.Local)}
+	xm, tracker := preparedManager(t, clock)
+	xm.Decisions = decisions
+	tracker.mu.Lock()
+	delete(tracker.prepared, xm.globalXID+",db1")
+	tracker.dead = 1
+	tracker.mu.Unlock()
+	return xm.CommitAll()
+}
+
+func TestRecoverCommitMissingXID(t *testing.T) {
+	if err := commitLostAfterApply(t, nil); !errors.Is(err, ErrOutcomeUnknown) {
+		t.Fatalf("without decision log: CommitAll = %v, want ErrOutcomeUnknown", err)
+	}
+	if err := commitLostAfterApply(t, &memDecisions{}); err != nil {
+		t.Fatalf("with commit decision: CommitAll = %v, want nil", err)
+	}
+}
+
+func TestRollbackHonoursCommitDecision(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 10, 0, 0, 0, time.Local)}
+	xm, tracker := preparedManager(t, clock)
+	decisions := &memDecisions{}
+	decisions.Record(context.Background(), xm.globalXID, DecisionCommit)
+	xm.Decisions = decisions
+
+	if err := xm.RollbackAll(); !errors.Is(err, ErrCommitDecided) {
+		t.Fatalf("RollbackAll = %v, want ErrCommitDecided", err)
+	}
+	xid := xm.globalXID + ",db1"
+	if slices.Contains(tracker.execs, "1 XA ROLLBACK '"+xid+"'") {
+		t.Fatalf("execs = %q, want no rollback", tracker.execs)
+	}
+
+	// 协调者退出后，恢复流程按决定提交而不是回滚
+	xm.RecoverXA()
+	if !slices.Contains(tracker.execs, "2 XA COMMIT '"+xid+"'") || len(tracker.prepared) != 0 {
+		t.Fatalf("execs = %q prepared = %v, want commit via recovery", tracker.execs, tracker.prepared)
+	}
+}
+
+func TestSQLDecisionLogFirstDecisionWins(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mock.ExpectExec("INSERT IGNORE INTO xa_decision_log").WithArgs("gx", "rollback").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT decision FROM xa_decision_log").WithArgs("gx").
+		WillReturnRows(sqlmock.NewRows([]string{"decision"}).AddRow("commit"))
+
+	l := &SQLDecisionLog{DB: db}
+	d, err := l.Record(context.Background(), "gx", DecisionRollback)
+	if err != nil || d != DecisionCommit {
+		t.Fatalf("Record = %q, %v; want commit", d, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io"
+	"log"
+	"test/trans/internal/phaselog"
+	"time"
+)
+
+// PhaseEvent 一个分支执行完一条 XA 阶段语句，Resource 为分支ID
+type PhaseEvent = phaselog.Event
+
+// Logger 接收每个阶段的结构化事件，XAManager.Logger 为 nil 时输出可读文本
+type Logger = phaselog.Logger
+
+type textLogger struct{}
+
+func (textLogger) LogPhase(ev PhaseEvent) {
+	if ev.Err != nil {
+		log.Printf("XA %s %s on %s failed after %v: %v", ev.TxID, ev.Phase, ev.Resource, ev.Duration, ev.Err)
+		return
+	}
+	log.Printf("XA %s %s on %s ok (%v)", ev.TxID, ev.Phase, ev.Resource, ev.Duration)
+}
+
+// NewJSONLogger 每个阶段输出一行 JSON，分支ID 输出为 branch 字段
+func NewJSONLogger(w io.Writer) *phaselog.JSONLogger {
+	return phaselog.NewJSONLogger(w, "xa phase", "branch")
+}
+
+// logPhase 记录 branchID 上从 start 开始的一个阶段
+func (xm *XAManager) logPhase(branchID, phase string, start time.Time, err error) {
+	ev := PhaseEvent{
+		TxID:     xm.globalXID,
+		Resource: branchID,
+		Phase:    phase,
+		Duration: time.Since(start),
+		Err:      err,
+	}
+	var l Logger = textLogger{}
+	if xm.Logger != nil {
+		l = xm.Logger
+	}
+	l.LogPhase(ev)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"testing"
+)
+
+func TestJSONLoggerCommitFields(t *testing.T) {
+	db1 := sql.OpenDB(&trackingDB{})
+	defer db1.Close()
+	db2 := sql.OpenDB(&trackingDB{})
+	defer db2.Close()
+
+	var buf bytes.Buffer
+	xm := NewXAManager("gx")
+	xm.Logger = NewJSONLogger(&buf)
+	xm.AddBranch("db1", "Database1", db1)
+	xm.AddBranch("db2", "Database2", db2)
+	xm.AddOperation("db1", "score", ExecuteScoreOperations)
+	xm.AddOperation("db2", "score", ExecuteScoreOperations)
+	if err := xm.ExecuteXA(); err != nil {
+		t.Fatal(err)
+	}
+
+	type line struct {
+		Level      string   `json:"level"`
+		TxID       string   `json:"txID"`
+		Branch     string   `json:"branch"`
+		Phase      string   `json:"phase"`
+		Outcome    string   `json:"outcome"`
+		DurationMs *float64 `json:"durationMs"`
+	}
+	phases := map[string][]string{}
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var l line
+		if err := dec.Decode(&l); err != nil {
+			t.Fatal(err)
+		}
+		if l.TxID != "gx" || l.Outcome != "ok" || l.Level != "INFO" || l.DurationMs == nil || *l.DurationMs < 0 {
+			t.Fatalf("line = %+v", l)
+		}
+		phases[l.Branch] = append(phases[l.Branch], l.Phase)
+	}
+	for _, branch := range []string{"db1", "db2"} {
+		if got := phases[branch]; len(got) != 3 || got[0] != "start" || got[1] != "prepare" || got[2] != "commit" {
+			t.Fatalf("%s phases = %v, want start/prepare/commit", branch, got)
+		}
+	}
+}
@@ -1,13 +1,23 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 )
 
 // XAContext 应用层事务上下文，用于在分支间传递数据
@@ -28,6 +38,72 @@ type Branch struct {
 	ID   string
 	DB   *sql.DB
 	Name string
+
+	// conn XA START 时从 DB 取出并固定的连接。XA START/END/PREPARE/COMMIT 都是连接级语句，
+	// 直接用 DB.Exec 时连接池可能每次给出不同的连接，业务 SQL 也就不在 XA 分支里
+	conn *sql.Conn
+	// state 分支在 MySQL 中的 XA 状态，由 XAManager.mu 保护，RollbackAll 据此决定要执行的语句
+	state BranchState
+}
+
+// BranchState 单个分支的 XA 状态
+type BranchState string
+
+const (
+	BranchIdle       BranchState = "idle"        // 未 XA START，没有需要回滚的内容
+	BranchActive     BranchState = "active"      // 已 XA START，回滚前需要先 XA END
+	BranchEnded      BranchState = "ended"       // 已 XA END 未 PREPARE，可直接 XA ROLLBACK
+	BranchPrepared   BranchState = "prepared"    // 已 PREPARE，持久化在服务端
+	BranchCommitted  BranchState = "committed"   // 已提交，不能再回滚
+	BranchRolledBack BranchState = "rolled_back" // 已回滚（包括 PREPARE 时被服务端回滚）
+)
+
+// OperationFunc 在分支固定的连接上执行的业务操作
+type OperationFunc func(conn *sql.Conn, ctx *XAContext) error
+
+// Operation 归属于某个分支的业务操作
+type Operation struct {
+	Name     string
+	BranchID string
+	Run      OperationFunc
+}
+
+// ErrUnknownBranch 操作引用了未注册的分支
+var ErrUnknownBranch = errors.New("unknown XA branch")
+
+// XAPhase 全局事务所处阶段
+type XAPhase string
+
+const (
+	PhaseIdle       XAPhase = "idle"        // 尚未开始
+	PhaseStarted    XAPhase = "started"     // 分支已 XA START，执行业务操作中
+	PhasePreparing  XAPhase = "preparing"   // 部分分支已 PREPARE
+	PhaseCommitting XAPhase = "committing"  // 正在提交
+	PhaseCommitted  XAPhase = "committed"   // 全部提交完成
+	PhaseRolledBack XAPhase = "rolled_back" // 已回滚
+)
+
+// XAState 全局事务状态快照
+type XAState struct {
+	Phase    XAPhase
+	Prepared int // 已 PREPARE 的分支数
+	Total    int // 分支总数
+}
+
+func (s XAState) String() string {
+	if s.Phase == PhasePreparing {
+		return fmt.Sprintf("%s %d/%d", s.Phase, s.Prepared, s.Total)
+	}
+	return string(s.Phase)
+}
+
+// Observer 订阅全局事务的阶段事件，回调在阶段切换时同步调用，不应阻塞
+type Observer interface {
+	OnBranchStarted(branchID string)
+	OnBranchPrepared(branchID string)
+	OnCommitStart()
+	OnCommitComplete()
+	OnRollback()
 }
 
 // XAManager 管理 XA 事务
@@ -36,6 +112,21 @@ type XAManager struct {
 	globalXID string
 	mu        sync.RWMutex
 	prepared  map[string]bool // 记录已准备的分支
+	phase     XAPhase
+	observer  Observer
+
+	operations []Operation // 按注册顺序执行，后面的操作可以使用前面写入 XAContext 的数据
+	owned      []*sql.DB   // NewXAManagerFromConfig 打开的数据库，Close 时关闭
+	reaped     bool        // 已被 ReapExpired 认领回滚，见 beginCommit/claimReap
+
+	// MaxTransactionAge 已 PREPARE 的事务最长存活时间，超过后由 ReapExpired 回滚，0 表示不限制
+	MaxTransactionAge time.Duration
+	// Clock MaxTransactionAge 判断使用的时间来源，nil 时使用系统时钟
+	Clock Clock
+	// Logger 各分支每个阶段的结构化日志，nil 时以文本输出到标准 log
+	Logger Logger
+	// Decisions 提交/回滚决定日志，nil 时不记录，XA RECOVER 找不到 XID 的分支无法判断结果
+	Decisions DecisionLog
 }
 
 // NewXAManager 初始化 XA 管理器
@@ -44,7 +135,42 @@ func NewXAManager(globalXID string) *XAManager {
 		branches:  make(map[string]*Branch),
 		globalXID: globalXID,
 		prepared:  make(map[string]bool),
+		phase:     PhaseIdle,
+	}
+}
+
+// SetObserver 设置阶段事件订阅者，nil 表示不订阅
+func (xm *XAManager) SetObserver(o Observer) {
+	xm.mu.Lock()
+	defer xm.mu.Unlock()
+	xm.observer = o
+}
+
+// State 返回全局事务当前状态
+func (xm *XAManager) State() XAState {
+	xm.mu.RLock()
+	defer xm.mu.RUnlock()
+	return XAState{Phase: xm.phase, Prepared: len(xm.prepared), Total: len(xm.branches)}
+}
+
+// setPhase 切换阶段并返回 observer，调用方在锁外回调
+func (xm *XAManager) setPhase(phase XAPhase) Observer {
+	xm.mu.Lock()
+	defer xm.mu.Unlock()
+	xm.phase = phase
+	return xm.observer
+}
+
+// branchIDs 按 ID 排序的分支列表，保证执行顺序稳定
+func (xm *XAManager) branchIDs() []string {
+	xm.mu.RLock()
+	defer xm.mu.RUnlock()
+	ids := make([]string, 0, len(xm.branches))
+	for id := range xm.branches {
+		ids = append(ids, id)
 	}
+	sort.Strings(ids)
+	return ids
 }
 
 // AddBranch 添加XA分支
@@ -54,14 +180,86 @@ func (xm *XAManager) AddBranch(id, name string, db *sql.DB) {
 	xm.mu.Lock()
 	defer xm.mu.Unlock()
 	xm.branches[id] = &Branch{
-		ID:   id,
-		DB:   db,
-		Name: name,
+		ID:    id,
+		DB:    db,
+		Name:  name,
+		state: BranchIdle,
+	}
+}
+
+// AddOperation 注册归属于 branchID 的业务操作，分支可以稍后再添加，ExecuteXA 时统一校验
+func (xm *XAManager) AddOperation(branchID, name string, run OperationFunc) {
+	xm.mu.Lock()
+	defer xm.mu.Unlock()
+	xm.operations = append(xm.operations, Operation{Name: name, BranchID: branchID, Run: run})
+}
+
+// validateOperations 检查每个操作的目标分支都已注册，返回操作列表副本
+func (xm *XAManager) validateOperations() ([]Operation, error) {
+	xm.mu.RLock()
+	defer xm.mu.RUnlock()
+	if len(xm.operations) == 0 {
+		return nil, errors.New("no XA operations registered")
+	}
+	for _, op := range xm.operations {
+		if _, ok := xm.branches[op.BranchID]; !ok {
+			return nil, fmt.Errorf("%w: operation %s targets branch %s", ErrUnknownBranch, op.Name, op.BranchID)
+		}
+	}
+	return append([]Operation(nil), xm.operations...), nil
+}
+
+// runOperations 按注册顺序在各自分支固定的连接上执行业务操作
+func (xm *XAManager) runOperations(ops []Operation, ctx *XAContext) error {
+	for _, op := range ops {
+		_, conn, err := xm.pinned(op.BranchID)
+		if err != nil {
+			return fmt.Errorf("operation %s: %w", op.Name, err)
+		}
+		if err := op.Run(conn, ctx); err != nil {
+			return fmt.Errorf("operation %s on %s: %w", op.Name, op.BranchID, err)
+		}
 	}
+	return nil
+}
+
+// pinned 返回分支及其在 XA START 时固定的连接
+func (xm *XAManager) pinned(branchID string) (*Branch, *sql.Conn, error) {
+	xm.mu.RLock()
+	defer xm.mu.RUnlock()
+	branch, exists := xm.branches[branchID]
+	if !exists {
+		return nil, nil, fmt.Errorf("branch %s not found", branchID)
+	}
+	if branch.conn == nil {
+		return nil, nil, fmt.Errorf("branch %s not started", branchID)
+	}
+	return branch, branch.conn, nil
+}
+
+// release 把分支固定的连接还回连接池。discard 为 true 时连接上可能残留 XA 状态，
+// 通过 Raw 返回 driver.ErrBadConn 让 database/sql 直接关闭它，不再交给后续请求
+func (xm *XAManager) release(branchID string, discard bool) {
+	xm.mu.Lock()
+	branch, exists := xm.branches[branchID]
+	var conn *sql.Conn
+	if exists {
+		conn, branch.conn = branch.conn, nil
+	}
+	xm.mu.Unlock()
+	if conn == nil {
+		return
+	}
+	if discard {
+		log.Printf("XA branch %s: discarding connection with unfinished XA state", branchID)
+		conn.Raw(func(any) error { return driver.ErrBadConn })
+	}
+	conn.Close()
 }
 
 // StartXA 开始 XA 事务
-func (xm *XAManager) StartXA(branchID string) error {
+func (xm *XAManager) StartXA(branchID string) (err error) {
+	defer func(start time.Time) { xm.logPhase(branchID, "start", start, err) }(time.Now())
 	xm.mu.RLock()
 	branch, exists := xm.branches[branchID]
 	xm.mu.RUnlock()
@@ -70,122 +268,375 @@ func (xm *XAManager) StartXA(branchID string) error {
 		return fmt.Errorf("branch %s not found", branchID)
 	}
 
-	xid := fmt.Sprintf("%s,%s", xm.globalXID, branchID)
-	_, err := branch.DB.Exec(fmt.Sprintf("XA START '%s'", xid))
+	conn, err := branch.DB.Conn(context.Background())
 	if err != nil {
+		return fmt.Errorf("XA START %s: get conn: %v", branchID, err)
+	}
+	xid := fmt.Sprintf("%s,%s", xm.globalXID, branchID)
+	if _, err := conn.ExecContext(context.Background(), fmt.Sprintf("XA START '%s'", xid)); err != nil {
+		conn.Close()
 		return fmt.Errorf("XA START %s: %v", branchID, err)
 	}
+	xm.mu.Lock()
+	branch.conn = conn
+	branch.state = BranchActive
+	xm.mu.Unlock()
+
+	if o := xm.setPhase(PhaseStarted); o != nil {
+		o.OnBranchStarted(branchID)
+	}
 	return nil
 }
 
 // EndAndPrepare 结束并准备XA分支
-func (xm *XAManager) EndAndPrepare(branchID string) error {
-	xm.mu.RLock()
-	branch, exists := xm.branches[branchID]
-	xm.mu.RUnlock()
-
-	if !exists {
-		return fmt.Errorf("branch %s not found", branchID)
+func (xm *XAManager) EndAndPrepare(branchID string) (err error) {
+	defer func(start time.Time) { xm.logPhase(branchID, "prepare", start, err) }(time.Now())
+	_, conn, err := xm.pinned(branchID)
+	if err != nil {
+		return err
 	}
 
 	xid := fmt.Sprintf("%s,%s", xm.globalXID, branchID)
 
 	// XA END
-	_, err := branch.DB.Exec(fmt.Sprintf("XA END '%s'", xid))
+	_, err = conn.ExecContext(context.Background(), fmt.Sprintf("XA END '%s'", xid))
 	if err != nil {
 		return fmt.Errorf("XA END %s: %v", branchID, err)
 	}
+	xm.setBranchState(branchID, BranchEnded)
 
 	// XA PREPARE
-	_, err = branch.DB.Exec(fmt.Sprintf("XA PREPARE '%s'", xid))
+	_, err = conn.ExecContext(context.Background(), fmt.Sprintf("XA PREPARE '%s'", xid))
 	if err != nil {
+		// XA_RB* 表示服务端已经回滚了该分支，之后再 XA ROLLBACK 只会报 XAER_NOTA
+		if isXARolledBack(err) {
+			xm.setBranchState(branchID, BranchRolledBack)
+		}
 		return fmt.Errorf("XA PREPARE %s: %v", branchID, err)
 	}
 
 	xm.mu.Lock()
 	xm.prepared[branchID] = true
+	xm.branches[branchID].state = BranchPrepared
+	xm.phase = PhasePreparing
+	o := xm.observer
 	xm.mu.Unlock()
 
+	if o != nil {
+		o.OnBranchPrepared(branchID)
+	}
+	return nil
+}
+
+// CommitOnePhase 单分支事务的一阶段提交：XA END 后直接 XA COMMIT ... ONE PHASE，省去 PREPARE
+func (xm *XAManager) CommitOnePhase(branchID string) (err error) {
+	defer func(start time.Time) { xm.logPhase(branchID, "commit_one_phase", start, err) }(time.Now())
+	_, conn, err := xm.pinned(branchID)
+	if err != nil {
+		return err
+	}
+
+	xid := fmt.Sprintf("%s,%s", xm.globalXID, branchID)
+
+	// XA END
+	_, err = conn.ExecContext(context.Background(), fmt.Sprintf("XA END '%s'", xid))
+	if err != nil {
+		return fmt.Errorf("XA END %s: %v", branchID, err)
+	}
+	xm.setBranchState(branchID, BranchEnded)
+
+	// XA COMMIT ONE PHASE
+	o, err := xm.beginCommit()
+	if err != nil {
+		return err
+	}
+	if o != nil {
+		o.OnCommitStart()
+	}
+	_, err = conn.ExecContext(context.Background(), fmt.Sprintf("XA COMMIT '%s' ONE PHASE", xid))
+	if err != nil {
+		return fmt.Errorf("XA COMMIT ONE PHASE %s: %v", branchID, err)
+	}
+	xm.setBranchState(branchID, BranchCommitted)
+	xm.release(branchID, false)
+
+	if o := xm.setPhase(PhaseCommitted); o != nil {
+		o.OnCommitComplete()
+	}
 	return nil
 }
 
 // CommitAll 提交所有已准备的分支
 func (xm *XAManager) CommitAll() error {
-	xm.mu.RLock()
-	defer xm.mu.RUnlock()
+	o, err := xm.beginCommit()
+	if err != nil {
+		return err
+	}
+	// 任何分支 XA COMMIT 之前先落下提交决定，之后的回滚方都会改为提交
+	d, err := xm.decide(xm.globalXID, DecisionCommit)
+	if err != nil {
+		return err
+	}
+	if d != DecisionCommit {
+		return ErrRollbackDecided
+	}
+	if o != nil {
+		o.OnCommitStart()
+	}
 
+	xm.mu.RLock()
+	var branchIDs []string
 	for branchID := range xm.prepared {
-		branch := xm.branches[branchID]
-		xid := fmt.Sprintf("%s,%s", xm.globalXID, branchID)
-		_, err := branch.DB.Exec(fmt.Sprintf("XA COMMIT '%s'", xid))
+		branchIDs = append(branchIDs, branchID)
+	}
+	xm.mu.RUnlock()
+
+	for _, branchID := range branchIDs {
+		_, conn, err := xm.pinned(branchID)
 		if err != nil {
-			return fmt.Errorf("XA COMMIT %s: %v", branchID, err)
+			return err
 		}
+		xid := fmt.Sprintf("%s,%s", xm.globalXID, branchID)
+		start := time.Now()
+		if _, err := conn.ExecContext(context.Background(), fmt.Sprintf("XA COMMIT '%s'", xid)); err != nil {
+			if !isBranchConnLost(err) {
+				xm.logPhase(branchID, "commit", start, err)
+				return fmt.Errorf("XA COMMIT %s: %v", branchID, err)
+			}
+			// PREPARE 之后的 XID 持久化在服务端，固定连接断开后换新连接找到它再提交
+			log.Printf("XA COMMIT %s: connection lost (%v), committing via XA RECOVER", branchID, err)
+			xm.release(branchID, true)
+			if rerr := xm.recoverCommit(branchID, xid); rerr != nil {
+				err = fmt.Errorf("XA COMMIT %s: %v; recover: %w", branchID, err, rerr)
+				xm.logPhase(branchID, "commit", start, err)
+				return err
+			}
+			xm.setBranchState(branchID, BranchCommitted)
+			xm.logPhase(branchID, "commit", start, nil)
+			continue
+		}
+		xm.setBranchState(branchID, BranchCommitted)
+		xm.release(branchID, false)
+		xm.logPhase(branchID, "commit", start, nil)
+	}
+
+	if o := xm.setPhase(PhaseCommitted); o != nil {
+		o.OnCommitComplete()
 	}
 	return nil
 }
 
-// RollbackAll 回滚所有分支
-func (xm *XAManager) RollbackAll() error {
+// isBranchConnLost 判断 XA COMMIT 失败是否因为固定连接已失效（实例重启、连接被断开），
+// 或服务端不认识该连接上的 XID（XAER_NOTA，常见于驱动自动重连后）。
+// 这类错误下已 PREPARE 的分支仍在服务端，可以在新连接上完成提交
+func isBranchConnLost(err error) bool {
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		return myErr.Number == mysqlErrXAERNota
+	}
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.As(err, &netErr)
+}
+
+// mysqlErrXAERNota ER_XAER_NOTA：Unknown XID
+const mysqlErrXAERNota = 1397
+
+// recoverAttempts 分支实例重启中时 XA RECOVER 的尝试次数，每次间隔 recoverBackoff
+var (
+	recoverAttempts = 3
+	recoverBackoff  = 500 * time.Millisecond
+)
+
+// recoverCommit 在分支的新连接上执行 XA RECOVER，找到 xid 后提交。
+// 找不到时分支可能已提交（断开前的 XA COMMIT 生效但没收到响应），也可能被其他进程回滚，
+// 只有决定日志里记录了提交才按已提交处理，否则返回 ErrOutcomeUnknown
+func (xm *XAManager) recoverCommit(branchID, xid string) error {
 	xm.mu.RLock()
-	defer xm.mu.RUnlock()
+	branch := xm.branches[branchID]
+	xm.mu.RUnlock()
+
+	var xids []RecoveredXID
+	var err error
+	for attempt := 1; attempt <= recoverAttempts; attempt++ {
+		if xids, err = xm.recoverBranch(context.Background(), branch); err == nil {
+			break
+		}
+		log.Printf("XA RECOVER %s attempt %d: %v", branchID, attempt, err)
+		if attempt < recoverAttempts {
+			time.Sleep(recoverBackoff)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	for _, x := range xids {
+		if x.Gtrid == xid && x.Bqual == "" {
+			_, err := branch.DB.Exec("XA COMMIT " + x.SQL())
+			return err
+		}
+	}
+	if xm.Decisions != nil {
+		d, ok, err := xm.Decisions.Lookup(context.Background(), xm.globalXID)
+		if err != nil {
+			return err
+		}
+		if ok && d == DecisionCommit {
+			log.Printf("XA COMMIT %s: %s not in XA RECOVER, already committed", branchID, xid)
+			return nil
+		}
+	}
+	return fmt.Errorf("%s not in XA RECOVER: %w", xid, ErrOutcomeUnknown)
+}
+
+// RollbackAll 回滚所有分支。按各分支的状态决定语句：未开始、已提交或已回滚的分支跳过，
+// 仍处于 active 的分支先 XA END 再 XA ROLLBACK，已 END 或已 PREPARE 的直接 XA ROLLBACK。
+// 有已 PREPARE 的分支时先记录回滚决定；事务已决定提交则不回滚，丢弃固定连接后返回 ErrCommitDecided，
+// 这些分支留给 RecoverXA 提交
+func (xm *XAManager) RollbackAll() error {
+	if xm.hasPrepared() {
+		d, err := xm.decide(xm.globalXID, DecisionRollback)
+		if err != nil {
+			return err
+		}
+		if d == DecisionCommit {
+			for _, branchID := range xm.branchIDs() {
+				xm.release(branchID, true)
+			}
+			return ErrCommitDecided
+		}
+	}
+	if o := xm.setPhase(PhaseRolledBack); o != nil {
+		defer o.OnRollback()
+	}
 
 	var lastErr error
-	for branchID := range xm.branches {
+	for _, branchID := range xm.branchIDs() {
+		xm.mu.RLock()
 		branch := xm.branches[branchID]
+		conn, state := branch.conn, branch.state
+		xm.mu.RUnlock()
+
+		switch state {
+		case BranchIdle, BranchCommitted, BranchRolledBack:
+			xm.release(branchID, false)
+			continue
+		}
+
+		// 已 XA START 的分支在固定连接上回滚，失败时连接上可能残留 XA 状态，丢弃而不是还回连接池
 		xid := fmt.Sprintf("%s,%s", xm.globalXID, branchID)
-		_, err := branch.DB.Exec(fmt.Sprintf("XA ROLLBACK '%s'", xid))
+		start := time.Now()
+		exec := func(query string) error {
+			if conn != nil {
+				_, err := conn.ExecContext(context.Background(), query)
+				return err
+			}
+			_, err := branch.DB.Exec(query)
+			return err
+		}
+		var err error
+		if state == BranchActive {
+			if err = exec(fmt.Sprintf("XA END '%s'", xid)); err != nil {
+				err = fmt.Errorf("XA END %s: %w", branchID, err)
+			}
+		}
+		if err == nil {
+			err = exec(fmt.Sprintf("XA ROLLBACK '%s'", xid))
+		}
 		if err != nil {
 			lastErr = err
-			log.Printf("XA ROLLBACK %s: %v", branchID, err)
+		} else {
+			xm.setBranchState(branchID, BranchRolledBack)
 		}
+		xm.logPhase(branchID, "rollback", start, err)
+		xm.release(branchID, err != nil)
 	}
 	return lastErr
 }
 
-// RecoverXA 恢复未完成的XA事务
-func (xm *XAManager) RecoverXA() error {
+// hasPrepared 是否有分支处于 PREPARE 状态
+func (xm *XAManager) hasPrepared() bool {
 	xm.mu.RLock()
 	defer xm.mu.RUnlock()
+	for _, branch := range xm.branches {
+		if branch.state == BranchPrepared {
+			return true
+		}
+	}
+	return false
+}
+
+// setBranchState 更新分支状态
+func (xm *XAManager) setBranchState(branchID string, state BranchState) {
+	xm.mu.Lock()
+	defer xm.mu.Unlock()
+	if branch, ok := xm.branches[branchID]; ok {
+		branch.state = state
+	}
+}
+
+// BranchStates 返回各分支当前的 XA 状态
+func (xm *XAManager) BranchStates() map[string]BranchState {
+	xm.mu.RLock()
+	defer xm.mu.RUnlock()
+	states := make(map[string]BranchState, len(xm.branches))
+	for id, branch := range xm.branches {
+		states[id] = branch.state
+	}
+	return states
+}
+
+// XA_RB* 错误码：PREPARE 等语句返回这些错误时服务端已回滚该分支
+const (
+	mysqlErrXARBRollback = 1402 // ER_XA_RBROLLBACK
+	mysqlErrXARBTimeout  = 1613 // ER_XA_RBTIMEOUT
+	mysqlErrXARBDeadlock = 1614 // ER_XA_RBDEADLOCK
+)
+
+func isXARolledBack(err error) bool {
+	var myErr *mysql.MySQLError
+	if !errors.As(err, &myErr) {
+		return false
+	}
+	switch myErr.Number {
+	case mysqlErrXARBRollback, mysqlErrXARBTimeout, mysqlErrXARBDeadlock:
+		return true
+	}
+	return false
+}
 
-	for branchID, branch := range xm.branches {
-		rows, err := branch.DB.Query("XA RECOVER")
+// RecoverXA 恢复未完成的XA事务
+func (xm *XAManager) RecoverXA() error {
+	for _, branchID := range xm.branchIDs() {
+		xm.mu.RLock()
+		branch := xm.branches[branchID]
+		xm.mu.RUnlock()
+
+		xids, err := xm.recoverBranch(context.Background(), branch)
 		if err != nil {
 			log.Printf("XA RECOVER failed for branch %s: %v", branchID, err)
 			continue
 		}
 
-		for rows.Next() {
-			var formatID, gtridLength, bqualLength int
-			var data []byte
-			err := rows.Scan(&formatID, &gtridLength, &bqualLength, &data)
-			if err != nil {
-				log.Printf("Scan XA RECOVER result failed: %v", err)
-				continue
-			}
-
+		for _, xid := range xids {
 			// 检查是否是我们的事务
-			xid := string(data)
-			if len(xid) > len(xm.globalXID) && xid[:len(xm.globalXID)] == xm.globalXID {
-				log.Printf("Found unfinished XA transaction: %s, rolling back", xid)
-				_, err := branch.DB.Exec(fmt.Sprintf("XA ROLLBACK '%s'", xid))
-				if err != nil {
-					log.Printf("XA ROLLBACK %s failed: %v", xid, err)
+			if len(xid.Gtrid) > len(xm.globalXID) && strings.HasPrefix(xid.Gtrid, xm.globalXID) {
+				log.Printf("Found unfinished XA transaction: %s, resolving", xid.Gtrid)
+				global, _, _ := strings.Cut(xid.Gtrid, ",")
+				if _, err := xm.resolve(context.Background(), branch, xid, global); err != nil {
+					log.Printf("XA resolve %s failed: %v", xid.Gtrid, err)
 				}
 			}
 		}
-		rows.Close()
 	}
 	return nil
 }
 
 // ExecuteUserOperations 执行用户相关操作
-func (xm *XAManager) ExecuteUserOperations(ctx *XAContext) error {
-	branchID := "db1"
-	branch := xm.branches[branchID]
-
+func ExecuteUserOperations(conn *sql.Conn, ctx *XAContext) error {
 	// 插入用户
-	result, err := branch.DB.Exec(
+	result, err := conn.ExecContext(context.Background(),
 		"INSERT INTO user (name, age, detail, created_at) VALUES (?, ?, ?, ?)",
 		ctx.UserName, ctx.Age, ctx.Detail, time.Now(),
 	)
@@ -201,7 +652,7 @@ func (xm *XAManager) ExecuteUserOperations(ctx *XAContext) error {
 	ctx.UserID = userID
 
 	// 插入用户信息
-	_, err = branch.DB.Exec(
+	_, err = conn.ExecContext(context.Background(),
 		"INSERT INTO userinfo (user_id, phone, address, created_at) VALUES (?, ?, ?, ?)",
 		ctx.UserID, ctx.Phone, ctx.Address, time.Now(),
 	)
@@ -213,12 +664,9 @@ func (xm *XAManager) ExecuteUserOperations(ctx *XAContext) error {
 }
 
 // ExecuteScoreOperations 执行积分相关操作
-func (xm *XAManager) ExecuteScoreOperations(ctx *XAContext) error {
-	branchID := "db2"
-	branch := xm.branches[branchID]
-
+func ExecuteScoreOperations(conn *sql.Conn, ctx *XAContext) error {
 	// 插入积分
-	_, err := branch.DB.Exec(
+	_, err := conn.ExecContext(context.Background(),
 		"INSERT INTO score (user_id, points, created_at) VALUES (?, ?, ?)",
 		ctx.UserID, ctx.Points, time.Now(),
 	)
@@ -227,7 +675,7 @@ func (xm *XAManager) ExecuteScoreOperations(ctx *XAContext) error {
 	}
 
 	// 插入邮件
-	_, err = branch.DB.Exec(
+	_, err = conn.ExecContext(context.Background(),
 		"INSERT INTO email (user_id, email_content, created_at) VALUES (?, ?, ?)",
 		ctx.UserID, ctx.Email, time.Now(),
 	)
@@ -240,6 +688,12 @@ func (xm *XAManager) ExecuteScoreOperations(ctx *XAContext) error {
 
 // ExecuteXA 执行 XA 事务
 func (xm *XAManager) ExecuteXA() error {
+	// 启动分支前校验操作与分支的对应关系，失败时不产生任何 XA 语句
+	ops, err := xm.validateOperations()
+	if err != nil {
+		return err
+	}
+
 	// 创建事务上下文
 	ctx := &XAContext{
 		GlobalXID: xm.globalXID,
@@ -253,35 +707,35 @@ func (xm *XAManager) ExecuteXA() error {
 	}
 
 	// 启动所有XA分支
-	for branchID := range xm.branches {
+	branchIDs := xm.branchIDs()
+	for _, branchID := range branchIDs {
 		if err := xm.StartXA(branchID); err != nil {
 			xm.RollbackAll()
 			return err
 		}
 	}
 
-	// 执行db1操作
-	if err := xm.ExecuteUserOperations(ctx); err != nil {
-		xm.RollbackAll()
-		return err
-	}
-
-	// 结束并准备db1分支
-	if err := xm.EndAndPrepare("db1"); err != nil {
+	// 按注册顺序执行各分支的业务操作
+	if err := xm.runOperations(ops, ctx); err != nil {
 		xm.RollbackAll()
 		return err
 	}
 
-	// 执行db2操作
-	if err := xm.ExecuteScoreOperations(ctx); err != nil {
-		xm.RollbackAll()
-		return err
+	// 只有一个分支时不需要两阶段提交
+	if len(branchIDs) == 1 {
+		if err := xm.CommitOnePhase(branchIDs[0]); err != nil {
+			xm.RollbackAll()
+			return err
+		}
+		return nil
 	}
 
-	// 结束并准备db2分支
-	if err := xm.EndAndPrepare("db2"); err != nil {
-		xm.RollbackAll()
-		return err
+	// 结束并准备所有分支
+	for _, branchID := range branchIDs {
+		if err := xm.EndAndPrepare(branchID); err != nil {
+			xm.RollbackAll()
+			return err
+		}
 	}
 
 	// 提交所有分支
@@ -294,26 +748,48 @@ func (xm *XAManager) ExecuteXA() error {
 }
 
 func main() {
-	// 连接两个 MySQL 实例
-	db1, err := sql.Open("mysql", "root:123456@tcp(localhost:3306)/test_db?parseTime=true")
+	debugAddr := flag.String("debug", "", "在该地址提供 GET /debug/xa，如 :6060")
+	logFormat := flag.String("log-format", "text", "阶段日志格式：text 或 json")
+	configPath := flag.String("config", "xa.json", "分支配置文件，见 XAConfig")
+	flag.Parse()
+
+	// 按配置文件连接各分支数据库并注册分支
+	cfg, err := LoadXAConfig(*configPath)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer db1.Close()
 
-	db2, err := sql.Open("mysql", "root:123456@tcp(localhost:3307)/test_db?parseTime=true")
+	// 创建 XA 管理器
+	globalXID := NewGlobalXID(time.Now())
+	xm, err := NewXAManagerFromConfig(globalXID, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer xm.Close()
+	if *logFormat == "json" {
+		xm.Logger = NewJSONLogger(os.Stdout)
+	}
+	// 决定日志放在第一个分支库上
+	xm.Decisions, err = NewSQLDecisionLog(context.Background(), xm.branches[cfg.Branches[0].ID].DB)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer db2.Close()
 
-	// 创建 XA 管理器
-	globalXID := "xa_tx_" + time.Now().Format("20060102150405")
-	xm := NewXAManager(globalXID)
+	// 注册各分支负责的业务操作：用户数据在 db1，积分和邮件在 db2
+	xm.AddOperation("db1", "user", ExecuteUserOperations)
+	xm.AddOperation("db2", "score", ExecuteScoreOperations)
 
-	// 添加分支
-	xm.AddBranch("db1", "Database1", db1)
-	xm.AddBranch("db2", "Database2", db2)
+	// 调试端点先于恢复和事务启动，卡在 XA RECOVER 或提交阶段时也能查看悬挂事务
+	if *debugAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("GET /debug/xa", xm.DebugHandler())
+		log.Printf("XA debug endpoint listening on %s: GET /debug/xa", *debugAddr)
+		go func() { log.Fatal(http.ListenAndServe(*debugAddr, mux)) }()
+	}
+
+	// 已 PREPARE 超过 5 分钟仍未提交的事务（如协调者挂起）自动回滚，释放分支上的锁
+	xm.MaxTransactionAge = 5 * time.Minute
+	xm.StartReaper(context.Background(), time.Minute)
 
 	// 恢复未完成的事务
 	if err := xm.RecoverXA(); err != nil {
@@ -325,4 +801,9 @@ func main() {
 		log.Fatal("XA failed:", err)
 	}
 	fmt.Println("XA transaction completed successfully")
+
+	// 开启调试端点时事务完成后继续提供服务
+	if *debugAddr != "" {
+		select {}
+	}
 }
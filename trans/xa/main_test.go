@@ -0,0 +1,539 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+)
+
+// matcher XA 语句精确匹配，以便区分 XA COMMIT 和 XA COMMIT ... ONE PHASE；
+// 期望只写了语句前缀（如 "XA START"）时按前缀匹配
+var matcher = sqlmock.QueryMatcherFunc(func(expected, actual string) error {
+	if actual == expected || (!strings.Contains(expected, "'") && strings.HasPrefix(actual, expected+" ")) {
+		return nil
+	}
+	return fmt.Errorf("query %q does not match %q", actual, expected)
+})
+
+func newMock(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(matcher))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, mock
+}
+
+const (
+	insertUser     = "INSERT INTO user (name, age, detail, created_at) VALUES (?, ?, ?, ?)"
+	insertUserInfo = "INSERT INTO userinfo (user_id, phone, address, created_at) VALUES (?, ?, ?, ?)"
+	insertScore    = "INSERT INTO score (user_id, points, created_at) VALUES (?, ?, ?)"
+	insertEmail    = "INSERT INTO email (user_id, email_content, created_at) VALUES (?, ?, ?)"
+)
+
+func TestExecuteXAOnePhase(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectExec("XA START 'gx,db1'").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(insertUser).WillReturnResult(sqlmock.NewResult(7, 1))
+	mock.ExpectExec(insertUserInfo).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("XA END 'gx,db1'").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("XA COMMIT 'gx,db1' ONE PHASE").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	xm := NewXAManager("gx")
+	xm.AddBranch("db1", "Database1", db)
+	xm.AddOperation("db1", "user", ExecuteUserOperations)
+	if err := xm.ExecuteXA(); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExecuteXATwoPhase(t *testing.T) {
+	db1, mock1 := newMock(t)
+	mock1.ExpectExec("XA START 'gx,db1'").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock1.ExpectExec(insertUser).WillReturnResult(sqlmock.NewResult(7, 1))
+	mock1.ExpectExec(insertUserInfo).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock1.ExpectExec("XA END 'gx,db1'").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock1.ExpectExec("XA PREPARE 'gx,db1'").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock1.ExpectExec("XA COMMIT 'gx,db1'").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	db2, mock2 := newMock(t)
+	mock2.ExpectExec("XA START 'gx,db2'").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock2.ExpectExec(insertScore).WithArgs(int64(7), 100, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock2.ExpectExec(insertEmail).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock2.ExpectExec("XA END 'gx,db2'").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock2.ExpectExec("XA PREPARE 'gx,db2'").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock2.ExpectExec("XA COMMIT 'gx,db2'").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	xm := NewXAManager("gx")
+	xm.AddBranch("db1", "Database1", db1)
+	xm.AddBranch("db2", "Database2", db2)
+	xm.AddOperation("db1", "user", ExecuteUserOperations)
+	xm.AddOperation("db2", "score", ExecuteScoreOperations)
+	if err := xm.ExecuteXA(); err != nil {
+		t.Fatal(err)
+	}
+	for _, mock := range []sqlmock.Sqlmock{mock1, mock2} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestExecuteXAThreeBranches(t *testing.T) {
+	const insertAudit = "INSERT INTO audit_log (user_id, action) VALUES (?, ?)"
+
+	users, mockUsers := newMock(t)
+	mockUsers.ExpectExec("XA START 'gx,users'").WillReturnResult(sqlmock.NewResult(0, 0))
+	mockUsers.ExpectExec(insertUser).WillReturnResult(sqlmock.NewResult(7, 1))
+	mockUsers.ExpectExec(insertUserInfo).WillReturnResult(sqlmock.NewResult(1, 1))
+	mockUsers.ExpectExec("XA END 'gx,users'").WillReturnResult(sqlmock.NewResult(0, 0))
+	mockUsers.ExpectExec("XA PREPARE 'gx,users'").WillReturnResult(sqlmock.NewResult(0, 0))
+	mockUsers.ExpectExec("XA COMMIT 'gx,users'").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	scores, mockScores := newMock(t)
+	mockScores.ExpectExec("XA START 'gx,scores'").WillReturnResult(sqlmock.NewResult(0, 0))
+	mockScores.ExpectExec(insertScore).WithArgs(int64(7), 100, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mockScores.ExpectExec(insertEmail).WillReturnResult(sqlmock.NewResult(1, 1))
+	mockScores.ExpectExec("XA END 'gx,scores'").WillReturnResult(sqlmock.NewResult(0, 0))
+	mockScores.ExpectExec("XA PREPARE 'gx,scores'").WillReturnResult(sqlmock.NewResult(0, 0))
+	mockScores.ExpectExec("XA COMMIT 'gx,scores'").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	audit, mockAudit := newMock(t)
+	mockAudit.ExpectExec("XA START 'gx,audit'").WillReturnResult(sqlmock.NewResult(0, 0))
+	mockAudit.ExpectExec(insertAudit).WithArgs(int64(7), "register").WillReturnResult(sqlmock.NewResult(1, 1))
+	mockAudit.ExpectExec("XA END 'gx,audit'").WillReturnResult(sqlmock.NewResult(0, 0))
+	mockAudit.ExpectExec("XA PREPARE 'gx,audit'").WillReturnResult(sqlmock.NewResult(0, 0))
+	mockAudit.ExpectExec("XA COMMIT 'gx,audit'").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	xm := NewXAManager("gx")
+	xm.AddBranch("users", "UserDB", users)
+	xm.AddBranch("scores", "ScoreDB", scores)
+	xm.AddBranch("audit", "AuditDB", audit)
+	xm.AddOperation("users", "user", ExecuteUserOperations)
+	xm.AddOperation("scores", "score", ExecuteScoreOperations)
+	xm.AddOperation("audit", "audit", func(conn *sql.Conn, ctx *XAContext) error {
+		_, err := conn.ExecContext(context.Background(), insertAudit, ctx.UserID, "register")
+		return err
+	})
+
+	if err := xm.ExecuteXA(); err != nil {
+		t.Fatal(err)
+	}
+	if got := xm.State(); got.Phase != PhaseCommitted || got.Total != 3 {
+		t.Fatalf("state = %+v, want committed with 3 branches", got)
+	}
+	for _, mock := range []sqlmock.Sqlmock{mockUsers, mockScores, mockAudit} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestExecuteXAUnknownBranch(t *testing.T) {
+	db, mock := newMock(t) // 不设置任何期望：校验失败时不应执行 SQL
+
+	xm := NewXAManager("gx")
+	xm.AddBranch("db1", "Database1", db)
+	xm.AddOperation("db1", "user", ExecuteUserOperations)
+	xm.AddOperation("db2", "score", ExecuteScoreOperations)
+
+	err := xm.ExecuteXA()
+	if !errors.Is(err, ErrUnknownBranch) {
+		t.Fatalf("err = %v, want ErrUnknownBranch", err)
+	}
+	if !strings.Contains(err.Error(), "score") || !strings.Contains(err.Error(), "db2") {
+		t.Fatalf("err = %v, want operation and branch names", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type recordingObserver struct {
+	xm     *XAManager
+	events []string
+}
+
+func (r *recordingObserver) record(event string) {
+	r.events = append(r.events, event+" ["+r.xm.State().String()+"]")
+}
+
+func (r *recordingObserver) OnBranchStarted(branchID string)  { r.record("started " + branchID) }
+func (r *recordingObserver) OnBranchPrepared(branchID string) { r.record("prepared " + branchID) }
+func (r *recordingObserver) OnCommitStart()                   { r.record("commit") }
+func (r *recordingObserver) OnCommitComplete()                { r.record("done") }
+func (r *recordingObserver) OnRollback()                      { r.record("rollback") }
+
+func TestObserverEventSequence(t *testing.T) {
+	db1, mock1 := newMock(t)
+	db2, mock2 := newMock(t)
+	for _, m := range []sqlmock.Sqlmock{mock1, mock2} {
+		m.MatchExpectationsInOrder(false)
+		m.ExpectExec("XA START").WillReturnResult(sqlmock.NewResult(0, 0))
+		m.ExpectExec("XA END").WillReturnResult(sqlmock.NewResult(0, 0))
+		m.ExpectExec("XA PREPARE").WillReturnResult(sqlmock.NewResult(0, 0))
+		m.ExpectExec("XA COMMIT").WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock1.ExpectExec(insertUser).WillReturnResult(sqlmock.NewResult(7, 1))
+	mock1.ExpectExec(insertUserInfo).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock2.ExpectExec(insertScore).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock2.ExpectExec(insertEmail).WillReturnResult(sqlmock.NewResult(1, 1))
+
+	xm := NewXAManager("gx")
+	xm.AddBranch("db1", "Database1", db1)
+	xm.AddBranch("db2", "Database2", db2)
+	xm.AddOperation("db1", "user", ExecuteUserOperations)
+	xm.AddOperation("db2", "score", ExecuteScoreOperations)
+	obs := &recordingObserver{xm: xm}
+	xm.SetObserver(obs)
+
+	if got := xm.State().Phase; got != PhaseIdle {
+		t.Fatalf("initial phase = %s", got)
+	}
+	if err := xm.ExecuteXA(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"started db1 [started]",
+		"started db2 [started]",
+		"prepared db1 [preparing 1/2]",
+		"prepared db2 [preparing 2/2]",
+		"commit [committing]",
+		"done [committed]",
+	}
+	if len(obs.events) != len(want) {
+		t.Fatalf("events = %q, want %q", obs.events, want)
+	}
+	for i := range want {
+		if obs.events[i] != want[i] {
+			t.Fatalf("event %d = %q, want %q", i, obs.events[i], want[i])
+		}
+	}
+}
+
+// trackingDB 给每个物理连接编号并记录每条语句由哪个连接执行的测试驱动；
+// failOn 匹配的语句返回错误，dead 编号的连接上所有语句返回 connection refused（模拟实例重启）。
+// prepared 模拟服务端持久化的已 PREPARE XID，XA RECOVER 返回其中的全部 XID，跨连接可见
+type trackingDB struct {
+	mu       sync.Mutex
+	next     int
+	execs    []string // "连接号 语句"
+	closed   []int
+	failOn   string
+	dead     int
+	prepared map[string]bool
+}
+
+var errConnRefused = &net.OpError{Op: "write", Net: "tcp", Err: syscall.ECONNREFUSED}
+
+func (d *trackingDB) Connect(context.Context) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.next++
+	return &trackingConn{db: d, id: d.next}, nil
+}
+func (d *trackingDB) Driver() driver.Driver { return d }
+func (d *trackingDB) Open(string) (driver.Conn, error) {
+	return d.Connect(context.Background())
+}
+
+type trackingConn struct {
+	db *trackingDB
+	id int
+}
+
+func (c *trackingConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *trackingConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+func (c *trackingConn) Close() error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.closed = append(c.db.closed, c.id)
+	return nil
+}
+
+func (c *trackingConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.execs = append(c.db.execs, fmt.Sprintf("%d %s", c.id, query))
+	if c.id == c.db.dead {
+		return nil, errConnRefused
+	}
+	if c.db.failOn != "" && strings.HasPrefix(query, c.db.failOn) {
+		return nil, errors.New("injected failure")
+	}
+	if xid, ok := strings.CutPrefix(query, "XA PREPARE "); ok {
+		if c.db.prepared == nil {
+			c.db.prepared = make(map[string]bool)
+		}
+		c.db.prepared[strings.Trim(xid, "'")] = true
+	}
+	if xid, ok := strings.CutPrefix(query, "XA COMMIT "); ok {
+		delete(c.db.prepared, strings.Trim(xid, "'"))
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *trackingConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.execs = append(c.db.execs, fmt.Sprintf("%d %s", c.id, query))
+	if query != "XA RECOVER" {
+		return nil, errors.New("not supported")
+	}
+	rows := &recoverRows{}
+	for xid := range c.db.prepared {
+		rows.xids = append(rows.xids, xid)
+	}
+	return rows, nil
+}
+
+// recoverRows XA RECOVER 结果：formatID=1，bqual 为空
+type recoverRows struct{ xids []string }
+
+func (r *recoverRows) Columns() []string {
+	return []string{"formatID", "gtrid_length", "bqual_length", "data"}
+}
+func (r *recoverRows) Close() error { return nil }
+func (r *recoverRows) Next(dest []driver.Value) error {
+	if len(r.xids) == 0 {
+		return io.EOF
+	}
+	xid := r.xids[0]
+	r.xids = r.xids[1:]
+	dest[0], dest[1], dest[2], dest[3] = int64(1), int64(len(xid)), int64(0), []byte(xid)
+	return nil
+}
+
+func TestBranchPinsOneConnection(t *testing.T) {
+	tracker := &trackingDB{}
+	db := sql.OpenDB(tracker)
+	defer db.Close()
+	// 不保留空闲连接：没有固定连接时每条语句都会拿到新的物理连接
+	db.SetMaxIdleConns(0)
+
+	xm := NewXAManager("gx")
+	xm.AddBranch("db1", "Database1", db)
+	xm.AddOperation("db1", "score", ExecuteScoreOperations)
+	other := sql.OpenDB(&trackingDB{})
+	defer other.Close()
+	xm.AddBranch("db2", "Database2", other)
+	xm.AddOperation("db2", "score", ExecuteScoreOperations)
+	if err := xm.ExecuteXA(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"1 XA START 'gx,db1'",
+		"1 " + insertScore,
+		"1 " + insertEmail,
+		"1 XA END 'gx,db1'",
+		"1 XA PREPARE 'gx,db1'",
+		"1 XA COMMIT 'gx,db1'",
+	}
+	if strings.Join(tracker.execs, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("execs = %q, want %q", tracker.execs, want)
+	}
+	// 提交后连接还回连接池，MaxIdleConns=0 时随即关闭
+	if len(tracker.closed) != 1 || tracker.closed[0] != 1 {
+		t.Fatalf("closed connections = %v, want [1]", tracker.closed)
+	}
+}
+
+func TestRollbackDiscardsFailedConnection(t *testing.T) {
+	tracker := &trackingDB{failOn: "INSERT INTO email"}
+	db := sql.OpenDB(tracker)
+	defer db.Close()
+
+	xm := NewXAManager("gx")
+	xm.AddBranch("db1", "Database1", db)
+	xm.AddOperation("db1", "score", ExecuteScoreOperations)
+	if err := xm.ExecuteXA(); err == nil {
+		t.Fatal("ExecuteXA succeeded, want error")
+	}
+
+	// 回滚在同一连接上执行；驱动接受了 XA ROLLBACK，连接还回连接池而不是被丢弃
+	if last := tracker.execs[len(tracker.execs)-1]; last != "1 XA ROLLBACK 'gx,db1'" {
+		t.Fatalf("last exec = %q, want rollback on connection 1", last)
+	}
+	if len(tracker.closed) != 0 {
+		t.Fatalf("closed connections = %v, want none", tracker.closed)
+	}
+
+	// XA ROLLBACK 也失败时连接可能残留 XA 状态，必须关闭
+	tracker.failOn = "XA ROLLBACK"
+	xm2 := NewXAManager("gx2")
+	xm2.AddBranch("db1", "Database1", db)
+	xm2.AddOperation("db1", "fail", func(*sql.Conn, *XAContext) error {
+		return errors.New("business failure")
+	})
+	if err := xm2.ExecuteXA(); err == nil {
+		t.Fatal("ExecuteXA succeeded, want error")
+	}
+	if len(tracker.closed) != 1 || tracker.closed[0] != 1 {
+		t.Fatalf("closed connections = %v, want [1]", tracker.closed)
+	}
+}
+
+func TestCommitAllRecoversAfterConnectionLost(t *testing.T) {
+	tracker := &trackingDB{}
+	db := sql.OpenDB(tracker)
+	defer db.Close()
+	other := sql.OpenDB(&trackingDB{})
+	defer other.Close()
+
+	xm := NewXAManager("gx")
+	xm.AddBranch("db1", "Database1", db)
+	xm.AddBranch("db2", "Database2", other)
+	for _, id := range []string{"db1", "db2"} {
+		if err := xm.StartXA(id); err != nil {
+			t.Fatal(err)
+		}
+		if err := xm.EndAndPrepare(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// db1 在 PREPARE 之后重启：固定的连接 1 已失效，XID 仍持久化在服务端
+	tracker.mu.Lock()
+	tracker.dead = 1
+	tracker.mu.Unlock()
+	if err := xm.CommitAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"1 XA COMMIT 'gx,db1'",
+		"2 XA RECOVER",
+		"2 XA COMMIT 'gx,db1'",
+	}
+	if got := tracker.execs[len(tracker.execs)-3:]; strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("execs = %q, want %q", got, want)
+	}
+	if len(tracker.prepared) != 0 {
+		t.Fatalf("prepared xids left: %v", tracker.prepared)
+	}
+	// 失效的连接被丢弃
+	if len(tracker.closed) == 0 || tracker.closed[0] != 1 {
+		t.Fatalf("closed connections = %v, want 1 first", tracker.closed)
+	}
+	if xm.State().Phase != PhaseCommitted {
+		t.Fatalf("phase = %s, want committed", xm.State())
+	}
+}
+
+func TestIsBranchConnLost(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want bool
+	}{
+		{errConnRefused, true},
+		{driver.ErrBadConn, true},
+		{&mysql.MySQLError{Number: 1397, Message: "XAER_NOTA: Unknown XID"}, true},
+		{&mysql.MySQLError{Number: 1399, Message: "XAER_RMFAIL"}, false},
+		{errors.New("injected failure"), false},
+	} {
+		if got := isBranchConnLost(tt.err); got != tt.want {
+			t.Errorf("isBranchConnLost(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRollbackAfterPartialPrepare(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		prepareErr  error
+		rollbackDB2 bool // db2 的 PREPARE 失败后是否还需要 XA ROLLBACK
+	}{
+		// 服务端已因死锁回滚了 db2，再 XA ROLLBACK 只会报 XAER_NOTA
+		{"server rolled back", &mysql.MySQLError{Number: 1614, Message: "XA_RBDEADLOCK"}, false},
+		// db2 已 XA END，停留在 IDLE 状态，可以直接回滚
+		{"left idle", errors.New("prepare failed"), true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			db1, mock1 := newMock(t)
+			mock1.ExpectExec("XA START 'gx,db1'").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock1.ExpectExec("XA END 'gx,db1'").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock1.ExpectExec("XA PREPARE 'gx,db1'").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock1.ExpectExec("XA ROLLBACK 'gx,db1'").WillReturnResult(sqlmock.NewResult(0, 0))
+
+			db2, mock2 := newMock(t)
+			mock2.ExpectExec("XA START 'gx,db2'").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock2.ExpectExec("XA END 'gx,db2'").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock2.ExpectExec("XA PREPARE 'gx,db2'").WillReturnError(tt.prepareErr)
+			if tt.rollbackDB2 {
+				mock2.ExpectExec("XA ROLLBACK 'gx,db2'").WillReturnResult(sqlmock.NewResult(0, 0))
+			}
+
+			xm := NewXAManager("gx")
+			xm.AddBranch("db1", "Database1", db1)
+			xm.AddBranch("db2", "Database2", db2)
+			for _, id := range []string{"db1", "db2"} {
+				if err := xm.StartXA(id); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := xm.EndAndPrepare("db1"); err != nil {
+				t.Fatal(err)
+			}
+			if err := xm.EndAndPrepare("db2"); err == nil {
+				t.Fatal("db2 prepare succeeded, want error")
+			}
+
+			if err := xm.RollbackAll(); err != nil {
+				t.Fatalf("RollbackAll = %v, want no error", err)
+			}
+			for id, state := range xm.BranchStates() {
+				if state != BranchRolledBack {
+					t.Fatalf("%s state = %s, want rolled_back", id, state)
+				}
+			}
+			for _, mock := range []sqlmock.Sqlmock{mock1, mock2} {
+				if err := mock.ExpectationsWereMet(); err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestRollbackEndsActiveAndSkipsIdleBranches(t *testing.T) {
+	db1, mock1 := newMock(t)
+	mock1.ExpectExec("XA START 'gx,db1'").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock1.ExpectExec("XA END 'gx,db1'").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock1.ExpectExec("XA ROLLBACK 'gx,db1'").WillReturnResult(sqlmock.NewResult(0, 0))
+	// db2 从未 XA START，不应收到任何语句
+	db2, mock2 := newMock(t)
+
+	xm := NewXAManager("gx")
+	xm.AddBranch("db1", "Database1", db1)
+	xm.AddBranch("db2", "Database2", db2)
+	if err := xm.StartXA("db1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := xm.RollbackAll(); err != nil {
+		t.Fatal(err)
+	}
+	if states := xm.BranchStates(); states["db1"] != BranchRolledBack || states["db2"] != BranchIdle {
+		t.Fatalf("states = %v", states)
+	}
+	for _, mock := range []sqlmock.Sqlmock{mock1, mock2} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
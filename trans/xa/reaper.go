@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+// Clock 时间来源，MaxTransactionAge 的判断从这里取当前时间，测试中可替换为手动推进的时钟
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+// ErrTransactionReaped 事务已因超过 MaxTransactionAge 被回滚，不能再提交
+var ErrTransactionReaped = errors.New("XA transaction exceeded MaxTransactionAge and was rolled back")
+
+// beginCommit 进入提交阶段。与 claimReap 互斥：超时回滚已认领时拒绝提交，
+// 已进入提交阶段后回收器不再回滚本事务
+func (xm *XAManager) beginCommit() (Observer, error) {
+	xm.mu.Lock()
+	defer xm.mu.Unlock()
+	if xm.reaped {
+		return nil, ErrTransactionReaped
+	}
+	xm.phase = PhaseCommitting
+	return xm.observer, nil
+}
+
+// claimReap 认领本管理器事务的超时回滚，事务已在提交或已提交时返回 false
+func (xm *XAManager) claimReap() bool {
+	xm.mu.Lock()
+	defer xm.mu.Unlock()
+	if xm.phase == PhaseCommitting || xm.phase == PhaseCommitted {
+		return false
+	}
+	xm.reaped = true
+	return true
+}
+
+func (xm *XAManager) now() time.Time {
+	if xm.Clock == nil {
+		return time.Now()
+	}
+	return xm.Clock.Now()
+}
+
+// ReapExpired 回滚各分支上已 PREPARE 且存在时间超过 MaxTransactionAge 的事务，返回被回滚的 XID。
+// 已 PREPARE 的分支持久化在各分支数据库中，通过 XA RECOVER 找到，
+// 开始时间取自 NewGlobalXID 生成的全局事务ID，无法解析出时间的 XID 不处理。
+// 本管理器自己的事务先经 claimReap 认领，正在提交的不回滚；其他进程的事务按决定日志处理，
+// 已决定提交的改为提交，未配置决定日志时只能依赖阈值足够大
+func (xm *XAManager) ReapExpired(ctx context.Context) ([]string, error) {
+	if xm.MaxTransactionAge <= 0 {
+		return nil, nil
+	}
+	txs, errs := xm.InDoubt(ctx)
+	now := xm.now()
+
+	var reaped []string
+	ownRolledBack := false
+	for _, tx := range txs {
+		if tx.StartedAt == nil || now.Sub(*tx.StartedAt) <= xm.MaxTransactionAge {
+			continue
+		}
+		if tx.GlobalXID == xm.globalXID {
+			if ownRolledBack {
+				reaped = append(reaped, tx.XID)
+				continue
+			}
+			if !xm.claimReap() {
+				log.Printf("XA reaper: %s is committing, skipped", tx.XID)
+				continue
+			}
+			// 在固定连接上回滚本事务的所有分支，并释放连接
+			log.Printf("XA reaper: %s older than %v, rolling back", xm.globalXID, xm.MaxTransactionAge)
+			xm.RollbackAll()
+			ownRolledBack = true
+			reaped = append(reaped, tx.XID)
+			continue
+		}
+
+		xm.mu.RLock()
+		branch := xm.branches[tx.Branch]
+		xm.mu.RUnlock()
+		xid := RecoveredXID{FormatID: tx.FormatID, Gtrid: tx.XID, Bqual: tx.Bqual}
+		log.Printf("XA reaper: %s on %s older than %v, rolling back", tx.XID, tx.Branch, xm.MaxTransactionAge)
+		d, err := xm.resolve(ctx, branch, xid, tx.GlobalXID)
+		if err != nil {
+			errs[tx.Branch] = err
+			continue
+		}
+		if d == DecisionRollback {
+			reaped = append(reaped, tx.XID)
+		}
+	}
+
+	var all []error
+	for branchID, err := range errs {
+		all = append(all, errors.New(branchID+": "+err.Error()))
+	}
+	return reaped, errors.Join(all...)
+}
+
+// StartReaper 每隔 interval 执行一次 ReapExpired，直到 ctx 取消
+func (xm *XAManager) StartReaper(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := xm.ReapExpired(ctx); err != nil {
+					log.Printf("XA reaper: %v", err)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// preparedManager 两个分支都已 PREPARE、尚未提交的事务
+func preparedManager(t *testing.T, clock Clock) (*XAManager, *trackingDB) {
+	t.Helper()
+	t0 := clock.Now()
+	tracker := &trackingDB{}
+	db := sql.OpenDB(tracker)
+	t.Cleanup(func() { db.Close() })
+	other := sql.OpenDB(&trackingDB{})
+	t.Cleanup(func() { other.Close() })
+
+	xm := NewXAManager(NewGlobalXID(t0))
+	xm.MaxTransactionAge = time.Minute
+	xm.Clock = clock
+	xm.AddBranch("db1", "Database1", db)
+	xm.AddBranch("db2", "Database2", other)
+	for _, id := range []string{"db1", "db2"} {
+		if err := xm.StartXA(id); err != nil {
+			t.Fatal(err)
+		}
+		if err := xm.EndAndPrepare(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return xm, tracker
+}
+
+func TestReaperRollsBackExpiredTransaction(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 10, 0, 0, 0, time.Local)}
+	xm, tracker := preparedManager(t, clock)
+	xid := xm.globalXID + ",db1"
+
+	// 未超时：不回滚
+	reaped, err := xm.ReapExpired(context.Background())
+	if err != nil || len(reaped) != 0 {
+		t.Fatalf("before limit: reaped=%v err=%v", reaped, err)
+	}
+
+	clock.Advance(xm.MaxTransactionAge + time.Second)
+	reaped, err = xm.ReapExpired(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(reaped)
+	if want := []string{xid, xm.globalXID + ",db2"}; !slices.Equal(reaped, want) {
+		t.Fatalf("reaped = %v, want %v", reaped, want)
+	}
+	if !slices.Contains(tracker.execs, "1 XA ROLLBACK '"+xid+"'") {
+		t.Fatalf("execs = %q, want rollback on the pinned connection", tracker.execs)
+	}
+	if xm.State().Phase != PhaseRolledBack {
+		t.Fatalf("phase = %s, want rolled_back", xm.State())
+	}
+	// 超时回滚后不能再提交
+	if err := xm.CommitAll(); !errors.Is(err, ErrTransactionReaped) {
+		t.Fatalf("CommitAll = %v, want ErrTransactionReaped", err)
+	}
+}
+
+// reapOnCommit 在提交开始时运行回收器，模拟回收与提交并发
+type reapOnCommit struct {
+	xm     *XAManager
+	reaped []string
+}
+
+func (o *reapOnCommit) OnBranchStarted(string)  {}
+func (o *reapOnCommit) OnBranchPrepared(string) {}
+func (o *reapOnCommit) OnCommitStart()          { o.reaped, _ = o.xm.ReapExpired(context.Background()) }
+func (o *reapOnCommit) OnCommitComplete()       {}
+func (o *reapOnCommit) OnRollback()             {}
+
+func TestReaperSkipsCommittingTransaction(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 10, 0, 0, 0, time.Local)}
+	xm, tracker := preparedManager(t, clock)
+	clock.Advance(xm.MaxTransactionAge + time.Second)
+
+	o := &reapOnCommit{xm: xm}
+	xm.SetObserver(o)
+	if err := xm.CommitAll(); err != nil {
+		t.Fatal(err)
+	}
+	if len(o.reaped) != 0 {
+		t.Fatalf("reaper rolled back %v during commit", o.reaped)
+	}
+	if slices.Contains(tracker.execs, "1 XA ROLLBACK '"+xm.globalXID+",db1'") {
+		t.Fatalf("execs = %q, want no rollback", tracker.execs)
+	}
+}
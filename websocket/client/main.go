@@ -3,15 +3,22 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"math/rand"
+	"net"
 	"net/url"
 	"os"
 	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/gobwas/ws"
 	"github.com/gobwas/ws/wsutil"
+
+	"test/websocket/message"
 )
 
 type SafeChan struct {
@@ -19,54 +26,234 @@ type SafeChan struct {
 	closed atomic.Bool
 }
 
-func main() {
-	// 设置 WebSocket 服务器的地址
-	serverURL := url.URL{Scheme: "ws", Host: "localhost:8080", Path: "/ws"}
-	fmt.Printf("Connecting to %s\n", serverURL.String())
+// closeTimeout 主动关闭后等待服务端回复 close 帧的最长时间
+const closeTimeout = time.Second
 
-	// 连接到 WebSocket 服务器
-	conn, _, _, err := ws.DefaultDialer.Dial(context.Background(), serverURL.String())
-	if err != nil {
-		log.Fatal("Failed to connect:", err)
+// ReconnectConfig 断线重连配置（指数退避 + 抖动）
+type ReconnectConfig struct {
+	InitialInterval time.Duration // 首次重试间隔
+	MaxInterval     time.Duration // 单次重试间隔上限
+	Multiplier      float64       // 退避倍数
+	MaxElapsedTime  time.Duration // 放弃重连前允许的最长总耗时
+}
+
+var defaultReconnectConfig = ReconnectConfig{
+	InitialInterval: 500 * time.Millisecond,
+	MaxInterval:     10 * time.Second,
+	Multiplier:      2,
+	MaxElapsedTime:  2 * time.Minute,
+}
+
+// jitter 在 [d/2, d*3/2) 范围内随机取值，避免大量客户端同时重连
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
 	}
-	defer conn.Close()
+	return d/2 + time.Duration(rand.Int63n(int64(d)))
+}
 
-	fmt.Println("Connected to WebSocket server.")
+// dialWithBackoff 按指数退避反复拨号，直到成功或超过 MaxElapsedTime
+func dialWithBackoff(ctx context.Context, addr string, cfg ReconnectConfig) (net.Conn, error) {
+	start := time.Now()
+	interval := cfg.InitialInterval
+	for {
+		conn, _, _, err := ws.DefaultDialer.Dial(ctx, addr)
+		if err == nil {
+			return conn, nil
+		}
+		if time.Since(start)+interval > cfg.MaxElapsedTime {
+			return nil, fmt.Errorf("reconnect gave up after %v: %w", time.Since(start), err)
+		}
 
-	// 启动一个 goroutine 来读取来自服务器的消息
-	go func() {
-		for {
-			// 读取服务器消息
-			msg, op, err := wsutil.ReadServerData(conn)
-			if err != nil {
-				log.Fatal("Failed to read message:", err)
-			}
-			if op == ws.OpClose {
-				fmt.Println("Server closed the connection.")
-				break
+		wait := jitter(interval)
+		log.Printf("Failed to connect: %v, retry in %v", err, wait)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+
+		interval = time.Duration(float64(interval) * cfg.Multiplier)
+		if interval > cfg.MaxInterval {
+			interval = cfg.MaxInterval
+		}
+	}
+}
+
+// Client WebSocket 客户端，连接断开时自动重连。
+// 读写错误通过返回值上报，不会在 goroutine 里直接退出进程。
+type Client struct {
+	Addr      string
+	Reconnect ReconnectConfig
+	// Codec 消息编解码，为 nil 时使用 message.RawCodec（文本为 string，二进制为 []byte）
+	Codec message.Codec
+	// OnMessage 收到并解码后的服务器消息
+	OnMessage func(msg any)
+}
+
+func NewClient(addr string, cfg ReconnectConfig, onMessage func(msg any)) *Client {
+	return &Client{Addr: addr, Reconnect: cfg, Codec: message.RawCodec{}, OnMessage: onMessage}
+}
+
+func (c *Client) codec() message.Codec {
+	if c.Codec == nil {
+		return message.RawCodec{}
+	}
+	return c.Codec
+}
+
+// Run 维持与服务器的连接并收发消息。
+// input 中读出但尚未成功发送的消息会保留下来，重连后继续发送；
+// 输入 "exit" 或 input 被关闭时正常退出，返回 nil；
+// 连接断开且重连放弃时返回导致断开的错误。
+func (c *Client) Run(ctx context.Context, input <-chan any) error {
+	var pending *any
+	var lost error
+	for {
+		conn, err := dialWithBackoff(ctx, c.Addr, c.Reconnect)
+		if err != nil {
+			if lost != nil {
+				return errors.Join(fmt.Errorf("connection lost: %w", lost), err)
 			}
-			fmt.Printf("Received from server: %s\n", string(msg))
+			return err
 		}
-	}()
+		fmt.Println("Connected to WebSocket server.")
+		// ctx 取消时阻塞中的读立即返回 message.ErrReadCanceled，读循环随之退出
+		conn = message.NewContextConn(ctx, conn)
+
+		// 读循环和主循环都可能关闭连接，只关闭一次
+		closeConn := sync.OnceFunc(func() { conn.Close() })
+		// 读循环回复 ping/close 与主循环发送消息写同一个连接，用 wmu 串行，避免帧交错
+		wmu := &sync.Mutex{}
+		readErr := make(chan error, 1)
+		go c.readLoop(conn, wmu, readErr, closeConn)
 
-	// 创建一个循环，不断从命令行输入消息并发送
-	reader := bufio.NewReader(os.Stdin)
+		done, err := c.pump(ctx, conn, wmu, input, &pending, readErr)
+		closeConn()
+		if done {
+			return err
+		}
+		lost = err
+		if code, reason, ok := message.CloseStatus(err); ok {
+			log.Printf("Server closed connection: %d %s, reconnecting...", code, reason)
+		} else {
+			log.Printf("Connection lost: %v, reconnecting...", err)
+		}
+	}
+}
+
+// readLoop 读取服务器消息，出错时关闭连接（让阻塞中的写立即失败），把错误交给主循环处理后退出；
+// conn 为 message.ContextConn 时 ctx 取消也会让读返回，错误包装 message.ErrReadCanceled
+func (c *Client) readLoop(conn net.Conn, wmu sync.Locker, readErr chan<- error, closeConn func()) {
 	for {
-		fmt.Print("Enter message to send: ")
-		text, _ := reader.ReadString('\n')
-		text = strings.TrimSpace(text)
+		// close 帧由 ReadMessageWith 处理，并以 wsutil.ClosedError 返回；回复在 wmu 下写出
+		data, op, err := message.ReadMessageWith(conn, ws.StateClientSide, message.ReadOptions{WriteLock: wmu})
+		if err != nil {
+			closeConn()
+			readErr <- err
+			return
+		}
+		msg, err := c.codec().Decode(data, op)
+		if err != nil {
+			log.Printf("Decode error: %v", err)
+			continue
+		}
+		if c.OnMessage != nil {
+			c.OnMessage(msg)
+		}
+	}
+}
+
+// pump 把 input 中的消息写到连接上。done 为 true 表示客户端应当退出，
+// 否则表示连接已断开需要重连，未发送成功的消息保存在 pending 中。
+// 消息经 Codec 编码后发送，无法编码的消息视为调用方错误，直接返回。
+func (c *Client) pump(ctx context.Context, conn net.Conn, wmu sync.Locker, input <-chan any, pending **any, readErr <-chan error) (done bool, err error) {
+	write := func(op ws.OpCode, p []byte) error {
+		wmu.Lock()
+		defer wmu.Unlock()
+		return wsutil.WriteClientMessage(conn, op, p)
+	}
+	for {
+		if *pending == nil {
+			select {
+			case <-ctx.Done():
+				return true, ctx.Err()
+			case err := <-readErr:
+				if errors.Is(err, message.ErrReadCanceled) {
+					return true, ctx.Err()
+				}
+				return false, err
+			case v, ok := <-input:
+				if !ok {
+					return true, nil
+				}
+				*pending = &v
+			}
+		}
 
+		v := **pending
 		// 检查是否输入 "exit" 退出循环
-		if text == "exit" {
+		if v == "exit" {
 			fmt.Println("Closing connection...")
-			wsutil.WriteClientMessage(conn, ws.OpClose, nil)
-			break
+			body := ws.NewCloseFrameBody(ws.StatusNormalClosure, "client requested")
+			if err := write(ws.OpClose, body); err != nil {
+				return true, err
+			}
+			// 等待服务端回复 close 帧，完成关闭握手
+			select {
+			case err := <-readErr:
+				if code, reason, ok := message.CloseStatus(err); ok {
+					log.Printf("Server acknowledged close: %d %s", code, reason)
+				}
+			case <-time.After(closeTimeout):
+			}
+			return true, nil
 		}
 
-		// 发送文本消息到服务器
-		err = wsutil.WriteClientMessage(conn, ws.OpText, []byte(text))
+		// 编码后发送到服务器
+		data, op, err := c.codec().Encode(v)
 		if err != nil {
-			log.Fatal("Failed to send message:", err)
+			return true, err
 		}
+		if err := write(op, data); err != nil {
+			return false, err
+		}
+		*pending = nil
+	}
+}
+
+func main() {
+	// 设置 WebSocket 服务器的地址
+	// 鉴权令牌通过 token 查询参数传递，可用环境变量 WS_TOKEN 覆盖
+	token := os.Getenv("WS_TOKEN")
+	if token == "" {
+		token = "demo-token"
+	}
+	serverURL := url.URL{Scheme: "ws", Host: "localhost:8080", Path: "/ws", RawQuery: url.Values{"token": {token}}.Encode()}
+	fmt.Printf("Connecting to %s\n", serverURL.String())
+
+	// 从命令行读取输入，放入缓冲通道中，重连期间的输入不会丢失
+	input := make(chan any, 64)
+	go func() {
+		defer close(input)
+		reader := bufio.NewReader(os.Stdin)
+		for {
+			fmt.Print("Enter message to send: ")
+			text, err := reader.ReadString('\n')
+			if text = strings.TrimSpace(text); text != "" {
+				input <- text
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	client := NewClient(serverURL.String(), defaultReconnectConfig, func(msg any) {
+		fmt.Printf("Received from server: %s\n", msg)
+	})
+	if err := client.Run(context.Background(), input); err != nil && !errors.Is(err, context.Canceled) {
+		fmt.Fprintln(os.Stderr, "Client stopped:", err)
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+
+	"test/websocket/message"
+)
+
+// 第一个连接回显一条消息后立即断开，之后的连接正常回显
+func startFlakyServer(t *testing.T) (string, *atomic.Int32) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	var accepted atomic.Int32
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			if _, err := ws.Upgrade(conn); err != nil {
+				conn.Close()
+				continue
+			}
+			n := accepted.Add(1)
+			go func() {
+				defer conn.Close()
+				for {
+					msg, op, err := wsutil.ReadClientData(conn)
+					if err != nil {
+						return
+					}
+					if err := wsutil.WriteServerMessage(conn, op, msg); err != nil {
+						return
+					}
+					if n == 1 {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return "ws://" + ln.Addr().String() + "/ws", &accepted
+}
+
+func TestRunClientReconnects(t *testing.T) {
+	addr, accepted := startFlakyServer(t)
+
+	received := make(chan string, 4)
+	input := make(chan any, 4)
+	cfg := ReconnectConfig{
+		InitialInterval: 10 * time.Millisecond,
+		MaxInterval:     50 * time.Millisecond,
+		Multiplier:      2,
+		MaxElapsedTime:  2 * time.Second,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- NewClient(addr, cfg, func(msg any) { received <- msg.(string) }).Run(ctx, input)
+	}()
+
+	input <- "first"
+	if got := <-received; got != "first" {
+		t.Fatalf("got %q, want first", got)
+	}
+
+	// 等待客户端重连
+	for accepted.Load() < 2 {
+		select {
+		case <-ctx.Done():
+			t.Fatal("client did not reconnect")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	input <- "second"
+	select {
+	case got := <-received:
+		if got != "second" {
+			t.Fatalf("got %q, want second", got)
+		}
+	case <-ctx.Done():
+		t.Fatal("no echo after reconnect")
+	}
+
+	close(input)
+	if err := <-done; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+}
+
+func TestDialWithBackoffGivesUp(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := "ws://" + ln.Addr().String()
+	ln.Close()
+
+	cfg := ReconnectConfig{
+		InitialInterval: 10 * time.Millisecond,
+		MaxInterval:     20 * time.Millisecond,
+		Multiplier:      2,
+		MaxElapsedTime:  100 * time.Millisecond,
+	}
+	if _, err := dialWithBackoff(context.Background(), addr, cfg); err == nil {
+		t.Fatal("expected dialWithBackoff to give up")
+	}
+}
+
+func TestClientReturnsErrorWhenServerDies(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		ws.Upgrade(conn)
+		// 服务端异常退出：不发 close 帧直接断开，且不再接受重连
+		ln.Close()
+		conn.Close()
+	}()
+
+	cfg := ReconnectConfig{
+		InitialInterval: 10 * time.Millisecond,
+		MaxInterval:     20 * time.Millisecond,
+		Multiplier:      2,
+		MaxElapsedTime:  100 * time.Millisecond,
+	}
+	input := make(chan any) // 不发送任何消息，错误只能来自读循环
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = NewClient("ws://"+ln.Addr().String(), cfg, nil).Run(ctx, input)
+	if err == nil {
+		t.Fatal("Run returned nil after the server died")
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Run did not notice the dead server: %v", err)
+	}
+	if !strings.Contains(err.Error(), "connection lost") {
+		t.Fatalf("err = %v, want the read error that dropped the connection", err)
+	}
+}
+
+func TestExitSendsNormalClosure(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	observed := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		ws.Upgrade(conn)
+		_, _, err = message.ReadMessage(conn, ws.StateServerSide)
+		observed <- err
+	}()
+
+	input := make(chan any, 1)
+	input <- "exit"
+	cfg := ReconnectConfig{InitialInterval: 10 * time.Millisecond, MaxInterval: 10 * time.Millisecond, Multiplier: 1, MaxElapsedTime: time.Second}
+	if err := NewClient("ws://"+ln.Addr().String(), cfg, nil).Run(context.Background(), input); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	code, reason, ok := message.CloseStatus(<-observed)
+	if !ok || code != ws.StatusNormalClosure || reason != "client requested" {
+		t.Fatalf("server observed close %d %q (ok=%v), want 1000 \"client requested\"", code, reason, ok)
+	}
+}
+
+func TestReadLoopReturnsOnCancel(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var closed atomic.Bool
+	readErr := make(chan error, 1)
+	c := NewClient("", defaultReconnectConfig, nil)
+	go c.readLoop(message.NewContextConn(ctx, client), &sync.Mutex{}, readErr, func() { closed.Store(true) })
+
+	time.Sleep(20 * time.Millisecond) // 服务端不发消息，读循环阻塞在读上
+	cancel()
+	select {
+	case err := <-readErr:
+		if !errors.Is(err, message.ErrReadCanceled) {
+			t.Fatalf("readLoop error = %v, want ErrReadCanceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("readLoop still blocked 1s after cancel")
+	}
+	if !closed.Load() {
+		t.Fatal("readLoop did not close the connection")
+	}
+}
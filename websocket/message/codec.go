@@ -0,0 +1,117 @@
+package message
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/gobwas/ws"
+)
+
+// Codec 负责消息与 Go 值之间的转换，收发两端需使用相同的编解码器
+type Codec interface {
+	Encode(v any) ([]byte, ws.OpCode, error)
+	Decode(data []byte, op ws.OpCode) (any, error)
+}
+
+// ErrorEncoder Codec 的可选接口：Decode 失败时生成回复给发送方的错误消息，
+// 未实现时服务端只记录日志并丢弃该消息
+type ErrorEncoder interface {
+	EncodeError(data []byte, err error) ([]byte, ws.OpCode, error)
+}
+
+// RawCodec 不做任何转换：string 作为文本消息，[]byte 作为二进制消息；
+// 解码时文本消息返回 string，二进制消息返回 []byte，与引入编解码器之前的行为一致
+type RawCodec struct{}
+
+func (RawCodec) Encode(v any) ([]byte, ws.OpCode, error) {
+	switch p := v.(type) {
+	case string:
+		return []byte(p), ws.OpText, nil
+	case []byte:
+		return p, ws.OpBinary, nil
+	}
+	return nil, 0, fmt.Errorf("raw codec: unsupported type %T", v)
+}
+
+func (RawCodec) Decode(data []byte, op ws.OpCode) (any, error) {
+	if op == ws.OpBinary {
+		return data, nil
+	}
+	return string(data), nil
+}
+
+// envelope JSON 消息的外层结构，type 用于区分负载类型
+type envelope struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// JSONCodec 以 {"type": ..., "payload": ...} 的形式收发文本消息，
+// 需要先用 Register 登记类型名与结构体的对应关系，解码时返回该结构体的指针
+type JSONCodec struct {
+	mu    sync.RWMutex
+	types map[string]reflect.Type
+	names map[reflect.Type]string
+}
+
+func NewJSONCodec() *JSONCodec {
+	return &JSONCodec{types: make(map[string]reflect.Type), names: make(map[reflect.Type]string)}
+}
+
+// Register 登记消息类型，proto 为该类型的零值或指针，如 Register("chat", ChatMessage{})
+func (c *JSONCodec) Register(name string, proto any) {
+	t := reflect.TypeOf(proto)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.types[name] = t
+	c.names[t] = name
+}
+
+func (c *JSONCodec) Encode(v any) ([]byte, ws.OpCode, error) {
+	t := reflect.TypeOf(v)
+	if t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	c.mu.RLock()
+	name, ok := c.names[t]
+	c.mu.RUnlock()
+	if !ok {
+		return nil, 0, fmt.Errorf("json codec: type %T is not registered", v)
+	}
+
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return nil, 0, err
+	}
+	data, err := json.Marshal(envelope{Type: name, Payload: payload})
+	if err != nil {
+		return nil, 0, err
+	}
+	return data, ws.OpText, nil
+}
+
+func (c *JSONCodec) Decode(data []byte, op ws.OpCode) (any, error) {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("json codec: %v", err)
+	}
+	c.mu.RLock()
+	t, ok := c.types[env.Type]
+	c.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("json codec: unknown message type %q", env.Type)
+	}
+
+	v := reflect.New(t)
+	if len(env.Payload) > 0 {
+		if err := json.Unmarshal(env.Payload, v.Interface()); err != nil {
+			return nil, fmt.Errorf("json codec: decode %s: %v", env.Type, err)
+		}
+	}
+	return v.Interface(), nil
+}
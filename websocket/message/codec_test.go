@@ -0,0 +1,84 @@
+package message
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/gobwas/ws"
+)
+
+type chatMessage struct {
+	Room string `json:"room"`
+	Text string `json:"text"`
+}
+
+func TestJSONCodecRoundTrip(t *testing.T) {
+	codec := NewJSONCodec()
+	codec.Register("chat", chatMessage{})
+
+	in := chatMessage{Room: "lobby", Text: "hi"}
+	data, op, err := codec.Encode(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op != ws.OpText || string(data) != `{"type":"chat","payload":{"room":"lobby","text":"hi"}}` {
+		t.Fatalf("encoded %v %s", op, data)
+	}
+
+	out, err := codec.Decode(data, op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := out.(*chatMessage); !ok || !reflect.DeepEqual(*got, in) {
+		t.Fatalf("decoded %#v, want *chatMessage %+v", out, in)
+	}
+
+	if _, _, err := codec.Encode(struct{}{}); err == nil {
+		t.Fatal("encoding an unregistered type should fail")
+	}
+	if _, err := codec.Decode([]byte(`{"type":"nope"}`), ws.OpText); err == nil {
+		t.Fatal("decoding an unknown type should fail")
+	}
+}
+
+func TestRawCodecPassThrough(t *testing.T) {
+	var codec RawCodec
+	for _, v := range []any{"text", []byte{1, 2, 3}} {
+		data, op, err := codec.Encode(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := codec.Decode(data, op)
+		if err != nil || !reflect.DeepEqual(out, v) {
+			t.Fatalf("round trip %#v = %#v, %v", v, out, err)
+		}
+	}
+}
+
+func TestFrameCodec(t *testing.T) {
+	var codec FrameCodec
+	data, op, err := codec.Encode(Frame{ID: "1", Type: "ping", Payload: json.RawMessage(`{"n":1}`)})
+	if err != nil || op != ws.OpText {
+		t.Fatalf("Encode = %s, %v, %v", data, op, err)
+	}
+	v, err := codec.Decode(data, op)
+	if f, ok := v.(*Frame); err != nil || !ok || f.ID != "1" || f.Type != "ping" || string(f.Payload) != `{"n":1}` {
+		t.Fatalf("Decode = %#v, %v", v, err)
+	}
+
+	// 缺少 type 时仍能从消息里取出 id 放进错误帧
+	bad := []byte(`{"id":"7","payload":1}`)
+	_, err = codec.Decode(bad, ws.OpText)
+	if err == nil {
+		t.Fatal("Decode accepted a frame without type")
+	}
+	data, _, err = codec.EncodeError(bad, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f Frame
+	if err := json.Unmarshal(data, &f); err != nil || f.ID != "7" || f.Type != FrameError {
+		t.Fatalf("error frame = %s, want id 7 type error", data)
+	}
+}
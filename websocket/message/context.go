@@ -0,0 +1,45 @@
+package message
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// ErrReadCanceled 读被 context 取消打断，读循环应按正常关闭处理而不是连接错误
+var ErrReadCanceled = errors.New("websocket read canceled")
+
+// ContextConn 让阻塞中的读可以被 context 取消。ReadMessage 等读函数本身不接受 context，
+// ctx 取消时把读 deadline 设为当前时间，阻塞的读随即返回超时错误，
+// Read 把这种错误转换为包装了 ErrReadCanceled 的错误。
+// 只改读 deadline，取消后仍可以正常写出 close 帧
+type ContextConn struct {
+	net.Conn
+	ctx  context.Context
+	stop func() bool
+}
+
+func NewContextConn(ctx context.Context, conn net.Conn) *ContextConn {
+	return &ContextConn{
+		Conn: conn,
+		ctx:  ctx,
+		stop: context.AfterFunc(ctx, func() { conn.SetReadDeadline(time.Now()) }),
+	}
+}
+
+func (c *ContextConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if err != nil && c.ctx.Err() != nil && errors.Is(err, os.ErrDeadlineExceeded) {
+		return n, fmt.Errorf("%w: %w", ErrReadCanceled, context.Cause(c.ctx))
+	}
+	return n, err
+}
+
+// Close 解除与 ctx 的关联并关闭连接
+func (c *ContextConn) Close() error {
+	c.stop()
+	return c.Conn.Close()
+}
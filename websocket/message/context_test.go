@@ -0,0 +1,42 @@
+package message
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws"
+)
+
+func TestContextConnCancelUnblocksRead(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	conn := NewContextConn(ctx, server)
+	defer conn.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := ReadMessage(conn, ws.StateServerSide)
+		done <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond) // 让读先阻塞
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrReadCanceled) || !errors.Is(err, context.Canceled) {
+			t.Fatalf("ReadMessage = %v, want ErrReadCanceled wrapping context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ReadMessage still blocked 1s after cancel")
+	}
+
+	// 只改了读 deadline，取消后仍能写出 close 帧
+	go ws.ReadFrame(client)
+	if err := ws.WriteFrame(conn, ws.NewCloseFrame(ws.NewCloseFrameBody(ws.StatusGoingAway, ""))); err != nil {
+		t.Fatalf("write after cancel: %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package message
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gobwas/ws"
+)
+
+// 帧协议中服务端使用的 type
+const (
+	FrameReply = "reply" // 对请求的回复，id 与请求相同
+	FrameError = "error" // 请求无法解析或处理失败，payload 为 {"error": ...}
+)
+
+// Frame 帧协议的一条消息：{"id": ..., "type": ..., "payload": ...}。
+// 客户端为每个请求生成唯一 id，服务端的回复带回同一个 id，客户端据此把回复与请求对应起来
+type Frame struct {
+	ID      string          `json:"id"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// ErrorFrame 构造 id 对应请求的错误回复
+func ErrorFrame(id string, err error) *Frame {
+	payload, _ := json.Marshal(map[string]string{"error": err.Error()})
+	return &Frame{ID: id, Type: FrameError, Payload: payload}
+}
+
+// FrameCodec 以 Frame 收发文本消息，解码时返回 *Frame，
+// 不是合法 JSON 或缺少 id/type 的消息通过 EncodeError 回复错误帧
+type FrameCodec struct{}
+
+func (FrameCodec) Encode(v any) ([]byte, ws.OpCode, error) {
+	var f *Frame
+	switch p := v.(type) {
+	case *Frame:
+		f = p
+	case Frame:
+		f = &p
+	default:
+		return nil, 0, fmt.Errorf("frame codec: unsupported type %T", v)
+	}
+	data, err := json.Marshal(f)
+	if err != nil {
+		return nil, 0, err
+	}
+	return data, ws.OpText, nil
+}
+
+func (FrameCodec) Decode(data []byte, op ws.OpCode) (any, error) {
+	if op != ws.OpText {
+		return nil, errors.New("frame codec: binary messages are not supported")
+	}
+	var f Frame
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("frame codec: %v", err)
+	}
+	if f.ID == "" || f.Type == "" {
+		return nil, errors.New("frame codec: id and type are required")
+	}
+	return &f, nil
+}
+
+// EncodeError 生成解码失败时的错误帧，能从消息中取出 id 时带上该 id
+func (c FrameCodec) EncodeError(data []byte, err error) ([]byte, ws.OpCode, error) {
+	var probe struct {
+		ID string `json:"id"`
+	}
+	json.Unmarshal(data, &probe)
+	return c.Encode(ErrorFrame(probe.ID, err))
+}
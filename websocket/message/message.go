@@ -0,0 +1,157 @@
+package message
+
+import (
+	"compress/flate"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsflate"
+	"github.com/gobwas/ws/wsutil"
+)
+
+const (
+	// DefaultFragmentSize 单个数据帧的负载上限，超过后拆分为连续帧（continuation frame）
+	DefaultFragmentSize = 32 << 10
+	// DefaultMaxMessageSize 重组后单条消息允许的最大字节数
+	DefaultMaxMessageSize = 16 << 20
+)
+
+// ErrMessageTooLarge 重组后的消息超过了允许的最大长度
+var ErrMessageTooLarge = errors.New("websocket message too large")
+
+// CloseStatus 从 ReadMessage 返回的错误中取出对端 close 帧的状态码和原因，
+// 不是因对端关闭而返回的错误时 ok 为 false
+func CloseStatus(err error) (code ws.StatusCode, reason string, ok bool) {
+	var closed wsutil.ClosedError
+	if errors.As(err, &closed) {
+		return closed.Code, closed.Reason, true
+	}
+	return 0, "", false
+}
+
+// WriteMessage 将 r 中的全部数据作为一条消息写出。
+// 数据超过 DefaultFragmentSize 时，第一帧使用 op，后续帧为 continuation，最后一帧置 FIN。
+// state 决定是否对帧做掩码：客户端使用 ws.StateClientSide，服务端使用 ws.StateServerSide。
+func WriteMessage(w io.Writer, state ws.State, op ws.OpCode, r io.Reader) error {
+	return WriteMessageSize(w, state, op, r, DefaultFragmentSize)
+}
+
+// WriteMessageSize 同 WriteMessage，可指定单帧负载大小
+func WriteMessageSize(w io.Writer, state ws.State, op ws.OpCode, r io.Reader, fragmentSize int) error {
+	writer := wsutil.NewWriterSize(w, state, op, fragmentSize)
+	if _, err := io.Copy(writer, r); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+// WriteMessageDeflate 使用 permessage-deflate（RFC 7692）压缩后写出一条消息，
+// 仅在握手阶段协商成功后使用。
+func WriteMessageDeflate(w io.Writer, state ws.State, op ws.OpCode, r io.Reader) error {
+	writer := wsutil.NewWriterSize(w, state|ws.StateExtended, op, DefaultFragmentSize)
+	ms := &wsflate.MessageState{}
+	ms.SetCompressed(true)
+	writer.SetExtensions(ms)
+
+	fw := wsflate.NewWriter(writer, func(w io.Writer) wsflate.Compressor {
+		f, _ := flate.NewWriter(w, flate.BestSpeed)
+		return f
+	})
+	if _, err := io.Copy(fw, r); err != nil {
+		return err
+	}
+	// Flush 以 sync flush 结束压缩流，wsflate 会去掉 RFC 7692 要求省略的 0x0000ffff 尾部
+	if err := fw.Flush(); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+// ReadMessage 读取一条完整的数据消息（text 或 binary），自动拼接分片帧，
+// 并在读取过程中处理 ping/pong/close 等控制帧。
+func ReadMessage(rw io.ReadWriter, state ws.State) ([]byte, ws.OpCode, error) {
+	return ReadMessageWith(rw, state, ReadOptions{})
+}
+
+// ReadMessageLimit 同 ReadMessage，消息超过 maxSize 字节时返回 ErrMessageTooLarge
+func ReadMessageLimit(rw io.ReadWriter, state ws.State, maxSize int64) ([]byte, ws.OpCode, error) {
+	return ReadMessageWith(rw, state, ReadOptions{MaxSize: maxSize})
+}
+
+// ReadMessageDeflate 同 ReadMessage，并对置了 RSV1 位的压缩消息解压；
+// 未压缩的消息按原样返回，因此对端可以按消息粒度选择是否压缩。
+func ReadMessageDeflate(rw io.ReadWriter, state ws.State) ([]byte, ws.OpCode, error) {
+	return ReadMessageWith(rw, state, ReadOptions{Deflate: true})
+}
+
+// ReadOptions ReadMessageWith 的可选参数
+type ReadOptions struct {
+	MaxSize int64 // 重组后消息的最大字节数，<=0 时为 DefaultMaxMessageSize
+	Deflate bool  // 解压置了 RSV1 位的压缩消息
+	// WriteLock 回复 ping/close 时持有的写锁。其他 goroutine 也在同一连接上写消息时必须设置，
+	// 否则 pong 可能插进对方正在写的分片消息中间；nil 表示只有读 goroutine 会写
+	WriteLock sync.Locker
+}
+
+// ReadMessageWith 按 opts 读取一条完整的数据消息
+func ReadMessageWith(rw io.ReadWriter, state ws.State, opts ReadOptions) ([]byte, ws.OpCode, error) {
+	maxSize := opts.MaxSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxMessageSize
+	}
+	return readMessage(rw, state, maxSize, opts.Deflate, opts.WriteLock)
+}
+
+func readMessage(rw io.ReadWriter, state ws.State, maxSize int64, deflate bool, wmu sync.Locker) ([]byte, ws.OpCode, error) {
+	controlHandler := wsutil.ControlFrameHandler(rw, state)
+	if wmu != nil {
+		reply := controlHandler
+		controlHandler = func(h ws.Header, r io.Reader) error {
+			wmu.Lock()
+			defer wmu.Unlock()
+			return reply(h, r)
+		}
+	}
+	rd := wsutil.Reader{
+		Source:         rw,
+		State:          state,
+		CheckUTF8:      !deflate, // 压缩后的负载不是合法 UTF-8，解压后再由上层处理
+		OnIntermediate: controlHandler,
+	}
+	ms := &wsflate.MessageState{}
+	if deflate {
+		rd.State |= ws.StateExtended
+		rd.Extensions = []wsutil.RecvExtension{ms}
+	}
+
+	for {
+		hdr, err := rd.NextFrame()
+		if err != nil {
+			return nil, 0, err
+		}
+		if hdr.OpCode.IsControl() {
+			if err := controlHandler(hdr, &rd); err != nil {
+				return nil, 0, err
+			}
+			continue
+		}
+
+		// Reader 会跨越 continuation 帧连续读取，直到 FIN 帧结束
+		var src io.Reader = &rd
+		if ms.IsCompressed() {
+			src = wsflate.NewReader(&rd, func(r io.Reader) wsflate.Decompressor {
+				return flate.NewReader(r)
+			})
+		}
+		data, err := io.ReadAll(io.LimitReader(src, maxSize+1))
+		if err != nil {
+			return nil, 0, err
+		}
+		if int64(len(data)) > maxSize {
+			return nil, 0, ErrMessageTooLarge
+		}
+		return data, hdr.OpCode, nil
+	}
+}
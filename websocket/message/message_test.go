@@ -0,0 +1,54 @@
+package message
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+// countingLocker 记录加锁次数，并检查写操作发生在锁内
+type countingLocker struct {
+	locked bool
+	n      int
+}
+
+func (l *countingLocker) Lock()   { l.locked = true; l.n++ }
+func (l *countingLocker) Unlock() { l.locked = false }
+
+// lockedWriter 在未持有写锁时写入即报错
+type lockedWriter struct {
+	io.Reader
+	out  bytes.Buffer
+	lock *countingLocker
+	t    *testing.T
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	if !w.lock.locked {
+		w.t.Error("control reply written without the write lock")
+	}
+	return w.out.Write(p)
+}
+
+func TestReadMessageWithLocksControlReplies(t *testing.T) {
+	var in bytes.Buffer
+	wsutil.WriteClientMessage(&in, ws.OpPing, []byte("p"))
+	wsutil.WriteClientMessage(&in, ws.OpText, []byte("hello"))
+	lock := &countingLocker{}
+	rw := &lockedWriter{Reader: &in, lock: lock, t: t}
+
+	data, op, err := ReadMessageWith(rw, ws.StateServerSide, ReadOptions{WriteLock: lock})
+	if err != nil || op != ws.OpText || string(data) != "hello" {
+		t.Fatalf("ReadMessageWith = %q, %v, %v", data, op, err)
+	}
+	if lock.n != 1 {
+		t.Fatalf("write lock taken %d times, want 1 for the pong", lock.n)
+	}
+	f, err := ws.ReadFrame(&rw.out)
+	if err != nil || f.Header.OpCode != ws.OpPong || string(f.Payload) != "p" {
+		t.Fatalf("reply = %+v, %v; want pong p", f, err)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/gobwas/ws"
+)
+
+// ErrInvalidToken 令牌缺失或校验失败
+var ErrInvalidToken = errors.New("invalid token")
+
+// Authenticator 校验握手中携带的令牌，返回对应的用户 ID
+type Authenticator interface {
+	Authenticate(token string) (userID string, err error)
+}
+
+// StaticAuthenticator 基于固定令牌表的 Authenticator，key 为令牌，value 为用户 ID
+type StaticAuthenticator map[string]string
+
+func (a StaticAuthenticator) Authenticate(token string) (string, error) {
+	userID, ok := a[token]
+	if !ok {
+		return "", ErrInvalidToken
+	}
+	return userID, nil
+}
+
+var errUnauthorized = ws.RejectConnectionError(
+	ws.RejectionStatus(http.StatusUnauthorized),
+	ws.RejectionReason("unauthorized"),
+)
+
+// tokenAuth 在握手过程中收集令牌：优先使用 Authorization: Bearer 头，其次使用 token 查询参数
+type tokenAuth struct {
+	auth   Authenticator
+	token  string
+	userID string
+}
+
+func (t *tokenAuth) onRequest(uri []byte) error {
+	u, err := url.ParseRequestURI(string(uri))
+	if err != nil {
+		return nil
+	}
+	if token := u.Query().Get("token"); token != "" {
+		t.token = token
+	}
+	return nil
+}
+
+func (t *tokenAuth) onHeader(key, value []byte) error {
+	if !bytes.EqualFold(key, []byte("Authorization")) {
+		return nil
+	}
+	const prefix = "bearer "
+	if len(value) > len(prefix) && bytes.EqualFold(value[:len(prefix)], []byte(prefix)) {
+		t.token = string(bytes.TrimSpace(value[len(prefix):]))
+	}
+	return nil
+}
+
+// onBeforeUpgrade 在返回 101 之前校验令牌，失败时以 401 拒绝升级
+func (t *tokenAuth) onBeforeUpgrade() (ws.HandshakeHeader, error) {
+	if t.token == "" {
+		return nil, errUnauthorized
+	}
+	userID, err := t.auth.Authenticate(t.token)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+	t.userID = userID
+	return nil, nil
+}
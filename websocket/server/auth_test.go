@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/gobwas/ws"
+)
+
+func startAuthServer(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	srv := &Server{Auth: StaticAuthenticator{"good-token": "alice"}}
+	go srv.Serve(ln)
+	return "ws://" + ln.Addr().String()
+}
+
+func TestAuthAccepted(t *testing.T) {
+	addr := startAuthServer(t)
+
+	dialer := ws.Dialer{Header: ws.HandshakeHeaderHTTP(http.Header{
+		"Authorization": []string{"Bearer good-token"},
+	})}
+	conn, _, _, err := dialer.Dial(context.Background(), addr)
+	if err != nil {
+		t.Fatalf("header token rejected: %v", err)
+	}
+	conn.Close()
+
+	conn, _, _, err = ws.DefaultDialer.Dial(context.Background(), addr+"/ws?token=good-token")
+	if err != nil {
+		t.Fatalf("query token rejected: %v", err)
+	}
+	conn.Close()
+}
+
+func TestAuthRejected(t *testing.T) {
+	addr := startAuthServer(t)
+
+	for _, target := range []string{addr, addr + "/ws?token=bad-token"} {
+		_, _, _, err := ws.DefaultDialer.Dial(context.Background(), target)
+		var status ws.StatusError
+		if !errors.As(err, &status) || int(status) != http.StatusUnauthorized {
+			t.Fatalf("dial %s: err = %v, want 401", target, err)
+		}
+	}
+}
+
+func TestTokenAuthAttachesUserID(t *testing.T) {
+	auth := &tokenAuth{auth: StaticAuthenticator{"good-token": "alice"}}
+	auth.onRequest([]byte("/ws?token=bad-token"))
+	auth.onHeader([]byte("Authorization"), []byte("Bearer good-token"))
+	if _, err := auth.onBeforeUpgrade(); err != nil {
+		t.Fatal(err)
+	}
+	if auth.userID != "alice" {
+		t.Fatalf("userID = %q, want alice", auth.userID)
+	}
+}
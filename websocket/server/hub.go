@@ -0,0 +1,265 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+// DefaultQueueSize 每个连接广播发送队列的默认长度
+const DefaultQueueSize = 64
+
+// OverflowPolicy 连接的发送队列满（慢消费者）时的处理策略
+type OverflowPolicy int
+
+const (
+	// OverflowClose 以 1011 断开慢连接，不阻塞广播
+	OverflowClose OverflowPolicy = iota
+	// OverflowDrop 丢弃这条广播，连接保持
+	OverflowDrop
+)
+
+// Hub 管理在线连接及其所在的房间，负责广播。
+// 广播只把消息放入每个连接自己的发送队列，由连接的写协程发出，慢连接不会拖住其他连接。
+type Hub struct {
+	// QueueSize 每个连接的发送队列长度，0 表示 DefaultQueueSize；需在注册连接前设置
+	QueueSize int
+	// Overflow 发送队列满时的处理策略
+	Overflow OverflowPolicy
+
+	mu    sync.RWMutex
+	conns map[*Session]map[string]struct{} // 连接 -> 已加入的房间
+	rooms map[string]map[*Session]struct{} // 房间 -> 成员
+	ids   map[string]*Session              // 连接 ID -> 连接
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		conns: make(map[*Session]map[string]struct{}),
+		rooms: make(map[string]map[*Session]struct{}),
+		ids:   make(map[string]*Session),
+	}
+}
+
+// Register 登记新连接，并启动该连接的广播写协程
+func (h *Hub) Register(conn *Session) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.registerLocked(conn)
+}
+
+func (h *Hub) registerLocked(conn *Session) {
+	if _, ok := h.conns[conn]; ok {
+		return
+	}
+	h.conns[conn] = make(map[string]struct{})
+	if conn.ID != "" {
+		h.ids[conn.ID] = conn
+	}
+	size := h.QueueSize
+	if size <= 0 {
+		size = DefaultQueueSize
+	}
+	conn.sendq = make(chan []byte, size)
+	go h.writeLoop(conn, conn.sendq)
+}
+
+// writeLoop 依次发出队列中的广播，队列在 Unregister 时关闭
+func (h *Hub) writeLoop(conn *Session, q <-chan []byte) {
+	for msg := range q {
+		if conn.evicted.Load() {
+			continue // 已被踢出，丢弃剩余消息，不能写在 close 帧之后
+		}
+		if err := conn.WriteMessage(ws.OpText, msg); err != nil {
+			log.Printf("Broadcast to %q failed: %v", conn.UserID, err)
+		}
+	}
+}
+
+// Unregister 移除连接，并将其从所有房间中退出
+func (h *Hub) Unregister(conn *Session) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.conns[conn]; !ok {
+		return
+	}
+	for room := range h.conns[conn] {
+		h.leaveLocked(conn, room)
+	}
+	delete(h.conns, conn)
+	close(conn.sendq)
+	if h.ids[conn.ID] == conn {
+		delete(h.ids, conn.ID)
+	}
+}
+
+// CloseConnection 取消指定连接的 context，连接处理协程随后以 1001 关闭连接；
+// 连接不存在时返回 false
+func (h *Hub) CloseConnection(id string) bool {
+	h.mu.RLock()
+	conn, ok := h.ids[id]
+	h.mu.RUnlock()
+	if !ok || conn.cancel == nil {
+		return false
+	}
+	conn.cancel()
+	return true
+}
+
+// ConnectionIDs 返回在线连接的 ID（按名称排序）
+func (h *Hub) ConnectionIDs() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	ids := make([]string, 0, len(h.ids))
+	for id := range h.ids {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Join 将连接加入房间；连接未登记、已注销或已被踢出时返回 false，
+// 不能把已移除的连接重新登记回来
+func (h *Hub) Join(conn *Session, room string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	rooms, ok := h.conns[conn]
+	if !ok || conn.evicted.Load() {
+		return false
+	}
+	rooms[room] = struct{}{}
+
+	members, ok := h.rooms[room]
+	if !ok {
+		members = make(map[*Session]struct{})
+		h.rooms[room] = members
+	}
+	members[conn] = struct{}{}
+	return true
+}
+
+// Leave 将连接退出房间
+func (h *Hub) Leave(conn *Session, room string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.leaveLocked(conn, room)
+}
+
+func (h *Hub) leaveLocked(conn *Session, room string) {
+	delete(h.conns[conn], room)
+	if members, ok := h.rooms[room]; ok {
+		delete(members, conn)
+		if len(members) == 0 {
+			delete(h.rooms, room)
+		}
+	}
+}
+
+// Rooms 返回连接已加入的房间（按名称排序）
+func (h *Hub) Rooms(conn *Session) []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	rooms := make([]string, 0, len(h.conns[conn]))
+	for room := range h.conns[conn] {
+		rooms = append(rooms, room)
+	}
+	sort.Strings(rooms)
+	return rooms
+}
+
+// Broadcast 向所有在线连接发送消息
+func (h *Hub) Broadcast(msg []byte) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for conn := range h.conns {
+		h.enqueueLocked(conn, msg)
+	}
+}
+
+// BroadcastTo 仅向房间内的成员发送消息
+func (h *Hub) BroadcastTo(room string, msg []byte) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for conn := range h.rooms[room] {
+		h.enqueueLocked(conn, msg)
+	}
+}
+
+// enqueueLocked 非阻塞地放入发送队列，调用方持有读锁，保证队列不会在此期间被关闭
+func (h *Hub) enqueueLocked(conn *Session, msg []byte) {
+	select {
+	case conn.sendq <- msg:
+		return
+	default:
+	}
+	if h.Overflow == OverflowDrop {
+		log.Printf("Send queue of %q full, broadcast dropped", conn.UserID)
+		return
+	}
+	go h.evict(conn)
+}
+
+// evict 移除慢连接并以 1011 关闭。写协程可能正阻塞在对方不读的连接上，
+// 先把写 deadline 设为当前时间让它返回，close 帧只能尽力发送
+func (h *Hub) evict(conn *Session) {
+	if !conn.evicted.CompareAndSwap(false, true) {
+		return
+	}
+	log.Printf("Evicting slow consumer %s (%q)", conn.ID, conn.UserID)
+	h.Unregister(conn)
+
+	conn.SetWriteDeadline(time.Now())
+	conn.wmu.Lock()
+	conn.SetWriteDeadline(time.Now().Add(closeTimeout))
+	body := ws.NewCloseFrameBody(ws.StatusInternalServerError, "slow consumer")
+	wsutil.WriteServerMessage(conn, ws.OpClose, body)
+	conn.wmu.Unlock()
+
+	// 让处理协程退出；没有 context 的连接直接关闭
+	if conn.cancel != nil {
+		conn.cancel()
+	} else {
+		conn.Close()
+	}
+}
+
+// handleCommand 处理房间相关的文本指令，返回 false 表示不是指令：
+//
+//	/join <room>          加入房间
+//	/leave <room>         退出房间
+//	/send <room> <text>   向房间广播
+func (h *Hub) handleCommand(conn *Session, text string) (reply string, ok bool) {
+	if !strings.HasPrefix(text, "/") {
+		return "", false
+	}
+	cmd, rest, _ := strings.Cut(text, " ")
+	switch cmd {
+	case "/join":
+		if rest == "" {
+			return "usage: /join <room>", true
+		}
+		if !h.Join(conn, rest) {
+			return "not connected", true
+		}
+		return "joined " + rest, true
+	case "/leave":
+		if rest == "" {
+			return "usage: /leave <room>", true
+		}
+		h.Leave(conn, rest)
+		return "left " + rest, true
+	case "/send":
+		room, body, found := strings.Cut(rest, " ")
+		if !found || room == "" {
+			return "usage: /send <room> <text>", true
+		}
+		h.BroadcastTo(room, []byte(body))
+		return "", true
+	}
+	return "", false
+}
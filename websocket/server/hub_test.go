@@ -0,0 +1,231 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+
+	"test/websocket/message"
+)
+
+func send(t *testing.T, conn net.Conn, text string) {
+	t.Helper()
+	if err := message.WriteMessage(conn, ws.StateClientSide, ws.OpText, strings.NewReader(text)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func expect(t *testing.T, conn net.Conn, want string) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	got, _, err := message.ReadMessage(conn, ws.StateClientSide)
+	if err != nil {
+		t.Fatalf("waiting for %q: %v", want, err)
+	}
+	if string(got) != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestHubRoomIsolation(t *testing.T) {
+	addr := startServer(t)
+	alice, bob, carol := dial(t, addr), dial(t, addr), dial(t, addr)
+
+	send(t, alice, "/join red")
+	expect(t, alice, "joined red")
+	send(t, bob, "/join red")
+	expect(t, bob, "joined red")
+	send(t, carol, "/join blue")
+	expect(t, carol, "joined blue")
+
+	send(t, alice, "/send red hello red")
+	expect(t, alice, "hello red")
+	expect(t, bob, "hello red")
+
+	send(t, carol, "/send blue hello blue")
+	expect(t, carol, "hello blue")
+
+	// bob 不在 blue 房间，不应收到消息
+	bob.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if got, _, err := message.ReadMessage(bob, ws.StateClientSide); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("bob received %q (err=%v), want nothing", got, err)
+	}
+}
+
+func TestHubUnregisterLeavesAllRooms(t *testing.T) {
+	h := NewHub()
+	conn := &Session{}
+	h.Register(conn)
+	h.Join(conn, "a")
+	h.Join(conn, "b")
+	if rooms := h.Rooms(conn); len(rooms) != 2 {
+		t.Fatalf("rooms = %v", rooms)
+	}
+
+	h.Unregister(conn)
+	if len(h.rooms) != 0 || len(h.conns) != 0 {
+		t.Fatalf("hub not empty after unregister: rooms=%v conns=%d", h.rooms, len(h.conns))
+	}
+}
+
+func TestHubJoinRejectsRemovedConnection(t *testing.T) {
+	h := NewHub()
+	if h.Join(&Session{}, "a") {
+		t.Fatal("joined with an unregistered connection")
+	}
+
+	conn := &Session{}
+	h.Register(conn)
+	h.Unregister(conn)
+	if h.Join(conn, "a") {
+		t.Fatal("joined after unregister")
+	}
+
+	evicted := &Session{}
+	h.Register(evicted)
+	evicted.evicted.Store(true)
+	if h.Join(evicted, "a") {
+		t.Fatal("joined after eviction")
+	}
+	if len(h.rooms) != 0 {
+		t.Fatalf("rooms = %v, want none", h.rooms)
+	}
+}
+
+func TestCloseConnection(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &Server{}
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(ln) }()
+
+	conn := dial(t, "ws://"+ln.Addr().String())
+	send(t, conn, "hi")
+	expect(t, conn, "Hello from server! hi")
+
+	ids := srv.Hub.ConnectionIDs()
+	if len(ids) != 1 {
+		t.Fatalf("connection ids = %v, want one", ids)
+	}
+	if !srv.Hub.CloseConnection(ids[0]) {
+		t.Fatal("CloseConnection returned false")
+	}
+
+	// 服务端以 1001 关闭，处理协程退出后连接从 Hub 中移除
+	expectClose(t, conn, ws.StatusGoingAway)
+	waitUntil(t, func() bool { return srv.Metrics().ActiveConns == 0 && len(srv.Hub.ConnectionIDs()) == 0 })
+	if srv.Hub.CloseConnection(ids[0]) {
+		t.Fatal("CloseConnection succeeded for a closed connection")
+	}
+
+	// Shutdown 取消剩余连接并让 Serve 返回
+	other := dial(t, "ws://"+ln.Addr().String())
+	send(t, other, "hi")
+	expect(t, other, "Hello from server! hi")
+	if err := srv.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	expectClose(t, other, ws.StatusGoingAway)
+	if err := <-served; !errors.Is(err, ErrServerClosed) {
+		t.Fatalf("Serve returned %v, want ErrServerClosed", err)
+	}
+}
+
+func expectClose(t *testing.T, conn net.Conn, want ws.StatusCode) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err := message.ReadMessage(conn, ws.StateClientSide)
+	var closed wsutil.ClosedError
+	if !errors.As(err, &closed) || closed.Code != want {
+		t.Fatalf("read err = %v, want close %d", err, want)
+	}
+}
+
+func waitUntil(t *testing.T, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); !cond(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within 2s")
+		}
+	}
+}
+
+func TestOnCloseReportsPeerCode(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	type closeEvent struct {
+		code   ws.StatusCode
+		reason string
+	}
+	closed := make(chan closeEvent, 1)
+	srv := &Server{OnClose: func(s *Session, code ws.StatusCode, reason string) {
+		closed <- closeEvent{code, reason}
+	}}
+	go srv.Serve(ln)
+	defer srv.Shutdown()
+
+	conn := dial(t, "ws://"+ln.Addr().String())
+	body := ws.NewCloseFrameBody(ws.StatusNormalClosure, "client requested")
+	if err := wsutil.WriteClientMessage(conn, ws.OpClose, body); err != nil {
+		t.Fatal(err)
+	}
+	// 服务端回复同样的 close 帧
+	expectClose(t, conn, ws.StatusNormalClosure)
+
+	select {
+	case ev := <-closed:
+		if ev.code != ws.StatusNormalClosure || ev.reason != "client requested" {
+			t.Fatalf("OnClose got %d %q", ev.code, ev.reason)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnClose not called")
+	}
+}
+
+func TestHubEvictsSlowConsumer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub := NewHub()
+	hub.QueueSize = 4
+	srv := &Server{Hub: hub}
+	go srv.Serve(ln)
+	defer srv.Shutdown()
+	addr := "ws://" + ln.Addr().String()
+
+	dial(t, addr) // 慢消费者：连上后从不读取
+	healthy := []net.Conn{dial(t, addr), dial(t, addr)}
+	waitUntil(t, func() bool { return len(srv.Hub.ConnectionIDs()) == 3 })
+
+	// 每轮广播后等健康客户端都收到再继续，只有不读的连接会积压
+	payload := strings.Repeat("x", 64<<10)
+	evicted := false
+	for i := 0; i < 2000 && !evicted; i++ {
+		srv.Hub.Broadcast([]byte(payload))
+		for _, c := range healthy {
+			expect(t, c, payload)
+		}
+		evicted = len(srv.Hub.ConnectionIDs()) == 2
+	}
+	if !evicted {
+		t.Fatal("stalled consumer was never evicted")
+	}
+
+	// 踢出后广播照常送达健康客户端
+	srv.Hub.Broadcast([]byte("still here"))
+	for _, c := range healthy {
+		expect(t, c, "still here")
+	}
+	waitUntil(t, func() bool { return srv.Metrics().ActiveConns == 2 })
+}
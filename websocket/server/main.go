@@ -1,10 +1,25 @@
 package main
 
 import (
-	"github.com/gobwas/ws"
-	"github.com/gobwas/ws/wsutil"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsflate"
+	"github.com/gobwas/ws/wsutil"
+
+	"test/websocket/message"
 )
 
 func main() {
@@ -14,43 +29,372 @@ func main() {
 		log.Fatal(err)
 	}
 
+	srv := &Server{
+		Auth: StaticAuthenticator{"demo-token": "demo-user"},
+	}
+
+	// 指标单独监听 9090 端口，不经过 WebSocket 鉴权
+	go func() {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", srv.MetricsHandler())
+		log.Println("Metrics server:", http.ListenAndServe(":9090", mux))
+	}()
+
+	// Ctrl+C 时以 1001 关闭所有连接后退出
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt)
+		<-sig
+		srv.Shutdown()
+	}()
+	if err := srv.Serve(ln); !errors.Is(err, ErrServerClosed) {
+		log.Fatal(err)
+	}
+	time.Sleep(closeTimeout) // 等待连接完成关闭握手
+}
+
+// closeTimeout 主动关闭时等待对端回复 close 帧的最长时间
+const closeTimeout = time.Second
+
+// Server WebSocket 服务端
+type Server struct {
+	// Auth 握手鉴权，为 nil 时不校验令牌
+	Auth Authenticator
+	// RateLimit 单连接限流，零值表示不限流
+	RateLimit RateLimitConfig
+	// Hub 连接与房间管理，为 nil 时在 Serve 中创建
+	Hub *Hub
+	// Codec 消息编解码，为 nil 时使用 message.RawCodec（文本为 string，二进制为 []byte）
+	Codec message.Codec
+	// Handle 处理 Codec 解出的结构化消息，返回非 nil 时编码后回复给发送方。
+	// 使用 message.FrameCodec 时 msg 为 *message.Frame，返回值作为 reply 帧的 payload，
+	// 回复的 id 与请求相同；Handle 为 nil 时原样回显请求的 payload
+	Handle func(s *Session, msg any) any
+	// Subprotocols 支持的子协议，为空时不协商 Sec-WebSocket-Protocol
+	Subprotocols []string
+	// RequireSubprotocol 为 true 时客户端必须协商出一个支持的子协议，否则以 400 拒绝升级
+	RequireSubprotocol bool
+	// OnClose 对端发送 close 帧断开时回调，code 和 reason 取自 close 帧
+	OnClose func(s *Session, code ws.StatusCode, reason string)
+	// MaxConnAge 单个连接的最长存活时间，到期后服务端以 1001 关闭连接，0 表示不限制
+	MaxConnAge time.Duration
+
+	metrics serverMetrics
+	nextID  atomic.Int64
+
+	mu     sync.Mutex
+	ln     net.Listener
+	ctx    context.Context // 所有连接 context 的父 context，Shutdown 时取消
+	cancel context.CancelFunc
+}
+
+// ErrServerClosed Shutdown 之后 Serve 返回的错误
+var ErrServerClosed = errors.New("websocket: server closed")
+
+func (srv *Server) init() {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.ctx == nil {
+		srv.ctx, srv.cancel = context.WithCancel(context.Background())
+	}
+}
+
+// Shutdown 停止接受新连接，并取消所有连接的 context，连接会以 1001 关闭
+func (srv *Server) Shutdown() error {
+	srv.init()
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.cancel()
+	if srv.ln != nil {
+		return srv.ln.Close()
+	}
+	return nil
+}
+
+// Serve 接受连接并完成协议升级，每个连接交给独立的 goroutine 处理
+func (srv *Server) Serve(ln net.Listener) error {
+	if srv.Hub == nil {
+		srv.Hub = NewHub()
+	}
+	if srv.Codec == nil {
+		srv.Codec = message.RawCodec{}
+	}
+	srv.init()
+	srv.mu.Lock()
+	if srv.ctx.Err() != nil {
+		srv.mu.Unlock()
+		ln.Close()
+		return ErrServerClosed
+	}
+	srv.ln = ln
+	srv.mu.Unlock()
+
 	for {
 		// 接受客户端的连接
 		conn, err := ln.Accept()
 		if err != nil {
-			log.Fatal(err)
+			if srv.ctx.Err() != nil {
+				return ErrServerClosed
+			}
+			return err
 		}
 
-		// 协议升级，建立 WebSocket 连接
-		_, err = ws.Upgrade(conn)
+		// 协议升级，建立 WebSocket 连接；客户端提供 permessage-deflate 时协商压缩
+		// wsflate.Extension 保存协商结果，必须每个连接单独创建
+		ext := wsflate.Extension{Parameters: wsflate.DefaultParameters}
+		upgrader := ws.Upgrader{Negotiate: ext.Negotiate}
+		var auth *tokenAuth
+		if srv.Auth != nil {
+			auth = &tokenAuth{auth: srv.Auth}
+			upgrader.OnRequest = auth.onRequest
+			upgrader.OnHeader = auth.onHeader
+		}
+		var proto *protocolSelector
+		if len(srv.Subprotocols) > 0 {
+			proto = &protocolSelector{supported: srv.Subprotocols, required: srv.RequireSubprotocol}
+			upgrader.Protocol = proto.selectProtocol
+		}
+		// 先鉴权再校验子协议，未鉴权的请求统一返回 401
+		upgrader.OnBeforeUpgrade = func() (ws.HandshakeHeader, error) {
+			if auth != nil {
+				if _, err := auth.onBeforeUpgrade(); err != nil {
+					return nil, err
+				}
+			}
+			if proto != nil {
+				return proto.onBeforeUpgrade()
+			}
+			return nil, nil
+		}
+		hs, err := upgrader.Upgrade(conn)
 		if err != nil {
 			log.Println("Upgrade error:", err)
 			conn.Close()
 			continue
 		}
 
-		go handleConnection(conn)
+		session := &Session{
+			Conn:     conn,
+			ID:       fmt.Sprintf("conn-%d", srv.nextID.Add(1)),
+			Protocol: hs.Protocol,
+			codec:    srv.Codec,
+			limiter:  newRateLimiter(srv.RateLimit),
+			metrics:  &srv.metrics,
+		}
+		if srv.MaxConnAge > 0 {
+			session.ctx, session.cancel = context.WithTimeout(srv.ctx, srv.MaxConnAge)
+		} else {
+			session.ctx, session.cancel = context.WithCancel(srv.ctx)
+		}
+		// context 取消时阻塞中的读立即返回 message.ErrReadCanceled
+		session.Conn = message.NewContextConn(session.ctx, conn)
+		_, session.Deflate = ext.Accepted()
+		if auth != nil {
+			session.UserID = auth.userID
+		}
+		srv.metrics.connOpened()
+		go srv.handleConnection(session)
 	}
 }
 
-func handleConnection(conn net.Conn) {
+// Session 一个已完成升级的 WebSocket 连接及其握手协商结果
+type Session struct {
+	net.Conn
+	ID       string // 连接 ID，Hub.CloseConnection 使用
+	Deflate  bool   // 是否协商了 permessage-deflate
+	Protocol string // 协商出的子协议，未协商时为空
+	UserID   string // 鉴权通过的用户 ID，未启用鉴权时为空
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	codec   message.Codec
+	limiter *rateLimiter
+	metrics *serverMetrics
+	wmu     sync.Mutex  // 本连接的回复与 Hub 广播可能并发写
+	sendq   chan []byte // Hub 广播发送队列
+	evicted atomic.Bool // 因发送队列溢出被 Hub 踢出
+}
+
+// ReadMessage 读取一条完整消息，已协商压缩时自动解压；
+// ping/close 的回复与 WriteMessage、Hub 广播一样在 wmu 下写出
+func (s *Session) ReadMessage() ([]byte, ws.OpCode, error) {
+	return message.ReadMessageWith(s.Conn, ws.StateServerSide, message.ReadOptions{Deflate: s.Deflate, WriteLock: &s.wmu})
+}
+
+// WriteMessage 写出一条消息，已协商压缩时压缩后发送
+func (s *Session) WriteMessage(op ws.OpCode, p []byte) error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	var err error
+	if s.Deflate {
+		err = message.WriteMessageDeflate(s.Conn, ws.StateServerSide, op, bytes.NewReader(p))
+	} else {
+		err = message.WriteMessage(s.Conn, ws.StateServerSide, op, bytes.NewReader(p))
+	}
+	if err == nil {
+		s.metrics.written(len(p))
+	}
+	return err
+}
+
+// Context 连接的 context，连接被关闭、超过 MaxConnAge 或服务端 Shutdown 时取消
+func (s *Session) Context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
+// Send 用连接的编解码器编码 v 后发送
+func (s *Session) Send(v any) error {
+	p, op, err := s.codec.Encode(v)
+	if err != nil {
+		return err
+	}
+	return s.WriteMessage(op, p)
+}
+
+func (srv *Server) handleConnection(conn *Session) {
+	defer srv.metrics.connClosed() // Serve 中已计入，任何退出路径都要减回去
 	defer conn.Close()
+	defer conn.cancel()
+	srv.Hub.Register(conn)
+	defer srv.Hub.Unregister(conn) // 断开时退出所有房间
+
+	// 读由 ContextConn 打断；context 取消时同样把写 deadline 设为当前时间，阻塞中的回复立即返回
+	stop := context.AfterFunc(conn.ctx, func() { conn.SetWriteDeadline(time.Now()) })
+	defer stop()
 
 	for {
-		// 读取客户端消息
-		msg, op, err := wsutil.ReadClientData(conn)
+		// 读取客户端消息，分片帧会被重组为完整消息
+		msg, op, err := conn.ReadMessage()
 		if err != nil {
+			if conn.evicted.Load() {
+				return // Hub 已发送 1011
+			}
+			if errors.Is(err, message.ErrReadCanceled) {
+				log.Printf("Connection %s closed by server: %v", conn.ID, context.Cause(conn.ctx))
+				conn.SetDeadline(time.Time{})
+				closeWithStatus(conn, ws.StatusGoingAway, "server closing connection")
+				return
+			}
+			if code, reason, ok := message.CloseStatus(err); ok {
+				// close 帧的回复已由 ReadMessage 中的控制帧处理器发出
+				log.Printf("Connection %s closed by peer: %d %s", conn.ID, code, reason)
+				if srv.OnClose != nil {
+					srv.OnClose(conn, code, reason)
+				}
+				return
+			}
 			log.Println("Read error:", err)
 			return
 		}
+		srv.metrics.read(len(msg))
 
-		log.Printf("Received: %s\n", string(msg))
+		// 限流：超出后丢弃消息或以 1008 关闭连接
+		if !conn.limiter.allow(len(msg)) {
+			if srv.RateLimit.Policy == RateClose {
+				log.Printf("Rate limit exceeded by %q, closing connection", conn.UserID)
+				closeWithStatus(conn, ws.StatusPolicyViolation, "rate limit exceeded")
+				return
+			}
+			log.Printf("Rate limit exceeded by %q, message dropped", conn.UserID)
+			continue
+		}
 
-		// 回复消息
-		err = wsutil.WriteServerMessage(conn, op, []byte("Hello from server! "+string(msg)))
+		v, err := srv.Codec.Decode(msg, op)
+		if err != nil {
+			log.Printf("Decode error from %q: %v", conn.UserID, err)
+			ee, ok := srv.Codec.(message.ErrorEncoder)
+			if !ok {
+				continue
+			}
+			p, op, err := ee.EncodeError(msg, err)
+			if err == nil {
+				err = conn.WriteMessage(op, p)
+			}
+			if err != nil {
+				log.Println("Write error:", err)
+				return
+			}
+			continue
+		}
+
+		var reply any
+		switch v := v.(type) {
+		case []byte:
+			// 二进制消息原样回显
+			log.Printf("Received binary: %d bytes\n", len(v))
+			reply = v
+		case string:
+			log.Printf("Received from %q: %s\n", conn.UserID, v)
+			if text, ok := srv.Hub.handleCommand(conn, v); ok {
+				if text == "" {
+					continue
+				}
+				reply = text
+				break
+			}
+			reply = "Hello from server! " + v
+		case *message.Frame:
+			if reply = srv.replyFrame(conn, v); reply == nil {
+				continue
+			}
+		default:
+			if srv.Handle == nil {
+				log.Printf("No handler for %T from %q", v, conn.UserID)
+				continue
+			}
+			if reply = srv.Handle(conn, v); reply == nil {
+				continue
+			}
+		}
+
+		// 回复消息，raw 编解码下保持原始 opcode，大消息自动分片
+		err = conn.Send(reply)
 		if err != nil {
 			log.Println("Write error:", err)
 			return
 		}
 	}
 }
+
+// replyFrame 生成帧协议请求的回复：id 与请求相同，type 为 reply；
+// Handle 返回 nil 时不回复，返回值无法编码为 JSON 时回复错误帧
+func (srv *Server) replyFrame(s *Session, req *message.Frame) any {
+	payload := req.Payload
+	if srv.Handle != nil {
+		v := srv.Handle(s, req)
+		if v == nil {
+			return nil
+		}
+		p, err := json.Marshal(v)
+		if err != nil {
+			return message.ErrorFrame(req.ID, err)
+		}
+		payload = p
+	}
+	return &message.Frame{ID: req.ID, Type: message.FrameReply, Payload: payload}
+}
+
+// closeWithStatus 发送带状态码的 close 帧，并等待对端回复 close 后再返回，
+// 避免接收缓冲区中尚有未读数据时直接关闭 TCP 导致对端收到 RST 而丢失 close 帧
+func closeWithStatus(conn *Session, code ws.StatusCode, reason string) {
+	body := ws.NewCloseFrameBody(code, reason)
+	// 与 Hub 的广播写协程共用写锁，close 帧不能和广播帧交错
+	conn.wmu.Lock()
+	err := wsutil.WriteServerMessage(conn, ws.OpClose, body)
+	conn.wmu.Unlock()
+	if err != nil {
+		return
+	}
+	conn.SetReadDeadline(time.Now().Add(closeTimeout))
+	for {
+		// 丢弃剩余数据帧，直到读到对端的 close 帧或超时
+		f, err := ws.ReadFrame(conn)
+		if err != nil || f.Header.OpCode == ws.OpClose {
+			return
+		}
+	}
+}
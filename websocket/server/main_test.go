@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/gobwas/httphead"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsflate"
+
+	"test/websocket/message"
+)
+
+func startServer(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go (&Server{}).Serve(ln)
+	return "ws://" + ln.Addr().String()
+}
+
+func dial(t *testing.T, addr string) net.Conn {
+	conn, _, _, err := ws.DefaultDialer.Dial(context.Background(), addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestBinaryRoundTrip1MB(t *testing.T) {
+	conn := dial(t, startServer(t))
+
+	payload := make([]byte, 1<<20)
+	rand.Read(payload)
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- message.WriteMessage(conn, ws.StateClientSide, ws.OpBinary, bytes.NewReader(payload))
+	}()
+
+	got, op, err := message.ReadMessage(conn, ws.StateClientSide)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if op != ws.OpBinary {
+		t.Fatalf("op = %v, want binary", op)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Fatalf("payload mismatch: got %d bytes", len(got))
+	}
+}
+
+func TestPermessageDeflateNegotiation(t *testing.T) {
+	addr := startServer(t)
+
+	dialer := ws.Dialer{
+		Extensions: []httphead.Option{wsflate.DefaultParameters.Option()},
+	}
+	conn, _, hs, err := dialer.Dial(context.Background(), addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if len(hs.Extensions) != 1 || string(hs.Extensions[0].Name) != wsflate.ExtensionName {
+		t.Fatalf("negotiated extensions = %v, want permessage-deflate", hs.Extensions)
+	}
+
+	text := strings.Repeat("compress me ", 1000)
+	if err := message.WriteMessageDeflate(conn, ws.StateClientSide, ws.OpText, strings.NewReader(text)); err != nil {
+		t.Fatal(err)
+	}
+	got, op, err := message.ReadMessageDeflate(conn, ws.StateClientSide)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op != ws.OpText || string(got) != "Hello from server! "+text {
+		t.Fatalf("unexpected reply: op=%v len=%d", op, len(got))
+	}
+}
+
+func TestNoDeflateWhenNotOffered(t *testing.T) {
+	conn, _, hs, err := ws.DefaultDialer.Dial(context.Background(), startServer(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if len(hs.Extensions) != 0 {
+		t.Fatalf("unexpected extensions: %v", hs.Extensions)
+	}
+
+	if err := message.WriteMessage(conn, ws.StateClientSide, ws.OpText, strings.NewReader("plain")); err != nil {
+		t.Fatal(err)
+	}
+	got, _, err := message.ReadMessage(conn, ws.StateClientSide)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "Hello from server! plain" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+type pingMsg struct {
+	Seq int `json:"seq"`
+}
+
+type pongMsg struct {
+	Seq  int    `json:"seq"`
+	User string `json:"user"`
+}
+
+func TestJSONCodecHandler(t *testing.T) {
+	codec := message.NewJSONCodec()
+	codec.Register("ping", pingMsg{})
+	codec.Register("pong", pongMsg{})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go (&Server{Codec: codec, Handle: func(s *Session, msg any) any {
+		if p, ok := msg.(*pingMsg); ok {
+			return pongMsg{Seq: p.Seq + 1, User: s.UserID}
+		}
+		return nil
+	}}).Serve(ln)
+	conn := dial(t, "ws://"+ln.Addr().String())
+
+	data, op, err := codec.Encode(pingMsg{Seq: 41})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := message.WriteMessage(conn, ws.StateClientSide, op, bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	data, op, err = message.ReadMessage(conn, ws.StateClientSide)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, err := codec.Decode(data, op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pong, ok := reply.(*pongMsg); !ok || pong.Seq != 42 {
+		t.Fatalf("reply = %#v, want pong 42", reply)
+	}
+}
+
+func TestFrameProtocolMatchesRepliesByID(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go (&Server{Codec: message.FrameCodec{}, Handle: func(s *Session, msg any) any {
+		var text string
+		if err := json.Unmarshal(msg.(*message.Frame).Payload, &text); err != nil {
+			return nil
+		}
+		return strings.ToUpper(text)
+	}}).Serve(ln)
+	conn := dial(t, "ws://"+ln.Addr().String())
+
+	// 连续发出两个请求后再读回复，按 id 对应
+	requests := map[string]string{"req-1": "alpha", "req-2": "beta"}
+	for _, id := range []string{"req-1", "req-2"} {
+		data := fmt.Sprintf(`{"id":%q,"type":"upper","payload":%q}`, id, requests[id])
+		if err := message.WriteMessage(conn, ws.StateClientSide, ws.OpText, strings.NewReader(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := message.WriteMessage(conn, ws.StateClientSide, ws.OpText, strings.NewReader(`{"id":"req-3",`)); err != nil {
+		t.Fatal(err)
+	}
+
+	replies := make(map[string]*message.Frame)
+	for i := 0; i < 3; i++ {
+		data, _, err := message.ReadMessage(conn, ws.StateClientSide)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 错误帧可能没有 id，FrameCodec.Decode 会拒绝，直接按 JSON 解析
+		f := &message.Frame{}
+		if err := json.Unmarshal(data, f); err != nil {
+			t.Fatalf("reply %s: %v", data, err)
+		}
+		replies[f.ID] = f
+	}
+
+	for id, text := range requests {
+		f := replies[id]
+		var got string
+		if f == nil || f.Type != message.FrameReply || json.Unmarshal(f.Payload, &got) != nil || got != strings.ToUpper(text) {
+			t.Fatalf("reply for %s = %+v, want reply %q", id, f, strings.ToUpper(text))
+		}
+	}
+	// 截断的 JSON 无法取出 id，错误帧的 id 为空
+	if f := replies[""]; f == nil || f.Type != message.FrameError || !strings.Contains(string(f.Payload), "frame codec") {
+		t.Fatalf("malformed request reply = %+v, want error frame", f)
+	}
+}
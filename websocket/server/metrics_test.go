@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws"
+)
+
+func TestMetricsActiveConnsReturnToZero(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	srv := &Server{}
+	go srv.Serve(ln)
+	addr := "ws://" + ln.Addr().String()
+
+	const clients = 10
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conn, _, _, err := ws.DefaultDialer.Dial(context.Background(), addr)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer conn.Close()
+			send(t, conn, "hi")
+			expect(t, conn, "Hello from server! hi")
+			if i%2 == 0 {
+				// 一半正常关闭，一半直接断开 TCP，走读错误路径
+				ws.WriteFrame(conn, ws.MaskFrame(ws.NewCloseFrame(ws.NewCloseFrameBody(ws.StatusNormalClosure, ""))))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for srv.Metrics().ActiveConns != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("active conns = %d, want 0", srv.Metrics().ActiveConns)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	m := srv.Metrics()
+	if m.TotalConns != clients || m.MessagesIn != clients || m.MessagesOut != clients {
+		t.Fatalf("metrics = %+v, want %d conns/messages each way", m, clients)
+	}
+	if m.BytesRead != clients*int64(len("hi")) || m.BytesWritten != clients*int64(len("Hello from server! hi")) {
+		t.Fatalf("byte counters = %+v", m)
+	}
+
+	rec := httptest.NewRecorder()
+	srv.MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	if !strings.Contains(string(body), "websocket_active_connections 0\n") ||
+		!strings.Contains(string(body), "websocket_connections_total 10\n") {
+		t.Fatalf("unexpected /metrics output:\n%s", body)
+	}
+}
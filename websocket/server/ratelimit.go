@@ -0,0 +1,106 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// RatePolicy 超出限流后的处理策略
+type RatePolicy int
+
+const (
+	// RateDrop 丢弃超限的消息，连接保持
+	RateDrop RatePolicy = iota
+	// RateClose 以 1008（policy violation）关闭连接
+	RateClose
+)
+
+// RateLimitConfig 单个连接的限流配置，速率为 0 表示不限制
+type RateLimitConfig struct {
+	MessagesPerSec float64 // 每秒消息数
+	MessageBurst   int     // 消息数突发上限
+	BytesPerSec    float64 // 每秒字节数
+	ByteBurst      int     // 字节数突发上限
+	Policy         RatePolicy
+}
+
+// tokenBucket 令牌桶：按 rate 匀速补充令牌，最多累积 burst 个。
+// 不带锁，由所属的 rateLimiter.mu 保护；nil 桶表示不限流
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		// 速率低于 1/s 时 int(rate) 为 0，桶永远攒不满一个令牌，至少允许 1 个
+		burst = max(int(rate), 1)
+	}
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst)}
+}
+
+// refill 补充从上次到 now 之间的令牌
+func (b *tokenBucket) refill(now time.Time) {
+	if b == nil {
+		return
+	}
+	if !b.last.IsZero() {
+		b.tokens = min(b.tokens+now.Sub(b.last).Seconds()*b.rate, b.burst)
+	}
+	b.last = now
+}
+
+func (b *tokenBucket) has(n float64) bool {
+	return b == nil || b.tokens >= n
+}
+
+func (b *tokenBucket) take(n float64) {
+	if b != nil {
+		b.tokens -= n
+	}
+}
+
+// allow 尝试消耗 n 个令牌
+func (b *tokenBucket) allow(n float64, now time.Time) bool {
+	b.refill(now)
+	if !b.has(n) {
+		return false
+	}
+	b.take(n)
+	return true
+}
+
+// rateLimiter 同时限制消息数和字节数
+type rateLimiter struct {
+	mu       sync.Mutex
+	messages *tokenBucket
+	bytes    *tokenBucket
+}
+
+func newRateLimiter(cfg RateLimitConfig) *rateLimiter {
+	return &rateLimiter{
+		messages: newTokenBucket(cfg.MessagesPerSec, cfg.MessageBurst),
+		bytes:    newTokenBucket(cfg.BytesPerSec, cfg.ByteBurst),
+	}
+}
+
+// allow 判断一条 size 字节的消息是否允许通过。两个桶都够时才扣减，
+// 因字节数超限被拒绝的消息不会白白消耗消息数令牌
+func (l *rateLimiter) allow(size int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.messages.refill(now)
+	l.bytes.refill(now)
+	if !l.messages.has(1) || !l.bytes.has(float64(size)) {
+		return false
+	}
+	l.messages.take(1)
+	l.bytes.take(float64(size))
+	return true
+}
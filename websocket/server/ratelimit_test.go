@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+
+	"test/websocket/message"
+)
+
+func TestRateLimitClosesWithPolicyViolation(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	srv := &Server{RateLimit: RateLimitConfig{MessagesPerSec: 5, MessageBurst: 5, Policy: RateClose}}
+	go srv.Serve(ln)
+
+	conn, _, _, err := ws.DefaultDialer.Dial(context.Background(), "ws://"+ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// 突发发送超过上限的消息
+	for i := 0; i < 20; i++ {
+		if err := message.WriteMessage(conn, ws.StateClientSide, ws.OpText, strings.NewReader("flood")); err != nil {
+			break
+		}
+	}
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		_, _, err := message.ReadMessage(conn, ws.StateClientSide)
+		if err == nil {
+			continue
+		}
+		var closed wsutil.ClosedError
+		if !errors.As(err, &closed) {
+			t.Fatalf("err = %v, want close frame", err)
+		}
+		if closed.Code != ws.StatusPolicyViolation {
+			t.Fatalf("close code = %d, want 1008", closed.Code)
+		}
+		return
+	}
+}
+
+func TestTokenBucketRefills(t *testing.T) {
+	b := newTokenBucket(10, 2)
+	now := time.Now()
+	if !b.allow(1, now) || !b.allow(1, now) {
+		t.Fatal("burst should be allowed")
+	}
+	if b.allow(1, now) {
+		t.Fatal("bucket should be empty")
+	}
+	if !b.allow(1, now.Add(100*time.Millisecond)) {
+		t.Fatal("bucket should refill after 100ms at 10/s")
+	}
+}
+
+func TestTokenBucketBurstAtLeastOne(t *testing.T) {
+	// 0.5 条/秒且未设置突发上限：第一条消息也必须能通过
+	b := newTokenBucket(0.5, 0)
+	now := time.Now()
+	if !b.allow(1, now) {
+		t.Fatal("first message rejected with rate < 1")
+	}
+	if b.allow(1, now.Add(time.Second)) || !b.allow(1, now.Add(2*time.Second)) {
+		t.Fatal("bucket should refill one token every 2s")
+	}
+}
+
+func TestRateLimiterOversizedMessageKeepsMessageToken(t *testing.T) {
+	l := newRateLimiter(RateLimitConfig{MessagesPerSec: 1, MessageBurst: 1, BytesPerSec: 100, ByteBurst: 100})
+	if l.allow(1000) {
+		t.Fatal("message larger than the byte burst allowed")
+	}
+	// 被字节数拒绝的消息不消耗消息数令牌
+	if !l.allow(10) {
+		t.Fatal("next small message rejected")
+	}
+}
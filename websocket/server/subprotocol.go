@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gobwas/ws"
+)
+
+var errUnsupportedProtocol = ws.RejectConnectionError(
+	ws.RejectionStatus(http.StatusBadRequest),
+	ws.RejectionReason("unsupported subprotocol"),
+)
+
+// protocolSelector 从客户端 Sec-WebSocket-Protocol 列表中按客户端给出的顺序
+// 选出第一个服务端支持的子协议，ws.Upgrader 会把选中的协议写回响应头
+type protocolSelector struct {
+	supported []string
+	required  bool // 为 true 时没有协商出子协议（包括客户端未提供）则拒绝升级
+	selected  string
+}
+
+func (p *protocolSelector) selectProtocol(proto []byte) bool {
+	for _, s := range p.supported {
+		if string(proto) == s {
+			p.selected = s
+			return true
+		}
+	}
+	return false
+}
+
+func (p *protocolSelector) onBeforeUpgrade() (ws.HandshakeHeader, error) {
+	if p.required && p.selected == "" {
+		return nil, errUnsupportedProtocol
+	}
+	return nil, nil
+}
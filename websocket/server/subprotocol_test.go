@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/gobwas/ws"
+)
+
+func TestSubprotocolNegotiation(t *testing.T) {
+	tests := []struct {
+		name     string
+		offered  []string
+		required bool
+		want     string
+		status   int // 期望的拒绝状态码，0 表示升级成功
+	}{
+		{name: "matched", offered: []string{"v2.chat", "json"}, want: "json"},
+		{name: "client order wins", offered: []string{"chat", "json"}, want: "chat"},
+		{name: "unmatched optional", offered: []string{"xml"}, want: ""},
+		{name: "unmatched required", offered: []string{"xml"}, required: true, status: http.StatusBadRequest},
+		{name: "absent optional", want: ""},
+		{name: "absent required", required: true, status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv := &Server{Subprotocols: []string{"json", "chat"}, RequireSubprotocol: tt.required}
+			go srv.Serve(ln)
+			defer srv.Shutdown()
+
+			dialer := ws.Dialer{Protocols: tt.offered}
+			conn, _, hs, err := dialer.Dial(context.Background(), "ws://"+ln.Addr().String())
+			if tt.status != 0 {
+				var se ws.StatusError
+				if !errors.As(err, &se) || int(se) != tt.status {
+					t.Fatalf("dial err = %v, want status %d", err, tt.status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			if hs.Protocol != tt.want {
+				t.Fatalf("client protocol = %q, want %q", hs.Protocol, tt.want)
+			}
+
+			// 处理协程拿到的是同一个协商结果
+			send(t, conn, "hi")
+			expect(t, conn, "Hello from server! hi")
+			srv.Hub.mu.RLock()
+			for s := range srv.Hub.conns {
+				if s.Protocol != tt.want {
+					t.Errorf("session protocol = %q, want %q", s.Protocol, tt.want)
+				}
+			}
+			srv.Hub.mu.RUnlock()
+		})
+	}
+}
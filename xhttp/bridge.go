@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"test/pb"
+)
+
+// BridgeConfig REST 转 gRPC 桥接的客户端配置
+type BridgeConfig struct {
+	DialTimeout  time.Duration // 阻塞拨号等待连接就绪的最长时间
+	CallTimeout  time.Duration // 单个 REST 请求内 gRPC 调用（含重试）的总时长
+	MaxRetries   int           // Unavailable 时的最大重试次数
+	RetryBackoff time.Duration // 首次重试间隔，之后每次翻倍
+}
+
+var defaultBridgeConfig = BridgeConfig{
+	DialTimeout:  3 * time.Second,
+	CallTimeout:  5 * time.Second,
+	MaxRetries:   3,
+	RetryBackoff: 100 * time.Millisecond,
+}
+
+// retryUnavailable 对返回 Unavailable 的一元调用按指数退避重试，
+// 其他错误和 context 结束时直接返回最后一次的错误
+func retryUnavailable(maxRetries int, backoff time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		for attempt := 0; ; attempt++ {
+			err := invoker(ctx, method, req, reply, cc, opts...)
+			if status.Code(err) != codes.Unavailable || attempt >= maxRetries {
+				return err
+			}
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(backoff << attempt):
+			}
+		}
+	}
+}
+
+// GoodsBridge 把 REST 请求转成对 GoodsService 的 gRPC 调用。
+// 连接在第一次请求时建立，拨号失败不缓存，下一次请求重新拨号，
+// 因此 gRPC 服务晚于 HTTP 启动或短暂不可用时不会让桥接永久失效
+type GoodsBridge struct {
+	addr string
+	cfg  BridgeConfig
+
+	mu   sync.Mutex
+	conn *grpc.ClientConn
+}
+
+func NewGoodsBridge(addr string, cfg BridgeConfig) *GoodsBridge {
+	return &GoodsBridge{addr: addr, cfg: cfg}
+}
+
+// client 返回已建立的连接，没有时以 WithBlock 阻塞拨号，最多等待 DialTimeout（或 ctx 先到期）
+func (b *GoodsBridge) client(ctx context.Context) (pb.GoodsServiceClient, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.conn != nil {
+		return pb.NewGoodsServiceClient(b.conn), nil
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, b.cfg.DialTimeout)
+	defer cancel()
+	// 默认重连退避从 1s 起步，缩短后拨号窗口内能多试几次
+	bc := backoff.DefaultConfig
+	bc.BaseDelay, bc.MaxDelay = 50*time.Millisecond, time.Second
+	// NewClient 不支持 WithBlock，这里需要阻塞拨号，沿用 DialContext
+	conn, err := grpc.DialContext(dialCtx, b.addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithBlock(),
+		grpc.WithConnectParams(grpc.ConnectParams{Backoff: bc, MinConnectTimeout: b.cfg.DialTimeout}),
+		grpc.WithUnaryInterceptor(retryUnavailable(b.cfg.MaxRetries, b.cfg.RetryBackoff)),
+	)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "dial %s: %v", b.addr, err)
+	}
+	b.conn = conn
+	return pb.NewGoodsServiceClient(conn), nil
+}
+
+func (b *GoodsBridge) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.conn == nil {
+		return nil
+	}
+	err := b.conn.Close()
+	b.conn = nil
+	return err
+}
+
+// Register 挂载 REST 接口：
+//
+//	GET /api/hello?name=xxx  -> SayHello
+//	GET /api/goods?limit=n   -> ListGoods，收齐流后以 JSON 数组返回
+func (b *GoodsBridge) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/hello", b.handleHello)
+	mux.HandleFunc("GET /api/goods", b.handleGoods)
+}
+
+func (b *GoodsBridge) handleHello(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), b.cfg.CallTimeout)
+	defer cancel()
+	client, err := b.client(ctx)
+	if err != nil {
+		writeGRPCError(w, err)
+		return
+	}
+	resp, err := client.SayHello(ctx, &pb.HelloRequest{Name: r.URL.Query().Get("name")})
+	if err != nil {
+		writeGRPCError(w, err)
+		return
+	}
+	writeJSON(w, map[string]string{"message": resp.Message})
+}
+
+func (b *GoodsBridge) handleGoods(w http.ResponseWriter, r *http.Request) {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil && r.URL.Query().Has("limit") {
+		http.Error(w, "invalid limit", http.StatusBadRequest)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), b.cfg.CallTimeout)
+	defer cancel()
+	client, err := b.client(ctx)
+	if err != nil {
+		writeGRPCError(w, err)
+		return
+	}
+	stream, err := client.ListGoods(ctx, &pb.ListGoodsRequest{Limit: int32(limit)})
+	if err != nil {
+		writeGRPCError(w, err)
+		return
+	}
+	goods := []*pb.GoodsReply{}
+	for {
+		g, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			writeGRPCError(w, err)
+			return
+		}
+		goods = append(goods, g)
+	}
+	writeJSON(w, goods)
+}
+
+// writeGRPCError 后端不可用或超时返回 503，其余 gRPC 错误视为网关错误返回 502
+func writeGRPCError(w http.ResponseWriter, err error) {
+	code := http.StatusBadGateway
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		code = http.StatusServiceUnavailable
+	}
+	http.Error(w, err.Error(), code)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"test/pb"
+)
+
+// freeAddr 取一个当前空闲的本地端口，稍后再在上面启动 gRPC 服务
+func freeAddr(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+func newBridgeServer(t *testing.T, addr string, cfg BridgeConfig) *httptest.Server {
+	bridge := NewGoodsBridge(addr, cfg)
+	t.Cleanup(func() { bridge.Close() })
+	mux := http.NewServeMux()
+	bridge.Register(mux)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestBridgeWaitsForLateGRPCServer(t *testing.T) {
+	addr := freeAddr(t)
+	srv := newBridgeServer(t, addr, BridgeConfig{
+		DialTimeout: 3 * time.Second, CallTimeout: 5 * time.Second, MaxRetries: 3, RetryBackoff: 50 * time.Millisecond,
+	})
+
+	// gRPC 服务在 REST 请求发出后 300ms 才启动
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		s := grpc.NewServer()
+		pb.RegisterGoodsServiceServer(s, NewGoodsService())
+		t.Cleanup(s.Stop)
+		s.Serve(ln)
+	}()
+
+	start := time.Now()
+	resp, err := http.Get(srv.URL + "/api/hello?name=bridge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	var body struct{ Message string }
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Message != "Hello, bridge" {
+		t.Fatalf("message = %q", body.Message)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("bridge took %v, want within dial window", elapsed)
+	}
+
+	resp, err = http.Get(srv.URL + "/api/goods?limit=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var goods []*pb.GoodsReply
+	if err := json.NewDecoder(resp.Body).Decode(&goods); err != nil {
+		t.Fatal(err)
+	}
+	if len(goods) != 2 {
+		t.Fatalf("got %d goods, want 2", len(goods))
+	}
+}
+
+func TestBridgeReturns503WhenBackendDown(t *testing.T) {
+	srv := newBridgeServer(t, freeAddr(t), BridgeConfig{
+		DialTimeout: 200 * time.Millisecond, CallTimeout: time.Second, MaxRetries: 1, RetryBackoff: 10 * time.Millisecond,
+	})
+
+	start := time.Now()
+	resp, err := http.Get(srv.URL + "/api/hello?name=x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", resp.StatusCode)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("request hung for %v", elapsed)
+	}
+}
+
+func TestRetryUnavailable(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		errs  []error
+		calls int
+		want  codes.Code
+	}{
+		{"recovers", []error{status.Error(codes.Unavailable, "down"), status.Error(codes.Unavailable, "down"), nil}, 3, codes.OK},
+		{"gives up", []error{status.Error(codes.Unavailable, "down")}, 3, codes.Unavailable},
+		{"other code not retried", []error{status.Error(codes.NotFound, "x")}, 1, codes.NotFound},
+	} {
+		calls := 0
+		invoker := func(context.Context, string, any, any, *grpc.ClientConn, ...grpc.CallOption) error {
+			err := tt.errs[min(calls, len(tt.errs)-1)]
+			calls++
+			return err
+		}
+		err := retryUnavailable(2, time.Millisecond)(context.Background(), "/m", nil, nil, nil, invoker)
+		if status.Code(err) != tt.want || calls != tt.calls {
+			t.Errorf("%s: code = %v, calls = %d; want %v, %d", tt.name, status.Code(err), calls, tt.want, tt.calls)
+		}
+	}
+}
@@ -2,44 +2,111 @@ package main
 
 import (
 	"context"
-	"google.golang.org/grpc"
+	"flag"
 	"log"
 	"net"
 	"net/http"
 	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+
 	"test/pb"
 )
 
-// gRPC Service Definition
-type GoodsService struct{}
+// GoodsService gRPC 商品服务，消息定义见 proto/goods/goods.proto
+type GoodsService struct {
+	pb.UnimplementedGoodsServiceServer
+	goods []*pb.GoodsReply
+}
 
-var GoodsServices = GoodsService{}
+// NewGoodsService 返回带演示商品数据的服务
+func NewGoodsService() *GoodsService {
+	return &GoodsService{goods: []*pb.GoodsReply{
+		{Id: 1, Name: "iPhone 15", Price: 599900},
+		{Id: 2, Name: "AirPods Pro", Price: 189900},
+		{Id: 3, Name: "MacBook Air", Price: 899900},
+		{Id: 4, Name: "Apple Watch", Price: 299900},
+	}}
+}
 
-func (s *GoodsService) SayHello(ctx context.Context, req *HelloRequest) (*HelloResponse, error) {
-	return &HelloResponse{Message: "Hello, " + req.Name}, nil
+func (s *GoodsService) SayHello(ctx context.Context, req *pb.HelloRequest) (*pb.HelloResponse, error) {
+	return &pb.HelloResponse{Message: "Hello, " + req.Name}, nil
 }
 
-// gRPC Protobuf Definitions (normally in a .proto file)
-type HelloRequest struct {
-	Name string
+// ListGoods 逐个流式返回商品，客户端取消时提前结束
+func (s *GoodsService) ListGoods(req *pb.ListGoodsRequest, stream grpc.ServerStreamingServer[pb.GoodsReply]) error {
+	goods := s.goods
+	if req.Limit > 0 && int(req.Limit) < len(goods) {
+		goods = goods[:req.Limit]
+	}
+	for _, g := range goods {
+		if err := stream.Context().Err(); err != nil {
+			return status.FromContextError(err).Err()
+		}
+		if err := stream.Send(g); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-type HelloResponse struct {
-	Message string
+// localAddr 把 ":3500" 这类监听地址转成本机可拨号的地址
+func localAddr(listen string) string {
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil || host != "" {
+		return listen
+	}
+	return net.JoinHostPort("localhost", port)
 }
 
 func main() {
+	// 设置 -single-port 后 HTTP 和 gRPC 共用一个端口，便于放在同一个负载均衡后面
+	singlePort := flag.String("single-port", "", "serve HTTP and gRPC on this address (e.g. :3500) instead of :3500 and :3501")
+	flag.Parse()
+
+	// HTTP 和 gRPC 共用一份计数，在 HTTP 的 /metrics 上输出
+	metrics := NewMetrics()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Hello, HTTP!"))
+	})
+	mux.Handle("/metrics", metrics)
+
+	// REST 转 gRPC：/api/* 通过内部 gRPC 客户端调用本进程的 GoodsService
+	grpcAddr := "localhost:3501"
+	if *singlePort != "" {
+		grpcAddr = localAddr(*singlePort)
+	}
+	bridge := NewGoodsBridge(grpcAddr, defaultBridgeConfig)
+	defer bridge.Close()
+	bridge.Register(mux)
+	httpHandler := metrics.HTTPMiddleware(mux)
+
+	grpcServer := grpc.NewServer(
+		grpc.UnaryInterceptor(metrics.UnaryServerInterceptor()),
+		grpc.StreamInterceptor(metrics.StreamServerInterceptor()),
+	)
+	pb.RegisterGoodsServiceServer(grpcServer, NewGoodsService())
+
+	if *singlePort != "" {
+		ln, err := net.Listen("tcp", *singlePort)
+		if err != nil {
+			log.Fatalf("Failed to listen on %s: %v", *singlePort, err)
+		}
+		log.Printf("Starting HTTP and gRPC server on %s", *singlePort)
+		log.Fatal(serveSinglePort(ln, grpcServer, httpHandler))
+	}
+
 	var wg sync.WaitGroup
 	wg.Add(2)
 
 	// Start HTTP server
 	go func() {
 		defer wg.Done()
-		http.HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) {
-			w.Write([]byte("Hello, HTTP!"))
-		})
 		log.Println("Starting HTTP server on :3500")
-		if err := http.ListenAndServe(":3500", nil); err != nil {
+		if err := http.ListenAndServe(":3500", httpHandler); err != nil {
 			log.Fatalf("Failed to start HTTP server: %v", err)
 		}
 	}()
@@ -47,10 +114,7 @@ func main() {
 	// Start gRPC server
 	go func() {
 		defer wg.Done()
-		s := grpc.NewServer()
 		listener, err := net.Listen("tcp", ":3501")
-		pb.RegisterGoodsServiceServer(s, GoodsServices)
-
 		if err != nil {
 			log.Fatalf("Failed to listen on port 3501: %v", err)
 		}
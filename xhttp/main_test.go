@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	"test/pb"
+)
+
+func newGoodsClient(t *testing.T) pb.GoodsServiceClient {
+	ln := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	pb.RegisterGoodsServiceServer(s, NewGoodsService())
+	go s.Serve(ln)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewGoodsServiceClient(conn)
+}
+
+func TestListGoodsStream(t *testing.T) {
+	client := newGoodsClient(t)
+	for _, tt := range []struct {
+		limit int32
+		want  []string
+	}{
+		{0, []string{"iPhone 15", "AirPods Pro", "MacBook Air", "Apple Watch"}},
+		{2, []string{"iPhone 15", "AirPods Pro"}},
+	} {
+		stream, err := client.ListGoods(context.Background(), &pb.ListGoodsRequest{Limit: tt.limit})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for {
+			g, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if int(g.Id) != len(got)+1 {
+				t.Fatalf("goods %d has id %d", len(got), g.Id)
+			}
+			got = append(got, g.Name)
+		}
+		if len(got) != len(tt.want) {
+			t.Fatalf("limit %d: got %v, want %v", tt.limit, got, tt.want)
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Fatalf("limit %d: got %v, want %v", tt.limit, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestSayHello(t *testing.T) {
+	reply, err := newGoodsClient(t).SayHello(context.Background(), &pb.HelloRequest{Name: "grpc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Message != "Hello, grpc" {
+		t.Fatalf("reply = %q", reply.Message)
+	}
+}
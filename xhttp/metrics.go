@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// Metrics 进程内的请求计数，HTTP 按路由和状态码统计，gRPC 按方法和状态码统计，
+// 通过 /metrics 以 Prometheus 文本格式输出
+type Metrics struct {
+	mu   sync.Mutex
+	http map[httpKey]int64
+	grpc map[grpcKey]int64
+}
+
+type httpKey struct {
+	path   string
+	status int
+}
+
+type grpcKey struct {
+	method string
+	code   string
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{http: make(map[httpKey]int64), grpc: make(map[grpcKey]int64)}
+}
+
+// statusWriter 记录 handler 写出的状态码
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// HTTPMiddleware 统计经过 next 的 HTTP 请求。
+// 路径取 ServeMux 匹配到的路由模式，未匹配的请求统一记为 "unmatched"，避免标签无限增长
+func (m *Metrics) HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		path := r.Pattern
+		if path == "" {
+			path = "unmatched"
+		}
+		m.mu.Lock()
+		m.http[httpKey{path, sw.status}]++
+		m.mu.Unlock()
+	})
+}
+
+func (m *Metrics) observeGRPC(method string, err error) {
+	code := status.Code(err).String()
+	m.mu.Lock()
+	m.grpc[grpcKey{method, code}]++
+	m.mu.Unlock()
+}
+
+// UnaryServerInterceptor 统计一元 gRPC 调用
+func (m *Metrics) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		resp, err := handler(ctx, req)
+		m.observeGRPC(info.FullMethod, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor 统计流式 gRPC 调用，流结束时计数
+func (m *Metrics) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		err := handler(srv, ss)
+		m.observeGRPC(info.FullMethod, err)
+		return err
+	}
+}
+
+// ServeHTTP 输出所有计数，按标签排序保证输出稳定
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	var httpLines, grpcLines []string
+	for k, v := range m.http {
+		httpLines = append(httpLines, fmt.Sprintf("http_requests_total{path=%q,status=\"%d\"} %d", k.path, k.status, v))
+	}
+	for k, v := range m.grpc {
+		grpcLines = append(grpcLines, fmt.Sprintf("grpc_server_handled_total{method=%q,code=%q} %d", k.method, k.code, v))
+	}
+	m.mu.Unlock()
+	sort.Strings(httpLines)
+	sort.Strings(grpcLines)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	var b strings.Builder
+	b.WriteString("# TYPE http_requests_total counter\n")
+	for _, l := range httpLines {
+		b.WriteString(l + "\n")
+	}
+	b.WriteString("# TYPE grpc_server_handled_total counter\n")
+	for _, l := range grpcLines {
+		b.WriteString(l + "\n")
+	}
+	w.Write([]byte(b.String()))
+}
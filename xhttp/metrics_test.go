@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestMetricsCountsHTTPAndGRPC(t *testing.T) {
+	m := NewMetrics()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("Hello, HTTP!")) })
+	mux.Handle("/metrics", m)
+	handler := m.HTTPMiddleware(mux)
+	for _, path := range []string{"/hello", "/hello", "/nope"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	// 用 health 服务走一遍真实的 gRPC 调用链路
+	ln := bufconn.Listen(1 << 20)
+	s := grpc.NewServer(grpc.UnaryInterceptor(m.UnaryServerInterceptor()))
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	go s.Serve(ln)
+	defer s.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+	if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "unknown"}) // NotFound
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	for _, want := range []string{
+		`http_requests_total{path="/hello",status="200"} 2`,
+		`http_requests_total{path="unmatched",status="404"} 1`,
+		`grpc_server_handled_total{method="/grpc.health.v1.Health/Check",code="OK"} 1`,
+		`grpc_server_handled_total{method="/grpc.health.v1.Health/Check",code="NotFound"} 1`,
+	} {
+		if !strings.Contains(string(body), want+"\n") {
+			t.Errorf("metrics output missing %q:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+)
+
+// mixedHandler 在同一个端口上按请求分流：HTTP/2 且 Content-Type 为 application/grpc 的交给 gRPC，
+// 其余交给 HTTP。明文端口上 gRPC 客户端直接以 HTTP/2 连接（prior knowledge），由 h2c 处理
+func mixedHandler(grpcServer *grpc.Server, httpHandler http.Handler) http.Handler {
+	return h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			grpcServer.ServeHTTP(w, r)
+			return
+		}
+		httpHandler.ServeHTTP(w, r)
+	}), &http2.Server{})
+}
+
+// serveSinglePort 在 ln 上同时提供 gRPC 和 HTTP。
+// 走的是 grpc.Server.ServeHTTP，比 grpc.Server.Serve 少一些性能优化，换来单端口部署
+func serveSinglePort(ln net.Listener, grpcServer *grpc.Server, httpHandler http.Handler) error {
+	return (&http.Server{Handler: mixedHandler(grpcServer, httpHandler)}).Serve(ln)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"test/pb"
+)
+
+func TestSinglePortServesHTTPAndGRPC(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcServer := grpc.NewServer()
+	pb.RegisterGoodsServiceServer(grpcServer, NewGoodsService())
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("Hello, HTTP!")) })
+	srv := &http.Server{Handler: mixedHandler(grpcServer, mux)}
+	go srv.Serve(ln)
+	defer srv.Close()
+	addr := ln.Addr().String()
+
+	resp, err := http.Get("http://" + addr + "/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "Hello, HTTP!" {
+		t.Fatalf("GET /hello = %d %q", resp.StatusCode, body)
+	}
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	reply, err := pb.NewGoodsServiceClient(conn).SayHello(context.Background(), &pb.HelloRequest{Name: "grpc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Message != "Hello, grpc" {
+		t.Fatalf("SayHello = %q", reply.Message)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// dayLayout 日期后缀格式
+const dayLayout = "2006-01-02"
+
+// DailyRotatingWriter 在 lumberjack 按大小滚动的基础上，每过本地零点再强制滚动一次：
+// 把当前文件重命名为 <name>-<日期>.log（日期是文件内容所属的那一天），之后的日志写入新文件。
+// 可被多个 goroutine 并发写入。
+// 注意按日期命名的备份不受 lumberjack 的 MaxBackups/MaxAge 清理。
+type DailyRotatingWriter struct {
+	rotator *lumberjack.Logger
+	now     func() time.Time // 测试时替换时钟
+
+	mu  sync.Mutex
+	day string // 当前文件内容所属日期
+}
+
+func NewDailyRotatingWriter(rotator *lumberjack.Logger) *DailyRotatingWriter {
+	return newDailyRotatingWriter(rotator, time.Now)
+}
+
+func newDailyRotatingWriter(rotator *lumberjack.Logger, now func() time.Time) *DailyRotatingWriter {
+	w := &DailyRotatingWriter{rotator: rotator, now: now}
+	// 文件已存在时以最后修改时间为准，进程跨零点重启后也能把昨天的日志滚走
+	if info, err := os.Stat(rotator.Filename); err == nil {
+		w.day = info.ModTime().In(now().Location()).Format(dayLayout)
+	}
+	return w
+}
+
+func (w *DailyRotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	today := w.now().Format(dayLayout)
+	if w.day != "" && w.day != today {
+		if err := w.rollover(w.day); err != nil {
+			return 0, err
+		}
+	}
+	w.day = today
+	return w.rotator.Write(p)
+}
+
+// rollover 关闭当前文件并加上日期后缀，lumberjack 下次写入时会重新创建原文件
+func (w *DailyRotatingWriter) rollover(day string) error {
+	if err := w.rotator.Close(); err != nil {
+		return fmt.Errorf("close log file: %w", err)
+	}
+	if _, err := os.Stat(w.rotator.Filename); os.IsNotExist(err) {
+		return nil // 当天还没写过内容
+	}
+	ext := filepath.Ext(w.rotator.Filename)
+	prefix := strings.TrimSuffix(w.rotator.Filename, ext) + "-" + day
+	name := prefix + ext
+	// 同一天的备份已存在（例如手动改过系统时间）时加序号，不覆盖
+	for i := 1; ; i++ {
+		if _, err := os.Stat(name); os.IsNotExist(err) {
+			break
+		}
+		name = fmt.Sprintf("%s.%d%s", prefix, i, ext)
+	}
+	if err := os.Rename(w.rotator.Filename, name); err != nil {
+		return fmt.Errorf("rotate log file: %w", err)
+	}
+	return nil
+}
+
+// Sync lumberjack 直接写文件没有缓冲，无需刷新
+func (w *DailyRotatingWriter) Sync() error { return nil }
+
+func (w *DailyRotatingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.rotator.Close()
+}
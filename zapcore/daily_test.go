@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+func TestDailyRotatingWriterCrossesMidnight(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "app.log")
+	rotator := &lumberjack.Logger{Filename: filename, MaxSize: 1}
+
+	// 时钟从 23:59:59 开始，前 50 次写入后跨过零点
+	start := time.Date(2024, 3, 9, 23, 59, 59, 0, time.Local)
+	var calls atomic.Int64
+	clock := func() time.Time {
+		if calls.Add(1) > 50 {
+			return start.Add(2 * time.Second)
+		}
+		return start
+	}
+	w := newDailyRotatingWriter(rotator, clock)
+	defer w.Close()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				if _, err := w.Write([]byte("line\n")); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	backup, err := os.ReadFile(filepath.Join(dir, "app-2024-03-09.log"))
+	if err != nil {
+		t.Fatalf("dated backup missing: %v", err)
+	}
+	current, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(string(backup), "\n"); got != 50 {
+		t.Errorf("backup has %d lines, want 50", got)
+	}
+	if got := strings.Count(string(current), "\n"); got != 50 {
+		t.Errorf("current file has %d lines, want 50", got)
+	}
+}
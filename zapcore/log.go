@@ -2,6 +2,10 @@ package main
 
 import (
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"log"
+	"net/http"
+	"os"
 	"test/common"
 	"time"
 )
@@ -33,5 +37,31 @@ func main1() {
 		)
 	}
 
+	// 通用配置：与 log/main.go 共用 common 包的编码、caller 和堆栈配置
+	{
+		logger := common.NewCommonLogger(zapcore.AddSync(os.Stdout), zapcore.InfoLevel, zap.String("extra_key", "demo"))
+		defer logger.Sync()
+
+		logger.Error("common config failed to fetch URL", zap.String("url", "https://jianghushinian.cn/"))
+	}
+
+	// 运行时修改级别：GET/PUT http://127.0.0.1:8081/loglevel，也可以直接调用 level.SetLevel
+	{
+		logger, level := common.NewLogger(common.LoggerConfig{Level: zapcore.InfoLevel})
+		defer logger.Sync()
+
+		mux := http.NewServeMux()
+		common.LevelHandler(mux, level)
+		go func() {
+			if err := http.ListenAndServe("127.0.0.1:8081", mux); err != nil {
+				log.Printf("loglevel server: %v", err)
+			}
+		}()
+
+		logger.Debug("suppressed at info level")
+		level.SetLevel(zapcore.DebugLevel)
+		logger.Debug("emitted after switching to debug")
+	}
+
 	common.LogOut()
 }
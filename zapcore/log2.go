@@ -4,12 +4,20 @@ import (
 	"fmt"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+	"io"
+	"log"
 	"os"
-	"time"
+	"path/filepath"
 )
 
 func main() {
-	logger := getLogger()
+	cfg := DefaultLoggerConfig()
+	cfg.Daily = true
+	logger, err := NewFileLogger(cfg)
+	if err != nil {
+		log.Fatal("create logger failed: ", err)
+	}
 	defer logger.Sync()
 
 	logger.Info("This is an info log")
@@ -17,24 +25,60 @@ func main() {
 	logger.Error("This is an error log")
 }
 
-// 创建 logger 并设置输出到文件
-func getLogger() *zap.Logger {
-	// 获取当前工作目录
-	currentDir, err := os.Getwd()
-	if err != nil {
-		fmt.Println("Error getting current directory:", err)
+// LoggerConfig 文件日志配置
+type LoggerConfig struct {
+	Dir        string // 日志目录，不存在时自动创建
+	Filename   string // 日志文件名，滚动后的备份为 <name>-<时间戳>.log
+	MaxSize    int    // 单个日志文件最大大小（单位：MB）
+	MaxBackups int    // 保留的旧日志文件个数
+	MaxAge     int    // 日志文件最多保存天数
+	Compress   bool   // 是否压缩旧的日志文件
+	Daily      bool   // 每天零点额外滚动一次，备份为 <name>-<日期>.log
+}
+
+// DefaultLoggerConfig 默认写入 ./logs/app.log
+func DefaultLoggerConfig() LoggerConfig {
+	return LoggerConfig{
+		Dir:        "./logs",
+		Filename:   "app.log",
+		MaxSize:    100,
+		MaxBackups: 7,
+		MaxAge:     30,
+		Compress:   true,
 	}
+}
 
-	// 输出当前工作目录
-	fmt.Println("Current Directory:", currentDir)
-	// 手动按日分割日志
-	fileName := "./logs/" + time.Now().Format("2006-01-02") + "-app.log"
-	file, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0777) //参数3只要在linux系统下才会生效
+// NewFileLogger 创建 logger 并设置输出到文件
+// 由 lumberjack 按大小和保存天数自动滚动，进程长期运行也不会写爆单个文件。
+// 目录不存在时自动创建；目录或文件不可写时返回错误，而不是 panic。
+func NewFileLogger(cfg LoggerConfig) (*zap.Logger, error) {
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("create log dir %s: %w", cfg.Dir, err)
+	}
 
+	// lumberjack 首次写入时才打开文件，这里提前打开一次，让权限等问题在构造时暴露
+	filename := filepath.Join(cfg.Dir, cfg.Filename)
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("open log file %s: %w", filename, err)
+	}
+	file.Close()
+
+	rotator := &lumberjack.Logger{
+		Filename:   filename,
+		MaxSize:    cfg.MaxSize,
+		MaxBackups: cfg.MaxBackups,
+		MaxAge:     cfg.MaxAge,
+		Compress:   cfg.Compress,
 	}
+	if cfg.Daily {
+		return newFileLogger(NewDailyRotatingWriter(rotator)), nil
+	}
+	return newFileLogger(rotator), nil
+}
 
+// newFileLogger 创建写入 rotator（lumberjack 或 DailyRotatingWriter）的 JSON logger
+func newFileLogger(rotator io.Writer) *zap.Logger {
 	// 创建日志的编码器配置
 	encoderConfig := zapcore.EncoderConfig{
 		TimeKey:        "time",
@@ -52,12 +96,11 @@ func getLogger() *zap.Logger {
 
 	// 创建核心，设置日志级别为 Info
 	core := zapcore.NewCore(
-		zapcore.NewJSONEncoder(encoderConfig),              // JSON 格式化日志
-		zapcore.NewMultiWriteSyncer(zapcore.AddSync(file)), // 输出到文件
-		zap.InfoLevel, // 日志级别
+		zapcore.NewJSONEncoder(encoderConfig), // JSON 格式化日志
+		zapcore.AddSync(rotator),              // 输出到滚动文件
+		zap.InfoLevel,                         // 日志级别
 	)
 
 	// 创建 logger
 	return zap.New(core, zap.AddCaller())
-
 }
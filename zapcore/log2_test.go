@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+func TestFileLoggerRotates(t *testing.T) {
+	dir := t.TempDir()
+	rotator := &lumberjack.Logger{
+		Filename:   filepath.Join(dir, "app.log"),
+		MaxSize:    1, // 1MB 即滚动
+		MaxBackups: 3,
+	}
+	defer rotator.Close()
+
+	logger := newFileLogger(rotator)
+	line := strings.Repeat("x", 1024)
+	for i := 0; i < 1500; i++ { // 约 1.5MB
+		logger.Info(line)
+	}
+	logger.Sync()
+
+	backups, err := filepath.Glob(filepath.Join(dir, "app-*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) == 0 {
+		t.Fatal("expected a rotated backup file")
+	}
+}
+
+func TestNewFileLoggerCreatesMissingDir(t *testing.T) {
+	cfg := DefaultLoggerConfig()
+	cfg.Dir = filepath.Join(t.TempDir(), "nested", "logs")
+
+	logger, err := NewFileLogger(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("hello")
+	logger.Sync()
+
+	data, err := os.ReadFile(filepath.Join(cfg.Dir, cfg.Filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"msg":"hello"`) {
+		t.Fatalf("log file content = %q", data)
+	}
+}
+
+func TestNewFileLoggerUnwritablePath(t *testing.T) {
+	// 用普通文件占住目录位置，MkdirAll 必然失败（不依赖文件权限，root 下同样有效）
+	blocker := filepath.Join(t.TempDir(), "not-a-dir")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := DefaultLoggerConfig()
+	cfg.Dir = filepath.Join(blocker, "logs")
+	if _, err := NewFileLogger(cfg); err == nil {
+		t.Fatal("expected error for unwritable path")
+	}
+}